@@ -105,6 +105,109 @@ export function decodeBase64(base64: string) {
 	return bytes.buffer;
 }
 
+/**
+ * Works out what to sign for a CHALLENGE. Servers that bind signatures to an
+ * audience send an object with the challenge and their audience, and expect
+ * the challenge followed by the audience to be signed
+ * @param data The data of the CHALLENGE message
+ * @param audience The audience we believe we are connected to
+ * @returns The bytes to sign
+ */
+function challengePayload(data: any, audience: string): ArrayBuffer {
+	if (typeof data === "string") {
+		return decodeBase64(data);
+	}
+
+	// Sign for the audience we know we're connected to, rather than the one the
+	// server claims, so that our signature is of no use to anyone else
+	if (data.audience !== audience) {
+		throw new Error(
+			`Server asked us to sign for ${data.audience}, but we are connected to ${audience}`
+		);
+	}
+
+	const challenge = new Uint8Array(decodeBase64(data.challenge));
+	const encodedAudience = new TextEncoder().encode(audience);
+	const payload = new Uint8Array(challenge.length + encodedAudience.length);
+	payload.set(challenge);
+	payload.set(encodedAudience, challenge.length);
+	return payload.buffer;
+}
+
+/**
+ * The parts of a WebAuthn assertion that a server needs to verify it
+ */
+export type Assertion = {
+	signature: ArrayBuffer;
+	authenticatorData: ArrayBuffer;
+	clientDataJSON: ArrayBuffer;
+};
+
+function challengeResponse(signed: ArrayBuffer | Assertion) {
+	if (signed instanceof ArrayBuffer) {
+		return { signature: encodeBase64(signed), hash: "SHA-256" };
+	}
+	return {
+		signature: encodeBase64(signed.signature),
+		hash: "SHA-256",
+		authenticatorData: encodeBase64(signed.authenticatorData),
+		clientDataJSON: encodeBase64(signed.clientDataJSON),
+	};
+}
+
+/**
+ * Derives the client ID of a passkey, from the public key that
+ * AuthenticatorAttestationResponse.getPublicKey returned when it was created
+ * @param spki The passkey's public key, in SPKI form. Only P-256 keys (COSE
+ *   algorithm -7) are supported
+ * @returns The client ID to connect with
+ */
+export function passkeyClientId(spki: ArrayBuffer) {
+	// a P-256 SPKI is a fixed header, followed by the uncompressed point
+	if (spki.byteLength !== 91) {
+		throw new Error("Expected a P-256 public key");
+	}
+	return `WebAuthn-raw.EC.P-256$${encodeBase64(spki.slice(-65))}`;
+}
+
+/**
+ * Creates a sign function for connect that has a passkey sign challenges
+ * @param credentialId The ID of the passkey's credential
+ * @param options.rpId The relying party ID the passkey was created for, if
+ *   not the current domain
+ * @param options.userVerification Whether to have the authenticator verify
+ *   the user
+ * @returns A sign function to pass to connect
+ */
+export function passkeySigner(
+	credentialId: BufferSource,
+	options: {
+		rpId?: string;
+		userVerification?: UserVerificationRequirement;
+	} = {}
+): (challenge: ArrayBuffer) => Promise<Assertion> {
+	return async (challenge) => {
+		const credential = (await navigator.credentials.get({
+			publicKey: {
+				challenge,
+				rpId: options.rpId,
+				allowCredentials: [{ type: "public-key", id: credentialId }],
+				userVerification: options.userVerification,
+			},
+		})) as PublicKeyCredential | null;
+		if (!credential) {
+			throw new Error("No passkey was selected");
+		}
+
+		const response = credential.response as AuthenticatorAssertionResponse;
+		return {
+			signature: response.signature,
+			authenticatorData: response.authenticatorData,
+			clientDataJSON: response.clientDataJSON,
+		};
+	};
+}
+
 function onOpen(ws: WebSocket): Promise<void> {
 	return new Promise((resolve) => {
 		if (ws.readyState === WebSocket.OPEN) {
@@ -120,17 +223,26 @@ function onOpen(ws: WebSocket): Promise<void> {
  * Connects to a WebSocket server, and performs the initial handshake
  * @param ws The WebSocket instance that will perform the initial handshake
  * @param keyPair The keypair to form the handshake with
+ * @param sign Signs the challenge, returning either the signature, or, for
+ *   passkeys, the whole assertion (see passkeySigner)
  * @param onMessage An optional on-message listener, for the purposes of
  *   debugging
+ * @param options.audience The audience to sign challenges for, for servers
+ *   that bind signatures to an audience. Defaults to the scheme and host of
+ *   the WebSocket's URL, such as "wss://example.com"
  * @returns A promise that resolves when the handshake is complete
  */
 export async function connect(
 	ws: WebSocket,
 	id: string,
-	sign: (data: ArrayBuffer) => Promise<ArrayBuffer>
+	sign: (data: ArrayBuffer) => Promise<ArrayBuffer | Assertion>,
+	options: { audience?: string } = {}
 ): Promise<void> {
 	await onOpen(ws);
 
+	const url = new URL(ws.url);
+	const audience = options.audience ?? `${url.protocol}//${url.host}`;
+
 	let currentState: "CONNECTING" | "SENT_CHALLENGE" = "CONNECTING";
 
 	const promise = new Promise<void>((resolve, reject) => {
@@ -153,15 +265,19 @@ export async function connect(
 					switch (data.type) {
 						case "CHALLENGE":
 							// This is where we sign the challenge payload, and send it off
-							sign(decodeBase64(data.data))
-								.then((signature) => {
+							let payload: ArrayBuffer;
+							try {
+								payload = challengePayload(data.data, audience);
+							} catch (e) {
+								close(e);
+								break;
+							}
+							sign(payload)
+								.then((signed) => {
 									ws.send(
 										JSON.stringify({
 											type: "CHALLENGE_RESPONSE",
-											data: {
-												signature: encodeBase64(signature),
-												hash: "SHA-256",
-											},
+											data: challengeResponse(signed),
 										})
 									);
 									console.log("Sent challenge response");
@@ -171,6 +287,9 @@ export async function connect(
 									close(e);
 								});
 							break;
+						case "TIMEOUT":
+							close(new Error("Handshake timed out"));
+							break;
 						default:
 							// This is where we got a message we didn't expect
 							close(
@@ -191,6 +310,9 @@ export async function connect(
 						case "SIGNATURE_MISMATCH":
 							close(new Error("Signature mismatch"));
 							break;
+						case "TIMEOUT":
+							close(new Error("Handshake timed out"));
+							break;
 						default:
 							close(new Error("Unexpected message"));
 							break;
@@ -0,0 +1,369 @@
+// Code generated by go run ./internal/tsgen; DO NOT EDIT.
+//
+// The types of the handshake's messages, generated from the Go structs the
+// server encodes and decodes them with, and a minimal client built on them.
+// Run go generate in the go directory to update it.
+
+/**
+ * Generated from the Go struct TypeData.
+ */
+export interface TypeData {
+	type: string;
+	data: unknown;
+}
+
+/**
+ * envelope is a TypeData, along with the members clients may send next to
+ * "data".
+ *
+ * Generated from the Go struct envelope.
+ */
+export interface ClientMessage {
+	type: string;
+	data?: unknown;
+	challenge?: string;
+	timestamp?: SignedTimestamp;
+	response?: ChallengeResponse;
+}
+
+/**
+ * Generated from the Go struct challengeResponse.
+ */
+export interface ChallengeResponse {
+	signature?: string;
+	hash?: string;
+	authenticatorData?: string;
+	clientDataJSON?: string;
+	ephemeral?: string;
+	idToken?: string;
+	proofOfWork?: string;
+	challenge?: string;
+}
+
+/**
+ * Generated from the Go struct typeMessage.
+ */
+export interface TypeMessage {
+	type: string;
+	retry?: boolean;
+}
+
+/**
+ * Generated from the Go struct stringMessage.
+ */
+export interface StringMessage {
+	type: string;
+	data: string;
+	retry?: boolean;
+}
+
+/**
+ * Generated from the Go struct errorMessage.
+ */
+export interface ErrorMessage {
+	type: string;
+	data: ErrorData;
+	retry?: boolean;
+}
+
+/**
+ * Generated from the Go struct challengeMessage.
+ */
+export interface ChallengeMessage {
+	type: string;
+	data: string;
+	capabilities: Capabilities | null;
+	server?: ServerProof;
+	proofOfWork?: ProofOfWorkData;
+}
+
+/**
+ * Generated from the Go struct audienceChallengeMessage.
+ */
+export interface AudienceChallengeMessage {
+	type: string;
+	data: ChallengeData;
+	capabilities: Capabilities | null;
+	server?: ServerProof;
+	proofOfWork?: ProofOfWorkData;
+}
+
+/**
+ * Generated from the Go struct matchesMessage.
+ */
+export interface MatchesMessage {
+	type: string;
+	data?: string;
+	token?: AccessToken;
+	macaroon?: string;
+	turn?: TURNCredentials;
+	guest?: boolean;
+	scopes?: string[];
+}
+
+/**
+ * SignedTimestamp is a timestamp signed by the client, sent alongside its
+ * CLIENT_ID.
+ *
+ * Generated from the Go struct SignedTimestamp.
+ */
+export interface SignedTimestamp {
+	/**
+	 * Time is when the client signed it, in milliseconds since the Unix
+	 * epoch.
+	 */
+	time: number;
+	signature: string;
+}
+
+/**
+ * Generated from the Go struct errorData.
+ */
+export interface ErrorData {
+	code?: string;
+	message: string;
+	error?: string;
+}
+
+/**
+ * Capabilities is what a server advertises alongside its CHALLENGE, in a
+ * "capabilities" member next to "data":
+ *
+ * 	{
+ * 		"type": "CHALLENGE",
+ * 		"data": "<base64 challenge>",
+ * 		"capabilities": {
+ * 			"hashes": ["SHA-256"],
+ * 			"curves": ["P-256", "Ed25519"],
+ * 			"extensions": ["audience"]
+ * 		}
+ * 	}
+ *
+ * Clients that predate it ignore it. Newer ones can use it to pick from what
+ * the server supports, and to only use protocol extensions the server
+ * understands. Hashes and curves are narrowed by WithAlgorithmPolicy.
+ *
+ * Generated from the Go struct Capabilities.
+ */
+export interface Capabilities {
+	/**
+	 * Hashes are the hashes signatures may be made with.
+	 */
+	hashes: string[];
+	/**
+	 * Curves are the named curves client IDs may use.
+	 */
+	curves: string[];
+	/**
+	 * MinRSABits is the smallest RSA key the certificates of X.509 client
+	 * IDs may have, if the server's AlgorithmPolicy says.
+	 */
+	minRSABits?: number;
+	/**
+	 * Extensions are the optional protocol features in use, such as
+	 * "audience" with WithAudience, "webauthn" with WithWebAuthn,
+	 * "server-key" with WithServerKey, "hmac" with a SecretStore, "srp" with
+	 * WithPasswords, "totp" with WithTOTP, "oidc" with WithOIDC, and any
+	 * given with WithExtensions.
+	 */
+	extensions?: string[];
+}
+
+/**
+ * serverProof is the "server" member of a CHALLENGE.
+ *
+ * Generated from the Go struct serverProof.
+ */
+export interface ServerProof {
+	id: string;
+	signature: string;
+}
+
+/**
+ * Generated from the Go struct proofOfWorkData.
+ */
+export interface ProofOfWorkData {
+	difficulty: number;
+}
+
+/**
+ * Generated from the Go struct challengeData.
+ */
+export interface ChallengeData {
+	challenge: string;
+	audience: string;
+}
+
+/**
+ * AccessToken is the token the authorization server issued for a client.
+ *
+ * Generated from the Go struct AccessToken.
+ */
+export interface AccessToken {
+	access_token: string;
+	issued_token_type?: string;
+	token_type: string;
+	expires_in?: number;
+	scope?: string;
+}
+
+/**
+ * TURNCredentials are credentials for TURN servers.
+ *
+ * Generated from the Go struct TURNCredentials.
+ */
+export interface TURNCredentials {
+	username: string;
+	password: string;
+	ttl: number;
+	uris?: string[];
+}
+
+/**
+ * Any message a server sends during the handshake
+ */
+export type ServerMessage =
+	| TypeMessage
+	| StringMessage
+	| ErrorMessage
+	| ChallengeMessage
+	| AudienceChallengeMessage
+	| MatchesMessage;
+
+/**
+ * A minimal client of the handshake, over a WebSocket that is open, or
+ * opening. Messages are queued as they arrive, so none are missed between
+ * calls to next
+ */
+export class Client {
+	private readonly queue: ServerMessage[] = [];
+	private readonly waiting: {
+		resolve: (message: ServerMessage) => void;
+		reject: (error: Error) => void;
+	}[] = [];
+	private closed = false;
+
+	constructor(readonly ws: WebSocket) {
+		ws.addEventListener("message", (event: MessageEvent) => {
+			const message = JSON.parse(event.data) as ServerMessage;
+			const waiter = this.waiting.shift();
+			if (waiter) {
+				waiter.resolve(message);
+			} else {
+				this.queue.push(message);
+			}
+		});
+		ws.addEventListener("close", () => {
+			this.closed = true;
+			for (const waiter of this.waiting.splice(0)) {
+				waiter.reject(new Error("Connection closed"));
+			}
+		});
+	}
+
+	/**
+	 * Sends a message to the server, once the WebSocket is open
+	 * @param message The message to send
+	 */
+	async send(message: ClientMessage): Promise<void> {
+		if (this.ws.readyState === WebSocket.CONNECTING) {
+			await new Promise((resolve) =>
+				this.ws.addEventListener("open", resolve, { once: true })
+			);
+		}
+		this.ws.send(JSON.stringify(message));
+	}
+
+	/**
+	 * Reads the next message from the server
+	 * @returns A promise of the message, rejected if the connection closes
+	 *   first
+	 */
+	next(): Promise<ServerMessage> {
+		const message = this.queue.shift();
+		if (message) {
+			return Promise.resolve(message);
+		}
+		if (this.closed) {
+			return Promise.reject(new Error("Connection closed"));
+		}
+		return new Promise((resolve, reject) => {
+			this.waiting.push({ resolve, reject });
+		});
+	}
+
+	/**
+	 * Performs the handshake
+	 * @param clientId The client ID to authenticate as
+	 * @param sign Signs the bytes the server asked us to, returning the data
+	 *   of the CHALLENGE_RESPONSE
+	 * @param options.audience The audience to sign challenges for, for servers
+	 *   that bind signatures to an audience. Defaults to the scheme and host of
+	 *   the WebSocket's URL
+	 * @returns The server's SIGNATURE_MATCHES
+	 */
+	async handshake(
+		clientId: string,
+		sign: (
+			payload: Uint8Array,
+			challenge: ChallengeMessage | AudienceChallengeMessage
+		) => Promise<ChallengeResponse>,
+		options: { audience?: string } = {}
+	): Promise<MatchesMessage> {
+		const url = new URL(this.ws.url);
+		const audience = options.audience ?? `${url.protocol}//${url.host}`;
+
+		await this.send({ type: "CLIENT_ID", data: clientId });
+
+		const challenge = (await this.expect("CHALLENGE")) as
+			| ChallengeMessage
+			| AudienceChallengeMessage;
+		const data = challenge.data;
+		let payload: Uint8Array;
+		if (typeof data === "string") {
+			payload = decode(data);
+		} else {
+			// sign for the audience we know we're connected to, rather than the
+			// one the server claims, so that our signature is of no use to
+			// anyone else
+			if (data.audience !== audience) {
+				throw new Error(
+					`Server asked us to sign for ${data.audience}, but we are connected to ${audience}`
+				);
+			}
+			const bytes = decode(data.challenge);
+			const encoded = new TextEncoder().encode(audience);
+			payload = new Uint8Array(bytes.length + encoded.length);
+			payload.set(bytes);
+			payload.set(encoded, bytes.length);
+		}
+
+		const response = await sign(payload, challenge);
+		await this.send({ type: "CHALLENGE_RESPONSE", data: response });
+
+		return (await this.expect("SIGNATURE_MATCHES")) as MatchesMessage;
+	}
+
+	/**
+	 * Reads the next message, failing unless it is of the type expected
+	 */
+	private async expect(type: string): Promise<ServerMessage> {
+		const message = await this.next();
+		if (message.type === type) {
+			return message;
+		}
+		this.ws.close();
+		const data = (message as { data?: unknown }).data;
+		const detail =
+			typeof data === "string" ? data : (data as ErrorData | undefined)?.message;
+		throw new Error(
+			detail
+				? `Expected ${type}, but got ${message.type}: ${detail}`
+				: `Expected ${type}, but got ${message.type}`
+		);
+	}
+}
+
+function decode(base64: string): Uint8Array {
+	return Uint8Array.from(atob(base64), (c) => c.charCodeAt(0));
+}
@@ -0,0 +1,191 @@
+// Package admin serves an HTTP API for operators to inspect the sessions of a
+// wskeyauth.Registry, and to revoke keys. It is meant to be embedded in a server's own mux, under
+// a prefix of its choosing, and behind an Authorizer:
+//
+//	http.Handle("/admin/", http.StripPrefix("/admin", &admin.Handler{
+//		Registry:    registry,
+//		Revocations: revocations,
+//		Authorizer:  admin.BearerToken(os.Getenv("ADMIN_TOKEN")),
+//	}))
+//
+// where revocations is the wskeyauth.RevocationList the server's handshakes
+// check, with WithRevocationList. The API has two resources:
+//
+//	GET  /sessions                           -> 200 {"sessions":[...]}
+//	GET  /sessions?clientId=<client ID>      -> 200 {"sessions":[...]}
+//	GET  /sessions?fingerprint=<fingerprint> -> 200 {"sessions":[...]}
+//	POST /revocations body: {"fingerprint":"<fingerprint>","reason":"..."} -> 200 {"fingerprint":"...","disconnected":1}
+//
+// Sessions are listed oldest first, as wskeyauth.SessionSnapshots. Posting a
+// revocation, which may name the key by "clientId" instead, adds it to the
+// revocation list and disconnects its sessions right away, on every server
+// of the cluster if the registry joined one.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// ErrUnauthorized is returned by authorizers for requests that don't carry
+// valid credentials.
+var ErrUnauthorized = errors.New("admin: unauthorized")
+
+// Authorizer decides whether a request may use the API.
+type Authorizer interface {
+	// Authorize returns nil if r may go ahead. The error isn't shown to the
+	// client, which is only told it is unauthorized.
+	Authorize(r *http.Request) error
+}
+
+// AuthorizerFunc is an Authorizer calling a function.
+type AuthorizerFunc func(r *http.Request) error
+
+func (f AuthorizerFunc) Authorize(r *http.Request) error {
+	return f(r)
+}
+
+// BearerToken authorizes requests with an "Authorization: Bearer <token>"
+// header. An empty token authorizes no request.
+func BearerToken(token string) Authorizer {
+	return AuthorizerFunc(func(r *http.Request) error {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return ErrUnauthorized
+		}
+		return nil
+	})
+}
+
+// Handler is the admin API, over the sessions of Registry, and the keys of
+// Revocations.
+type Handler struct {
+	Registry *wskeyauth.Registry
+
+	// Revocations is the revocation list keys are revoked on. Without one,
+	// POST /revocations fails with 501 Not Implemented.
+	Revocations wskeyauth.RevocationList
+
+	// Authorizer decides which requests may use the API. Without one, none
+	// may, so that the API can't be left open by mistake.
+	Authorizer Authorizer
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Authorizer == nil || h.Authorizer.Authorize(r) != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/sessions":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.listSessions(w, r)
+	case "/revocations":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.revoke(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) listSessions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	fingerprint := query.Get("fingerprint")
+	if clientID := query.Get("clientId"); clientID != "" {
+		var err error
+		if fingerprint, err = wskeyauth.Fingerprint(clientID); err != nil {
+			http.Error(w, "invalid client ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var sessions []wskeyauth.SessionSnapshot
+	if fingerprint != "" {
+		sessions = h.Registry.SnapshotByFingerprint(fingerprint)
+	} else {
+		sessions = h.Registry.Snapshot()
+	}
+	if sessions == nil {
+		sessions = []wskeyauth.SessionSnapshot{}
+	}
+	writeJSON(w, map[string]any{"sessions": sessions})
+}
+
+// Revoke adds the key with fingerprint to the revocation list, for reason,
+// and disconnects its sessions with wskeyauth.ForbiddenCloseCode. It returns
+// how many sessions of this server were disconnected.
+func (h *Handler) Revoke(ctx context.Context, fingerprint, reason string) (int, error) {
+	if h.Revocations == nil {
+		return 0, errNoRevocations
+	}
+	if err := h.Revocations.Revoke(ctx, fingerprint, reason); err != nil {
+		return 0, err
+	}
+
+	disconnected := len(h.Registry.GetByFingerprint(fingerprint))
+	err := h.Registry.DisconnectFingerprint(fingerprint, wskeyauth.ForbiddenCloseCode, "key was revoked")
+	if errors.Is(err, wskeyauth.ErrNotConnected) {
+		err = nil
+	}
+	return disconnected, err
+}
+
+var errNoRevocations = errors.New("admin: no revocation list")
+
+// maxRequestSize caps request bodies, which are tiny.
+const maxRequestSize = 16 << 10
+
+func (h *Handler) revoke(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ClientID    string `json:"clientId"`
+		Fingerprint string `json:"fingerprint"`
+		Reason      string `json:"reason"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+		http.Error(w, "request is not valid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.ClientID != "" {
+		fingerprint, err := wskeyauth.Fingerprint(req.ClientID)
+		if err != nil {
+			http.Error(w, "invalid client ID", http.StatusBadRequest)
+			return
+		}
+		req.Fingerprint = fingerprint
+	}
+	if req.Fingerprint == "" {
+		http.Error(w, "missing fingerprint or client ID", http.StatusBadRequest)
+		return
+	}
+
+	disconnected, err := h.Revoke(r.Context(), req.Fingerprint, req.Reason)
+	switch {
+	case errors.Is(err, errNoRevocations):
+		http.Error(w, "no revocation list", http.StatusNotImplemented)
+		return
+	case err != nil:
+		http.Error(w, "failed to revoke key", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{"fingerprint": req.Fingerprint, "disconnected": disconnected})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,170 @@
+package wskeyauth
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// supportedHashes are the hashes the handshake can verify signatures made
+// with.
+var supportedHashes = []string{"SHA-256"}
+
+// AlgorithmPolicy restricts the algorithms clients may authenticate with, for
+// servers that must hold to a policy such as one of their compliance
+// regime's. The policy is advertised in the CHALLENGE's capabilities, and
+// clients whose keys it doesn't allow are turned away as soon as they send
+// their CLIENT_ID, with UNSUPPORTED_ALGORITHM:
+//
+//	{"type": "UNSUPPORTED_ALGORITHM", "data": {"message": "Ed25519 keys are not accepted"}}
+type AlgorithmPolicy struct {
+	// Curves are the curves client keys may be on, of "P-256" and
+	// "Ed25519". Empty allows both.
+	Curves []string
+
+	// Hashes are the hashes signatures may be made with, both those of
+	// clients over challenges, which are always SHA-256 but for Ed25519
+	// ones, and those of the certificates of X.509 client IDs, such as
+	// "SHA-384". Empty allows any the handshake supports.
+	Hashes []string
+
+	// MinRSABits is the smallest RSA key, in bits, that the certificates of
+	// X.509 client IDs may have. Zero leaves it to crypto/x509.
+	MinRSABits int
+
+	// fips narrows the policy further, to the algorithms WithFIPS allows.
+	fips bool
+}
+
+// The algorithms WithFIPS allows.
+var (
+	approvedCurves = []string{"P-256"}
+	approvedHashes = []string{"SHA-256", "SHA-384", "SHA-512"}
+)
+
+const minApprovedRSABits = 2048
+
+// WithAlgorithmPolicy only accepts clients that authenticate with algorithms
+// p allows, and advertises p in the server's capabilities.
+func WithAlgorithmPolicy(p AlgorithmPolicy) Option {
+	return func(cfg *config) {
+		cfg.algorithms = &p
+	}
+}
+
+// withFIPS returns a copy of p, narrowed to the algorithms WithFIPS allows.
+func (p *AlgorithmPolicy) withFIPS() *AlgorithmPolicy {
+	var fips AlgorithmPolicy
+	if p != nil {
+		fips = *p
+	}
+	fips.fips = true
+	return &fips
+}
+
+// allows reports whether name is among allowed, unless that is empty, and,
+// in FIPS mode, among approved.
+func (p *AlgorithmPolicy) allows(allowed, approved []string, name string) bool {
+	if len(allowed) > 0 && !slices.Contains(allowed, name) {
+		return false
+	}
+	return !p.fips || slices.Contains(approved, name)
+}
+
+// curves returns the curves p allows.
+func (p *AlgorithmPolicy) curves() []string {
+	if p == nil || len(p.Curves) == 0 && !p.fips {
+		return defaultCapabilities.Curves
+	}
+	return slices.DeleteFunc(slices.Clone(defaultCapabilities.Curves), func(curve string) bool {
+		return !p.allows(p.Curves, approvedCurves, curve)
+	})
+}
+
+// hashes returns the hashes of challenge signatures p allows.
+func (p *AlgorithmPolicy) hashes() []string {
+	if p == nil || len(p.Hashes) == 0 && !p.fips {
+		return supportedHashes
+	}
+	return slices.DeleteFunc(slices.Clone(supportedHashes), func(hash string) bool {
+		return !p.allows(p.Hashes, approvedHashes, hash)
+	})
+}
+
+// minRSABits returns the smallest RSA key p allows, or zero if it leaves
+// that to crypto/x509.
+func (p *AlgorithmPolicy) minRSABits() int {
+	if p.fips {
+		return max(p.MinRSABits, minApprovedRSABits)
+	}
+	return p.MinRSABits
+}
+
+// allowsHash reports whether a challenge may be signed with hash.
+func (p *AlgorithmPolicy) allowsHash(hash string) bool {
+	return slices.Contains(p.hashes(), hash)
+}
+
+// check checks that the key of pub, and the certificates it came with, if
+// any, are of algorithms p allows.
+func (p *AlgorithmPolicy) check(pub *publicKey) error {
+	if p == nil {
+		return nil
+	}
+
+	var curve string
+	switch {
+	case pub.ed25519 != nil:
+		curve = "Ed25519"
+	case pub.ecdsa != nil:
+		curve = "P-256"
+	}
+	if curve != "" && !slices.Contains(p.curves(), curve) {
+		if p.fips && !slices.Contains(approvedCurves, curve) {
+			return fmt.Errorf("%s keys are not approved in FIPS mode", curve)
+		}
+		return fmt.Errorf("%s keys are not accepted", curve)
+	}
+	if pub.username != "" && p.fips {
+		return errors.New("password client IDs are not approved in FIPS mode")
+	}
+
+	for _, cert := range pub.certificates {
+		if key, ok := cert.PublicKey.(*rsa.PublicKey); ok && key.N.BitLen() < p.minRSABits() {
+			return fmt.Errorf("certificate %q has a %d bit RSA key, but keys of at least %d bits are required", cert.Subject, key.N.BitLen(), p.minRSABits())
+		}
+		hash := certificateHash(cert.SignatureAlgorithm)
+		if hash == "" && p.fips {
+			return fmt.Errorf("certificate %q is signed with Ed25519, which is not approved in FIPS mode", cert.Subject)
+		}
+		if hash != "" && !p.allows(p.Hashes, approvedHashes, hash) {
+			return fmt.Errorf("certificate %q is signed with %s, which is not accepted", cert.Subject, hash)
+		}
+	}
+	return nil
+}
+
+// certificateHash returns the name of the hash certificates signed with alg
+// are signed over, or "" for Ed25519 signatures, which have none of their
+// own.
+func certificateHash(alg x509.SignatureAlgorithm) string {
+	switch alg {
+	case x509.PureEd25519:
+		return ""
+	case x509.MD5WithRSA:
+		return "MD5"
+	case x509.SHA1WithRSA, x509.ECDSAWithSHA1, x509.DSAWithSHA1:
+		return "SHA-1"
+	}
+	// SHA256WithRSA, ECDSAWithSHA384, SHA512WithRSAPSS, and their like
+	name := alg.String()
+	for _, hash := range []string{"256", "384", "512"} {
+		if strings.Contains(name, hash) {
+			return "SHA-" + hash
+		}
+	}
+	return name
+}
@@ -0,0 +1,58 @@
+package wskeyauth
+
+import "crypto/sha256"
+
+// WithAudience binds signatures to the server they were made for, so that a
+// signature harvested by another site, or another server, is worthless here.
+// audience names the server as clients dial it, such as
+// "wss://example.com".
+//
+// With an audience, the CHALLENGE is an object, rather than a string:
+//
+//	{"challenge": "<base64 challenge>", "audience": "wss://example.com"}
+//
+// and the client signs the challenge followed by the audience it believes it
+// is connected to, in UTF-8. Clients that predate audiences can't
+// authenticate with servers that use them.
+func WithAudience(audience string) Option {
+	return func(cfg *config) {
+		cfg.audience = audience
+	}
+}
+
+type challengeData struct {
+	Challenge string `json:"challenge"`
+	Audience  string `json:"audience"`
+}
+
+type audienceChallengeMessage struct {
+	Type         string        `json:"type"`
+	Data         challengeData `json:"data"`
+	Capabilities *Capabilities `json:"capabilities"`
+	Server       *serverProof  `json:"server,omitempty"`
+
+	ProofOfWork *proofOfWorkData `json:"proofOfWork,omitempty"`
+}
+
+// signedHash hashes what the client was asked to sign.
+func signedHash(challenge []byte, audience string) [sha256.Size]byte {
+	if audience == "" {
+		return sha256.Sum256(challenge)
+	}
+
+	h := sha256.New()
+	h.Write(challenge)
+	h.Write([]byte(audience))
+
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// signedChallenge returns a copy of challenge followed by audience, which is
+// what clients sign, for it to be cleared once checked without clearing
+// challenge, which is needed again.
+func signedChallenge(challenge []byte, audience string) []byte {
+	b := make([]byte, 0, len(challenge)+len(audience))
+	return append(append(b, challenge...), audience...)
+}
@@ -0,0 +1,39 @@
+package wskeyauth
+
+import "time"
+
+// AuditRecord describes one authentication attempt. Records are passed by
+// value, and hold no references into the handshake.
+type AuditRecord struct {
+	// Time is when the handshake started.
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+
+	// ClientID and Fingerprint are empty if the client didn't get as far as
+	// sending a client ID that could be parsed.
+	ClientID    string `json:"client_id,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	Authenticated bool `json:"authenticated"`
+
+	// Reason and Error are set if the attempt failed.
+	Reason FailureReason `json:"reason,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// AuditSink receives a record of every authentication attempt, successful or
+// not. The audit subpackage provides JSON lines and syslog implementations.
+//
+// Record is called from the goroutine running the handshake, so it must be
+// safe for concurrent use. Errors are logged, but don't affect the outcome of
+// the handshake.
+type AuditSink interface {
+	Record(AuditRecord) error
+}
+
+// WithAuditSink records every authentication attempt to sink.
+func WithAuditSink(sink AuditSink) Option {
+	return func(cfg *config) {
+		cfg.audit = sink
+	}
+}
@@ -0,0 +1,60 @@
+// Package audit provides wskeyauth.AuditSink implementations.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// JSONLines writes every record as a single line of JSON.
+type JSONLines struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var _ wskeyauth.AuditSink = (*JSONLines)(nil)
+
+func NewJSONLines(w io.Writer) *JSONLines {
+	return &JSONLines{w: w}
+}
+
+// OpenFile opens (or creates) the file at path for appending, and returns a
+// sink writing to it.
+func OpenFile(path string) (*JSONLines, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONLines(f), nil
+}
+
+func (s *JSONLines) Record(record wskeyauth.AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// a single write per record keeps lines whole even when several processes
+	// append to the same file
+	_, err = s.w.Write(line)
+	return err
+}
+
+// Close closes the underlying writer, if it is an io.Closer.
+func (s *JSONLines) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"encoding/json"
+	"log/syslog"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// Syslog writes records as JSON to the system log. Successful attempts are
+// logged at info level, failed ones at warning.
+type Syslog struct {
+	w *syslog.Writer
+}
+
+var _ wskeyauth.AuditSink = (*Syslog)(nil)
+
+// NewSyslog connects to the local syslog daemon, logging under the given
+// facility and tag.
+func NewSyslog(facility syslog.Priority, tag string) (*Syslog, error) {
+	w, err := syslog.New(facility|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &Syslog{w: w}, nil
+}
+
+// DialSyslog connects to a syslog daemon at raddr over network.
+func DialSyslog(network, raddr string, facility syslog.Priority, tag string) (*Syslog, error) {
+	w, err := syslog.Dial(network, raddr, facility|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &Syslog{w: w}, nil
+}
+
+func (s *Syslog) Record(record wskeyauth.AuditRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if record.Authenticated {
+		return s.w.Info(string(b))
+	}
+	return s.w.Warning(string(b))
+}
+
+func (s *Syslog) Close() error {
+	return s.w.Close()
+}
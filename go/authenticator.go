@@ -0,0 +1,272 @@
+package wskeyauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// GoingAwayCloseCode is the WebSocket close code sent to clients when the
+// server shuts down, as defined by RFC 6455.
+const GoingAwayCloseCode = 1001
+
+// ErrShuttingDown is returned by Authenticator.Handshake once Shutdown was
+// called, and by Registry.Add once Shutdown disconnected the registry's
+// sessions.
+var ErrShuttingDown = errors.New("wskeyauth: server is shutting down")
+
+// Authenticator runs handshakes with the same options, and keeps track of
+// them, so that the server can shut down cleanly:
+//
+//	auth := wskeyauth.NewAuthenticator(registry, wskeyauth.WithLogger(logger))
+//	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+//		conn, _ := upgrader.Upgrade(w, r, nil)
+//		authenticated, clientID, err := auth.Handshake(conn)
+//		...
+//	})
+//
+//	// on SIGTERM
+//	auth.Shutdown(ctx)
+type Authenticator struct {
+	registry    *Registry
+	opts        []Option
+	rateLimiter RateLimiter
+	since       time.Time
+
+	// inFlight holds the connections of the handshakes in flight, and the
+	// ServerHandshakes of those started with Start.
+	mu       sync.Mutex
+	closing  bool
+	inFlight map[any]struct{}
+	done     chan struct{}
+
+	// counts of the handshakes that finished, or were refused, for Stats
+	succeeded, failed, refused int64
+	failures                   map[FailureReason]int64
+}
+
+// NewAuthenticator creates an authenticator that runs handshakes with opts.
+// registry, if not nil, holds the connections it authenticated, for Shutdown
+// to close.
+func NewAuthenticator(registry *Registry, opts ...Option) *Authenticator {
+	return &Authenticator{
+		registry:    registry,
+		opts:        opts,
+		rateLimiter: newConfig(opts).rateLimiter,
+		since:       time.Now(),
+		inFlight:    map[any]struct{}{},
+		failures:    map[FailureReason]int64{},
+	}
+}
+
+// Handshake runs the handshake over conn as Handshake does, with the
+// authenticator's options, followed by opts. Once Shutdown was called, the
+// client is sent a GOING_AWAY message instead, and ErrShuttingDown returned.
+func (a *Authenticator) Handshake(conn Conn, opts ...Option) (bool, string, error) {
+	result, err := a.HandshakeResult(conn, opts...)
+	return result.Authenticated, result.ClientID, err
+}
+
+// HandshakeResult runs the handshake as Handshake does, but returns all there
+// is to know about its outcome, as HandshakeResult does.
+func (a *Authenticator) HandshakeResult(conn Conn, opts ...Option) (*Result, error) {
+	if !a.begin(conn) {
+		goAway(conn)
+		return &Result{}, ErrShuttingDown
+	}
+	return a.run(conn, opts)
+}
+
+// begin counts a handshake, by its connection or ServerHandshake, as in
+// flight, unless the authenticator is shutting down.
+func (a *Authenticator) begin(handshake any) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closing {
+		a.refused++
+		return false
+	}
+	a.inFlight[handshake] = struct{}{}
+	return true
+}
+
+// end counts a handshake begin counted as in flight as over, with result.
+func (a *Authenticator) end(handshake any, result *Result) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.count(result)
+	delete(a.inFlight, handshake)
+	if len(a.inFlight) == 0 && a.done != nil {
+		close(a.done)
+		a.done = nil
+	}
+}
+
+// run runs the handshake over conn, which begin counted as in flight.
+func (a *Authenticator) run(conn Conn, opts []Option) (result *Result, err error) {
+	defer func() { a.end(conn, result) }()
+	return HandshakeResult(conn, a.options(opts)...)
+}
+
+// options returns the authenticator's options, followed by opts.
+func (a *Authenticator) options(opts []Option) []Option {
+	if len(opts) == 0 {
+		return a.opts
+	}
+	return append(append([]Option{}, a.opts...), opts...)
+}
+
+// Start starts a handshake as a ServerHandshake, with the authenticator's
+// options, followed by opts, for servers that run a central event loop
+// rather than a goroutine per connection: the loop feeds the handshake the
+// client's messages as it reads them, and sends what it returns:
+//
+//	hs, out, state, err := auth.Start()
+//	send(conn, out)
+//	...
+//	// in the loop, for each message from a client in handshake
+//	out, state, err = hs.Feed(msg)
+//	send(conn, out)
+//	if state == wskeyauth.StateAuthenticated {
+//		...
+//	}
+//
+// The handshake counts as in flight from the moment Start returns until it is
+// over, or closed, so that Shutdown waits for it, and closes it if it runs
+// out of time. Once Shutdown was called, no handshake is returned, but the
+// GOING_AWAY message to send the client, and ErrShuttingDown.
+func (a *Authenticator) Start(opts ...Option) (*ServerHandshake, []json.RawMessage, State, error) {
+	hs := NewServerHandshake(a.options(opts)...)
+	if !a.begin(hs) {
+		msg, _ := json.Marshal(goingAwayMessage)
+		return nil, []json.RawMessage{msg}, StateFailed, ErrShuttingDown
+	}
+	hs.ended = func(result *Result) { a.end(hs, result) }
+
+	out, state, err := hs.Start()
+	return hs, out, state, err
+}
+
+// InFlight returns how many handshakes the authenticator is running, for
+// telling when the server is under load, such as for ProofOfWork.Required.
+func (a *Authenticator) InFlight() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.inFlight)
+}
+
+// Shutdown stops the authenticator from accepting new handshakes, and waits
+// for those in flight to finish, until ctx is done. Those still in flight by
+// then are interrupted by closing their connections, which must be
+// io.Closers for that, or by closing the ServerHandshakes of those started
+// with Start. Finally, every session in the authenticator's registry
+// is sent a GOING_AWAY message and disconnected with GoingAwayCloseCode, so
+// that clients know to reconnect elsewhere, until ctx is done, when the
+// connections of those left are closed. The registry refuses sessions from
+// then on.
+//
+// It returns ctx.Err() if handshakes or sessions had to be interrupted.
+func (a *Authenticator) Shutdown(ctx context.Context) error {
+	a.mu.Lock()
+	a.closing = true
+	var done chan struct{}
+	if len(a.inFlight) > 0 {
+		if a.done == nil {
+			a.done = make(chan struct{})
+		}
+		done = a.done
+	}
+	a.mu.Unlock()
+
+	var err error
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+
+			// closed outside the lock, as closing a ServerHandshake ends
+			// it, which takes the lock
+			var closers []io.Closer
+			a.mu.Lock()
+			for handshake := range a.inFlight {
+				if c, ok := handshake.(io.Closer); ok {
+					closers = append(closers, c)
+				}
+			}
+			a.mu.Unlock()
+			for _, c := range closers {
+				c.Close()
+			}
+		}
+	}
+
+	if a.registry != nil {
+		if goneErr := a.disconnect(ctx); err == nil {
+			err = goneErr
+		}
+	}
+	return err
+}
+
+// disconnect sends every session in the registry a GOING_AWAY message and
+// disconnects it, concurrently, so that a slow connection doesn't hold up the
+// others. Sessions still connected once ctx is done have their connections
+// closed outright, and it returns ctx.Err().
+func (a *Authenticator) disconnect(ctx context.Context) error {
+	// handshakes that ended, or were interrupted, may still register their
+	// sessions, which would never be disconnected
+	a.registry.mu.Lock()
+	a.registry.closed = true
+	a.registry.mu.Unlock()
+
+	var sessions []*Session
+	a.registry.Range(func(s *Session) bool {
+		sessions = append(sessions, s)
+		return true
+	})
+
+	var wg sync.WaitGroup
+	for _, s := range sessions {
+		wg.Add(1)
+		go func(s *Session) {
+			defer wg.Done()
+			s.Send(goingAwayMessage)
+			s.Disconnect(GoingAwayCloseCode, "server is shutting down")
+		}(s)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		// closed without the write lock, which a stuck Send holds, so
+		// that it fails
+		for _, s := range sessions {
+			if c, ok := s.Conn.(io.Closer); ok && a.registry.registered(s) {
+				c.Close()
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+var goingAwayMessage = &stringMessage{Type: "GOING_AWAY", Data: "The server is shutting down"}
+
+// goAway tells a client the server is shutting down.
+func goAway(conn Conn) {
+	conn.WriteJSON(goingAwayMessage)
+	if c, ok := conn.(controlWriter); ok {
+		c.WriteControl(closeMessage, closeFrame(GoingAwayCloseCode, "server is shutting down"), time.Now().Add(time.Second))
+	}
+}
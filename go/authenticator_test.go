@@ -0,0 +1,137 @@
+package wskeyauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+func TestAuthenticatorStart(t *testing.T) {
+	key := wskeyauthtest.MustGenerateKey()
+	auth := wskeyauth.NewAuthenticator(nil)
+
+	hs, out, state, err := auth.Start()
+	if len(out) != 0 || state != wskeyauth.StateAwaitingMessage || err != nil {
+		t.Fatalf("Start() = %s, %v, %v", out, state, err)
+	}
+	if n := auth.InFlight(); n != 1 {
+		t.Fatalf("InFlight() = %d while the handshake runs, want 1", n)
+	}
+
+	out, _, _ = hs.Feed(marshal(t, map[string]any{"type": "CLIENT_ID", "data": key.ClientID()}))
+	if _, state, err := hs.Feed(respond(t, key, only(t, out))); state != wskeyauth.StateAuthenticated || err != nil {
+		t.Fatalf("Feed(CHALLENGE_RESPONSE) = %v, %v", state, err)
+	}
+	if n := auth.InFlight(); n != 0 {
+		t.Fatalf("InFlight() = %d once the handshake is over, want 0", n)
+	}
+	if stats := auth.Stats(); stats.Succeeded != 1 {
+		t.Fatalf("Stats().Succeeded = %d, want 1", stats.Succeeded)
+	}
+}
+
+func TestAuthenticatorShutdownClosesStarted(t *testing.T) {
+	auth := wskeyauth.NewAuthenticator(nil)
+	hs, _, _, _ := auth.Start()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := auth.Shutdown(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Shutdown() = %v, want context.Canceled", err)
+	}
+	if result := hs.Result(); result == nil || result.Authenticated {
+		t.Fatalf("Result() of the interrupted handshake = %+v", result)
+	}
+	if n := auth.InFlight(); n != 0 {
+		t.Fatalf("InFlight() = %d after Shutdown, want 0", n)
+	}
+
+	hs, out, state, err := auth.Start()
+	if hs != nil || state != wskeyauth.StateFailed || !errors.Is(err, wskeyauth.ErrShuttingDown) {
+		t.Fatalf("Start() once shut down = %v, %v, %v", hs, state, err)
+	}
+	var td wskeyauth.TypeData
+	if len(out) != 1 || json.Unmarshal(out[0], &td) != nil || td.Type != "GOING_AWAY" {
+		t.Fatalf("Start() once shut down sent %s, want a GOING_AWAY", out)
+	}
+}
+
+// closeConn records what was written to it, and blocks writes until it is
+// closed if stuck.
+type closeConn struct {
+	stuck  bool
+	mu     sync.Mutex
+	wrote  []any
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (c *closeConn) ReadJSON(any) error {
+	<-c.closed
+	return errors.New("closed")
+}
+
+func (c *closeConn) WriteJSON(v any) error {
+	if c.stuck {
+		<-c.closed
+		return errors.New("closed")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.wrote = append(c.wrote, v)
+	return nil
+}
+
+func (c *closeConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+func TestAuthenticatorShutdownStopsAtDeadline(t *testing.T) {
+	registry := wskeyauth.NewRegistry()
+	auth := wskeyauth.NewAuthenticator(registry)
+	stuck := &closeConn{stuck: true, closed: make(chan struct{})}
+	fine := &closeConn{closed: make(chan struct{})}
+	for _, conn := range []*closeConn{stuck, fine} {
+		if _, err := registry.Add(wskeyauthtest.MustGenerateKey().ClientID(), conn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := auth.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() = %v, want context.DeadlineExceeded", err)
+	}
+
+	for _, conn := range []*closeConn{stuck, fine} {
+		select {
+		case <-conn.closed:
+		default:
+			t.Fatal("Shutdown() left a connection open")
+		}
+	}
+	fine.mu.Lock()
+	if len(fine.wrote) != 1 {
+		t.Fatalf("the session that could be written to got %v, want a GOING_AWAY", fine.wrote)
+	}
+	fine.mu.Unlock()
+	for deadline := time.Now().Add(time.Second); registry.Count() != 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d sessions still registered after Shutdown", registry.Count())
+		}
+	}
+
+	// a handshake that ended as Shutdown interrupted it can't register a
+	// session that would never be disconnected
+	late := &closeConn{closed: make(chan struct{})}
+	if _, err := registry.Add(wskeyauthtest.MustGenerateKey().ClientID(), late); !errors.Is(err, wskeyauth.ErrShuttingDown) {
+		t.Fatalf("Add() after Shutdown = %v, want ErrShuttingDown", err)
+	}
+}
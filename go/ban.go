@@ -0,0 +1,291 @@
+package wskeyauth
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBanThreshold is how many handshakes may fail in a row before the
+	// client is locked out, unless Bans.Threshold says otherwise.
+	DefaultBanThreshold = 5
+
+	// DefaultBanLockout is how long the first lockout lasts, unless
+	// Bans.Lockout says otherwise.
+	DefaultBanLockout = 30 * time.Second
+
+	// DefaultMaxBanLockout is the longest lockout, unless Bans.MaxLockout
+	// says otherwise.
+	DefaultMaxBanLockout = time.Hour
+
+	// DefaultBanForget is how long failures are remembered after the last
+	// one, unless Bans.Forget says otherwise.
+	DefaultBanForget = 24 * time.Hour
+)
+
+// BanStore counts failed handshakes in a row. Failures are counted against
+// the client's address, as "addr:<host>", and, with Bans.Keys, against its
+// key, as "key:<fingerprint>", as a RateLimiter counts handshakes. A store shared
+// between servers, such as the Redis one in contrib/redis, locks clients out
+// of all of them.
+type BanStore interface {
+	// Fail counts a failed handshake against key, and returns how many have
+	// failed in a row, this one included. They are forgotten ttl after the
+	// last one.
+	Fail(ctx context.Context, key string, ttl time.Duration) (int, error)
+
+	// Failures returns how many handshakes have failed in a row against key,
+	// and when the last of them did.
+	Failures(ctx context.Context, key string) (int, time.Time, error)
+
+	// Clear forgets the failures counted against key.
+	Clear(ctx context.Context, key string) error
+}
+
+// Bans lock clients out when their handshakes keep failing, for longer and
+// longer, to blunt attempts to guess keys and scans. Once Threshold
+// handshakes failed in a row, the client is locked out for Lockout after the
+// last of them, and the lockout doubles with every failure after that, up to
+// MaxLockout. Clients that are locked out get RETRY_AFTER, in place of
+// whatever they would have been sent next:
+//
+//	{"type": "RETRY_AFTER", "data": {"message": "Too many failed handshakes, try again later", "retryAfter": 60}}
+//
+// where retryAfter is in seconds. Handshakes that fail on the server's end,
+// by a pairing pending, by an IP filter, a rate limit or an interceptor's
+// veto, by timing out, or by the client going away don't count.
+type Bans struct {
+	// Store counts failures. It defaults to a MemoryBanStore.
+	Store BanStore
+
+	// Threshold, Lockout, MaxLockout and Forget default to
+	// DefaultBanThreshold, DefaultBanLockout, DefaultMaxBanLockout and
+	// DefaultBanForget.
+	Threshold  int
+	Lockout    time.Duration
+	MaxLockout time.Duration
+	Forget     time.Duration
+
+	// Keys also locks out keys whose handshakes keep failing, whatever
+	// address they come from, and a handshake that succeeds clears the
+	// failures counted against its key, but not those against its
+	// address, which may be shared by many clients. Client IDs are public,
+	// and failing a handshake takes no more than sending one, so anyone
+	// who knows a client's ID can lock it out: only set Keys where client
+	// IDs aren't known beyond their clients.
+	Keys bool
+
+	once sync.Once
+}
+
+// WithBans locks clients out as b says once their handshakes keep failing.
+func WithBans(b *Bans) Option {
+	return func(cfg *config) {
+		cfg.bans = b
+	}
+}
+
+func (b *Bans) store() BanStore {
+	b.once.Do(func() {
+		if b.Store == nil {
+			b.Store = NewMemoryBanStore()
+		}
+	})
+	return b.Store
+}
+
+func (b *Bans) threshold() int {
+	if b.Threshold <= 0 {
+		return DefaultBanThreshold
+	}
+	return b.Threshold
+}
+
+func (b *Bans) forget() time.Duration {
+	if b.Forget <= 0 {
+		return DefaultBanForget
+	}
+	return b.Forget
+}
+
+// lockout returns how long a client is locked out for after failures
+// failures in a row.
+func (b *Bans) lockout(failures int) time.Duration {
+	lockout, max := b.Lockout, b.MaxLockout
+	if lockout <= 0 {
+		lockout = DefaultBanLockout
+	}
+	if max <= 0 {
+		max = DefaultMaxBanLockout
+	}
+
+	doublings := failures - b.threshold()
+	if doublings < 0 {
+		return 0
+	}
+	if float64(lockout)*math.Exp2(float64(doublings)) >= float64(max) {
+		return max
+	}
+	return lockout << doublings
+}
+
+// banned returns when the ban on key ends, or the zero time if there is
+// none at now.
+func (b *Bans) banned(ctx context.Context, key string, now time.Time) (time.Time, error) {
+	failures, last, err := b.store().Failures(ctx, key)
+	if err != nil || failures < b.threshold() {
+		return time.Time{}, err
+	}
+	if until := last.Add(b.lockout(failures)); now.Before(until) {
+		return until, nil
+	}
+	return time.Time{}, nil
+}
+
+// counts reports whether handshakes that failed with reason count towards a
+// ban.
+func (b *Bans) counts(reason FailureReason) bool {
+	switch reason {
+	case ReasonServerError, ReasonPairingPending, ReasonAddressDenied, ReasonRateLimited, ReasonReadFailed, ReasonBanned,
+		ReasonVetoed, ReasonTimeout:
+		return false
+	}
+	return true
+}
+
+// applies reports whether the bans lock key out, which keys only are with
+// Keys.
+func (b *Bans) applies(key string) bool {
+	return b.Keys || !strings.HasPrefix(key, "key:")
+}
+
+type retryAfterMessage struct {
+	Type string         `json:"type"`
+	Data retryAfterData `json:"data"`
+}
+
+type retryAfterData struct {
+	Message    string `json:"message"`
+	RetryAfter int64  `json:"retryAfter"`
+}
+
+// checkBan tells the client to retry later if key is banned.
+func (h *handshakeState) checkBan(key string) (bool, FailureReason, error) {
+	now := h.cfg.clock.Now()
+	until, err := h.cfg.bans.banned(h.cfg.ctx, key, now)
+	if err != nil {
+		h.conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to check ban", err))
+		return false, ReasonServerError, err
+	}
+	if until.IsZero() {
+		return true, "", nil
+	}
+
+	h.log.Debug("wskeyauth: client is locked out", "key", key, "until", until)
+	h.conn.WriteJSON(&retryAfterMessage{Type: "RETRY_AFTER", Data: retryAfterData{
+		Message:    "Too many failed handshakes, try again later",
+		RetryAfter: int64(math.Ceil(until.Sub(now).Seconds())),
+	}})
+	return false, ReasonBanned, nil
+}
+
+// recordBan counts the outcome of the handshake towards the client's bans.
+func (h *handshakeState) recordBan(authenticated bool, reason FailureReason) {
+	b, ctx := h.cfg.bans, h.cfg.ctx
+
+	if authenticated {
+		if !b.Keys {
+			return
+		}
+		if err := b.store().Clear(ctx, "key:"+h.fingerprint); err != nil {
+			h.log.Error("wskeyauth: failed to clear failed handshakes", "error", err)
+		}
+		return
+	}
+	if !b.counts(reason) {
+		return
+	}
+
+	var keys []string
+	if h.remoteAddr != "" {
+		keys = append(keys, addrKey(h.remoteAddr))
+	}
+	if h.fingerprint != "" && b.Keys {
+		keys = append(keys, "key:"+h.fingerprint)
+	}
+	for _, key := range keys {
+		if _, err := b.store().Fail(ctx, key, b.forget()); err != nil {
+			h.log.Error("wskeyauth: failed to count failed handshake", "key", key, "error", err)
+		}
+	}
+}
+
+// MemoryBanStore is a BanStore for a single process.
+type MemoryBanStore struct {
+	// Clock tells when failures happen, which lockouts are timed from, and
+	// when they are forgotten; give it the clock of the handshakes. It
+	// defaults to SystemClock.
+	Clock Clock
+
+	mu       sync.Mutex
+	failures map[string]*banFailures
+	swept    time.Time
+}
+
+type banFailures struct {
+	count   int
+	last    time.Time
+	expires time.Time
+}
+
+// NewMemoryBanStore creates an empty MemoryBanStore.
+func NewMemoryBanStore() *MemoryBanStore {
+	return &MemoryBanStore{failures: map[string]*banFailures{}}
+}
+
+func (s *MemoryBanStore) Fail(_ context.Context, key string, ttl time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := clockOr(s.Clock).Now()
+
+	// forget failures once in a while, rather than on every one
+	if now.Sub(s.swept) > time.Minute {
+		for k, f := range s.failures {
+			if now.After(f.expires) {
+				delete(s.failures, k)
+			}
+		}
+		s.swept = now
+	}
+
+	f, ok := s.failures[key]
+	if !ok || now.After(f.expires) {
+		f = &banFailures{}
+		s.failures[key] = f
+	}
+	f.count++
+	f.last, f.expires = now, now.Add(ttl)
+	return f.count, nil
+}
+
+func (s *MemoryBanStore) Failures(_ context.Context, key string) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.failures[key]
+	if !ok || clockOr(s.Clock).Now().After(f.expires) {
+		return 0, time.Time{}, nil
+	}
+	return f.count, f.last, nil
+}
+
+func (s *MemoryBanStore) Clear(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, key)
+	return nil
+}
@@ -0,0 +1,96 @@
+package wskeyauth_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+// attempt runs a handshake as key, from addr, signing the challenge with
+// signer, and returns why it failed, if it did.
+func attempt(t *testing.T, addr string, key, signer *wskeyauthtest.Key, opts ...wskeyauth.Option) wskeyauth.FailureReason {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = addr
+	hs := wskeyauth.NewServerHandshake(append(opts, wskeyauth.WithRequest(r))...)
+	hs.Start()
+
+	out, state, _ := hs.Feed(marshal(t, map[string]any{"type": "CLIENT_ID", "data": key.ClientID()}))
+	if state == wskeyauth.StateAwaitingMessage {
+		var challenge string
+		json.Unmarshal(only(t, out).Data, &challenge)
+		sig, _ := signer.SignChallengeFor(challenge, wskeyauth.SignedAudience("", key.ClientID()))
+		hs.Feed(marshal(t, map[string]any{
+			"type": "CHALLENGE_RESPONSE",
+			"data": map[string]string{"signature": sig, "hash": "SHA-256"},
+		}))
+	}
+	return hs.Result().Reason
+}
+
+func TestBansLockOutAddresses(t *testing.T) {
+	key, other := wskeyauthtest.MustGenerateKey(), wskeyauthtest.MustGenerateKey()
+	bans := wskeyauth.WithBans(&wskeyauth.Bans{Threshold: 1})
+
+	if reason := attempt(t, "192.0.2.1:1000", key, other, bans); reason != wskeyauth.ReasonSignatureMismatch {
+		t.Fatalf("first attempt failed with %q, want a signature mismatch", reason)
+	}
+	if reason := attempt(t, "192.0.2.1:1001", key, key, bans); reason != wskeyauth.ReasonBanned {
+		t.Fatalf("attempt from the same address failed with %q, want it banned", reason)
+	}
+	// anyone can fail a handshake as key, so key isn't locked out elsewhere
+	if reason := attempt(t, "192.0.2.2:1000", key, key, bans); reason != "" {
+		t.Fatalf("attempt from another address failed with %q", reason)
+	}
+}
+
+func TestBansLockOutKeys(t *testing.T) {
+	key, other := wskeyauthtest.MustGenerateKey(), wskeyauthtest.MustGenerateKey()
+	bans := wskeyauth.WithBans(&wskeyauth.Bans{Threshold: 1, Keys: true})
+
+	attempt(t, "192.0.2.1:1000", key, other, bans)
+	if reason := attempt(t, "192.0.2.2:1000", key, key, bans); reason != wskeyauth.ReasonBanned {
+		t.Fatalf("attempt as the key from another address failed with %q, want it banned", reason)
+	}
+}
+
+func TestBansIgnoreVetoes(t *testing.T) {
+	key := wskeyauthtest.MustGenerateKey()
+	bans := wskeyauth.WithBans(&wskeyauth.Bans{Threshold: 1})
+	veto := wskeyauth.WithInterceptors(func(msg json.RawMessage, fromClient bool) (json.RawMessage, error) {
+		return nil, errors.New("vetoed")
+	})
+
+	if reason := attempt(t, "192.0.2.1:1000", key, key, bans, veto); reason != wskeyauth.ReasonVetoed {
+		t.Fatalf("vetoed attempt failed with %q", reason)
+	}
+	if reason := attempt(t, "192.0.2.1:1000", key, key, bans); reason != "" {
+		t.Fatalf("attempt after a veto failed with %q", reason)
+	}
+}
+
+func TestBansLockoutEnds(t *testing.T) {
+	key, other := wskeyauthtest.MustGenerateKey(), wskeyauthtest.MustGenerateKey()
+	clock := wskeyauthtest.NewClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := wskeyauth.NewMemoryBanStore()
+	store.Clock = clock
+	opts := []wskeyauth.Option{
+		wskeyauth.WithBans(&wskeyauth.Bans{Store: store, Threshold: 1, Lockout: time.Minute}),
+		wskeyauth.WithClock(clock),
+	}
+
+	attempt(t, "192.0.2.1:1000", key, other, opts...)
+	clock.Advance(59 * time.Second)
+	if reason := attempt(t, "192.0.2.1:1000", key, key, opts...); reason != wskeyauth.ReasonBanned {
+		t.Fatalf("attempt within the lockout failed with %q, want it banned", reason)
+	}
+	clock.Advance(time.Second + time.Millisecond)
+	if reason := attempt(t, "192.0.2.1:1000", key, key, opts...); reason != "" {
+		t.Fatalf("attempt after the lockout failed with %q", reason)
+	}
+}
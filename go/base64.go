@@ -0,0 +1,125 @@
+package wskeyauth
+
+import (
+	"encoding/base64"
+	"errors"
+)
+
+// Base64 is a set of base64 variants that the server accepts for the keys in
+// client IDs and for signatures. The challenge is always sent in standard,
+// padded base64.
+type Base64 uint8
+
+const (
+	// Base64Std is standard, padded base64, as RFC 4648 section 4 describes
+	// and btoa produces.
+	Base64Std Base64 = 1 << iota
+
+	// Base64RawStd is standard base64 without padding.
+	Base64RawStd
+
+	// Base64URL is URL-safe base64, using - and _ in place of + and /.
+	Base64URL
+
+	// Base64RawURL is URL-safe base64 without padding, as used by JWTs and
+	// WebAuthn.
+	Base64RawURL
+
+	// Base64Strict rejects encodings that aren't canonical, whose unused
+	// trailing bits aren't zero, so that every key and signature has exactly
+	// one accepted encoding in each variant.
+	Base64Strict
+)
+
+// Base64Any accepts every variant of base64.
+const Base64Any = Base64Std | Base64RawStd | Base64URL | Base64RawURL
+
+// WithBase64 sets the base64 variants accepted from clients. The default is
+// Base64Std. For exact, canonical encodings only, use
+// Base64Std|Base64Strict.
+func WithBase64(accept Base64) Option {
+	return func(cfg *config) {
+		cfg.base64 = accept
+	}
+}
+
+var base64Variants = []struct {
+	variant  Base64
+	encoding *base64.Encoding
+	name     string
+}{
+	{Base64Std, base64.StdEncoding, "standard"},
+	{Base64RawStd, base64.RawStdEncoding, "unpadded"},
+	{Base64URL, base64.URLEncoding, "URL-safe"},
+	{Base64RawURL, base64.RawURLEncoding, "unpadded URL-safe"},
+}
+
+// decode decodes s into dst with the first accepted variant that takes it,
+// falling back to allocating if s is too long for dst.
+func (accept Base64) decode(dst []byte, s string) ([]byte, error) {
+	var firstErr error
+	for _, v := range base64Variants {
+		if accept&v.variant == 0 {
+			continue
+		}
+
+		enc := v.encoding
+		if accept&Base64Strict != 0 {
+			enc = enc.Strict()
+		}
+
+		var b []byte
+		var err error
+		if enc.DecodedLen(len(s)) > len(dst) {
+			b, err = enc.DecodeString(s)
+		} else {
+			var n int
+			n, err = enc.Decode(dst, []byte(s))
+			b = dst[:n]
+		}
+		if err == nil {
+			return b, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if firstErr == nil {
+		return nil, errors.New("no base64 variants are accepted")
+	}
+	return nil, accept.explain(s, firstErr)
+}
+
+// explain turns the error from decoding s into a more useful one, if s is
+// base64 that we just happen not to accept.
+func (accept Base64) explain(s string, err error) error {
+	for _, v := range base64Variants {
+		if _, e := v.encoding.DecodeString(s); e != nil {
+			continue
+		}
+		if accept&v.variant == 0 {
+			return errors.New("expected " + accept.String() + " base64, but got " + v.name + " base64")
+		}
+		return errors.New("expected canonical base64, but the unused trailing bits aren't zero")
+	}
+	return err
+}
+
+// String lists the variants in accept, such as "standard or URL-safe".
+func (accept Base64) String() string {
+	s := ""
+	for _, v := range base64Variants {
+		if accept&v.variant == 0 {
+			continue
+		}
+		if s != "" {
+			s += " or "
+		}
+		s += v.name
+	}
+	if accept&Base64Strict != 0 {
+		s = "canonical " + s
+	}
+	return s
+}
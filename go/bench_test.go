@@ -0,0 +1,49 @@
+package wskeyauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+)
+
+func BenchmarkParseClientID(b *testing.B) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		b.Fatal(err)
+	}
+	point, err := key.PublicKey.ECDH()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bench := range []struct {
+		name, clientID string
+	}{
+		{"WebCrypto-raw", "WebCrypto-raw.EC.P-256$" + base64.StdEncoding.EncodeToString(point.Bytes())},
+		{"did:key Ed25519", "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"},
+		{"did:key P-256", "did:key:zDnaerDaTF5BXEavCrfRZEk316dpbLsfPDZ3WJ5hRTPFU2169"},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := parseClientID(bench.clientID, Base64Any); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSendChallenge(b *testing.B) {
+	h := newHandshakeState(rawConn(nil), nil)
+	var buf buffers
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if ok, _, err := h.sendChallenge(&buf, nil); !ok {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package wskeyauth
+
+// Capabilities is what a server advertises alongside its CHALLENGE, in a
+// "capabilities" member next to "data":
+//
+//	{
+//		"type": "CHALLENGE",
+//		"data": "<base64 challenge>",
+//		"capabilities": {
+//			"hashes": ["SHA-256"],
+//			"curves": ["P-256", "Ed25519"],
+//			"extensions": ["audience"]
+//		}
+//	}
+//
+// Clients that predate it ignore it. Newer ones can use it to pick from what
+// the server supports, and to only use protocol extensions the server
+// understands. Hashes and curves are narrowed by WithAlgorithmPolicy.
+type Capabilities struct {
+	// Hashes are the hashes signatures may be made with.
+	Hashes []string `json:"hashes"`
+
+	// Curves are the named curves client IDs may use.
+	Curves []string `json:"curves"`
+
+	// MinRSABits is the smallest RSA key the certificates of X.509 client
+	// IDs may have, if the server's AlgorithmPolicy says.
+	MinRSABits int `json:"minRSABits,omitempty"`
+
+	// Extensions are the optional protocol features in use, such as
+	// "audience" with WithAudience, "webauthn" with WithWebAuthn,
+	// "server-key" with WithServerKey, "hmac" with a SecretStore, "srp" with
+	// WithPasswords, "totp" with WithTOTP, "oidc" with WithOIDC, and any
+	// given with WithExtensions.
+	Extensions []string `json:"extensions,omitempty"`
+}
+
+var defaultCapabilities = &Capabilities{
+	Hashes: []string{"SHA-256"},
+	Curves: []string{"P-256", "Ed25519"},
+}
+
+// WithExtensions advertises extensions in the server's capabilities, for
+// applications that build their own features on top of the handshake.
+func WithExtensions(extensions ...string) Option {
+	return func(cfg *config) {
+		cfg.extensions = append(cfg.extensions, extensions...)
+	}
+}
+
+// capabilities returns the capabilities to advertise with cfg.
+func (cfg *config) capabilities() *Capabilities {
+	_, hmac := cfg.keyStore.(SecretStore)
+	if cfg.audience == "" && cfg.webauthn == nil && cfg.serverKey == nil && cfg.passwords == nil && cfg.totp == nil && cfg.oidc == nil && !hmac && len(cfg.extensions) == 0 && cfg.algorithms == nil {
+		return defaultCapabilities
+	}
+
+	c := *defaultCapabilities
+	if cfg.algorithms != nil {
+		c.Hashes = cfg.algorithms.hashes()
+		c.Curves = cfg.algorithms.curves()
+		c.MinRSABits = cfg.algorithms.minRSABits()
+	}
+	if cfg.audience != "" {
+		c.Extensions = append(c.Extensions, "audience")
+	}
+	if cfg.webauthn != nil {
+		c.Extensions = append(c.Extensions, "webauthn")
+	}
+	if cfg.serverKey != nil {
+		c.Extensions = append(c.Extensions, "server-key")
+	}
+	if hmac {
+		c.Extensions = append(c.Extensions, "hmac")
+	}
+	if cfg.passwords != nil {
+		c.Extensions = append(c.Extensions, "srp")
+	}
+	if cfg.totp != nil {
+		c.Extensions = append(c.Extensions, "totp")
+	}
+	if cfg.oidc != nil {
+		c.Extensions = append(c.Extensions, "oidc")
+	}
+	c.Extensions = append(c.Extensions, cfg.extensions...)
+	return &c
+}
@@ -0,0 +1,90 @@
+package wskeyauth
+
+// MessageCatalog replaces the human-readable text of the errors sent to
+// clients, such as to translate it. Texts are keyed by the code of the error,
+// which doesn't change as the English text is reworded, or has something the
+// client sent filled in:
+//
+//	catalog := wskeyauth.MessageCatalogFunc(func(code, text string) string {
+//		if t, ok := german[code]; ok {
+//			return t
+//		}
+//		return text
+//	})
+//
+// The code is that of the CLIENT_ERROR or SERVER_ERROR, such as
+// "UNKNOWN_KEY", and, for errors that carry no code, such as RATE_LIMITED and
+// SIGNATURE_MISMATCH, the type of the message. The code and type, which
+// clients go by, are never replaced, nor are errors behind the text, such as
+// why a message failed to parse.
+type MessageCatalog interface {
+	// Message returns the text to send in place of text, for an error of
+	// code, such as "UNKNOWN_KEY" and "Client key is not known". Returning
+	// text sends it as it is.
+	Message(code, text string) string
+}
+
+// MessageCatalogFunc is a MessageCatalog of a function.
+type MessageCatalogFunc func(code, text string) string
+
+func (f MessageCatalogFunc) Message(code, text string) string {
+	return f(code, text)
+}
+
+// WithMessageCatalog replaces the text of the errors sent to clients with
+// catalog's. For clients that speak different languages, each handshake can
+// be given a catalog of its own, such as by the Accept-Language of the
+// request:
+//
+//	auth.Handshake(conn, wskeyauth.WithMessageCatalog(catalogs[lang]))
+func WithMessageCatalog(catalog MessageCatalog) Option {
+	return func(cfg *config) {
+		cfg.catalog = catalog
+	}
+}
+
+// textTypes are the messages whose data is text that a MessageCatalog
+// replaces, besides errorTypes.
+var textTypes = map[string]bool{
+	"RATE_LIMITED": true,
+	"FORBIDDEN":    true,
+	"TIMEOUT":      true,
+}
+
+// wrapCatalog returns conn, replacing the text of the errors written to it
+// with catalog's, if there is a catalog.
+func wrapCatalog(conn Conn, catalog MessageCatalog) Conn {
+	if catalog == nil {
+		return conn
+	}
+	return catalogConn{conn, catalog}
+}
+
+// catalogConn replaces the text of the errors written to it. Messages may be
+// shared, so they are copied rather than changed.
+type catalogConn struct {
+	Conn
+	catalog MessageCatalog
+}
+
+func (c catalogConn) WriteJSON(v any) error {
+	switch m := v.(type) {
+	case *errorMessage:
+		translated := *m
+		code := m.Data.Code
+		if code == "" {
+			code = m.Type
+		}
+		translated.Data.Message = c.catalog.Message(code, m.Data.Message)
+		v = &translated
+	case *stringMessage:
+		if errorTypes[m.Type] || textTypes[m.Type] {
+			v = &stringMessage{Type: m.Type, Data: c.catalog.Message(m.Type, m.Data), Retry: m.Retry}
+		}
+	case *retryAfterMessage:
+		translated := *m
+		translated.Data.Message = c.catalog.Message(m.Type, m.Data.Message)
+		v = &translated
+	}
+	return c.Conn.WriteJSON(v)
+}
@@ -0,0 +1,35 @@
+package wskeyauth_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+func TestMessageCatalog(t *testing.T) {
+	german := map[string]string{"INVALID_CLIENT_ID": "Ungültige Client-ID"}
+	catalog := wskeyauth.MessageCatalogFunc(func(code, text string) string {
+		if t, ok := german[code]; ok {
+			return t
+		}
+		return text
+	})
+
+	hs := wskeyauth.NewServerHandshake(wskeyauth.WithMessageCatalog(catalog))
+	hs.Start()
+	out, _, _ := hs.Feed(json.RawMessage(`{"type":"CLIENT_ID","data":"not a client ID"}`))
+
+	var msg struct {
+		Data struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(only(t, out).Data, &msg.Data); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Data.Code != "INVALID_CLIENT_ID" || msg.Data.Message != german["INVALID_CLIENT_ID"] {
+		t.Fatalf("got %s, want the code kept, and the message translated", out[0])
+	}
+}
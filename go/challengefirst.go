@@ -0,0 +1,27 @@
+package wskeyauth
+
+// Servers with WithChallengeFirst send the CHALLENGE as soon as the client
+// connects, before they know who it is, and the client responds with its
+// client ID and its response to the challenge in one message:
+//
+//	-> CHALLENGE
+//	<- {
+//		"type": "CLIENT_ID",
+//		"data": "<client ID>",
+//		"response": {"signature": "<base64 signature>", "hash": "SHA-256"}
+//	}
+//	-> SIGNATURE_MATCHES
+//
+// The response is what the data of a CHALLENGE_RESPONSE would have been. It
+// saves a round trip, but the server can't tailor the CHALLENGE to the
+// client, so it can't prove its identity with WithServerKey, and password
+// clients, whose CHALLENGE is their own, can't authenticate at all. Clients
+// must know to wait for the CHALLENGE, as it won't come otherwise.
+
+// WithChallengeFirst sends the CHALLENGE as soon as the handshake starts, and
+// expects the client ID and response together.
+func WithChallengeFirst() Option {
+	return func(cfg *config) {
+		cfg.challengeFirst = true
+	}
+}
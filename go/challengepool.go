@@ -0,0 +1,90 @@
+package wskeyauth
+
+import (
+	"sync"
+	"time"
+)
+
+// ChallengePool pre-generates challenge payloads in the background, so that
+// bursts of handshakes don't each wait on the system's random number
+// generator. When the pool runs dry, handshakes generate their own payload as
+// usual.
+type ChallengePool struct {
+	payloads chan [challengeByteLength]byte
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewChallengePool starts a worker that keeps up to size payloads ready. Call
+// Close to stop it.
+func NewChallengePool(size int) *ChallengePool {
+	p := &ChallengePool{
+		payloads: make(chan [challengeByteLength]byte, size),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.fill()
+	return p
+}
+
+func (p *ChallengePool) fill() {
+	defer close(p.done)
+
+	for {
+		var payload [challengeByteLength]byte
+		if err := readChallengePayload(payload[:]); err != nil {
+			// give the system a moment before trying again; handshakes fall back
+			// to generating payloads themselves in the meantime
+			select {
+			case <-time.After(100 * time.Millisecond):
+				continue
+			case <-p.stop:
+				return
+			}
+		}
+
+		select {
+		case p.payloads <- payload:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Depth returns the number of payloads currently ready.
+func (p *ChallengePool) Depth() int {
+	return len(p.payloads)
+}
+
+// Capacity returns the most payloads the pool keeps ready.
+func (p *ChallengePool) Capacity() int {
+	return cap(p.payloads)
+}
+
+// Close stops the worker. Handshakes may keep using the pool, but will
+// generate their own payloads once it is drained.
+func (p *ChallengePool) Close() {
+	p.stopOnce.Do(func() { close(p.stop) })
+	<-p.done
+}
+
+// take copies a ready payload into b, returning false if there was none.
+func (p *ChallengePool) take(b []byte) bool {
+	select {
+	case payload := <-p.payloads:
+		copy(b, payload[:])
+		return true
+	default:
+		return false
+	}
+}
+
+// WithChallengePool takes challenge payloads from pool, rather than
+// generating them during the handshake.
+func WithChallengePool(pool *ChallengePool) Option {
+	return func(cfg *config) {
+		cfg.challengePool = pool
+	}
+}
@@ -0,0 +1,236 @@
+package wskeyauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// Once authenticated, clients join and leave named channels, such as the
+// rooms of a signaling server, by sending
+//
+//	{"type": "JOIN", "data": "room"}
+//	{"type": "LEAVE", "data": "room"}
+//
+// to which servers handling them with Channels respond with
+//
+//	{"type": "JOINED", "data": "room"}
+//	{"type": "LEFT", "data": "room"}
+//
+// or, if the client may not join the channel, with
+//
+//	{"type": "JOIN_DENIED", "data": "room"}
+//
+// Clients that re-authenticate as one that may not be in a channel they
+// joined are sent a LEFT of it.
+
+// ErrJoinDenied is returned by Channels.Join when the client may not join the
+// channel.
+var ErrJoinDenied = errors.New("wskeyauth: join denied")
+
+// Channels tracks which of a registry's sessions are in which channels, and
+// lets clients join them as an authorize function allows. Sessions that leave the
+// registry leave their channels with it, and sessions that re-authenticate
+// leave those the authorize function doesn't let them stay in under their new
+// identity.
+type Channels struct {
+	registry  *Registry
+	authorize func(ctx context.Context, s *Session, channel string) error
+
+	mu        sync.RWMutex
+	members   map[string]map[*Session]struct{}
+	bySession map[*Session]map[string]struct{}
+}
+
+// NewChannels creates channels for the sessions of registry. authorize is
+// called for every join, with the session that would join, and denies it by
+// returning an error; if it is nil, any session may join any channel.
+func NewChannels(registry *Registry, authorize func(ctx context.Context, s *Session, channel string) error) *Channels {
+	c := &Channels{
+		registry:  registry,
+		authorize: authorize,
+		members:   map[string]map[*Session]struct{}{},
+		bySession: map[*Session]map[string]struct{}{},
+	}
+	registry.Subscribe(func(e RegistryEvent) {
+		switch e.Type {
+		case SessionLeft:
+			c.leaveAll(e.Session)
+		case SessionReidentified:
+			c.reauthorize(e.Session)
+		}
+	})
+	return c
+}
+
+// HandleSession handles msg, a message the session's client sent once
+// authenticated, if it is a JOIN or a LEAVE. It reports whether it was; the
+// application should handle any other message itself, as with
+// Refresh.HandleSession. A join that was denied is reported with
+// ErrJoinDenied, which needn't end the session.
+func (c *Channels) HandleSession(ctx context.Context, s *Session, msg TypeData) (bool, error) {
+	if msg.Type != "JOIN" && msg.Type != "LEAVE" {
+		return false, nil
+	}
+
+	var channel string
+	if err := json.Unmarshal(msg.Data, &channel); err != nil || channel == "" {
+		s.Send(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Expected "+msg.Type+" to carry the name of a channel", err))
+		return true, err
+	}
+
+	if msg.Type == "LEAVE" {
+		c.Leave(s, channel)
+		return true, s.Send(&stringMessage{Type: "LEFT", Data: channel})
+	}
+
+	if err := c.Join(ctx, s, channel); err != nil {
+		s.Send(&stringMessage{Type: "JOIN_DENIED", Data: channel})
+		return true, err
+	}
+	return true, s.Send(&stringMessage{Type: "JOINED", Data: channel})
+}
+
+// Join adds s to channel, if the authorize function allows it, emitting a
+// ChannelJoined event from the registry. Joining a channel s is in already
+// is a no-op, and sessions that left the registry can't join.
+func (c *Channels) Join(ctx context.Context, s *Session, channel string) error {
+	if c.authorize != nil {
+		if err := c.authorize(ctx, s, channel); err != nil {
+			return errors.Join(ErrJoinDenied, err)
+		}
+	}
+
+	c.mu.Lock()
+	// checked under c.mu, so that a session leaving the registry either
+	// isn't let in, or is removed again by leaveAll
+	if !c.registry.registered(s) {
+		c.mu.Unlock()
+		return ErrNotConnected
+	}
+	members, ok := c.members[channel]
+	if !ok {
+		members = map[*Session]struct{}{}
+		c.members[channel] = members
+	}
+	_, joined := members[s]
+	members[s] = struct{}{}
+
+	channels, ok := c.bySession[s]
+	if !ok {
+		channels = map[string]struct{}{}
+		c.bySession[s] = channels
+	}
+	channels[channel] = struct{}{}
+	c.mu.Unlock()
+
+	if !joined {
+		c.registry.emit(RegistryEvent{Type: ChannelJoined, Session: s, Channel: channel})
+	}
+	return nil
+}
+
+// Leave removes s from channel, emitting a ChannelLeft event from the
+// registry. Leaving a channel s isn't in is a no-op.
+func (c *Channels) Leave(s *Session, channel string) {
+	c.mu.Lock()
+	left := c.remove(s, channel)
+	c.mu.Unlock()
+
+	if left {
+		c.registry.emit(RegistryEvent{Type: ChannelLeft, Session: s, Channel: channel})
+	}
+}
+
+// leaveAll removes s from every channel it is in.
+func (c *Channels) leaveAll(s *Session) {
+	c.mu.Lock()
+	var left []string
+	for channel := range c.bySession[s] {
+		if c.remove(s, channel) {
+			left = append(left, channel)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, channel := range left {
+		c.registry.emit(RegistryEvent{Type: ChannelLeft, Session: s, Channel: channel})
+	}
+}
+
+// reauthorize runs the authorize function again for every channel s is in,
+// once it took on a new identity, and removes it from those it may no longer
+// be in, telling its client with a LEFT.
+func (c *Channels) reauthorize(s *Session) {
+	if c.authorize == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	for _, channel := range c.Joined(s) {
+		if c.authorize(ctx, s, channel) == nil {
+			continue
+		}
+		c.mu.Lock()
+		left := c.remove(s, channel)
+		c.mu.Unlock()
+
+		if left {
+			c.registry.emit(RegistryEvent{Type: ChannelLeft, Session: s, Channel: channel})
+			s.Send(&stringMessage{Type: "LEFT", Data: channel})
+		}
+	}
+}
+
+// remove removes s from channel, and reports whether it was in it. c.mu must
+// be held.
+func (c *Channels) remove(s *Session, channel string) bool {
+	members := c.members[channel]
+	if _, ok := members[s]; !ok {
+		return false
+	}
+
+	delete(members, s)
+	if len(members) == 0 {
+		delete(c.members, channel)
+	}
+	delete(c.bySession[s], channel)
+	if len(c.bySession[s]) == 0 {
+		delete(c.bySession, s)
+	}
+	return true
+}
+
+// Members returns the sessions in channel.
+func (c *Channels) Members(channel string) []*Session {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	members := c.members[channel]
+	result := make([]*Session, 0, len(members))
+	for s := range members {
+		result = append(result, s)
+	}
+	return result
+}
+
+// Joined returns the channels s is in.
+func (c *Channels) Joined(s *Session) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	channels := c.bySession[s]
+	result := make([]string, 0, len(channels))
+	for channel := range channels {
+		result = append(result, channel)
+	}
+	return result
+}
+
+// Broadcast sends message to every session in channel, as Registry.Broadcast
+// does to every registered session.
+func (c *Channels) Broadcast(channel string, message any) error {
+	return send(c.Members(channel), message)
+}
@@ -0,0 +1,44 @@
+package wskeyauthclient
+
+import (
+	"errors"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// handshakeChallengeFirst authenticates over conn with a server that
+// challenges the client before it knows who it is.
+func (c *Client) handshakeChallengeFirst(conn wskeyauth.Conn) ([]byte, error) {
+	if c.password != nil {
+		return nil, errors.New("wskeyauthclient: password clients can't authenticate to servers that challenge first")
+	}
+	if c.ServerID != "" {
+		return nil, errors.New("wskeyauthclient: servers that challenge first can't prove their identity")
+	}
+
+	var msg challengeMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		return nil, err
+	}
+	if msg.Type != "CHALLENGE" {
+		return nil, rejected(msg.TypeData)
+	}
+
+	response, binding, err := c.respond(&msg, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = conn.WriteJSON(map[string]any{
+		"type":     "CLIENT_ID",
+		"data":     c.clientID,
+		"response": response,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.result(conn); err != nil {
+		return nil, err
+	}
+	return binding, nil
+}
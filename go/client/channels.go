@@ -0,0 +1,36 @@
+package wskeyauthclient
+
+import (
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// Join asks to join channel over conn, once authenticated, as servers
+// handling JOIN with wskeyauth.Channels do. As with Refresh, it reads the
+// server's response, so it must not be called while anything else reads from
+// conn.
+//
+// A join that was denied is reported as a *RejectedError of type
+// JOIN_DENIED.
+func (c *Client) Join(conn wskeyauth.Conn, channel string) error {
+	return c.channel(conn, "JOIN", "JOINED", channel)
+}
+
+// Leave leaves channel over conn, as Join joins it.
+func (c *Client) Leave(conn wskeyauth.Conn, channel string) error {
+	return c.channel(conn, "LEAVE", "LEFT", channel)
+}
+
+func (c *Client) channel(conn wskeyauth.Conn, typ, reply, channel string) error {
+	if err := conn.WriteJSON(map[string]string{"type": typ, "data": channel}); err != nil {
+		return err
+	}
+
+	var td wskeyauth.TypeData
+	if err := conn.ReadJSON(&td); err != nil {
+		return err
+	}
+	if td.Type != reply {
+		return rejected(td)
+	}
+	return nil
+}
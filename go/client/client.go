@@ -0,0 +1,737 @@
+// Package wskeyauthclient authenticates to ws-key-auth servers from Go.
+//
+// Keys are used through crypto.Signer, so that they can live in an HSM, a
+// TPM or a cloud KMS, and never be exported:
+//
+//	client, err := wskeyauthclient.New(signer)
+//	if err != nil {
+//		return err
+//	}
+//	conn, err := client.Dial(ctx, "wss://example.com/ws", nil)
+//
+// Compiled to WebAssembly, for GOOS=js, NewWebCryptoSigner signs with
+// WebCrypto key pairs, and Client.DialWebSocket dials with the browser's
+// WebSocket. Command wskeyauth-wasm exposes them to JavaScript.
+package wskeyauthclient
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/internal/base58"
+)
+
+// Client authenticates with a key.
+type Client struct {
+	signer   crypto.Signer
+	secret   []byte
+	password *password
+	clientID string
+
+	destroyed bool
+
+	// last is shared by the copies of the client Dial makes, so that
+	// NewMessageSigner finds the handshakes they complete.
+	last *lastHandshake
+
+	// Audience is the server the client believes it is connected to, such as
+	// "wss://example.com", for servers that bind signatures to an audience
+	// with wskeyauth.WithAudience. Dial sets it from the URL it dials.
+	Audience string
+
+	// ServerID, if set, is the server ID the server must prove it holds the
+	// key to, as given by wskeyauth.ServerKey.ID. The handshake is abandoned
+	// before the client signs anything if it doesn't.
+	ServerID string
+
+	// IDToken, if set, is called for an OIDC ID token to present with the
+	// client's key, for servers using wskeyauth.WithOIDC to link the key to
+	// the account it identifies. The token must carry nonce, which binds it
+	// to the handshake, in its "nonce" claim; ask the identity provider for
+	// one with it.
+	IDToken func(nonce string) (string, error)
+
+	// SecondFactor, if set, is called for a one-time code when the server
+	// asks for one, as servers using wskeyauth.WithTOTP do.
+	SecondFactor func() (string, error)
+
+	// OnAccessToken, if set, is called with the access token the server
+	// hands out on authenticating the client, if it does, as servers using
+	// wskeyauth.WithTokenExchange do.
+	OnAccessToken func(*wskeyauth.AccessToken)
+
+	// OnMacaroon, if set, is called with the macaroon the server hands out on
+	// authenticating the client, if it does, as servers using
+	// wskeyauth.WithMacaroons do.
+	OnMacaroon func(macaroon string)
+
+	// OnTURN, if set, is called with the TURN credentials the server hands
+	// out on authenticating the client, if it does, as servers using
+	// wskeyauth.WithTURN do.
+	OnTURN func(*wskeyauth.TURNCredentials)
+
+	// SignTimestamp, if set, signs the time along with the client ID, for
+	// servers using wskeyauth.WithSignedTimestamps to authenticate the client
+	// in a single round trip, without a challenge. Servers that don't, or
+	// don't trust the time, challenge the client as usual. It has no effect
+	// for clients that have the server prove its identity, or present an ID
+	// token, which both need the challenge.
+	SignTimestamp bool
+
+	// OnGuest, if set, is called with the scopes the client was granted when
+	// the server let it in as a guest, as servers using wskeyauth.WithGuests
+	// do for clients whose keys they don't know.
+	OnGuest func(scopes []string)
+
+	// ChallengeFirst, if set, waits for the server to send the CHALLENGE
+	// before the client sends anything, and responds with the client ID and
+	// the signature together, as servers using wskeyauth.WithChallengeFirst
+	// expect.
+	ChallengeFirst bool
+
+	// Encoding, if set, encodes the handshake's messages for the wire, for
+	// servers using wskeyauth.WithEncoding with the same encoding. The
+	// connection must then be a wskeyauth.MessageConn, as gorilla/websocket's
+	// is.
+	Encoding wskeyauth.Encoding
+
+	// EchoChallenge, if set, sends the challenge back with the response, for
+	// servers using wskeyauth.WithStatelessChallenges behind a load balancer,
+	// which may have the response reach another server than the one that
+	// sent the challenge.
+	EchoChallenge bool
+
+	// MaxProofOfWork is the highest difficulty of proof of work the client
+	// does, when servers using wskeyauth.WithProofOfWork ask for one, so that
+	// a server can't have it work forever. It defaults to
+	// DefaultMaxProofOfWork.
+	MaxProofOfWork int
+
+	// Retry, if set, is how Dial retries after failing to connect or
+	// authenticate.
+	Retry *RetryPolicy
+
+	// Rand is the source of randomness for signing. It defaults to
+	// crypto/rand.
+	Rand io.Reader
+}
+
+// DefaultMaxProofOfWork is the highest difficulty of proof of work a client
+// does unless Client.MaxProofOfWork says otherwise. It takes some 16 million
+// hashes, in the order of seconds.
+const DefaultMaxProofOfWork = 24
+
+// New creates a client that authenticates with signer, which must hold a
+// P-256 ECDSA or an Ed25519 key. P-256 keys are identified by client IDs in
+// the format the browser client uses, and Ed25519 keys by did:keys.
+func New(signer crypto.Signer) (*Client, error) {
+	raw, err := rawKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &Client{signer: signer, clientID: rawClientID(raw), last: &lastHandshake{}}, nil
+}
+
+// rawClientID returns the client ID of raw, a key as rawKey returns it.
+func rawClientID(raw []byte) string {
+	if len(raw) == ed25519.PublicKeySize {
+		// multicodec ed25519-pub, followed by the key
+		return "did:key:z" + base58.Encode(append([]byte{0xed, 0x01}, raw...))
+	}
+	return "WebCrypto-raw.EC.P-256$" + base64.StdEncoding.EncodeToString(raw)
+}
+
+// rawKey returns key as a P-256 or Ed25519 key of raw bytes, as WebCrypto
+// and delegated client IDs hold them.
+func rawKey(key crypto.PublicKey) ([]byte, error) {
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, errors.New("wskeyauthclient: ECDSA keys must be on P-256")
+		}
+		raw := make([]byte, 65)
+		raw[0] = 4
+		pub.X.FillBytes(raw[1:33])
+		pub.Y.FillBytes(raw[33:])
+		return raw, nil
+	case ed25519.PublicKey:
+		if wskeyauth.FIPS() {
+			return nil, fmt.Errorf("%w: Ed25519 keys", wskeyauth.ErrNotApproved)
+		}
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("wskeyauthclient: unsupported key type %T", pub)
+	}
+}
+
+// NewHMAC creates a client that authenticates with a secret it shares with
+// the server, under keyID, rather than with a key pair.
+func NewHMAC(keyID string, secret []byte) *Client {
+	return &Client{secret: secret, clientID: "HMAC-SHA-256$" + keyID, last: &lastHandshake{}}
+}
+
+// SetNamespace puts the client's client ID in namespace, for servers using
+// wskeyauth.WithNamespace. Ed25519 keys, identified by did:keys, can't be in
+// a namespace.
+func (c *Client) SetNamespace(namespace string) error {
+	clientID, err := wskeyauth.NamespaceClientID(c.clientID, namespace)
+	if err != nil {
+		return err
+	}
+	c.clientID = clientID
+	return nil
+}
+
+// ClientID returns the client ID the client authenticates as.
+func (c *Client) ClientID() string {
+	return c.clientID
+}
+
+// ErrDestroyed is returned by the handshakes of a client after Destroy.
+var ErrDestroyed = errors.New("wskeyauthclient: client was destroyed")
+
+// Destroy zeroes the client's private key, or its shared secret, once it is
+// no longer needed, for deployments with strict memory hygiene requirements.
+// The client's handshakes fail with ErrDestroyed from then on.
+//
+// Only keys in memory the client can get at are zeroed, which are
+// *ecdsa.PrivateKey and ed25519.PrivateKey ones. Keys in hardware or in
+// WebCrypto stay where they are, as do copies crypto/ecdsa keeps of keys it
+// signed with, and the passwords of NewSRP clients, as strings can't be
+// zeroed.
+func (c *Client) Destroy() {
+	switch key := c.signer.(type) {
+	case *ecdsa.PrivateKey:
+		if key.D != nil {
+			clear(key.D.Bits())
+			key.D.SetInt64(0)
+		}
+	case ed25519.PrivateKey:
+		clear(key)
+	}
+	clear(c.secret)
+	c.destroyed = true
+}
+
+// RejectedError is returned when the server didn't authenticate the client.
+type RejectedError struct {
+	// Type is the message the server ended the handshake with, such as
+	// SIGNATURE_MISMATCH or TIMEOUT.
+	Type string
+
+	// Code is the code of the error, such as UNKNOWN_KEY, if it has one;
+	// see wskeyauth.FailureReason.Code. Unlike Message, it isn't
+	// translated, nor left out by servers with wskeyauth.TerseErrors.
+	Code string
+
+	// Message is the server's explanation, if it gave one.
+	Message string
+}
+
+func (e *RejectedError) Error() string {
+	if e.Message == "" {
+		return "wskeyauthclient: server responded with " + e.Type
+	}
+	return "wskeyauthclient: server responded with " + e.Type + ": " + e.Message
+}
+
+// Handshake authenticates over conn, which must be freshly connected.
+func (c *Client) Handshake(conn wskeyauth.Conn) error {
+	if c.destroyed {
+		return ErrDestroyed
+	}
+
+	binding, err := c.handshake(conn)
+	if err != nil {
+		return err
+	}
+	c.last.mu.Lock()
+	c.last.conn, c.last.binding = conn, binding
+	c.last.mu.Unlock()
+	return nil
+}
+
+// lastHandshake is the connection a client last completed a handshake over,
+// and the binding of the messages it signs over it.
+type lastHandshake struct {
+	mu      sync.Mutex
+	conn    wskeyauth.Conn
+	binding []byte
+}
+
+// handshake authenticates over conn, and returns the binding of the messages
+// the client signs over it, if the server challenged it.
+func (c *Client) handshake(conn wskeyauth.Conn) ([]byte, error) {
+	conn, err := c.encode(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ChallengeFirst {
+		return c.handshakeChallengeFirst(conn)
+	}
+
+	hello := map[string]any{"type": "CLIENT_ID", "data": c.clientID}
+
+	var clientChallenge []byte
+	if c.ServerID != "" {
+		clientChallenge = make([]byte, 32)
+		if _, err := io.ReadFull(c.random(), clientChallenge); err != nil {
+			return nil, err
+		}
+		hello["challenge"] = base64.StdEncoding.EncodeToString(clientChallenge)
+	}
+
+	if c.SignTimestamp && c.signer != nil && c.ServerID == "" && c.IDToken == nil {
+		now := time.Now().UnixMilli()
+		signature, err := c.sign(wskeyauth.TimestampSigningInput(now, wskeyauth.SignedAudience(c.Audience, c.clientID)))
+		if err != nil {
+			return nil, err
+		}
+		hello["timestamp"] = &wskeyauth.SignedTimestamp{Time: now, Signature: base64.StdEncoding.EncodeToString(signature)}
+	}
+
+	if err := conn.WriteJSON(hello); err != nil {
+		return nil, err
+	}
+
+	var msg challengeMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		return nil, err
+	}
+	if msg.Type == "SIGNATURE_MATCHES" || msg.Type == "SECOND_FACTOR_REQUIRED" {
+		// the server took our signed timestamp, or our TLS client
+		// certificate, for a signature
+		if c.ServerID != "" {
+			return nil, errors.New("wskeyauthclient: server skipped the challenge, so it didn't prove its identity")
+		}
+		_, err := c.finish(conn, &msg.resultMessage)
+		return nil, err
+	}
+	if msg.Type != "CHALLENGE" {
+		return nil, rejected(msg.TypeData)
+	}
+	if c.password != nil {
+		return nil, c.handshakeSRP(conn, msg.Data)
+	}
+
+	response, binding, err := c.respond(&msg, clientChallenge)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.WriteJSON(map[string]any{"type": "CHALLENGE_RESPONSE", "data": response}); err != nil {
+		return nil, err
+	}
+
+	if _, err := c.result(conn); err != nil {
+		return nil, err
+	}
+	return binding, nil
+}
+
+// encode returns conn in the client's Encoding, if it has one.
+func (c *Client) encode(conn wskeyauth.Conn) (wskeyauth.Conn, error) {
+	if c.Encoding == nil {
+		return conn, nil
+	}
+	mc, ok := conn.(wskeyauth.MessageConn)
+	if !ok {
+		return nil, errors.New("wskeyauthclient: Encoding needs a connection with ReadMessage and WriteMessage methods")
+	}
+	return wskeyauth.NewEncodedConn(mc, c.Encoding), nil
+}
+
+// respond returns the data of the CHALLENGE_RESPONSE to msg, checking the
+// server's proof of its identity against clientChallenge, if the client
+// asked for one, and the binding of the messages signed after it.
+func (c *Client) respond(msg *challengeMessage, clientChallenge []byte) (map[string]string, []byte, error) {
+	challenge, issued, err := c.challenge(msg.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer clear(challenge)
+	if bytes.HasPrefix(challenge, []byte(wskeyauth.MessageSigningPrefix)) {
+		return nil, nil, errors.New("wskeyauthclient: server sent a challenge that passes for a signed message")
+	}
+	if c.ServerID != "" {
+		if err := c.verifyServer(msg.Server, clientChallenge, challenge); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := c.checkCapabilities(msg.Capabilities); err != nil {
+		return nil, nil, err
+	}
+
+	var work string
+	if msg.ProofOfWork != nil {
+		if work, err = c.work(challenge, msg.ProofOfWork.Difficulty); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	signature, err := c.sign(challenge)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := c.response(map[string]string{
+		"signature": base64.StdEncoding.EncodeToString(signature),
+		"hash":      "SHA-256",
+	}, challenge)
+	clear(signature)
+	if err != nil {
+		return nil, nil, err
+	}
+	if work != "" {
+		data["proofOfWork"] = work
+	}
+	if c.EchoChallenge {
+		data["challenge"] = base64.StdEncoding.EncodeToString(challenge[:issued])
+	}
+	return data, wskeyauth.MessageBinding(challenge), nil
+}
+
+// work does the proof of work the server asked for on challenge.
+func (c *Client) work(challenge []byte, difficulty int) (string, error) {
+	max := c.MaxProofOfWork
+	if max <= 0 {
+		max = DefaultMaxProofOfWork
+	}
+	if difficulty > max {
+		return "", fmt.Errorf("wskeyauthclient: server asked for a proof of work of difficulty %d, but the most the client does is %d", difficulty, max)
+	}
+	return wskeyauth.SolveProofOfWork(challenge, difficulty), nil
+}
+
+// response completes the data of a CHALLENGE_RESPONSE to challenge, what the
+// client proves it holds its key with.
+func (c *Client) response(data map[string]string, challenge []byte) (map[string]string, error) {
+	if c.IDToken == nil {
+		return data, nil
+	}
+	fingerprint, err := wskeyauth.Fingerprint(c.clientID)
+	if err != nil {
+		return nil, err
+	}
+	token, err := c.IDToken(wskeyauth.OIDCNonce(challenge, fingerprint))
+	if err != nil {
+		return nil, fmt.Errorf("wskeyauthclient: failed to get ID token: %w", err)
+	}
+	data["idToken"] = token
+	return data, nil
+}
+
+// result reads the outcome of the handshake, once the client has responded
+// to the CHALLENGE, giving a second factor first if the server asks for one.
+// It returns the data of SIGNATURE_MATCHES.
+func (c *Client) result(conn wskeyauth.Conn) (json.RawMessage, error) {
+	var td resultMessage
+	if err := conn.ReadJSON(&td); err != nil {
+		return nil, err
+	}
+	return c.finish(conn, &td)
+}
+
+// finish completes the handshake from td, the first message the server sent
+// after the client proved itself.
+func (c *Client) finish(conn wskeyauth.Conn, td *resultMessage) (json.RawMessage, error) {
+	if td.Type == "SECOND_FACTOR_REQUIRED" {
+		if c.SecondFactor == nil {
+			return nil, errors.New("wskeyauthclient: server asked for a second factor, but SecondFactor isn't set")
+		}
+		code, err := c.SecondFactor()
+		if err != nil {
+			return nil, err
+		}
+		if err := conn.WriteJSON(map[string]string{"type": "SECOND_FACTOR", "data": code}); err != nil {
+			return nil, err
+		}
+		td = &resultMessage{}
+		if err := conn.ReadJSON(td); err != nil {
+			return nil, err
+		}
+	}
+
+	if td.Type != "SIGNATURE_MATCHES" {
+		return nil, rejected(td.TypeData)
+	}
+	c.credentials(td)
+	return td.Data, nil
+}
+
+// credentials passes on the credentials td came with.
+func (c *Client) credentials(td *resultMessage) {
+	if td.Token != nil && c.OnAccessToken != nil {
+		c.OnAccessToken(td.Token)
+	}
+	if td.Macaroon != "" && c.OnMacaroon != nil {
+		c.OnMacaroon(td.Macaroon)
+	}
+	if td.TURN != nil && c.OnTURN != nil {
+		c.OnTURN(td.TURN)
+	}
+	if td.Guest && c.OnGuest != nil {
+		c.OnGuest(td.Scopes)
+	}
+}
+
+// resultMessage is the message the server ends the handshake with, and the
+// credentials it came with, if any, and whether the client is a guest.
+type resultMessage struct {
+	wskeyauth.TypeData
+	Token    *wskeyauth.AccessToken     `json:"token"`
+	Macaroon string                     `json:"macaroon"`
+	TURN     *wskeyauth.TURNCredentials `json:"turn"`
+	Guest    bool                       `json:"guest"`
+	Scopes   []string                   `json:"scopes"`
+}
+
+// challengeMessage is a CHALLENGE, with the server's proof of its identity, if
+// it sent one. It may be SIGNATURE_MATCHES instead, and come with all a
+// resultMessage does, for clients that authenticated with a TLS client
+// certificate or a signed timestamp.
+type challengeMessage struct {
+	resultMessage
+	Capabilities *wskeyauth.Capabilities `json:"capabilities"`
+	Server       *serverProof            `json:"server"`
+	ProofOfWork  *struct {
+		Difficulty int `json:"difficulty"`
+	} `json:"proofOfWork"`
+}
+
+type serverProof struct {
+	ID        string `json:"id"`
+	Signature string `json:"signature"`
+}
+
+// challenge returns what to sign for the data of a CHALLENGE, and the length
+// of the challenge the server sent, which it starts with.
+func (c *Client) challenge(data json.RawMessage) ([]byte, int, error) {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err == nil {
+		challenge, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, 0, err
+		}
+		return append(challenge, wskeyauth.SignedAudience("", c.clientID)...), len(challenge), nil
+	}
+
+	var structured struct {
+		Challenge string `json:"challenge"`
+		Audience  string `json:"audience"`
+	}
+	if err := json.Unmarshal(data, &structured); err != nil {
+		return nil, 0, fmt.Errorf("wskeyauthclient: failed to parse CHALLENGE: %w", err)
+	}
+
+	// sign for the server we know we're talking to, rather than the one it
+	// claims to be, so that our signature is of no use to anyone else
+	if structured.Audience != c.Audience {
+		return nil, 0, fmt.Errorf("wskeyauthclient: server asked us to sign for %q, but we are connected to %q", structured.Audience, c.Audience)
+	}
+
+	challenge, err := base64.StdEncoding.DecodeString(structured.Challenge)
+	if err != nil {
+		return nil, 0, err
+	}
+	return append(challenge, wskeyauth.SignedAudience(c.Audience, c.clientID)...), len(challenge), nil
+}
+
+// checkCapabilities checks that the server accepts the SHA-256 signatures
+// the client makes, unless it signs with Ed25519, which hashes on its own, so
+// that it gives up before signing anything the server would turn down.
+func (c *Client) checkCapabilities(caps *wskeyauth.Capabilities) error {
+	if caps == nil || caps.Hashes == nil {
+		return nil
+	}
+	if c.signer != nil {
+		if _, ok := c.signer.Public().(ed25519.PublicKey); ok {
+			return nil
+		}
+	}
+	if !slices.Contains(caps.Hashes, "SHA-256") {
+		return &UnsupportedAlgorithmError{Algorithm: "SHA-256", Supported: caps.Hashes}
+	}
+	return nil
+}
+
+// UnsupportedAlgorithmError is returned when the server advertises that it
+// doesn't accept the hash the client signs with. Servers that don't accept
+// the client's key turn it away with UNSUPPORTED_ALGORITHM, which is a
+// RejectedError.
+type UnsupportedAlgorithmError struct {
+	Algorithm string
+
+	// Supported are the algorithms the server advertised instead.
+	Supported []string
+}
+
+func (e *UnsupportedAlgorithmError) Error() string {
+	if len(e.Supported) == 0 {
+		return "wskeyauthclient: server doesn't accept " + e.Algorithm
+	}
+	return "wskeyauthclient: server doesn't accept " + e.Algorithm + ", only " + strings.Join(e.Supported, ", ")
+}
+
+// verifyServer checks that the server signed our challenge, followed by
+// signed, which is its challenge and audience, with the key we expect.
+func (c *Client) verifyServer(server *serverProof, clientChallenge, signed []byte) error {
+	if server == nil {
+		return errors.New("wskeyauthclient: server didn't prove its identity")
+	}
+	if server.ID != c.ServerID {
+		return fmt.Errorf("wskeyauthclient: expected server %q, but got %q", c.ServerID, server.ID)
+	}
+
+	pub, err := wskeyauth.ParseServerID(server.ID)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(server.Signature)
+	if err != nil {
+		return fmt.Errorf("wskeyauthclient: failed to decode server signature: %w", err)
+	}
+
+	message := append(append([]byte{}, clientChallenge...), signed...)
+	var ok bool
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(pub, message, sig)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		ok = len(sig) == 64 && ecdsa.Verify(pub, digest[:],
+			new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+	}
+	if !ok {
+		return errors.New("wskeyauthclient: server signature doesn't match its ID")
+	}
+	return nil
+}
+
+func (c *Client) random() io.Reader {
+	if c.Rand == nil {
+		return rand.Reader
+	}
+	return c.Rand
+}
+
+// sign signs challenge as the browser client would: for ECDSA, the
+// concatenation of r and s over its SHA-256 hash. With a secret, it is the
+// HMAC of the challenge instead.
+func (c *Client) sign(challenge []byte) ([]byte, error) {
+	if c.destroyed {
+		return nil, ErrDestroyed
+	}
+	if c.secret != nil {
+		mac := hmac.New(sha256.New, c.secret)
+		mac.Write(challenge)
+		return mac.Sum(nil), nil
+	}
+	if s, ok := c.signer.(messageSigner); ok {
+		return s.signMessage(challenge)
+	}
+
+	if _, ok := c.signer.Public().(ed25519.PublicKey); ok {
+		return c.signer.Sign(c.random(), challenge, crypto.Hash(0))
+	}
+
+	digest := sha256.Sum256(challenge)
+	der, err := c.signer.Sign(c.random(), digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	var sig struct{ R, S *big.Int }
+	if rest, err := asn1.Unmarshal(der, &sig); err != nil || len(rest) > 0 {
+		return nil, errors.New("wskeyauthclient: signer returned a malformed ECDSA signature")
+	}
+	clear(der)
+	raw := make([]byte, 64)
+	sig.R.FillBytes(raw[:32])
+	sig.S.FillBytes(raw[32:])
+	return raw, nil
+}
+
+// messageSigner is a crypto.Signer that signs messages itself, rather than
+// their digests, as WebCrypto keys do. Its signatures are those sign makes.
+type messageSigner interface {
+	signMessage(message []byte) ([]byte, error)
+}
+
+// PairingPendingError is returned when the server doesn't know the client's
+// key yet, and opened a pairing request for it, as servers using
+// wskeyauth.WithPairing do. Show Code to someone who can approve it, and
+// handshake again once they did.
+type PairingPendingError struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (e *PairingPendingError) Error() string {
+	return "wskeyauthclient: pairing pending with code " + e.Code
+}
+
+// RetryAfterError is returned when the server locked the client out, after
+// too many of its handshakes failed, as servers using wskeyauth.WithBans do.
+// Handshakes before RetryAfter has passed are turned away too.
+type RetryAfterError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return "wskeyauthclient: server asked to retry after " + e.RetryAfter.String() + ": " + e.Message
+}
+
+func rejected(td wskeyauth.TypeData) error {
+	if td.Type == "PAIRING_PENDING" {
+		var pending PairingPendingError
+		if json.Unmarshal(td.Data, &pending) == nil {
+			return &pending
+		}
+	}
+	if td.Type == "RETRY_AFTER" {
+		var retry struct {
+			Message    string `json:"message"`
+			RetryAfter int64  `json:"retryAfter"`
+		}
+		if json.Unmarshal(td.Data, &retry) == nil {
+			return &RetryAfterError{Message: retry.Message, RetryAfter: time.Duration(retry.RetryAfter) * time.Second}
+		}
+	}
+
+	err := &RejectedError{Type: td.Type}
+
+	var message string
+	var structured struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	if json.Unmarshal(td.Data, &message) == nil {
+		err.Message = message
+	} else if json.Unmarshal(td.Data, &structured) == nil {
+		err.Code, err.Message = structured.Code, structured.Message
+		if structured.Error != "" {
+			err.Message += ": " + structured.Error
+		}
+	}
+	return err
+}
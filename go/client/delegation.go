@@ -0,0 +1,71 @@
+package wskeyauthclient
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// Delegate vouches for device, a P-256 or Ed25519 public key, with the
+// client's key as the root, returning the delegated client ID for the device
+// to authenticate as with NewDelegated. It expires at expires, or never if
+// expires is zero. Servers accept it with wskeyauth.WithDelegation, given the
+// root's ClientID.
+//
+// Delegating needs no connection to the server, so that devices can be
+// provisioned offline.
+func (c *Client) Delegate(device crypto.PublicKey, expires time.Time) (string, error) {
+	if c.signer == nil {
+		return "", errors.New("wskeyauthclient: only clients with a key pair can delegate")
+	}
+	key, err := rawKey(device)
+	if err != nil {
+		return "", err
+	}
+
+	sig, err := c.sign(wskeyauth.DelegationSigningInput(key, expires))
+	if err != nil {
+		return "", err
+	}
+
+	var expiry int64
+	if !expires.IsZero() {
+		expiry = expires.Unix()
+	}
+	return "Delegated$" + base64.StdEncoding.EncodeToString(key) + "," +
+		strconv.FormatInt(expiry, 10) + "," + base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// NewDelegated creates a client that authenticates with signer as clientID,
+// a delegated client ID made by Delegate for signer's key.
+func NewDelegated(signer crypto.Signer, clientID string) (*Client, error) {
+	c, err := New(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, _, ok := strings.Cut(strings.TrimPrefix(clientID, "Delegated$"), ",")
+	if !ok || !strings.HasPrefix(clientID, "Delegated$") {
+		return nil, errors.New("wskeyauthclient: not a delegated client ID")
+	}
+	delegated, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("wskeyauthclient: not a delegated client ID")
+	}
+	key, err := rawKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(key, delegated) {
+		return nil, errors.New("wskeyauthclient: delegated client ID is for another key")
+	}
+
+	c.clientID = clientID
+	return c, nil
+}
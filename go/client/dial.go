@@ -0,0 +1,60 @@
+package wskeyauthclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Dial connects to the server at rawURL, and authenticates. The handshake is
+// bounded by ctx's deadline, if it has one. The audience the client signs for
+// is the URL's scheme and host, unless Audience is set.
+//
+// If Retry is set, failed attempts are retried as it says, all of them
+// bounded by ctx's deadline together.
+func (c *Client) Dial(ctx context.Context, rawURL string, header http.Header) (*websocket.Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Retry == nil {
+		return c.dial(ctx, u, rawURL, header)
+	}
+	var conn *websocket.Conn
+	err = c.Retry.Do(ctx, func(ctx context.Context) error {
+		var err error
+		conn, err = c.dial(ctx, u, rawURL, header)
+		return err
+	})
+	return conn, err
+}
+
+func (c *Client) dial(ctx context.Context, u *url.URL, rawURL string, header http.Header) (*websocket.Conn, error) {
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, rawURL, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(deadline)
+		conn.SetWriteDeadline(deadline)
+	}
+
+	client := *c
+	if client.Audience == "" {
+		client.Audience = u.Scheme + "://" + u.Host
+	}
+	if err := client.Handshake(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Time{})
+	conn.SetWriteDeadline(time.Time{})
+	return conn, nil
+}
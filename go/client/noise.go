@@ -0,0 +1,35 @@
+package wskeyauthclient
+
+import (
+	"errors"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// NoiseHandshake authenticates over conn with a Noise handshake, for servers
+// using wskeyauth.NoiseHandshake, and returns conn encrypted with the keys it
+// agreed on. If ServerID is set, the server must prove it holds its key.
+func (c *Client) NoiseHandshake(conn wskeyauth.Conn) (*wskeyauth.EncryptedConn, error) {
+	if c.signer == nil {
+		return nil, errors.New("wskeyauthclient: client has no key for a Noise handshake")
+	}
+
+	conn, err := c.encode(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := wskeyauth.NoiseClientHandshake(conn, c.clientID, c.ServerID, c.sign)
+	if err != nil {
+		return nil, err
+	}
+
+	var td wskeyauth.TypeData
+	if err := conn.ReadJSON(&td); err != nil {
+		return nil, err
+	}
+	if td.Type != "SIGNATURE_MATCHES" {
+		return nil, rejected(td)
+	}
+	return encrypted, nil
+}
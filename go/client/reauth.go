@@ -0,0 +1,28 @@
+package wskeyauthclient
+
+import (
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// ReAuth authenticates over conn again, once authenticated, as servers
+// handling RE_AUTH with wskeyauth.ReAuth do. It is called on a Client of the
+// new key, so that, to rotate keys:
+//
+//	rotated, _ := wskeyauthclient.New(newKey)
+//	if err := rotated.ReAuth(conn); err != nil {
+//		...
+//	}
+//
+// keeps conn, as the new key's, if it succeeds. As with Refresh, it reads the
+// server's responses, so it must not be called while anything else reads
+// from conn.
+func (c *Client) ReAuth(conn wskeyauth.Conn) error {
+	encoded, err := c.encode(conn)
+	if err != nil {
+		return err
+	}
+	if err := encoded.WriteJSON(map[string]string{"type": "RE_AUTH"}); err != nil {
+		return err
+	}
+	return c.Handshake(conn)
+}
@@ -0,0 +1,28 @@
+package wskeyauthclient
+
+import (
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// Refresh asks the server for fresh credentials over conn, once
+// authenticated, as servers handling REFRESH with wskeyauth.Refresh do, and
+// passes them to OnAccessToken, OnMacaroon and OnTURN. It reads the server's
+// response, so it must not be called while anything else reads from conn.
+//
+// A server that denied the refresh is reported as a *RejectedError of type
+// REFRESH_DENIED.
+func (c *Client) Refresh(conn wskeyauth.Conn) error {
+	if err := conn.WriteJSON(map[string]string{"type": "REFRESH"}); err != nil {
+		return err
+	}
+
+	var td resultMessage
+	if err := conn.ReadJSON(&td); err != nil {
+		return err
+	}
+	if td.Type != "REFRESHED" {
+		return rejected(td.TypeData)
+	}
+	c.credentials(&td)
+	return nil
+}
@@ -0,0 +1,142 @@
+package wskeyauthclient
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultMaxAttempts is how many times a RetryPolicy tries unless
+	// MaxAttempts says otherwise.
+	DefaultMaxAttempts = 5
+
+	// DefaultInitialBackoff is how long a RetryPolicy waits before its first
+	// retry unless InitialBackoff says otherwise.
+	DefaultInitialBackoff = 500 * time.Millisecond
+
+	// DefaultMaxBackoff is the longest a RetryPolicy waits between attempts
+	// unless MaxBackoff says otherwise.
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// RetryPolicy retries connecting and authenticating after failures that may
+// well go away on their own, such as the server being restarted, with an
+// exponential, jittered backoff. Set it as the client's Retry for Dial to
+// follow it.
+type RetryPolicy struct {
+	// MaxAttempts is how many times to try in all, the first attempt
+	// included. It defaults to DefaultMaxAttempts.
+	MaxAttempts int
+
+	// InitialBackoff is how long to wait before the first retry. Every retry
+	// after it waits twice as long as the one before, up to MaxBackoff. Each
+	// wait is randomized to between half of that and all of it, so that
+	// clients that failed together don't all retry together.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Retryable reports whether to retry after err. It defaults to
+	// Retryable.
+	Retryable func(err error) bool
+
+	// OnRetry, if set, is called before every retry, with the number of the
+	// attempt that failed, starting at 1, its error, and how long until the
+	// next attempt.
+	OnRetry func(attempt int, err error, backoff time.Duration)
+
+	// Random, if set, is where the waits are randomized from, in place of
+	// math/rand, for tests that need them reproducible. Should reading it
+	// fail, the full wait is used.
+	Random io.Reader
+}
+
+// Retryable reports whether err, from Dial or Handshake, may go away if the
+// client tries again: network errors, the connection dropping, and the
+// server responding with SERVER_ERROR, TIMEOUT, RATE_LIMITED or GOING_AWAY.
+// Rejections of the client's credentials, such as SIGNATURE_MISMATCH and
+// CLIENT_ERROR, are not, nor are pending pairings, which wait on a person,
+// lockouts, which retrying only prolongs, nor ctx being done.
+func Retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var rejected *RejectedError
+	if errors.As(err, &rejected) {
+		switch rejected.Type {
+		case "SERVER_ERROR", "TIMEOUT", "RATE_LIMITED", "GOING_AWAY":
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	var closeErr *websocket.CloseError
+	return errors.As(err, &netErr) ||
+		errors.As(err, &closeErr) ||
+		errors.Is(err, websocket.ErrBadHandshake) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Do calls attempt until it succeeds, fails with an error that isn't
+// retryable, or has been tried MaxAttempts times, waiting between attempts as
+// the policy says. It returns the last attempt's error, or ctx.Err() if ctx
+// is done while waiting.
+func (p *RetryPolicy) Do(ctx context.Context, attempt func(ctx context.Context) error) error {
+	maxAttempts, backoff, maxBackoff := p.MaxAttempts, p.InitialBackoff, p.MaxBackoff
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	if backoff <= 0 {
+		backoff = DefaultInitialBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = Retryable
+	}
+
+	for n := 1; ; n++ {
+		err := attempt(ctx)
+		if err == nil || n >= maxAttempts || !retryable(err) {
+			return err
+		}
+
+		backoff = min(backoff, maxBackoff)
+		wait := backoff/2 + time.Duration(p.jitter(int64(backoff/2)+1))
+		if p.OnRetry != nil {
+			p.OnRetry(n, err, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// jitter returns a random number in [0, n).
+func (p *RetryPolicy) jitter(n int64) int64 {
+	if p.Random == nil {
+		return rand.Int63n(n)
+	}
+	var b [8]byte
+	if _, err := io.ReadFull(p.Random, b[:]); err != nil {
+		return n - 1
+	}
+	return int64(binary.BigEndian.Uint64(b[:]) % uint64(n))
+}
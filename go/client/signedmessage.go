@@ -0,0 +1,107 @@
+package wskeyauthclient
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// MessageSigner signs the messages a client sends over one connection, for
+// servers that check them with wskeyauth.MessageVerifier. Create one per
+// connection, once the handshake is over.
+type MessageSigner struct {
+	client  *Client
+	binding []byte
+
+	mu  sync.Mutex
+	seq uint64
+}
+
+// NewMessageSigner creates a signer for conn, which its messages are bound
+// to, so that they can't be replayed over another connection. It must be
+// created after the handshake over conn, and before the client starts
+// another. Clients that authenticate with a shared secret or a password have
+// no key to sign with, and those that authenticated without a challenge,
+// with a signed timestamp or a TLS client certificate, nothing to bind to.
+func (c *Client) NewMessageSigner(conn wskeyauth.Conn) (*MessageSigner, error) {
+	if c.signer == nil {
+		return nil, errors.New("wskeyauthclient: client has no key to sign messages with")
+	}
+
+	c.last.mu.Lock()
+	bound, binding := c.last.conn, c.last.binding
+	c.last.mu.Unlock()
+	if bound != conn {
+		return nil, errors.New("wskeyauthclient: the client's last handshake wasn't over conn")
+	}
+	if binding == nil {
+		return nil, errors.New("wskeyauthclient: the handshake over conn had no challenge to bind messages to")
+	}
+	return &MessageSigner{client: c, binding: binding}, nil
+}
+
+// Sign signs v, encoded as JSON, as the next message.
+func (s *MessageSigner) Sign(v any) (*wskeyauth.SignedMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.next(v)
+	if err != nil {
+		return nil, err
+	}
+	s.seq++
+	return m, nil
+}
+
+// WriteJSON signs v and writes it to conn. Messages must arrive in the order
+// they were signed in, so WriteJSON holds the signer while writing.
+func (s *MessageSigner) WriteJSON(conn wskeyauth.Conn, v any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.next(v)
+	if err != nil {
+		return err
+	}
+	if err := conn.WriteJSON(m); err != nil {
+		return err
+	}
+	s.seq++
+	return nil
+}
+
+// next signs v as the next message, without counting it as sent.
+func (s *MessageSigner) next(v any) (*wskeyauth.SignedMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	seq := s.seq + 1
+	signature, err := s.client.sign(wskeyauth.MessageSigningInput(s.binding, seq, data))
+	if err != nil {
+		return nil, err
+	}
+	return &wskeyauth.SignedMessage{
+		Seq:       seq,
+		Data:      data,
+		Signature: base64.StdEncoding.EncodeToString(signature),
+	}, nil
+}
+
+// EncryptSession agrees on keys with the server, once the handshake is over,
+// and returns conn encrypted with them, as wskeyauth.EncryptServerSession
+// describes. The server must have proven its identity, so ServerID must be
+// set.
+func (c *Client) EncryptSession(conn wskeyauth.Conn) (*wskeyauth.EncryptedConn, error) {
+	if c.ServerID == "" {
+		return nil, errors.New("wskeyauthclient: can't encrypt a session without ServerID")
+	}
+	if c.signer == nil {
+		return nil, errors.New("wskeyauthclient: client has no key to sign the key exchange with")
+	}
+	return wskeyauth.EncryptClientSession(conn, c.ServerID, c.sign)
+}
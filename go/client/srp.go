@@ -0,0 +1,77 @@
+package wskeyauthclient
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/internal/srp"
+)
+
+// password is the credentials of a client created by NewSRP.
+type password struct {
+	username, password string
+}
+
+// NewSRP creates a client that authenticates with a password, to servers
+// using wskeyauth.WithPasswords. The password is never sent, and the server
+// has to prove it knew the user's verifier in turn.
+func NewSRP(username, pass string) *Client {
+	return &Client{password: &password{username, pass}, clientID: "SRP-6a$" + username, last: &lastHandshake{}}
+}
+
+// handshakeSRP finishes the handshake of a password client, given the data
+// of the server's CHALLENGE.
+func (c *Client) handshakeSRP(conn wskeyauth.Conn, data json.RawMessage) error {
+	var challenge struct {
+		Salt      string `json:"salt"`
+		Ephemeral string `json:"ephemeral"`
+	}
+	if err := json.Unmarshal(data, &challenge); err != nil {
+		return fmt.Errorf("wskeyauthclient: failed to parse CHALLENGE: %w", err)
+	}
+	salt, err := base64.StdEncoding.DecodeString(challenge.Salt)
+	if err != nil {
+		return fmt.Errorf("wskeyauthclient: failed to parse CHALLENGE: %w", err)
+	}
+	b, err := base64.StdEncoding.DecodeString(challenge.Ephemeral)
+	if err != nil {
+		return fmt.Errorf("wskeyauthclient: failed to parse CHALLENGE: %w", err)
+	}
+
+	a, proof, serverProof, err := srp.Client(c.password.username, c.password.password, salt, b, c.random())
+	if err != nil {
+		return err
+	}
+
+	response, err := c.response(map[string]string{
+		"ephemeral": base64.StdEncoding.EncodeToString(a),
+		"signature": base64.StdEncoding.EncodeToString(proof),
+		"hash":      "SHA-256",
+	}, b)
+	if err != nil {
+		return err
+	}
+	err = conn.WriteJSON(map[string]any{"type": "CHALLENGE_RESPONSE", "data": response})
+	if err != nil {
+		return err
+	}
+
+	matched, err := c.result(conn)
+	if err != nil {
+		return err
+	}
+
+	var encoded string
+	if err := json.Unmarshal(matched, &encoded); err != nil {
+		return errors.New("wskeyauthclient: server didn't prove it knew our verifier")
+	}
+	got, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || subtle.ConstantTimeCompare(got, serverProof) != 1 {
+		return errors.New("wskeyauthclient: server didn't prove it knew our verifier")
+	}
+	return nil
+}
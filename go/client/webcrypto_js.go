@@ -0,0 +1,137 @@
+//go:build js && wasm
+
+package wskeyauthclient
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"errors"
+	"io"
+	"math/big"
+	"syscall/js"
+)
+
+// webCryptoKey is a crypto.Signer of a WebCrypto key pair, which signs
+// through crypto.subtle, so that non-extractable keys stay in the browser.
+type webCryptoKey struct {
+	privateKey js.Value
+	public     crypto.PublicKey
+	algorithm  js.Value
+}
+
+// NewWebCryptoSigner returns a signer of keyPair, a WebCrypto CryptoKeyPair
+// of an ECDSA P-256 or an Ed25519 key, such as crypto.subtle.generateKey
+// resolves to, for New. The private key needn't be extractable.
+//
+// WebCrypto is asynchronous, so the signer, and the clients made with it,
+// must not be used from a function JavaScript calls into Go with, but only
+// from goroutines: blocking in those deadlocks.
+func NewWebCryptoSigner(keyPair js.Value) (crypto.Signer, error) {
+	private, public := keyPair.Get("privateKey"), keyPair.Get("publicKey")
+	if private.Type() != js.TypeObject || public.Type() != js.TypeObject {
+		return nil, errors.New("wskeyauthclient: expected a CryptoKeyPair")
+	}
+
+	exported, err := await(js.Global().Get("crypto").Get("subtle").Call("exportKey", "raw", public))
+	if err != nil {
+		return nil, err
+	}
+	raw := bytesOf(exported)
+
+	k := &webCryptoKey{privateKey: private}
+	switch name := public.Get("algorithm").Get("name").String(); name {
+	case "ECDSA":
+		if _, err := ecdh.P256().NewPublicKey(raw); err != nil {
+			return nil, errors.New("wskeyauthclient: ECDSA keys must be on P-256")
+		}
+		k.public = &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(raw[1:33]),
+			Y:     new(big.Int).SetBytes(raw[33:]),
+		}
+		k.algorithm = js.ValueOf(map[string]any{"name": "ECDSA", "hash": "SHA-256"})
+	case "Ed25519":
+		if len(raw) != ed25519.PublicKeySize {
+			return nil, errors.New("wskeyauthclient: malformed Ed25519 key")
+		}
+		k.public = ed25519.PublicKey(raw)
+		k.algorithm = js.ValueOf(map[string]any{"name": "Ed25519"})
+	default:
+		return nil, errors.New("wskeyauthclient: unsupported WebCrypto algorithm " + name)
+	}
+	return k, nil
+}
+
+func (k *webCryptoKey) Public() crypto.PublicKey {
+	return k.public
+}
+
+// Sign signs message with Ed25519 keys. WebCrypto can't sign the digests
+// crypto.Signer is given for ECDSA, so clients sign with signMessage.
+func (k *webCryptoKey) Sign(_ io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := k.public.(ed25519.PublicKey); !ok || opts.HashFunc() != 0 {
+		return nil, errors.New("wskeyauthclient: WebCrypto keys can't sign digests")
+	}
+	return k.signMessage(message)
+}
+
+// signMessage signs message, which WebCrypto hashes itself for ECDSA, giving
+// the concatenation of r and s, as the browser client signs.
+func (k *webCryptoKey) signMessage(message []byte) ([]byte, error) {
+	data := js.Global().Get("Uint8Array").New(len(message))
+	js.CopyBytesToJS(data, message)
+	signature, err := await(js.Global().Get("crypto").Get("subtle").Call("sign", k.algorithm, k.privateKey, data))
+	if err != nil {
+		return nil, err
+	}
+	return bytesOf(signature), nil
+}
+
+// await blocks until promise settles.
+func await(promise js.Value) (js.Value, error) {
+	type result struct {
+		value js.Value
+		err   error
+	}
+	settled := make(chan result, 1)
+	resolve := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		settled <- result{value: arg(args)}
+		return nil
+	})
+	defer resolve.Release()
+	reject := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		settled <- result{err: jsError(arg(args))}
+		return nil
+	})
+	defer reject.Release()
+
+	promise.Call("then", resolve, reject)
+	r := <-settled
+	return r.value, r.err
+}
+
+func arg(args []js.Value) js.Value {
+	if len(args) == 0 {
+		return js.Undefined()
+	}
+	return args[0]
+}
+
+// jsError returns an error of v, a value a promise was rejected with.
+func jsError(v js.Value) error {
+	if v.Type() == js.TypeObject && v.Get("message").Type() == js.TypeString {
+		return errors.New("wskeyauthclient: " + v.Get("message").String())
+	}
+	return errors.New("wskeyauthclient: " + v.String())
+}
+
+// bytesOf copies the bytes of an ArrayBuffer or a typed array out of v.
+func bytesOf(v js.Value) []byte {
+	array := js.Global().Get("Uint8Array").New(v)
+	b := make([]byte, array.Get("length").Int())
+	js.CopyBytesToGo(b, array)
+	return b
+}
@@ -0,0 +1,189 @@
+//go:build js && wasm
+
+package wskeyauthclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sync"
+	"syscall/js"
+)
+
+// ErrWebSocketClosed is returned by WebSocket's methods once the connection
+// closed.
+var ErrWebSocketClosed = errors.New("wskeyauthclient: WebSocket closed")
+
+// WebSocket is a wskeyauth.Conn over a browser's WebSocket, for clients
+// compiled to WebAssembly, where gorilla/websocket can't dial. Its methods
+// block, and so must only be called from goroutines.
+type WebSocket struct {
+	ws js.Value
+
+	mu        sync.Mutex
+	queue     [][]byte
+	listeners []listener
+
+	// ready is signalled whenever a message is queued
+	ready  chan struct{}
+	closed chan struct{}
+	once   sync.Once
+}
+
+type listener struct {
+	event string
+	fn    js.Func
+}
+
+// DialWebSocket opens a WebSocket to rawURL, and waits for it to open.
+func DialWebSocket(ctx context.Context, rawURL string) (*WebSocket, error) {
+	c := &WebSocket{
+		ws:     js.Global().Get("WebSocket").New(rawURL),
+		ready:  make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+	c.ws.Set("binaryType", "arraybuffer")
+
+	opened := make(chan struct{})
+	c.listen("open", func(js.Value) { close(opened) })
+	c.listen("message", func(event js.Value) {
+		data := event.Get("data")
+		var b []byte
+		if data.Type() == js.TypeString {
+			b = []byte(data.String())
+		} else {
+			b = bytesOf(data)
+		}
+		c.mu.Lock()
+		c.queue = append(c.queue, b)
+		c.mu.Unlock()
+		select {
+		case c.ready <- struct{}{}:
+		default:
+		}
+	})
+	// an error always fails the connection, although not every runtime
+	// follows it with a close
+	c.listen("error", func(js.Value) { c.once.Do(func() { close(c.closed) }) })
+	c.listen("close", func(js.Value) { c.once.Do(func() { close(c.closed) }) })
+
+	select {
+	case <-opened:
+		return c, nil
+	case <-c.closed:
+		c.release()
+		return nil, ErrWebSocketClosed
+	case <-ctx.Done():
+		c.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// listen adds a listener for event. fn runs on JavaScript's event loop, so
+// it must not block.
+func (c *WebSocket) listen(event string, fn func(event js.Value)) {
+	f := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		fn(arg(args))
+		return nil
+	})
+	c.ws.Call("addEventListener", event, f)
+	c.mu.Lock()
+	c.listeners = append(c.listeners, listener{event: event, fn: f})
+	c.mu.Unlock()
+}
+
+func (c *WebSocket) ReadJSON(v any) error {
+	for {
+		c.mu.Lock()
+		if len(c.queue) > 0 {
+			b := c.queue[0]
+			c.queue = c.queue[1:]
+			c.mu.Unlock()
+			return json.Unmarshal(b, v)
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-c.ready:
+		case <-c.closed:
+			return ErrWebSocketClosed
+		}
+	}
+}
+
+func (c *WebSocket) WriteJSON(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.closed:
+		return ErrWebSocketClosed
+	default:
+	}
+	c.ws.Call("send", string(b))
+	return nil
+}
+
+// Close closes the WebSocket.
+func (c *WebSocket) Close() error {
+	c.ws.Call("close")
+	c.once.Do(func() { close(c.closed) })
+	c.release()
+	return nil
+}
+
+// Release stops reading the WebSocket, and returns it, for JavaScript to use
+// once authenticated, along with the messages that arrived but weren't read.
+func (c *WebSocket) Release() (ws js.Value, unread []string) {
+	c.release()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, b := range c.queue {
+		unread = append(unread, string(b))
+	}
+	c.queue = nil
+	return c.ws, unread
+}
+
+func (c *WebSocket) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, l := range c.listeners {
+		c.ws.Call("removeEventListener", l.event, l.fn)
+		l.fn.Release()
+	}
+	c.listeners = nil
+}
+
+// DialWebSocket connects to the server at rawURL with a browser's WebSocket,
+// and authenticates, as Dial does with gorilla/websocket.
+func (c *Client) DialWebSocket(ctx context.Context, rawURL string) (*WebSocket, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := DialWebSocket(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client := *c
+	if client.Audience == "" {
+		client.Audience = u.Scheme + "://" + u.Host
+	}
+	done := make(chan error, 1)
+	go func() { done <- client.Handshake(conn) }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,143 @@
+package wskeyauth
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// ClientIDFormat is the kind of client ID a client authenticates with.
+type ClientIDFormat string
+
+// The formats of client IDs, as described in lib.go and the files it points
+// to.
+const (
+	FormatWebCrypto ClientIDFormat = "WebCrypto"
+	FormatWebAuthn  ClientIDFormat = "WebAuthn"
+	FormatDIDKey    ClientIDFormat = "did:key"
+	FormatSSH       ClientIDFormat = "SSH"
+	FormatX509      ClientIDFormat = "X509"
+	FormatDelegated ClientIDFormat = "Delegated"
+	FormatJWK       ClientIDFormat = "JWK"
+	FormatSPKI      ClientIDFormat = "SPKI"
+	FormatHMAC      ClientIDFormat = "HMAC"
+	FormatSRP       ClientIDFormat = "SRP"
+)
+
+// ErrUnknownClientIDFormat is wrapped by the ClientIDError of a client ID that
+// is of none of the formats.
+var ErrUnknownClientIDFormat = errors.New("wskeyauth: client ID is of no known format")
+
+// ClientIDError is returned by ParseClientID for client IDs that don't
+// parse. It wraps ErrUnknownClientIDFormat, or ErrInvalidPublicKey, when
+// that is the problem.
+type ClientIDError struct {
+	ClientID string
+
+	// Format is the format the client ID claimed to be of, as its prefix
+	// says, or "" if none.
+	Format ClientIDFormat
+
+	Err error
+}
+
+func (e *ClientIDError) Error() string {
+	if e.Format == "" {
+		return e.Err.Error()
+	}
+	return "wskeyauth: invalid " + string(e.Format) + " client ID: " + e.Err.Error()
+}
+
+func (e *ClientIDError) Unwrap() error {
+	return e.Err
+}
+
+// ParsedClientID is what a client ID says about the client.
+type ParsedClientID struct {
+	Format ClientIDFormat
+
+	// Algorithm is "ECDSA", "Ed25519", "HMAC-SHA-256" or "SRP".
+	Algorithm string
+
+	// Curve is "P-256" for ECDSA keys, and "" otherwise.
+	Curve string
+
+	// Key is the raw public key: the uncompressed point of P-256 keys, and
+	// the 32 bytes of Ed25519 ones. It, and PublicKey, are nil for HMAC and
+	// SRP client IDs, which have none.
+	Key []byte
+
+	// PublicKey is an *ecdsa.PublicKey or an ed25519.PublicKey.
+	PublicKey crypto.PublicKey
+
+	// Name is the key ID of HMAC client IDs, and the username of SRP ones.
+	Name string
+
+	// Namespace is the namespace of the client ID, if any.
+	Namespace string
+
+	// Certificates are those of an X.509 client ID, the client's first.
+	Certificates []*x509.Certificate
+
+	// Fingerprint identifies the client, as Fingerprint returns it.
+	Fingerprint string
+
+	canonical string
+}
+
+// String returns the client ID in a canonical form, which is the same for
+// every encoding of it: standard base64 for WebCrypto and WebAuthn client
+// IDs, and no comment for OpenSSH keys.
+func (p *ParsedClientID) String() string {
+	return p.canonical
+}
+
+// ParseClientID parses clientID, in any of the formats the handshake
+// accepts, with any base64 encoding, so that applications can validate
+// client IDs long before they are used, such as when they're registered.
+// Whether a server accepts the format is up to its options.
+func ParseClientID(clientID string) (*ParsedClientID, error) {
+	pubKey, err := parseClientID(clientID, Base64Any)
+	if err != nil {
+		format := clientIDFormat(clientID)
+		if format == "" {
+			err = ErrUnknownClientIDFormat
+		}
+		return nil, &ClientIDError{ClientID: clientID, Format: format, Err: err}
+	}
+
+	format := pubKey.format
+	p := &ParsedClientID{
+		Format:       format,
+		Namespace:    pubKey.namespace,
+		Certificates: pubKey.certificates,
+		Fingerprint:  fingerprint(pubKey),
+		canonical:    clientID,
+	}
+	switch {
+	case pubKey.keyID != "":
+		p.Algorithm, p.Name = "HMAC-SHA-256", pubKey.keyID
+	case pubKey.username != "":
+		p.Algorithm, p.Name = "SRP", pubKey.username
+	case pubKey.ed25519 != nil:
+		p.Algorithm, p.Key, p.PublicKey = "Ed25519", pubKey.ed25519, pubKey.ed25519
+	default:
+		p.Algorithm, p.Curve, p.PublicKey = "ECDSA", "P-256", pubKey.ecdsa
+		p.Key = make([]byte, 65)
+		p.Key[0] = 4
+		pubKey.ecdsa.X.FillBytes(p.Key[1:33])
+		pubKey.ecdsa.Y.FillBytes(p.Key[33:])
+	}
+
+	switch format {
+	case FormatWebCrypto, FormatWebAuthn:
+		prefix, _, _ := strings.Cut(clientID, "$")
+		p.canonical = prefix + "$" + base64.StdEncoding.EncodeToString(p.Key)
+	case FormatSSH:
+		fields := strings.Fields(clientID)
+		p.canonical = fields[0] + " " + fields[1]
+	}
+	return p, nil
+}
@@ -0,0 +1,50 @@
+package wskeyauth
+
+import "time"
+
+// Clock tells the time, and runs functions once some of it passed. Handshakes
+// use it for their timeout and for every expiry and replay window they check,
+// so that a fake clock, such as wskeyauthtest.Clock, makes them deterministic
+// in tests, and lets load tests run in virtual time. Deadlines on the
+// connections themselves are still set in real time, as the network knows no
+// other.
+type Clock interface {
+	Now() time.Time
+
+	// AfterFunc calls f on a goroutine of its own once d passed, unless the
+	// returned timer was stopped first.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a function waiting to be run by a Clock.
+type Timer interface {
+	// Stop stops the timer, and reports whether that kept its function from
+	// running.
+	Stop() bool
+}
+
+// SystemClock is the Clock of the time package, which handshakes use unless
+// WithClock says otherwise.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+// WithClock runs the handshake by clock, instead of SystemClock.
+func WithClock(clock Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = clock
+	}
+}
+
+// clockOr returns clock, or SystemClock if it is nil, for the Clock fields of
+// types used outside of handshakes.
+func clockOr(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock
+	}
+	return clock
+}
@@ -0,0 +1,136 @@
+package wskeyauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// ClusterBackend carries messages between the registries of a cluster's
+// servers, so that SendTo, Broadcast and Disconnect reach the sessions of
+// every server, rather than just those of the one they are called on.
+type ClusterBackend interface {
+	// Publish sends m to every server sharing the backend. Whether the
+	// server that published it gets it too doesn't matter; registries ignore
+	// their own messages.
+	Publish(ctx context.Context, m ClusterMessage) error
+
+	// Subscribe calls handle with every message published, until
+	// unsubscribe is called.
+	Subscribe(handle func(ClusterMessage)) (unsubscribe func() error, err error)
+}
+
+// ClusterOp is what a ClusterMessage asks servers to do.
+type ClusterOp string
+
+const (
+	// ClusterSendTo sends Message to the sessions of Fingerprint.
+	ClusterSendTo ClusterOp = "send"
+	// ClusterBroadcast sends Message to every session.
+	ClusterBroadcast ClusterOp = "broadcast"
+	// ClusterDisconnect disconnects the sessions of Fingerprint, with
+	// CloseCode and Reason.
+	ClusterDisconnect ClusterOp = "disconnect"
+)
+
+// ClusterMessage is what servers tell one another through a ClusterBackend.
+// Backends that carry bytes encode it as JSON.
+type ClusterMessage struct {
+	Op ClusterOp `json:"op"`
+
+	// Node is the server that published the message.
+	Node string `json:"node"`
+
+	Fingerprint string          `json:"fingerprint,omitempty"`
+	Message     json.RawMessage `json:"message,omitempty"`
+	CloseCode   int             `json:"closeCode,omitempty"`
+	Reason      string          `json:"reason,omitempty"`
+}
+
+// JoinCluster connects the registry to the other servers sharing b, with
+// node identifying this server among them. From then on, SendTo, Broadcast
+// and Disconnect are published to the cluster, and those of other servers
+// reach the sessions of this one. With a PresenceBackend shared by the same
+// servers, SendTo and Disconnect return ErrNotConnected when a client isn't
+// connected to any of them; otherwise they can only tell for this server,
+// and return nil once they published.
+//
+// Call leave to disconnect the registry from the cluster again.
+func (r *Registry) JoinCluster(b ClusterBackend, node string) (leave func() error, err error) {
+	r.mu.Lock()
+	if r.cluster != nil {
+		r.mu.Unlock()
+		return nil, errors.New("wskeyauth: registry is in a cluster already")
+	}
+	r.cluster, r.node = b, node
+	r.mu.Unlock()
+
+	unsubscribe, err := b.Subscribe(r.handleCluster)
+	if err != nil {
+		r.mu.Lock()
+		r.cluster, r.node = nil, ""
+		r.mu.Unlock()
+		return nil, err
+	}
+	return func() error {
+		r.mu.Lock()
+		r.cluster, r.node = nil, ""
+		r.mu.Unlock()
+		return unsubscribe()
+	}, nil
+}
+
+// clusterBackend returns the cluster the registry is in, if any, and the
+// name of this server in it.
+func (r *Registry) clusterBackend() (ClusterBackend, string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cluster, r.node
+}
+
+// handleCluster handles m, a message published by a server of the cluster.
+func (r *Registry) handleCluster(m ClusterMessage) {
+	if _, node := r.clusterBackend(); m.Node == node {
+		return
+	}
+
+	switch m.Op {
+	case ClusterSendTo:
+		send(r.GetByFingerprint(m.Fingerprint), m.Message)
+	case ClusterBroadcast:
+		r.broadcast(m.Message)
+	case ClusterDisconnect:
+		for _, s := range r.GetByFingerprint(m.Fingerprint) {
+			s.Disconnect(m.CloseCode, m.Reason)
+		}
+	}
+}
+
+// publish publishes m to the cluster, if the registry is in one. It reports
+// whether it did.
+func (r *Registry) publish(m ClusterMessage, message any) (bool, error) {
+	b, node := r.clusterBackend()
+	if b == nil {
+		return false, nil
+	}
+	if message != nil {
+		encoded, err := json.Marshal(message)
+		if err != nil {
+			return true, err
+		}
+		m.Message = encoded
+	}
+	m.Node = node
+	return true, b.Publish(context.Background(), m)
+}
+
+// connectedElsewhere reports whether the client of fingerprint may be
+// connected to another server of the cluster; without a PresenceBackend,
+// there is no telling that it isn't.
+func (r *Registry) connectedElsewhere(fingerprint string) bool {
+	if r.presence == nil {
+		return true
+	}
+	p, err := r.presence.Presence(context.Background(), fingerprint)
+	return err != nil || p.Online()
+}
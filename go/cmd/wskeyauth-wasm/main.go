@@ -0,0 +1,121 @@
+//go:build js && wasm
+
+// Command wskeyauth-wasm is the Go client compiled to WebAssembly, for web
+// and Electron apps that would rather run the exact handshake the Go client
+// does than the TypeScript one. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o wskeyauth.wasm ./cmd/wskeyauth-wasm
+//
+// and load it with the wasm_exec.js that ships with Go. It defines a global
+// wskeyauth object, with
+//
+//	wskeyauth.clientId(keyPair): Promise<string>
+//	wskeyauth.connect(url, keyPair, options): Promise<{socket, clientId, unread}>
+//
+// where keyPair is a WebCrypto CryptoKeyPair of an ECDSA P-256 or an Ed25519
+// key, and options may set audience, the audience to sign challenges for,
+// and timeout, in milliseconds. connect resolves to the authenticated
+// WebSocket, which is the application's from then on, along with the
+// messages that arrived on it after the handshake, before it was handed over.
+package main
+
+import (
+	"context"
+	"syscall/js"
+	"time"
+
+	wskeyauthclient "github.com/castcam-live/ws-key-auth/go/client"
+)
+
+func main() {
+	js.Global().Set("wskeyauth", js.ValueOf(map[string]any{
+		"clientId": promise(clientID),
+		"connect":  promise(connect),
+	}))
+	select {}
+}
+
+func clientID(args []js.Value) (any, error) {
+	client, err := newClient(args)
+	if err != nil {
+		return nil, err
+	}
+	return client.ClientID(), nil
+}
+
+func connect(args []js.Value) (any, error) {
+	if len(args) < 2 || args[0].Type() != js.TypeString {
+		return nil, errUsage("connect(url, keyPair, options)")
+	}
+	client, err := newClient(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	if len(args) > 2 && args[2].Type() == js.TypeObject {
+		options := args[2]
+		if audience := options.Get("audience"); audience.Type() == js.TypeString {
+			client.Audience = audience.String()
+		}
+		if timeout := options.Get("timeout"); timeout.Type() == js.TypeNumber {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout.Float()*float64(time.Millisecond)))
+			defer cancel()
+		}
+	}
+
+	conn, err := client.DialWebSocket(ctx, args[0].String())
+	if err != nil {
+		return nil, err
+	}
+	socket, unread := conn.Release()
+	messages := make([]any, len(unread))
+	for i, message := range unread {
+		messages[i] = message
+	}
+	return map[string]any{
+		"socket":   socket,
+		"clientId": client.ClientID(),
+		"unread":   messages,
+	}, nil
+}
+
+func newClient(args []js.Value) (*wskeyauthclient.Client, error) {
+	if len(args) == 0 {
+		return nil, errUsage("a CryptoKeyPair")
+	}
+	signer, err := wskeyauthclient.NewWebCryptoSigner(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return wskeyauthclient.New(signer)
+}
+
+type errUsage string
+
+func (e errUsage) Error() string {
+	return "wskeyauth: expected " + string(e)
+}
+
+// promise wraps fn as a JavaScript function returning a promise, running fn
+// on a goroutine of its own, as it blocks.
+func promise(fn func(args []js.Value) (any, error)) js.Func {
+	return js.FuncOf(func(_ js.Value, args []js.Value) any {
+		// the executor is called before Promise returns
+		executor := js.FuncOf(func(_ js.Value, callbacks []js.Value) any {
+			resolve, reject := callbacks[0], callbacks[1]
+			go func() {
+				result, err := fn(args)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New(err.Error()))
+					return
+				}
+				resolve.Invoke(result)
+			}()
+			return nil
+		})
+		defer executor.Release()
+		return js.Global().Get("Promise").New(executor)
+	})
+}
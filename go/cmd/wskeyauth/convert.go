@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	wskeyauthkeys "github.com/castcam-live/ws-key-auth/go/keys"
+)
+
+// convert reads the key in, whichever of the formats it is in, and writes it
+// to w in format to.
+func convert(w io.Writer, to string, in []byte) error {
+	pub, err := readKey(in)
+	if err != nil {
+		return err
+	}
+
+	switch to {
+	case "clientid":
+		s, err := wskeyauthkeys.ClientID(pub)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, s)
+		return err
+	case "jwk":
+		b, err := wskeyauthkeys.JWK(pub)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	case "pem":
+		b, err := wskeyauthkeys.PEM(pub)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	case "ssh":
+		s, err := wskeyauthkeys.SSH(pub)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, s)
+		return err
+	}
+	return fmt.Errorf("unknown format %q, expected clientid, jwk, pem or ssh", to)
+}
+
+// readKey parses a PEM public key, a JWK, or anything that is a client ID,
+// OpenSSH keys among them.
+func readKey(in []byte) (crypto.PublicKey, error) {
+	in = bytes.TrimSpace(in)
+	switch {
+	case bytes.HasPrefix(in, []byte("-----BEGIN")):
+		return wskeyauthkeys.FromPEM(in)
+	case bytes.HasPrefix(in, []byte("{")):
+		return wskeyauthkeys.FromJWK(in)
+	}
+	return wskeyauthkeys.FromClientID(strings.TrimSpace(string(in)))
+}
+
+func convertMain(args []string) int {
+	if len(args) != 2 {
+		usage()
+	}
+
+	var in []byte
+	var err error
+	if args[1] == "-" {
+		in, err = io.ReadAll(os.Stdin)
+	} else if _, statErr := os.Stat(args[1]); statErr == nil {
+		in, err = os.ReadFile(args[1])
+	} else {
+		in = []byte(args[1])
+	}
+	if err == nil {
+		err = convert(os.Stdout, args[0], in)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "wskeyauth:", err)
+		return 1
+	}
+	return 0
+}
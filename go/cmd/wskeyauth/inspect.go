@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// inspect prints what it can make of clientID, and reports whether it is
+// well-formed.
+func inspect(w io.Writer, clientID string) bool {
+	fmt.Fprintf(w, "client ID:   %s\n", clientID)
+
+	problems := diagnose(w, clientID)
+
+	// whatever we think of it, the package has the final word
+	fingerprint, err := wskeyauth.Fingerprint(clientID)
+	if err != nil && len(problems) == 0 {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		fmt.Fprintln(w, "status:      MALFORMED")
+		for _, problem := range problems {
+			fmt.Fprintf(w, "  - %s\n", problem)
+		}
+		return false
+	}
+
+	fmt.Fprintf(w, "fingerprint: %s\n", fingerprint)
+	fmt.Fprintln(w, "status:      OK")
+	return true
+}
+
+// diagnose walks through the client ID the way a server parses it, printing
+// the parts it understood, and returns everything wrong with it.
+func diagnose(w io.Writer, clientID string) []string {
+	if clientID != strings.TrimSpace(clientID) {
+		return []string{"has leading or trailing whitespace"}
+	}
+
+	// nothing more to say about these than whether they parse
+	if strings.HasPrefix(clientID, "did:key:") {
+		fmt.Fprintln(w, "format:      did:key")
+		return nil
+	}
+	if strings.HasPrefix(clientID, "ssh-") || strings.HasPrefix(clientID, "ecdsa-sha2-") {
+		fmt.Fprintln(w, "format:      OpenSSH")
+		return nil
+	}
+	if strings.HasPrefix(clientID, "X509$") {
+		fmt.Fprintln(w, "format:      X.509")
+		return nil
+	}
+	if strings.HasPrefix(clientID, "HMAC-SHA-256$") {
+		fmt.Fprintln(w, "format:      HMAC pre-shared key")
+		return nil
+	}
+	if strings.HasPrefix(clientID, "SRP-6a$") {
+		fmt.Fprintln(w, "format:      SRP-6a password")
+		return nil
+	}
+
+	parts := strings.Split(clientID, "$")
+	switch {
+	case len(parts) == 1:
+		return []string{`missing the "$" separating the key type from the key`}
+	case len(parts) > 2:
+		return []string{fmt.Sprintf(`expected exactly one "$", found %d`, len(parts)-1)}
+	}
+	keyType, encoded := parts[0], parts[1]
+
+	var problems []string
+
+	typeParts := strings.SplitN(keyType, ".", 3)
+	if len(typeParts) != 3 {
+		return []string{fmt.Sprintf("key type %q is not of the form <format>.<algorithm>.<curve>", keyType)}
+	}
+	format, algorithm, curve := typeParts[0], typeParts[1], typeParts[2]
+	fmt.Fprintf(w, "format:      %s\n", format)
+	fmt.Fprintf(w, "algorithm:   %s\n", algorithm)
+	fmt.Fprintf(w, "curve:       %s\n", curve)
+
+	if format != "WebCrypto-raw" && format != "WebAuthn-raw" {
+		problems = append(problems, fmt.Sprintf("unsupported format %q, expected WebCrypto-raw or WebAuthn-raw", format))
+	}
+	if algorithm != "EC" {
+		problems = append(problems, fmt.Sprintf("unsupported algorithm %q, expected EC", algorithm))
+	}
+	if curve != "P-256" {
+		problems = append(problems, fmt.Sprintf("unsupported curve %q, expected P-256", curve))
+	}
+
+	raw, problem := decode(encoded)
+	if problem != "" {
+		return append(problems, problem)
+	}
+	fmt.Fprintf(w, "key bytes:   %d\n", len(raw))
+
+	switch {
+	case len(raw) == 33 && (raw[0] == 2 || raw[0] == 3):
+		return append(problems, "key is a compressed point; export it in the raw, uncompressed form (65 bytes, starting with 0x04)")
+	case len(raw) != 65:
+		return append(problems, fmt.Sprintf("key is %d bytes long; uncompressed P-256 keys are 65 bytes", len(raw)))
+	case raw[0] != 4:
+		return append(problems, fmt.Sprintf("key starts with 0x%02x; uncompressed points start with 0x04", raw[0]))
+	}
+
+	x := new(big.Int).SetBytes(raw[1:33])
+	y := new(big.Int).SetBytes(raw[33:])
+	fmt.Fprintf(w, "x:           %s\n", hex.EncodeToString(raw[1:33]))
+	fmt.Fprintf(w, "y:           %s\n", hex.EncodeToString(raw[33:]))
+
+	sum := sha256.Sum256(raw)
+	fmt.Fprintf(w, "sha256:      %s\n", hex.EncodeToString(sum[:]))
+
+	if !elliptic.P256().IsOnCurve(x, y) {
+		problems = append(problems, "key is not a point on P-256")
+	}
+
+	return problems
+}
+
+// decode decodes the key, explaining anything that isn't standard, padded
+// base64.
+func decode(encoded string) ([]byte, string) {
+	if encoded == "" {
+		return nil, "the key is empty"
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err == nil {
+		return raw, ""
+	}
+
+	if strings.ContainsAny(encoded, "-_") {
+		if _, err := base64.URLEncoding.DecodeString(encoded); err == nil {
+			return nil, "key uses the URL-safe base64 alphabet (- and _); expected standard base64 (+ and /); servers only accept it with the WithBase64 option"
+		}
+		if _, err := base64.RawURLEncoding.DecodeString(encoded); err == nil {
+			return nil, "key uses unpadded, URL-safe base64; expected standard base64 with = padding; servers only accept it with the WithBase64 option"
+		}
+	}
+	if _, err := base64.RawStdEncoding.DecodeString(encoded); err == nil {
+		return nil, "key is missing its base64 = padding; servers only accept it with the WithBase64 option"
+	}
+
+	var corrupt base64.CorruptInputError
+	if errors.As(err, &corrupt) && int(corrupt) < len(encoded) {
+		return nil, fmt.Sprintf("key is not valid base64: unexpected %q at offset %d", encoded[corrupt], int(corrupt))
+	}
+	return nil, "key is not valid base64: " + err.Error()
+}
@@ -0,0 +1,78 @@
+// Command wskeyauth is a toolbox for working with ws-key-auth client IDs.
+//
+// Usage:
+//
+//	wskeyauth inspect <client ID>...
+//	wskeyauth convert clientid|jwk|pem|ssh <key>
+//
+// inspect parses each client ID and prints its curve, key coordinates and
+// fingerprints, or explains precisely what is wrong with it. Pass - to read
+// client IDs from standard input, one per line. The exit status is 1 if any
+// client ID is malformed.
+//
+// convert prints a key in another format: as a client ID, a JWK, a PEM
+// public key, or an OpenSSH public key. The key may be given in any of those
+// formats, as an argument, as the name of a file holding it, or as - to read
+// it from standard input.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: wskeyauth inspect <client ID>...")
+	fmt.Fprintln(os.Stderr, "       wskeyauth convert clientid|jwk|pem|ssh <key>")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "inspect":
+		os.Exit(inspectAll(os.Stdout, os.Stdin, os.Args[2:]))
+	case "convert":
+		os.Exit(convertMain(os.Args[2:]))
+	default:
+		usage()
+	}
+}
+
+func inspectAll(w io.Writer, stdin io.Reader, args []string) int {
+	if len(args) == 0 {
+		usage()
+	}
+
+	var clientIDs []string
+	for _, arg := range args {
+		if arg != "-" {
+			clientIDs = append(clientIDs, arg)
+			continue
+		}
+		scanner := bufio.NewScanner(stdin)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				clientIDs = append(clientIDs, line)
+			}
+		}
+	}
+
+	status := 0
+	for i, clientID := range clientIDs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if !inspect(w, clientID) {
+			status = 1
+		}
+	}
+	return status
+}
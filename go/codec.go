@@ -0,0 +1,442 @@
+package wskeyauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// ClientMessage is a decoded protocol message from the client.
+type ClientMessage struct {
+	Type string
+
+	// ClientID is the data of a CLIENT_ID message.
+	ClientID string
+
+	// Challenge is the challenge a client that wants the server to prove its
+	// identity sends alongside its CLIENT_ID.
+	Challenge string
+
+	// Timestamp is the signed timestamp a client may send alongside its
+	// CLIENT_ID, to skip the challenge.
+	Timestamp *SignedTimestamp
+
+	// Signature and Hash are the data of a CHALLENGE_RESPONSE message.
+	Signature string
+	Hash      string
+
+	// AuthenticatorData and ClientDataJSON complete the WebAuthn assertion
+	// in the CHALLENGE_RESPONSE of a WebAuthn client.
+	AuthenticatorData string
+	ClientDataJSON    string
+
+	// IDToken is the OIDC ID token a client may present in its
+	// CHALLENGE_RESPONSE.
+	IDToken string
+
+	// ProofOfWork is the nonce of the proof of work in the
+	// CHALLENGE_RESPONSE of a client that was asked for one.
+	ProofOfWork string
+
+	// EchoedChallenge is the challenge a client signed, as it echoes it back
+	// in its CHALLENGE_RESPONSE for servers using WithStatelessChallenges.
+	EchoedChallenge string
+
+	// SecondFactor is the data of a SECOND_FACTOR message.
+	SecondFactor string
+
+	// Ephemeral is the public value A in the CHALLENGE_RESPONSE of a password
+	// client, whose Signature is its proof.
+	Ephemeral string
+}
+
+// MessageError reports that a message was read, but its data could not be
+// decoded.
+type MessageError struct {
+	Type string
+	Err  error
+}
+
+func (e *MessageError) Error() string {
+	return "failed to parse " + e.Type + ": " + e.Err.Error()
+}
+
+func (e *MessageError) Unwrap() error {
+	return e.Err
+}
+
+// Codec reads client messages off a connection.
+//
+// ReadMessage must set msg.Type whenever it managed to read a message at all.
+// If the message's data couldn't be decoded, it returns a *MessageError;
+// any other error is treated as the connection having failed.
+//
+// Codecs read messages as JSON. With WithEncoding, messages are encoded
+// otherwise on the wire, and converted to JSON before the codec reads them.
+type Codec interface {
+	ReadMessage(conn Conn, msg *ClientMessage) error
+}
+
+// WithCodec sets the codec client messages are read with. It defaults to
+// JSONCodec.
+func WithCodec(codec Codec) Option {
+	return func(cfg *config) {
+		cfg.codec = codec
+	}
+}
+
+// JSONCodec decodes each message generically, as a TypeData, and then decodes
+// its data according to the message's type.
+var JSONCodec Codec = jsonCodec{}
+
+// CompactJSONCodec decodes each message in a single pass into a preallocated
+// message, without an intermediate json.RawMessage. It accepts exactly what
+// JSONCodec does, and allocates less doing so.
+var CompactJSONCodec Codec = compactJSONCodec{}
+
+// StrictJSONCodec decodes messages as JSONCodec does, but only accepts the
+// exact wire format: unknown fields, duplicate keys, and missing or null data
+// are all errors, and are reported to the client precisely. JSONCodec stays
+// the default so that clients can grow new fields without breaking older
+// servers.
+var StrictJSONCodec Codec = strictJSONCodec{}
+
+type jsonCodec struct{}
+
+// envelope is a TypeData, along with the members clients may send next to
+// "data".
+type envelope struct {
+	TypeData
+	Challenge string             `json:"challenge"`
+	Timestamp *SignedTimestamp   `json:"timestamp"`
+	Response  *challengeResponse `json:"response"`
+}
+
+var envelopePool = sync.Pool{
+	New: func() any { return new(envelope) },
+}
+
+func (jsonCodec) ReadMessage(conn Conn, msg *ClientMessage) error {
+	td := envelopePool.Get().(*envelope)
+	defer func() {
+		// Data keeps its capacity for the next message
+		td.Type = ""
+		td.Data = td.Data[:0]
+		td.Challenge = ""
+		td.Timestamp = nil
+		td.Response = nil
+		envelopePool.Put(td)
+	}()
+
+	if err := conn.ReadJSON(td); err != nil {
+		return err
+	}
+
+	*msg = ClientMessage{Type: td.Type}
+
+	var err error
+	switch td.Type {
+	case "CLIENT_ID":
+		err = json.Unmarshal(td.Data, &msg.ClientID)
+		msg.Challenge, msg.Timestamp = td.Challenge, td.Timestamp
+		if td.Response != nil {
+			td.Response.copyTo(msg)
+		}
+	case "SECOND_FACTOR":
+		err = json.Unmarshal(td.Data, &msg.SecondFactor)
+	case "CHALLENGE_RESPONSE":
+		var response challengeResponse
+		err = json.Unmarshal(td.Data, &response)
+		response.copyTo(msg)
+	}
+	if err != nil {
+		return &MessageError{Type: td.Type, Err: err}
+	}
+	return nil
+}
+
+type compactJSONCodec struct{}
+
+// compactMessage is decoded straight off the connection. As "data" may come
+// before "type", data is decoded by its shape, and checked against the type
+// afterwards.
+type compactMessage struct {
+	Type      string           `json:"type"`
+	Data      compactData      `json:"data"`
+	Challenge string           `json:"challenge"`
+	Timestamp *SignedTimestamp `json:"timestamp"`
+
+	Response *challengeResponse `json:"response"`
+}
+
+type compactData struct {
+	// first byte of the JSON value, or 0 if there was none
+	kind     byte
+	str      string
+	response challengeResponse
+	err      error
+}
+
+func (d *compactData) UnmarshalJSON(b []byte) error {
+	// with duplicate "data" members, the last one wins, as with JSONCodec
+	*d = compactData{kind: b[0]}
+	switch d.kind {
+	case '"':
+		// strings without escapes are taken verbatim, unless they'd need invalid
+		// UTF-8 replaced
+		if bytes.IndexByte(b, '\\') < 0 && utf8.Valid(b) {
+			d.str = string(b[1 : len(b)-1])
+		} else {
+			d.err = json.Unmarshal(b, &d.str)
+		}
+	case '{':
+		d.err = json.Unmarshal(b, &d.response)
+	}
+	return nil
+}
+
+var compactMessagePool = sync.Pool{
+	New: func() any { return new(compactMessage) },
+}
+
+var (
+	errMissingData     = errors.New("unexpected end of JSON input")
+	errNotAString      = errors.New("expected data to be a string")
+	errNotAnObject     = errors.New("expected data to be an object")
+	errMalformedString = errors.New("malformed string")
+)
+
+func (compactJSONCodec) ReadMessage(conn Conn, msg *ClientMessage) error {
+	m := compactMessagePool.Get().(*compactMessage)
+	defer func() {
+		*m = compactMessage{}
+		compactMessagePool.Put(m)
+	}()
+
+	if err := conn.ReadJSON(m); err != nil {
+		return err
+	}
+
+	*msg = ClientMessage{Type: m.Type}
+
+	var err error
+	switch m.Type {
+	case "CLIENT_ID":
+		msg.Challenge, msg.Timestamp = m.Challenge, m.Timestamp
+		if m.Response != nil {
+			m.Response.copyTo(msg)
+		}
+		switch m.Data.kind {
+		case '"':
+			msg.ClientID, err = m.Data.str, m.Data.err
+			if err != nil {
+				err = errMalformedString
+			}
+		case 'n':
+		case 0:
+			err = errMissingData
+		default:
+			err = errNotAString
+		}
+	case "SECOND_FACTOR":
+		switch m.Data.kind {
+		case '"':
+			msg.SecondFactor, err = m.Data.str, m.Data.err
+			if err != nil {
+				err = errMalformedString
+			}
+		case 'n':
+		case 0:
+			err = errMissingData
+		default:
+			err = errNotAString
+		}
+	case "CHALLENGE_RESPONSE":
+		switch m.Data.kind {
+		case '{':
+			m.Data.response.copyTo(msg)
+			err = m.Data.err
+		case 'n':
+		case 0:
+			err = errMissingData
+		default:
+			err = errNotAnObject
+		}
+	}
+	if err != nil {
+		return &MessageError{Type: m.Type, Err: err}
+	}
+	return nil
+}
+
+type strictJSONCodec struct{}
+
+func (strictJSONCodec) ReadMessage(conn Conn, msg *ClientMessage) error {
+	var raw json.RawMessage
+	if err := conn.ReadJSON(&raw); err != nil {
+		return err
+	}
+
+	// anything JSONCodec can't read at all isn't a message
+	var td TypeData
+	if err := json.Unmarshal(raw, &td); err != nil {
+		return err
+	}
+
+	*msg = ClientMessage{Type: td.Type}
+
+	if err := decodeStrict(raw, msg); err != nil {
+		return &MessageError{Type: td.Type, Err: err}
+	}
+	return nil
+}
+
+func decodeStrict(raw json.RawMessage, msg *ClientMessage) error {
+	if err := checkDuplicateKeys(raw); err != nil {
+		return err
+	}
+
+	var envelope struct {
+		Type      *string         `json:"type"`
+		Data      json.RawMessage `json:"data"`
+		Challenge *string         `json:"challenge"`
+		Timestamp *struct {
+			Time      *int64  `json:"time"`
+			Signature *string `json:"signature"`
+		} `json:"timestamp"`
+		Response json.RawMessage `json:"response"`
+	}
+	if err := unmarshalStrict(raw, &envelope); err != nil {
+		return err
+	}
+	if envelope.Type == nil {
+		return errors.New(`missing "type"`)
+	}
+	if len(envelope.Data) == 0 || string(envelope.Data) == "null" {
+		return errors.New(`missing "data"`)
+	}
+
+	if envelope.Challenge != nil && msg.Type != "CLIENT_ID" {
+		return errors.New(`unexpected "challenge"`)
+	}
+	if envelope.Timestamp != nil && msg.Type != "CLIENT_ID" {
+		return errors.New(`unexpected "timestamp"`)
+	}
+	if envelope.Response != nil && msg.Type != "CLIENT_ID" {
+		return errors.New(`unexpected "response"`)
+	}
+
+	switch msg.Type {
+	case "CLIENT_ID":
+		if envelope.Challenge != nil {
+			msg.Challenge = *envelope.Challenge
+		}
+		if ts := envelope.Timestamp; ts != nil {
+			if ts.Time == nil || ts.Signature == nil {
+				return errors.New(`timestamp must have a "time" and a "signature"`)
+			}
+			msg.Timestamp = &SignedTimestamp{Time: *ts.Time, Signature: *ts.Signature}
+		}
+		if envelope.Response != nil {
+			if err := decodeStrictResponse(envelope.Response, msg); err != nil {
+				return fmt.Errorf("response: %w", err)
+			}
+		}
+		return unmarshalStrict(envelope.Data, &msg.ClientID)
+	case "SECOND_FACTOR":
+		return unmarshalStrict(envelope.Data, &msg.SecondFactor)
+	case "CHALLENGE_RESPONSE":
+		return decodeStrictResponse(envelope.Data, msg)
+	}
+	return nil
+}
+
+// decodeStrictResponse decodes the data of a CHALLENGE_RESPONSE into msg.
+func decodeStrictResponse(data json.RawMessage, msg *ClientMessage) error {
+	var response struct {
+		Signature         *string `json:"signature"`
+		Hash              *string `json:"hash"`
+		AuthenticatorData string  `json:"authenticatorData"`
+		ClientDataJSON    string  `json:"clientDataJSON"`
+		Ephemeral         string  `json:"ephemeral"`
+		IDToken           string  `json:"idToken"`
+		ProofOfWork       string  `json:"proofOfWork"`
+		Challenge         string  `json:"challenge"`
+	}
+	if err := unmarshalStrict(data, &response); err != nil {
+		return err
+	}
+	if response.Signature == nil {
+		return errors.New(`data is missing "signature"`)
+	}
+	if response.Hash == nil {
+		return errors.New(`data is missing "hash"`)
+	}
+	msg.Signature, msg.Hash = *response.Signature, *response.Hash
+	msg.AuthenticatorData, msg.ClientDataJSON = response.AuthenticatorData, response.ClientDataJSON
+	msg.Ephemeral, msg.IDToken = response.Ephemeral, response.IDToken
+	msg.ProofOfWork, msg.EchoedChallenge = response.ProofOfWork, response.Challenge
+	return nil
+}
+
+func unmarshalStrict(b []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// checkDuplicateKeys reports the first key that appears twice in the same
+// object, anywhere in b.
+func checkDuplicateKeys(b []byte) error {
+	return walkJSON(json.NewDecoder(bytes.NewReader(b)), "")
+}
+
+func walkJSON(dec *json.Decoder, path string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		seen := map[string]bool{}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := tok.(string)
+			if seen[key] {
+				if path == "" {
+					return fmt.Errorf("duplicate key %q", key)
+				}
+				return fmt.Errorf("duplicate key %q in %s", key, path)
+			}
+			seen[key] = true
+
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			if err := walkJSON(dec, child); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := walkJSON(dec, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+
+	// the closing delimiter
+	_, err = dec.Token()
+	return err
+}
@@ -0,0 +1,131 @@
+package conformance
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// ClientChecker is an http.Handler that plays the server side of the
+// handshake, with the vectors' fixed challenge, against a client
+// implementation under test. It reports how every connection went to
+// OnResult.
+type ClientChecker struct {
+	Upgrader websocket.Upgrader
+	OnResult func(Result)
+}
+
+func (c *ClientChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := c.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	result := Result{Name: "client handshake from " + r.RemoteAddr, Expected: "SIGNATURE_MATCHES"}
+	result.Got, result.Err = checkClient(conn)
+
+	if c.OnResult != nil {
+		c.OnResult(result)
+	}
+}
+
+func checkClient(conn *websocket.Conn) (string, error) {
+	v, err := Load()
+	if err != nil {
+		return "", err
+	}
+
+	var td wskeyauth.TypeData
+	if err := conn.ReadJSON(&td); err != nil {
+		return "", err
+	}
+	if td.Type != "CLIENT_ID" {
+		return "", fmt.Errorf("expected CLIENT_ID, got %s", td.Type)
+	}
+
+	var clientID string
+	if err := json.Unmarshal(td.Data, &clientID); err != nil {
+		return "", fmt.Errorf("CLIENT_ID data is not a string: %w", err)
+	}
+	pub, err := publicKey(clientID)
+	if err != nil {
+		return "", fmt.Errorf("client sent an invalid client ID: %w", err)
+	}
+
+	conn.WriteJSON(map[string]string{"type": "CHALLENGE", "data": v.ClientChallenge})
+
+	if err := conn.ReadJSON(&td); err != nil {
+		return "", err
+	}
+	if td.Type != "CHALLENGE_RESPONSE" {
+		return "", fmt.Errorf("expected CHALLENGE_RESPONSE, got %s", td.Type)
+	}
+
+	var response struct {
+		Signature string `json:"signature"`
+		Hash      string `json:"hash"`
+	}
+	if err := json.Unmarshal(td.Data, &response); err != nil {
+		return "", fmt.Errorf("CHALLENGE_RESPONSE data is malformed: %w", err)
+	}
+	if response.Hash != "SHA-256" {
+		return "", fmt.Errorf("expected hash SHA-256, got %s", response.Hash)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(response.Signature)
+	if err != nil {
+		return "", fmt.Errorf("signature is not standard base64: %w", err)
+	}
+	if len(sig) != 64 {
+		return "", fmt.Errorf("expected a 64 byte signature, got %d bytes", len(sig))
+	}
+
+	challenge, _ := base64.StdEncoding.DecodeString(v.ClientChallenge)
+	hash := sha256.Sum256(challenge)
+	if !ecdsa.Verify(pub, hash[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		conn.WriteJSON(map[string]string{"type": "SIGNATURE_MISMATCH"})
+		return "SIGNATURE_MISMATCH", nil
+	}
+
+	conn.WriteJSON(map[string]string{"type": "SIGNATURE_MATCHES"})
+	return "SIGNATURE_MATCHES", nil
+}
+
+// publicKey parses a client ID independently of the wskeyauth package, so
+// that the checker doesn't inherit its bugs.
+func publicKey(clientID string) (*ecdsa.PublicKey, error) {
+	prefix, encoded, ok := strings.Cut(clientID, "$")
+	if !ok || prefix != "WebCrypto-raw.EC.P-256" {
+		return nil, errors.New("expected a WebCrypto-raw.EC.P-256$ prefix")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != 65 || raw[0] != 4 {
+		return nil, errors.New("expected an uncompressed P-256 point")
+	}
+
+	pub := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(raw[1:33]),
+		Y:     new(big.Int).SetBytes(raw[33:]),
+	}
+	if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+		return nil, errors.New("point is not on P-256")
+	}
+	return pub, nil
+}
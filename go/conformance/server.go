@@ -0,0 +1,99 @@
+package conformance
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+// RunServer checks the server at url (a ws:// or wss:// URL) against every
+// client ID vector and server scenario, on a fresh connection each. dialer
+// may be nil to use websocket.DefaultDialer.
+func RunServer(ctx context.Context, url string, dialer *websocket.Dialer) ([]Result, error) {
+	v, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+
+	var results []Result
+
+	for _, vector := range v.ClientIDs {
+		expected := "CLIENT_ERROR"
+		if vector.Valid {
+			expected = "CHALLENGE"
+		}
+
+		client := &wskeyauthtest.Client{
+			FirstMessage: map[string]any{"type": "CLIENT_ID", "data": vector.ClientID},
+		}
+		results = append(results, runServerScenario(ctx, url, dialer, "client ID: "+vector.Name, expected, client))
+	}
+
+	for _, scenario := range v.ServerScenarios {
+		client, err := scenario.client(v)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, runServerScenario(ctx, url, dialer, scenario.Name, scenario.Expect, client))
+	}
+
+	return results, nil
+}
+
+func (s ServerScenario) client(v *Vectors) (*wskeyauthtest.Client, error) {
+	key, err := v.Key(s.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &wskeyauthtest.Client{Key: key, ClientID: s.ClientID, Hash: s.Hash}
+
+	if s.FirstMessage != nil {
+		client.FirstMessage = s.FirstMessage
+	}
+
+	if s.SignWith != "" {
+		client.SignWith, err = v.Key(s.SignWith)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.TruncateSignature > 0 {
+		n := s.TruncateSignature
+		client.TamperSignature = func(signature string) string {
+			sig, err := base64.StdEncoding.DecodeString(signature)
+			if err != nil || len(sig) < n {
+				return signature
+			}
+			return base64.StdEncoding.EncodeToString(sig[:n])
+		}
+	}
+
+	return client, nil
+}
+
+func runServerScenario(ctx context.Context, url string, dialer *websocket.Dialer, name, expected string, client *wskeyauthtest.Client) Result {
+	r := Result{Name: name, Expected: expected}
+
+	conn, _, err := dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		r.Err = fmt.Errorf("failed to connect: %w", err)
+		return r
+	}
+	defer conn.Close()
+
+	result, err := client.Run(conn)
+	r.Got = result.Last()
+	if err != nil && r.Got == "" {
+		r.Err = err
+	}
+	return r
+}
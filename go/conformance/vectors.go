@@ -0,0 +1,170 @@
+// Package conformance holds canonical test vectors for the ws-key-auth
+// protocol, and runners that check a server or client implementation
+// against them over a real WebSocket.
+//
+// The vectors live in vectors.json, so that implementations in other
+// languages (such as the browser client in this repository) can load the
+// very same file in their own test suites.
+package conformance
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+//go:embed vectors.json
+var vectorsJSON []byte
+
+// Vectors is the contents of vectors.json.
+type Vectors struct {
+	// Keys are fixed key pairs that the other vectors refer to by name.
+	Keys []KeyVector `json:"keys"`
+
+	// ClientIDs are client IDs that servers must accept or reject.
+	ClientIDs []ClientIDVector `json:"client_ids"`
+
+	// Signatures are challenge responses that servers must verify or reject.
+	Signatures []SignatureVector `json:"signatures"`
+
+	// ServerScenarios are whole handshakes a client may play against a server,
+	// and the message the server must end them with.
+	ServerScenarios []ServerScenario `json:"server_scenarios"`
+
+	// ClientChallenge is the base64 challenge sent to clients under test.
+	ClientChallenge string `json:"client_challenge"`
+}
+
+type KeyVector struct {
+	Name string `json:"name"`
+	// PrivateKey is the hex encoded P-256 private scalar.
+	PrivateKey string `json:"private_key"`
+	ClientID   string `json:"client_id"`
+}
+
+type ClientIDVector struct {
+	Name     string `json:"name"`
+	ClientID string `json:"client_id"`
+	Valid    bool   `json:"valid"`
+}
+
+type SignatureVector struct {
+	Name      string `json:"name"`
+	ClientID  string `json:"client_id"`
+	Challenge string `json:"challenge"`
+	Signature string `json:"signature"`
+	Hash      string `json:"hash"`
+	Valid     bool   `json:"valid"`
+}
+
+type ServerScenario struct {
+	Name string `json:"name"`
+	// Key names the key the client plays the handshake with.
+	Key string `json:"key"`
+	// ClientID, if set, is sent instead of the key's client ID.
+	ClientID string `json:"client_id,omitempty"`
+	// FirstMessage, if set, is sent instead of CLIENT_ID, and the server's
+	// reply to it is the outcome.
+	FirstMessage json.RawMessage `json:"first_message,omitempty"`
+	// SignWith, if set, names the key that signs the challenge instead.
+	SignWith string `json:"sign_with,omitempty"`
+	// Hash, if set, is reported instead of SHA-256.
+	Hash string `json:"hash,omitempty"`
+	// TruncateSignature, if set, is the number of signature bytes to send.
+	TruncateSignature int `json:"truncate_signature,omitempty"`
+	// Expect is the type of the server's final message.
+	Expect string `json:"expect"`
+}
+
+// Load parses the embedded vectors.
+func Load() (*Vectors, error) {
+	var v Vectors
+	if err := json.Unmarshal(vectorsJSON, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Key returns the named key pair.
+func (v *Vectors) Key(name string) (*wskeyauthtest.Key, error) {
+	for _, k := range v.Keys {
+		if k.Name == name {
+			return k.Key()
+		}
+	}
+	return nil, fmt.Errorf("conformance: no key named %q", name)
+}
+
+// Key decodes the key pair.
+func (k KeyVector) Key() (*wskeyauthtest.Key, error) {
+	d, err := hex.DecodeString(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	priv := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(d)}
+	priv.Curve = elliptic.P256()
+	priv.X, priv.Y = priv.Curve.ScalarBaseMult(d)
+
+	return &wskeyauthtest.Key{Private: priv}, nil
+}
+
+// KeyVectorFromSeed returns a vector for the key wskeyauthtest.KeyFromSeed
+// derives from seed, so that new vectors can be generated reproducibly.
+func KeyVectorFromSeed(name, seed string) KeyVector {
+	k := wskeyauthtest.KeyFromSeed(seed)
+	return KeyVector{
+		Name:       name,
+		PrivateKey: hex.EncodeToString(k.Private.D.FillBytes(make([]byte, 32))),
+		ClientID:   k.ClientID(),
+	}
+}
+
+// SignatureVectorFromSeed returns a valid signature vector: the challenge
+// derived from challengeSeed, signed by the key derived from keySeed. It is
+// the same every time.
+func SignatureVectorFromSeed(name, keySeed, challengeSeed string) (SignatureVector, error) {
+	k := wskeyauthtest.KeyFromSeed(keySeed)
+	challenge := wskeyauthtest.ChallengeFromSeed(challengeSeed)
+	signature, err := k.SignChallenge(challenge)
+	if err != nil {
+		return SignatureVector{}, err
+	}
+	return SignatureVector{
+		Name:      name,
+		ClientID:  k.ClientID(),
+		Challenge: challenge,
+		Signature: signature,
+		Hash:      "SHA-256",
+		Valid:     true,
+	}, nil
+}
+
+// Result is the outcome of checking an implementation against one vector.
+type Result struct {
+	Name     string
+	Expected string
+	Got      string
+	Err      error
+}
+
+func (r Result) Passed() bool {
+	return r.Err == nil && r.Expected == r.Got
+}
+
+func (r Result) String() string {
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("FAIL %s: %v", r.Name, r.Err)
+	case !r.Passed():
+		return fmt.Sprintf("FAIL %s: expected %s, got %s", r.Name, r.Expected, r.Got)
+	default:
+		return "PASS " + r.Name
+	}
+}
@@ -0,0 +1,50 @@
+// Package wskeyauthfiber runs the ws-key-auth handshake on Fiber servers, using
+// fasthttp's WebSocket implementation rather than bridging to net/http.
+package wskeyauthfiber
+
+import (
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// Handler is called with every connection that completed the handshake. The
+// connection is closed once the handler returns.
+type Handler func(conn *websocket.Conn, clientID string)
+
+// RequireUpgrade is a middleware that rejects requests that aren't WebSocket
+// upgrades with 426 Upgrade Required. Mount it in front of New.
+func RequireUpgrade(c *fiber.Ctx) error {
+	if websocket.IsWebSocketUpgrade(c) {
+		return c.Next()
+	}
+	return fiber.ErrUpgradeRequired
+}
+
+// Config configures New.
+type Config struct {
+	// Options are the options of the handshake.
+	Options []wskeyauth.Option
+
+	// WebSocket configures the upgrade, as websocket.New takes it.
+	WebSocket websocket.Config
+}
+
+// New returns a Fiber handler that upgrades the request, performs the
+// handshake, and hands authenticated connections off to handler. Connections
+// that fail to authenticate are closed without calling handler.
+func New(handler Handler, config ...Config) fiber.Handler {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	return websocket.New(func(conn *websocket.Conn) {
+		authenticated, clientID, err := wskeyauth.Handshake(conn, cfg.Options...)
+		if !authenticated || err != nil {
+			return
+		}
+
+		handler(conn, clientID)
+	}, cfg.WebSocket)
+}
@@ -0,0 +1,220 @@
+// Package wskeyauthgrpc authenticates gRPC services that are tunneled over
+// WebSockets. Clients run the key handshake on the WebSocket before any gRPC
+// traffic flows, and the verified client ID is then made available to every
+// RPC on that connection.
+//
+// A typical server looks like:
+//
+//	lis := wskeyauthgrpc.NewListener(websocket.Upgrader{})
+//	srv := grpc.NewServer(
+//		grpc.Creds(wskeyauthgrpc.Credentials()),
+//		grpc.UnaryInterceptor(wskeyauthgrpc.UnaryServerInterceptor()),
+//		grpc.StreamInterceptor(wskeyauthgrpc.StreamServerInterceptor()),
+//	)
+//	go srv.Serve(lis)
+//	http.Handle("/grpc", lis)
+package wskeyauthgrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// MetadataKey is the incoming metadata key that carries the verified client
+// ID. Any value the client sent under this key is overwritten.
+const MetadataKey = "wskeyauth-client-id"
+
+// ErrListenerClosed is returned by Accept once the listener has been closed.
+var ErrListenerClosed = errors.New("wskeyauthgrpc: listener closed")
+
+// Listener is a net.Listener (for handing to grpc.Server.Serve) and an
+// http.Handler. Every request it serves is upgraded to a WebSocket and put
+// through the key handshake; only authenticated connections are accepted.
+type Listener struct {
+	upgrader websocket.Upgrader
+	opts     []wskeyauth.Option
+
+	conns     chan *Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewListener creates a listener that upgrades requests with upgrader, and
+// runs handshakes with opts.
+func NewListener(upgrader websocket.Upgrader, opts ...wskeyauth.Option) *Listener {
+	return &Listener{
+		upgrader: upgrader,
+		opts:     opts,
+		conns:    make(chan *Conn),
+		done:     make(chan struct{}),
+	}
+}
+
+func (l *Listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := l.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	authenticated, clientID, err := wskeyauth.Handshake(ws, l.opts...)
+	if !authenticated || err != nil {
+		ws.Close()
+		return
+	}
+
+	conn := &Conn{ws: ws, clientID: clientID, secure: r.TLS != nil}
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		ws.Close()
+	}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, ErrListenerClosed
+	}
+}
+
+func (l *Listener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *Listener) Addr() net.Addr {
+	return addr{}
+}
+
+type addr struct{}
+
+func (addr) Network() string { return "websocket" }
+func (addr) String() string  { return "websocket" }
+
+// AuthInfo is the credentials.AuthInfo attached to the peer of every RPC
+// served over a Listener connection.
+type AuthInfo struct {
+	credentials.CommonAuthInfo
+	ClientID string
+}
+
+func (AuthInfo) AuthType() string {
+	return "wskeyauth"
+}
+
+type transportCredentials struct{}
+
+// Credentials returns server transport credentials that surface the client ID
+// of Listener connections as an AuthInfo. The handshake itself has already
+// run by the time gRPC sees the connection, so connections from anywhere else
+// are refused.
+func Credentials() credentials.TransportCredentials {
+	return transportCredentials{}
+}
+
+func (transportCredentials) ServerHandshake(c net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, ok := c.(*Conn)
+	if !ok {
+		return nil, nil, errors.New("wskeyauthgrpc: connection was not accepted by a wskeyauthgrpc.Listener")
+	}
+
+	level := credentials.NoSecurity
+	if conn.secure {
+		level = credentials.PrivacyAndIntegrity
+	}
+
+	return conn, AuthInfo{
+		CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: level},
+		ClientID:       conn.clientID,
+	}, nil
+}
+
+func (transportCredentials) ClientHandshake(context.Context, string, net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("wskeyauthgrpc: client handshakes are not supported")
+}
+
+func (transportCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "wskeyauth"}
+}
+
+func (c transportCredentials) Clone() credentials.TransportCredentials {
+	return c
+}
+
+func (transportCredentials) OverrideServerName(string) error {
+	return nil
+}
+
+// ClientIDFromContext returns the verified client ID of the peer that issued
+// the RPC.
+func ClientIDFromContext(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	info, ok := p.AuthInfo.(AuthInfo)
+	if !ok {
+		return "", false
+	}
+	return info.ClientID, true
+}
+
+func withClientID(ctx context.Context) (context.Context, error) {
+	clientID, ok := ClientIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "connection is not authenticated")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+	md.Set(MetadataKey, clientID)
+	return metadata.NewIncomingContext(ctx, md), nil
+}
+
+// UnaryServerInterceptor injects the verified client ID into the incoming
+// metadata of every unary RPC, and rejects RPCs from unauthenticated peers.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := withClientID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := withClientID(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
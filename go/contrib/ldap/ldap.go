@@ -0,0 +1,180 @@
+// Package wskeyauthldap provides a wskeyauth.KeyStore of the keys listed in
+// an LDAP directory, for organizations whose device inventory already lives
+// in one.
+//
+// It doesn't bundle an LDAP client; Directory adapts whichever one the
+// application already uses. With github.com/go-ldap/ldap/v3, for instance:
+//
+//	dir := wskeyauthldap.DirectoryFunc(func(ctx context.Context, base, filter, attribute string) ([]string, error) {
+//		res, err := conn.Search(ldap.NewSearchRequest(base, ldap.ScopeWholeSubtree,
+//			ldap.NeverDerefAliases, 0, 0, false, filter, []string{attribute}, nil))
+//		if err != nil {
+//			return nil, err
+//		}
+//		var values []string
+//		for _, entry := range res.Entries {
+//			values = append(values, entry.GetAttributeValues(attribute)...)
+//		}
+//		return values, nil
+//	})
+//	store := wskeyauthldap.New(dir, wskeyauthldap.Config{
+//		BaseDN:    "ou=devices,dc=example,dc=com",
+//		Filter:    "(objectClass=device)",
+//		Attribute: "sshPublicKey",
+//	})
+//	wskeyauth.Handshake(conn, wskeyauth.WithKeyStore(store))
+package wskeyauthldap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// Directory searches an LDAP directory.
+type Directory interface {
+	// Search returns every value of attribute, on every entry under base
+	// that matches filter.
+	Search(ctx context.Context, base, filter, attribute string) ([]string, error)
+}
+
+// DirectoryFunc is a Directory that calls itself.
+type DirectoryFunc func(ctx context.Context, base, filter, attribute string) ([]string, error)
+
+func (f DirectoryFunc) Search(ctx context.Context, base, filter, attribute string) ([]string, error) {
+	return f(ctx, base, filter, attribute)
+}
+
+// DefaultRefreshInterval is how long keys are cached for, unless
+// Config.RefreshInterval says otherwise.
+const DefaultRefreshInterval = 5 * time.Minute
+
+// Config says where in the directory keys are.
+type Config struct {
+	// BaseDN is where the search starts.
+	BaseDN string
+
+	// Filter selects the entries that hold keys. It defaults to
+	// "(objectClass=*)".
+	Filter string
+
+	// Attribute is the attribute keys are in. Its values may be client IDs,
+	// OpenSSH public keys, as in the sshPublicKey attribute, or fingerprints,
+	// as returned by wskeyauth.Fingerprint. Values that are none of these are
+	// skipped.
+	Attribute string
+
+	// ByFingerprint searches for each client's fingerprint as it connects,
+	// rather than loading every key, for attributes that only hold
+	// fingerprints. Nothing is cached then.
+	ByFingerprint bool
+
+	// RefreshInterval is how long keys are cached for before they are loaded
+	// again. It defaults to DefaultRefreshInterval.
+	RefreshInterval time.Duration
+}
+
+// KeyStore is a wskeyauth.KeyStore of the keys in a directory.
+type KeyStore struct {
+	dir Directory
+	cfg Config
+
+	mu           sync.Mutex
+	fingerprints map[string]bool
+	loadedAt     time.Time
+}
+
+var _ wskeyauth.KeyStore = (*KeyStore)(nil)
+
+// New creates a KeyStore of the keys in dir, as cfg locates them. Keys are
+// loaded on the first lookup.
+func New(dir Directory, cfg Config) *KeyStore {
+	if cfg.Filter == "" {
+		cfg.Filter = "(objectClass=*)"
+	}
+	if cfg.RefreshInterval == 0 {
+		cfg.RefreshInterval = DefaultRefreshInterval
+	}
+	return &KeyStore{dir: dir, cfg: cfg}
+}
+
+// Lookup reports whether the key with fingerprint is in the directory. An
+// error searching it fails the handshake, rather than serving keys that
+// might have since been removed.
+func (s *KeyStore) Lookup(ctx context.Context, fingerprint string) (bool, error) {
+	if s.cfg.ByFingerprint {
+		filter := "(&" + s.cfg.Filter + "(" + s.cfg.Attribute + "=" + escapeFilter(fingerprint) + "))"
+		values, err := s.dir.Search(ctx, s.cfg.BaseDN, filter, s.cfg.Attribute)
+		if err != nil {
+			return false, err
+		}
+		for _, v := range values {
+			if strings.TrimSpace(v) == fingerprint {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fingerprints == nil || time.Since(s.loadedAt) >= s.cfg.RefreshInterval {
+		if err := s.load(ctx); err != nil {
+			return false, err
+		}
+	}
+	return s.fingerprints[fingerprint], nil
+}
+
+// Refresh loads the keys again, without waiting for the refresh interval to
+// pass, for when a key was just revoked.
+func (s *KeyStore) Refresh(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(ctx)
+}
+
+func (s *KeyStore) load(ctx context.Context) error {
+	values, err := s.dir.Search(ctx, s.cfg.BaseDN, s.cfg.Filter, s.cfg.Attribute)
+	if err != nil {
+		return err
+	}
+
+	fingerprints := make(map[string]bool, len(values))
+	for _, v := range values {
+		if fp, ok := fingerprintOf(v); ok {
+			fingerprints[fp] = true
+		}
+	}
+	s.fingerprints, s.loadedAt = fingerprints, time.Now()
+	return nil
+}
+
+// fingerprintOf returns the fingerprint of the key an attribute value holds.
+func fingerprintOf(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "SHA256:") {
+		return value, true
+	}
+	fp, err := wskeyauth.Fingerprint(value)
+	return fp, err == nil
+}
+
+// escapeFilter escapes a value for use in a search filter, as in RFC 4515.
+func escapeFilter(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\', '*', '(', ')', 0:
+			fmt.Fprintf(&b, `\%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
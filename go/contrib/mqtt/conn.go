@@ -0,0 +1,256 @@
+package wskeyauthmqtt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxConnectSize bounds the CONNECT packet, which is read whole to rewrite
+// it. It leaves room for a will message and a password, but not for clients
+// to make the server buffer whatever they like.
+const maxConnectSize = 64 << 10
+
+// ErrNotConnect is returned by Conn.Read if the client's first packet isn't
+// a CONNECT.
+var ErrNotConnect = errors.New("wskeyauthmqtt: expected a CONNECT packet")
+
+var errMalformedConnect = errors.New("wskeyauthmqtt: malformed CONNECT packet")
+
+// Conn is a net.Conn of the MQTT packets carried over binary WebSocket
+// messages, belonging to a client that completed the key handshake. The
+// client identifier of its CONNECT is replaced with the client's.
+type Conn struct {
+	ws         *websocket.Conn
+	clientID   string
+	identifier string
+
+	readMu    sync.Mutex
+	reader    io.Reader
+	connected bool
+	pending   []byte
+
+	writeMu sync.Mutex
+}
+
+// ClientID returns the ID the client authenticated with.
+func (c *Conn) ClientID() string {
+	return c.clientID
+}
+
+// Identifier returns the client identifier the client's CONNECT carries.
+func (c *Conn) Identifier() string {
+	return c.identifier
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	if !c.connected {
+		packet, err := c.readConnect()
+		if err != nil {
+			return 0, err
+		}
+		c.pending = packet
+		c.connected = true
+	}
+	if len(c.pending) > 0 {
+		n := copy(p, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+	return c.read(p)
+}
+
+// readConnect reads the client's CONNECT, and returns it with the client's
+// identifier.
+func (c *Conn) readConnect() ([]byte, error) {
+	r := &byteReader{read: c.read}
+
+	first, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if first>>4 != 1 {
+		return nil, ErrNotConnect
+	}
+	length, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxConnectSize {
+		return nil, errMalformedConnect
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	// protocol name, level, flags and keep alive
+	if len(body) < 2 {
+		return nil, errMalformedConnect
+	}
+	at := 2 + int(binary.BigEndian.Uint16(body))
+	if len(body) < at+4 {
+		return nil, errMalformedConnect
+	}
+	level := body[at]
+	at += 4
+	if level == 5 {
+		properties, n, err := uvarint(body[at:])
+		if err != nil {
+			return nil, err
+		}
+		at += n + properties
+	}
+	if len(body) < at+2 {
+		return nil, errMalformedConnect
+	}
+	end := at + 2 + int(binary.BigEndian.Uint16(body[at:]))
+	if len(body) < end || len(c.identifier) > 0xffff {
+		return nil, errMalformedConnect
+	}
+
+	var rewritten bytes.Buffer
+	rewritten.Grow(len(body) + len(c.identifier) + 5)
+	rewritten.Write(body[:at])
+	rewritten.Write(binary.BigEndian.AppendUint16(nil, uint16(len(c.identifier))))
+	rewritten.WriteString(c.identifier)
+	rewritten.Write(body[end:])
+
+	packet := append([]byte{first}, appendLength(nil, rewritten.Len())...)
+	return append(packet, rewritten.Bytes()...), nil
+}
+
+// read reads from the binary messages of the WebSocket, as one stream.
+func (c *Conn) read(p []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			messageType, r, err := c.ws.NextReader()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					return 0, io.EOF
+				}
+				return 0, err
+			}
+			if messageType != websocket.BinaryMessage {
+				continue
+			}
+			c.reader = r
+		}
+
+		n, err := c.reader.Read(p)
+		if err == io.EOF {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// byteReader reads a byte at a time, or more, from read.
+type byteReader struct {
+	read func(p []byte) (int, error)
+}
+
+func (r *byteReader) Read(p []byte) (int, error) {
+	return r.read(p)
+}
+
+func (r *byteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// readLength reads the remaining length of an MQTT packet.
+func readLength(r io.ByteReader) (int, error) {
+	var length, shift int
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return length, nil
+		}
+		shift += 7
+	}
+	return 0, errMalformedConnect
+}
+
+// uvarint decodes the variable byte integer at the start of b, returning it
+// and its size.
+func uvarint(b []byte) (int, int, error) {
+	r := bytes.NewReader(b)
+	length, err := readLength(r)
+	if err != nil {
+		return 0, 0, errMalformedConnect
+	}
+	return length, len(b) - r.Len(), nil
+}
+
+// appendLength appends the encoding of length as a variable byte integer.
+func appendLength(b []byte, length int) []byte {
+	for {
+		digit := byte(length & 0x7f)
+		length >>= 7
+		if length > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if length == 0 {
+			return b
+		}
+	}
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.ws.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *Conn) Close() error {
+	return c.ws.Close()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.ws.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.ws.RemoteAddr()
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	if err := c.ws.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.ws.SetWriteDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.ws.SetWriteDeadline(t)
+}
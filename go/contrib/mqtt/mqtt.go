@@ -0,0 +1,114 @@
+// Package wskeyauthmqtt runs the key handshake in front of MQTT over
+// WebSockets. Clients run the handshake on the WebSocket before sending any
+// MQTT packet, and the broker is handed only authenticated connections, as
+// net.Conns of the MQTT packets that follow, whose CONNECT carries the
+// verified client's identifier, whatever the client put in it.
+//
+// Brokers that serve a net.Listener take a Listener as it is. With
+// github.com/mochi-mqtt/server, for instance:
+//
+//	lis := wskeyauthmqtt.NewListener(websocket.Upgrader{Subprotocols: []string{"mqtt"}}, wskeyauthmqtt.Config{})
+//	server.AddListener(listeners.NewNet("wskeyauth", lis))
+//	http.Handle("/mqtt", lis)
+//
+// and hooks that need the client ID, such as to authorize topics, find it on
+// the client's connection, which is a *Conn.
+package wskeyauthmqtt
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// ErrListenerClosed is returned by Accept once the listener has been closed.
+var ErrListenerClosed = errors.New("wskeyauthmqtt: listener closed")
+
+// Config configures a Listener.
+type Config struct {
+	// Options are the options of the handshake.
+	Options []wskeyauth.Option
+
+	// Identifier maps the client IDs of authenticated clients to their MQTT
+	// client identifiers. It defaults to the client's fingerprint, as
+	// returned by wskeyauth.Fingerprint, so that every encoding of a key
+	// maps to the same identifier.
+	Identifier func(clientID string) string
+}
+
+// Listener is a net.Listener, for handing to an MQTT broker, and an
+// http.Handler. Every request it serves is upgraded to a WebSocket and put
+// through the key handshake; only authenticated connections are accepted.
+type Listener struct {
+	upgrader websocket.Upgrader
+	cfg      Config
+
+	conns     chan *Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewListener creates a listener that upgrades requests with upgrader, which
+// should offer the "mqtt" subprotocol, as MQTT clients ask for it.
+func NewListener(upgrader websocket.Upgrader, cfg Config) *Listener {
+	if cfg.Identifier == nil {
+		cfg.Identifier = func(clientID string) string {
+			fp, _ := wskeyauth.Fingerprint(clientID)
+			return fp
+		}
+	}
+	return &Listener{
+		upgrader: upgrader,
+		cfg:      cfg,
+		conns:    make(chan *Conn),
+		done:     make(chan struct{}),
+	}
+}
+
+func (l *Listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := l.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	authenticated, clientID, err := wskeyauth.Handshake(ws, l.cfg.Options...)
+	if !authenticated || err != nil {
+		ws.Close()
+		return
+	}
+
+	conn := &Conn{ws: ws, clientID: clientID, identifier: l.cfg.Identifier(clientID)}
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		ws.Close()
+	}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, ErrListenerClosed
+	}
+}
+
+func (l *Listener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *Listener) Addr() net.Addr {
+	return addr{}
+}
+
+type addr struct{}
+
+func (addr) Network() string { return "websocket" }
+func (addr) String() string  { return "websocket" }
@@ -0,0 +1,316 @@
+// Package wskeyauthnats bridges authenticated WebSocket clients to NATS, so
+// that browsers can publish and subscribe to the subjects of backends already
+// on NATS, as far as their client IDs permit.
+//
+// Once authenticated, clients send
+//
+//	{"type": "SUBSCRIBE", "data": "orders.eu"}
+//	{"type": "UNSUBSCRIBE", "data": "orders.eu"}
+//	{"type": "PUBLISH", "data": {"subject": "orders.eu", "payload": {...}}}
+//
+// to which the bridge responds with SUBSCRIBED or UNSUBSCRIBED, of the
+// subject, or with SUBSCRIBE_DENIED or PUBLISH_DENIED if the client may not
+// use it. Clients that re-authenticate as one that may not subscribe to a
+// subject they subscribed to are sent an UNSUBSCRIBED of it. Messages on subjects the client subscribed to are delivered as
+//
+//	{"type": "MESSAGE", "data": {"subject": "orders.eu", "payload": {...}}}
+//
+// where the payload is the message's, if it is JSON, or else the message's as
+// a string. Published payloads are sent on as their JSON.
+//
+// It doesn't bundle a NATS client; Broker adapts whichever one the
+// application already uses. With github.com/nats-io/nats.go, for instance:
+//
+//	type broker struct{ nc *nats.Conn }
+//
+//	func (b broker) Publish(subject string, data []byte) error {
+//		return b.nc.Publish(subject, data)
+//	}
+//
+//	func (b broker) Subscribe(subject string, handler func(subject string, data []byte)) (func() error, error) {
+//		sub, err := b.nc.Subscribe(subject, func(m *nats.Msg) { handler(m.Subject, m.Data) })
+//		if err != nil {
+//			return nil, err
+//		}
+//		return sub.Unsubscribe, nil
+//	}
+//
+//	bridge := wskeyauthnats.New(registry, broker{nc}, wskeyauthnats.Config{
+//		Authorize: func(ctx context.Context, clientID string, op wskeyauthnats.Op, subject string) error {
+//			...
+//		},
+//	})
+//
+// and, in the read loop of every registered session:
+//
+//	if ok, err := bridge.HandleSession(ctx, session, msg); ok {
+//		...
+//	}
+package wskeyauthnats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// Broker publishes and subscribes to NATS subjects.
+type Broker interface {
+	// Publish publishes data on subject.
+	Publish(subject string, data []byte) error
+
+	// Subscribe calls handler with every message on subject, which may have
+	// wildcards, until unsubscribe is called.
+	Subscribe(subject string, handler func(subject string, data []byte)) (unsubscribe func() error, err error)
+}
+
+// Op is what a client asks to do with a subject.
+type Op int
+
+const (
+	Publish Op = iota
+	Subscribe
+)
+
+// ErrDenied is returned by HandleSession when the client may not publish or
+// subscribe to a subject.
+var ErrDenied = errors.New("wskeyauthnats: denied")
+
+// Config says what clients may do.
+type Config struct {
+	// Authorize is called before every publish and subscription, with the
+	// subject as the client gave it, and denies it by returning an error.
+	// It is required: without it, every client would have the run of NATS.
+	Authorize func(ctx context.Context, clientID string, op Op, subject string) error
+
+	// Subject, if set, maps the subjects clients give to those on NATS,
+	// such as to put every client under a subject of its own. Messages are
+	// delivered to clients under the subject they subscribed to, or, for
+	// wildcard subscriptions, under the subject on NATS.
+	Subject func(clientID, subject string) string
+}
+
+// Bridge bridges the sessions of a registry to NATS. Sessions that leave the
+// registry are unsubscribed from everything, and sessions that re-authenticate
+// have their subscriptions authorized, and mapped to NATS, again under their
+// new client ID.
+type Bridge struct {
+	registry *wskeyauth.Registry
+	broker   Broker
+	cfg      Config
+
+	mu   sync.Mutex
+	subs map[*wskeyauth.Session]map[string]func() error
+}
+
+// New creates a bridge between the sessions of registry and broker. It panics
+// if cfg has no Authorize function.
+func New(registry *wskeyauth.Registry, broker Broker, cfg Config) *Bridge {
+	if cfg.Authorize == nil {
+		panic("wskeyauthnats: Config.Authorize is required")
+	}
+	b := &Bridge{
+		registry: registry,
+		broker:   broker,
+		cfg:      cfg,
+		subs:     map[*wskeyauth.Session]map[string]func() error{},
+	}
+	registry.Subscribe(func(e wskeyauth.RegistryEvent) {
+		switch e.Type {
+		case wskeyauth.SessionLeft:
+			b.unsubscribeAll(e.Session)
+		case wskeyauth.SessionReidentified:
+			b.resubscribe(e.Session)
+		}
+	})
+	return b
+}
+
+type message struct {
+	Subject string          `json:"subject"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type deliveryMessage struct {
+	Type string  `json:"type"`
+	Data message `json:"data"`
+}
+
+type subjectMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+// HandleSession handles msg, a message the session's client sent once
+// authenticated, if it is a SUBSCRIBE, an UNSUBSCRIBE or a PUBLISH. It
+// reports whether it was; the application should handle any other message
+// itself, as with wskeyauth.Refresh.HandleSession. Denials are reported with
+// ErrDenied, which needn't end the session.
+func (b *Bridge) HandleSession(ctx context.Context, s *wskeyauth.Session, msg wskeyauth.TypeData) (bool, error) {
+	switch msg.Type {
+	case "PUBLISH":
+		var m message
+		if err := json.Unmarshal(msg.Data, &m); err != nil || !validSubject(m.Subject) {
+			s.Send(&subjectMessage{Type: "CLIENT_ERROR", Data: "Expected PUBLISH to carry a subject"})
+			return true, err
+		}
+		return true, b.publish(ctx, s, m.Subject, m.Payload)
+	case "SUBSCRIBE", "UNSUBSCRIBE":
+		var subject string
+		if err := json.Unmarshal(msg.Data, &subject); err != nil || !validSubject(subject) {
+			s.Send(&subjectMessage{Type: "CLIENT_ERROR", Data: "Expected " + msg.Type + " to carry a subject"})
+			return true, err
+		}
+		if msg.Type == "UNSUBSCRIBE" {
+			return true, b.unsubscribe(s, subject)
+		}
+		return true, b.subscribe(ctx, s, subject)
+	}
+	return false, nil
+}
+
+func (b *Bridge) publish(ctx context.Context, s *wskeyauth.Session, subject string, payload json.RawMessage) error {
+	if err := b.cfg.Authorize(ctx, s.ClientID(), Publish, subject); err != nil {
+		s.Send(&subjectMessage{Type: "PUBLISH_DENIED", Data: subject})
+		return errors.Join(ErrDenied, err)
+	}
+	return b.broker.Publish(b.natsSubject(s.ClientID(), subject), payload)
+}
+
+func (b *Bridge) subscribe(ctx context.Context, s *wskeyauth.Session, subject string) error {
+	if err := b.cfg.Authorize(ctx, s.ClientID(), Subscribe, subject); err != nil {
+		s.Send(&subjectMessage{Type: "SUBSCRIBE_DENIED", Data: subject})
+		return errors.Join(ErrDenied, err)
+	}
+
+	if err := b.add(s, subject); err != nil {
+		s.Send(&subjectMessage{Type: "SERVER_ERROR", Data: "Failed to subscribe to " + subject})
+		return err
+	}
+	return s.Send(&subjectMessage{Type: "SUBSCRIBED", Data: subject})
+}
+
+// add subscribes s to subject, unless it is subscribed already.
+func (b *Bridge) add(s *wskeyauth.Session, subject string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// checked under b.mu, so that a session leaving the registry either
+	// isn't subscribed, or is unsubscribed again by unsubscribeAll
+	if !b.registered(s) {
+		return wskeyauth.ErrNotConnected
+	}
+	if _, ok := b.subs[s][subject]; ok {
+		return nil
+	}
+
+	wildcard := strings.ContainsAny(subject, "*>")
+	unsubscribe, err := b.broker.Subscribe(b.natsSubject(s.ClientID(), subject), func(natsSubject string, payload []byte) {
+		delivered := subject
+		if wildcard {
+			delivered = natsSubject
+		}
+		if !json.Valid(payload) {
+			payload, _ = json.Marshal(string(payload))
+		}
+		s.Send(&deliveryMessage{Type: "MESSAGE", Data: message{Subject: delivered, Payload: payload}})
+	})
+	if err != nil {
+		return err
+	}
+
+	subs, ok := b.subs[s]
+	if !ok {
+		subs = map[string]func() error{}
+		b.subs[s] = subs
+	}
+	subs[subject] = unsubscribe
+	return nil
+}
+
+// registered reports whether s is in the bridge's registry.
+func (b *Bridge) registered(s *wskeyauth.Session) bool {
+	for _, other := range b.registry.GetByFingerprint(s.Fingerprint()) {
+		if other == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Bridge) unsubscribe(s *wskeyauth.Session, subject string) error {
+	b.mu.Lock()
+	unsubscribe := b.subs[s][subject]
+	delete(b.subs[s], subject)
+	if len(b.subs[s]) == 0 {
+		delete(b.subs, s)
+	}
+	b.mu.Unlock()
+
+	if unsubscribe != nil {
+		if err := unsubscribe(); err != nil {
+			return err
+		}
+	}
+	return s.Send(&subjectMessage{Type: "UNSUBSCRIBED", Data: subject})
+}
+
+// unsubscribeAll ends every subscription of s.
+func (b *Bridge) unsubscribeAll(s *wskeyauth.Session) {
+	b.mu.Lock()
+	subs := b.subs[s]
+	delete(b.subs, s)
+	b.mu.Unlock()
+
+	for _, unsubscribe := range subs {
+		unsubscribe()
+	}
+}
+
+// resubscribe ends every subscription of s, once it took on a new identity,
+// and subscribes it again to those subjects it may still subscribe to, which
+// Subject may map elsewhere for the new client ID. The client is sent an
+// UNSUBSCRIBED of the others.
+func (b *Bridge) resubscribe(s *wskeyauth.Session) {
+	b.mu.Lock()
+	subs := b.subs[s]
+	delete(b.subs, s)
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), wskeyauth.DefaultTimeout)
+	defer cancel()
+
+	for subject, unsubscribe := range subs {
+		unsubscribe()
+		if b.cfg.Authorize(ctx, s.ClientID(), Subscribe, subject) != nil || b.add(s, subject) != nil {
+			s.Send(&subjectMessage{Type: "UNSUBSCRIBED", Data: subject})
+		}
+	}
+}
+
+// natsSubject returns the subject on NATS of subject, as the client gave it.
+func (b *Bridge) natsSubject(clientID, subject string) string {
+	if b.cfg.Subject == nil {
+		return subject
+	}
+	return b.cfg.Subject(clientID, subject)
+}
+
+// validSubject reports whether subject is one NATS accepts, as far as clients
+// are concerned: tokens separated by dots, none of them empty, and no
+// whitespace.
+func validSubject(subject string) bool {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return false
+	}
+	for _, token := range strings.Split(subject, ".") {
+		if token == "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+// Package wskeyauthprom exposes handshake metrics as Prometheus collectors.
+//
+//	collector := wskeyauthprom.New(registry)
+//	prometheus.MustRegister(collector)
+//	...
+//	wskeyauth.Handshake(conn, wskeyauth.WithMetrics(collector))
+package wskeyauthprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+const namespace = "wskeyauth"
+
+// Collector is both a prometheus.Collector and a wskeyauth.Metrics.
+type Collector struct {
+	started      prometheus.Counter
+	succeeded    prometheus.Counter
+	failed       *prometheus.CounterVec
+	verification prometheus.Histogram
+	active       prometheus.GaugeFunc
+}
+
+var _ wskeyauth.Metrics = (*Collector)(nil)
+var _ prometheus.Collector = (*Collector)(nil)
+
+// New creates the collectors. If registry is not nil, the number of
+// connections it tracks is exported as the active connections gauge.
+func New(registry *wskeyauth.Registry) *Collector {
+	c := &Collector{
+		started: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "handshakes_started_total",
+			Help:      "Number of handshakes started.",
+		}),
+		succeeded: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "handshakes_succeeded_total",
+			Help:      "Number of handshakes that authenticated the client.",
+		}),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "handshakes_failed_total",
+			Help:      "Number of handshakes that failed to authenticate the client, by reason.",
+		}, []string{"reason"}),
+		verification: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "signature_verification_seconds",
+			Help:      "Time taken to verify challenge signatures.",
+			Buckets:   prometheus.ExponentialBuckets(0.00001, 2, 14),
+		}),
+	}
+
+	if registry != nil {
+		c.active = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_connections",
+			Help:      "Number of authenticated connections currently open.",
+		}, func() float64 {
+			return float64(registry.Count())
+		})
+	}
+
+	return c
+}
+
+func (c *Collector) HandshakeStarted() {
+	c.started.Inc()
+}
+
+func (c *Collector) HandshakeSucceeded() {
+	c.succeeded.Inc()
+}
+
+func (c *Collector) HandshakeFailed(reason wskeyauth.FailureReason) {
+	c.failed.WithLabelValues(string(reason)).Inc()
+}
+
+func (c *Collector) VerificationDuration(d time.Duration) {
+	c.verification.Observe(d.Seconds())
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.started.Describe(ch)
+	c.succeeded.Describe(ch)
+	c.failed.Describe(ch)
+	c.verification.Describe(ch)
+	if c.active != nil {
+		c.active.Describe(ch)
+	}
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.started.Collect(ch)
+	c.succeeded.Collect(ch)
+	c.failed.Collect(ch)
+	c.verification.Collect(ch)
+	if c.active != nil {
+		c.active.Collect(ch)
+	}
+}
+
+// ChallengePoolDepth returns a gauge reporting how many pre-generated
+// challenges pool has ready.
+func ChallengePoolDepth(pool *wskeyauth.ChallengePool) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "challenge_pool_depth",
+		Help:      "Number of pre-generated challenges ready for use.",
+	}, func() float64 {
+		return float64(pool.Depth())
+	})
+}
@@ -0,0 +1,474 @@
+// Package wskeyauthredis provides Redis-backed nonce stores, rate limiters,
+// ban stores, revocation lists, presence and a cluster backend, so that every
+// server in a cluster shares them:
+//
+//	pool := &redis.Pool{Dial: func() (redis.Conn, error) { return redis.Dial("tcp", "localhost:6379") }}
+//	wskeyauth.Handshake(conn,
+//		wskeyauth.WithNonceStore(&wskeyauthredis.NonceStore{Pool: pool}),
+//		wskeyauth.WithRateLimiter(&wskeyauthredis.RateLimiter{Pool: pool, Limit: 10, Window: time.Minute}),
+//	)
+//	registry := wskeyauth.NewRegistry(wskeyauth.WithPresenceBackend(
+//		&wskeyauthredis.PresenceBackend{Pool: pool, Node: hostname}))
+//	leave, err := registry.JoinCluster(&wskeyauthredis.ClusterBackend{Pool: pool}, hostname)
+package wskeyauthredis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// DefaultPrefix is what keys are prefixed with in Redis, unless Prefix says
+// otherwise.
+const DefaultPrefix = "wskeyauth:"
+
+// DefaultWindow is the window handshakes are counted in, unless
+// RateLimiter.Window says otherwise.
+const DefaultWindow = time.Minute
+
+// NonceStore is a wskeyauth.NonceStore that records nonces as keys that
+// expire.
+type NonceStore struct {
+	Pool *redis.Pool
+
+	// Prefix is prepended to every key. It defaults to DefaultPrefix.
+	Prefix string
+}
+
+var _ wskeyauth.NonceStore = (*NonceStore)(nil)
+
+func (s *NonceStore) Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	conn, err := s.Pool.GetContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// SET NX only succeeds for the first user of the nonce
+	_, err = redis.String(conn.Do("SET", prefix(s.Prefix)+"nonce:"+nonce, 1, "NX", "PX", ttl.Milliseconds()))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RateLimiter is a wskeyauth.RateLimiter allowing Limit handshakes per key in
+// each Window, counted in keys that expire with the window.
+type RateLimiter struct {
+	Pool  *redis.Pool
+	Limit int
+
+	// Window defaults to DefaultWindow. Keys expire in whole milliseconds,
+	// so a shorter one is taken as a millisecond.
+	Window time.Duration
+
+	// Clock tells which window handshakes fall in. It defaults to
+	// wskeyauth.SystemClock.
+	Clock wskeyauth.Clock
+
+	// Prefix is prepended to every key. It defaults to DefaultPrefix.
+	Prefix string
+}
+
+var _ wskeyauth.RateLimiter = (*RateLimiter)(nil)
+
+// incr counts a handshake against KEYS[1], starting its expiry with the
+// first one.
+var incr = redis.NewScript(1, `
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+func (l *RateLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	conn, err := l.Pool.GetContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	ms := windowMillis(l.Window)
+	window := now(l.Clock).UnixMilli() / ms
+	k := prefix(l.Prefix) + "rate:" + key + ":" + strconv.FormatInt(window, 10)
+
+	n, err := redis.Int(incr.Do(conn, k, ms))
+	if err != nil {
+		return false, err
+	}
+	return n <= l.Limit, nil
+}
+
+// BanStore is a wskeyauth.BanStore counting failures in a hash per key, of
+// their count and the time of the last one, which expires with them.
+type BanStore struct {
+	Pool *redis.Pool
+
+	// Clock tells when failures happen, which lockouts are timed from; give
+	// it the clock of the handshakes. It defaults to wskeyauth.SystemClock.
+	Clock wskeyauth.Clock
+
+	// Prefix is prepended to every key. It defaults to DefaultPrefix.
+	Prefix string
+}
+
+var _ wskeyauth.BanStore = (*BanStore)(nil)
+
+// fail counts a failure against KEYS[1] at ARGV[1], in Unix milliseconds,
+// forgetting them all ARGV[2] milliseconds later.
+var fail = redis.NewScript(1, `
+local n = redis.call("HINCRBY", KEYS[1], "count", 1)
+redis.call("HSET", KEYS[1], "last", ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return n
+`)
+
+func (s *BanStore) key(key string) string {
+	return prefix(s.Prefix) + "ban:" + key
+}
+
+func (s *BanStore) Fail(ctx context.Context, key string, ttl time.Duration) (int, error) {
+	conn, err := s.Pool.GetContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	return redis.Int(fail.Do(conn, s.key(key), now(s.Clock).UnixMilli(), ttl.Milliseconds()))
+}
+
+func (s *BanStore) Failures(ctx context.Context, key string) (int, time.Time, error) {
+	conn, err := s.Pool.GetContext(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer conn.Close()
+
+	values, err := redis.Int64s(conn.Do("HMGET", s.key(key), "count", "last"))
+	if err != nil || values[0] == 0 {
+		return 0, time.Time{}, err
+	}
+	return int(values[0]), time.UnixMilli(values[1]), nil
+}
+
+func (s *BanStore) Clear(ctx context.Context, key string) error {
+	conn, err := s.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("DEL", s.key(key))
+	return err
+}
+
+// RevocationList is a wskeyauth.RevocationList keeping a key per revoked
+// client key, holding the reason it was revoked for.
+type RevocationList struct {
+	Pool *redis.Pool
+
+	// Prefix is prepended to every key. It defaults to DefaultPrefix.
+	Prefix string
+}
+
+var _ wskeyauth.RevocationList = (*RevocationList)(nil)
+
+func (l *RevocationList) key(fingerprint string) string {
+	return prefix(l.Prefix) + "revoked:" + fingerprint
+}
+
+func (l *RevocationList) Revoked(ctx context.Context, fingerprint string) (bool, error) {
+	conn, err := l.Pool.GetContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	return redis.Bool(conn.Do("EXISTS", l.key(fingerprint)))
+}
+
+func (l *RevocationList) Revoke(ctx context.Context, fingerprint, reason string) error {
+	conn, err := l.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// NX keeps the original revocation
+	_, err = conn.Do("SET", l.key(fingerprint), reason, "NX")
+	return err
+}
+
+// PresenceBackend is a wskeyauth.PresenceBackend keeping a hash per client,
+// with a field for each server it is connected to.
+type PresenceBackend struct {
+	Pool *redis.Pool
+
+	// Node identifies this server among those sharing the backend.
+	Node string
+
+	// Prefix is prepended to every key. It defaults to DefaultPrefix.
+	Prefix string
+}
+
+var _ wskeyauth.PresenceBackend = (*PresenceBackend)(nil)
+
+func (b *PresenceBackend) key(fingerprint string) string {
+	return prefix(b.Prefix) + "presence:" + fingerprint
+}
+
+func (b *PresenceBackend) Update(ctx context.Context, p wskeyauth.Presence) error {
+	conn, err := b.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if !p.Online() {
+		_, err = conn.Do("HDEL", b.key(p.Fingerprint), b.Node)
+		return err
+	}
+	value := fmt.Sprintf("%d %d %s", p.Connections, p.Since.UnixMilli(), p.ClientID)
+	_, err = conn.Do("HSET", b.key(p.Fingerprint), b.Node, value)
+	return err
+}
+
+func (b *PresenceBackend) Presence(ctx context.Context, fingerprint string) (wskeyauth.Presence, error) {
+	conn, err := b.Pool.GetContext(ctx)
+	if err != nil {
+		return wskeyauth.Presence{}, err
+	}
+	defer conn.Close()
+
+	return b.presence(conn, fingerprint)
+}
+
+func (b *PresenceBackend) Online(ctx context.Context) ([]wskeyauth.Presence, error) {
+	conn, err := b.Pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	keys, err := b.scan(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var online []wskeyauth.Presence
+	for _, key := range keys {
+		p, err := b.presence(conn, strings.TrimPrefix(key, b.key("")))
+		if err != nil {
+			return nil, err
+		}
+		if p.Online() {
+			online = append(online, p)
+		}
+	}
+	return online, nil
+}
+
+// Forget removes this server's sessions from the backend. Call it as the
+// server starts up, in case it went away before it could report its sessions
+// leaving.
+func (b *PresenceBackend) Forget(ctx context.Context) error {
+	conn, err := b.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	keys, err := b.scan(conn)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if _, err := conn.Do("HDEL", key, b.Node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scan returns the keys of every client with presence.
+func (b *PresenceBackend) scan(conn redis.Conn) ([]string, error) {
+	var keys []string
+	cursor := "0"
+	for {
+		values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", b.key("*"), "COUNT", 1000))
+		if err != nil {
+			return nil, err
+		}
+		page, err := redis.Strings(values[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, page...)
+
+		if cursor, err = redis.String(values[0], nil); err != nil {
+			return nil, err
+		}
+		if cursor == "0" {
+			return keys, nil
+		}
+	}
+}
+
+// presence adds up what every server reported of the client.
+func (b *PresenceBackend) presence(conn redis.Conn, fingerprint string) (wskeyauth.Presence, error) {
+	nodes, err := redis.StringMap(conn.Do("HGETALL", b.key(fingerprint)))
+	if err != nil {
+		return wskeyauth.Presence{}, err
+	}
+
+	p := wskeyauth.Presence{Fingerprint: fingerprint}
+	for node, value := range nodes {
+		fields := strings.SplitN(value, " ", 3)
+		if len(fields) != 3 {
+			return wskeyauth.Presence{}, fmt.Errorf("wskeyauthredis: malformed presence of %s on %s", fingerprint, node)
+		}
+		connections, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return wskeyauth.Presence{}, fmt.Errorf("wskeyauthredis: malformed presence of %s on %s: %w", fingerprint, node, err)
+		}
+		since, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return wskeyauth.Presence{}, fmt.Errorf("wskeyauthredis: malformed presence of %s on %s: %w", fingerprint, node, err)
+		}
+
+		p.Connections += connections
+		if t := time.UnixMilli(since); p.Since.IsZero() || t.Before(p.Since) {
+			p.ClientID, p.Since = fields[2], t
+		}
+	}
+	return p, nil
+}
+
+// ClusterBackend is a wskeyauth.ClusterBackend publishing messages to a
+// Redis channel that every server subscribes to.
+type ClusterBackend struct {
+	Pool *redis.Pool
+
+	// Prefix is prepended to the channel's name. It defaults to
+	// DefaultPrefix.
+	Prefix string
+}
+
+var _ wskeyauth.ClusterBackend = (*ClusterBackend)(nil)
+
+func (b *ClusterBackend) channel() string {
+	return prefix(b.Prefix) + "cluster"
+}
+
+func (b *ClusterBackend) Publish(ctx context.Context, m wskeyauth.ClusterMessage) error {
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	conn, err := b.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("PUBLISH", b.channel(), payload)
+	return err
+}
+
+// Subscribe subscribes a connection of its own from the pool to the channel.
+// Should the connection fail, it subscribes another, although messages
+// published meanwhile are lost.
+func (b *ClusterBackend) Subscribe(handle func(wskeyauth.ClusterMessage)) (func() error, error) {
+	psc, err := b.subscribe()
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	done := false
+	go func() {
+		for {
+			switch v := psc.Receive().(type) {
+			case redis.Message:
+				var m wskeyauth.ClusterMessage
+				if json.Unmarshal(v.Data, &m) == nil {
+					handle(m)
+				}
+			case redis.Subscription:
+				if v.Count == 0 {
+					psc.Close()
+					return
+				}
+			case error:
+				psc.Close()
+				for {
+					mu.Lock()
+					if done {
+						mu.Unlock()
+						return
+					}
+					next, err := b.subscribe()
+					if err == nil {
+						psc = next
+						mu.Unlock()
+						break
+					}
+					mu.Unlock()
+					time.Sleep(time.Second)
+				}
+			}
+		}
+	}()
+
+	return func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		done = true
+		return psc.Unsubscribe()
+	}, nil
+}
+
+func (b *ClusterBackend) subscribe() (redis.PubSubConn, error) {
+	psc := redis.PubSubConn{Conn: b.Pool.Get()}
+	if err := psc.Subscribe(b.channel()); err != nil {
+		psc.Close()
+		return redis.PubSubConn{}, err
+	}
+	return psc, nil
+}
+
+func prefix(p string) string {
+	if p == "" {
+		return DefaultPrefix
+	}
+	return p
+}
+
+// now returns the time by clock, or by wskeyauth.SystemClock if it is nil.
+func now(clock wskeyauth.Clock) time.Time {
+	if clock == nil {
+		clock = wskeyauth.SystemClock
+	}
+	return clock.Now()
+}
+
+// windowMillis returns how many milliseconds a rate limiting window of w
+// lasts, which is never 0.
+func windowMillis(w time.Duration) int64 {
+	if w <= 0 {
+		w = DefaultWindow
+	}
+	if ms := w.Milliseconds(); ms > 0 {
+		return ms
+	}
+	return 1
+}
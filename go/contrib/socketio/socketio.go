@@ -0,0 +1,184 @@
+// Package wskeyauthsocketio runs the ws-key-auth handshake as the first
+// exchange on a Socket.IO connection, using github.com/googollee/go-socket.io.
+//
+// Every protocol message is mapped onto a Socket.IO event named after the
+// message type. Clients emit theirs with the message data as the only
+// argument, and the server emits its with the whole message, as it would send
+// it over a WebSocket, so that members besides data, such as the proofOfWork
+// of a CHALLENGE or the token of a SIGNATURE_MATCHES, reach the client. A
+// client therefore emits CLIENT_ID with its client ID, and listens for
+// CHALLENGE; then emits CHALLENGE_RESPONSE, and listens for SIGNATURE_MATCHES
+// or one of the error events.
+package wskeyauthsocketio
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	socketio "github.com/googollee/go-socket.io"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// ErrDisconnected is returned to the handshake when the Socket.IO connection
+// went away before the handshake completed.
+var ErrDisconnected = errors.New("wskeyauthsocketio: connection closed during handshake")
+
+// ErrTooManyMessages is returned to the handshake when the client sent more
+// handshake events than it could have possibly been asked for.
+var ErrTooManyMessages = errors.New("wskeyauthsocketio: client sent too many handshake messages")
+
+// the handshake never has more than one message outstanding from the client,
+// so anything beyond this is a misbehaving client.
+const inboxSize = 4
+
+// Authenticator tracks the handshakes and authenticated connections of one
+// Socket.IO namespace.
+type Authenticator struct {
+	mu sync.Mutex
+	// keyed by socketio.Conn.ID()
+	pending       map[string]*conn
+	authenticated map[string]string
+}
+
+// Register installs the connect, disconnect and handshake event handlers on
+// namespace of server, running handshakes with opts. onAuthenticated is
+// called, from its own goroutine, with every connection that completes the
+// handshake; connections that fail it are closed.
+//
+// Register takes over the namespace's OnConnect and OnDisconnect handlers.
+func Register(server *socketio.Server, namespace string, onAuthenticated func(s socketio.Conn, clientID string), opts ...wskeyauth.Option) *Authenticator {
+	a := &Authenticator{
+		pending:       map[string]*conn{},
+		authenticated: map[string]string{},
+	}
+
+	server.OnConnect(namespace, func(s socketio.Conn) error {
+		c := &conn{s: s, inbox: make(chan wskeyauth.TypeData, inboxSize), done: make(chan struct{})}
+
+		a.mu.Lock()
+		a.pending[s.ID()] = c
+		a.mu.Unlock()
+
+		go func() {
+			authenticated, clientID, err := wskeyauth.Handshake(c, opts...)
+
+			a.mu.Lock()
+			delete(a.pending, s.ID())
+			if authenticated && err == nil {
+				a.authenticated[s.ID()] = clientID
+			}
+			a.mu.Unlock()
+
+			if !authenticated || err != nil {
+				s.Close()
+				return
+			}
+
+			onAuthenticated(s, clientID)
+		}()
+
+		return nil
+	})
+
+	server.OnDisconnect(namespace, func(s socketio.Conn, _ string) {
+		a.mu.Lock()
+		c := a.pending[s.ID()]
+		delete(a.pending, s.ID())
+		delete(a.authenticated, s.ID())
+		a.mu.Unlock()
+
+		if c != nil {
+			c.close()
+		}
+	})
+
+	for _, event := range []string{"CLIENT_ID", "CHALLENGE_RESPONSE"} {
+		event := event
+		server.OnEvent(namespace, event, func(s socketio.Conn, data json.RawMessage) {
+			a.mu.Lock()
+			c := a.pending[s.ID()]
+			a.mu.Unlock()
+
+			if c != nil {
+				c.deliver(wskeyauth.TypeData{Type: event, Data: data})
+			}
+		})
+	}
+
+	return a
+}
+
+// ClientID returns the client ID that s authenticated with, if it has.
+func (a *Authenticator) ClientID(s socketio.Conn) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	clientID, ok := a.authenticated[s.ID()]
+	return clientID, ok
+}
+
+// conn adapts the event-driven Socket.IO connection to the blocking
+// wskeyauth.Conn that the handshake expects.
+type conn struct {
+	s     socketio.Conn
+	inbox chan wskeyauth.TypeData
+
+	closeOnce sync.Once
+	done      chan struct{}
+	err       error
+}
+
+func (c *conn) deliver(td wskeyauth.TypeData) {
+	select {
+	case c.inbox <- td:
+	default:
+		c.closeWith(ErrTooManyMessages)
+	}
+}
+
+func (c *conn) close() {
+	c.closeWith(ErrDisconnected)
+}
+
+// Close interrupts the handshake, such as when it times out.
+func (c *conn) Close() error {
+	c.close()
+	return nil
+}
+
+func (c *conn) closeWith(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.done)
+	})
+}
+
+func (c *conn) ReadJSON(v any) error {
+	select {
+	case td := <-c.inbox:
+		b, err := json.Marshal(td)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, v)
+	case <-c.done:
+		return c.err
+	}
+}
+
+func (c *conn) WriteJSON(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var td wskeyauth.TypeData
+	if err := json.Unmarshal(b, &td); err != nil {
+		return err
+	}
+
+	c.s.Emit(td.Type, json.RawMessage(b))
+	return nil
+}
@@ -0,0 +1,184 @@
+package wskeyauth
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client IDs may carry a key along with the signature of a root key that
+// vouches for it, so that a fleet of devices can be provisioned offline, with
+// only the root known to the server:
+//
+//	Delegated$<base64 key>,<expiry>,<base64 signature>
+//
+// The key is an uncompressed P-256 point, 65 bytes, or an Ed25519 key, 32
+// bytes. The expiry is in seconds since the Unix epoch, or 0 for a key that
+// doesn't expire. The signature is over DelegationSigningInput, made by the
+// root as a WebCrypto or did:key client signs its challenge. The base64
+// variants accepted are those of WithBase64.
+//
+// The client is challenged to prove it holds the key, and signs as it would
+// with a WebCrypto or did:key client ID. Delegated keys need not be in the
+// KeyStore.
+
+const delegationPrefix = "Delegated$"
+
+// DelegationSigningPrefix starts everything a root signs for a delegated
+// client ID, so that its signature can never pass for anything else.
+const DelegationSigningPrefix = "wskeyauth delegation\x00"
+
+// DelegationSigningInput returns what a root signs to vouch for key, the raw
+// key of a delegated client ID, until expires, or for good if expires is
+// zero.
+func DelegationSigningInput(key []byte, expires time.Time) []byte {
+	b := make([]byte, 0, len(DelegationSigningPrefix)+8+len(key))
+	b = append(b, DelegationSigningPrefix...)
+	b = binary.BigEndian.AppendUint64(b, uint64(delegationExpiry(expires)))
+	return append(b, key...)
+}
+
+func delegationExpiry(expires time.Time) int64 {
+	if expires.IsZero() {
+		return 0
+	}
+	return expires.Unix()
+}
+
+// Delegation configures the server to accept delegated client IDs.
+type Delegation struct {
+	// Roots are the client IDs of the root keys that may vouch for client
+	// keys. They must be P-256 or Ed25519 keys, such as those of WebCrypto
+	// and did:key client IDs. In FIPS mode, Ed25519 roots vouch for no
+	// one.
+	Roots []string
+
+	// CheckRevocation, if set, is called with the fingerprint of a delegated
+	// key, and returns an error if it was revoked.
+	CheckRevocation func(fingerprint string) error
+}
+
+// WithDelegation accepts delegated client IDs, whose keys must be vouched for
+// by one of d.Roots, and not have expired. Without it, delegated client IDs
+// are rejected.
+func WithDelegation(d Delegation) Option {
+	return func(cfg *config) {
+		cfg.delegation = &d
+	}
+}
+
+// delegation is what a delegated client ID says about its key.
+type delegation struct {
+	key       []byte
+	expires   time.Time
+	signature []byte
+}
+
+var errDelegationFormat = errors.New("expected delegated client ID to be a base64 key, an expiry and a base64 signature, separated by commas")
+
+func parseDelegatedClientID(clientID string, accept Base64) (*publicKey, error) {
+	fields := strings.Split(strings.TrimPrefix(clientID, delegationPrefix), ",")
+	if len(fields) != 3 {
+		return nil, errDelegationFormat
+	}
+
+	key, err := accept.decode(nil, fields[0])
+	if err != nil {
+		return nil, errDelegationFormat
+	}
+	expiry, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || expiry < 0 {
+		return nil, errDelegationFormat
+	}
+	signature, err := accept.decode(nil, fields[2])
+	if err != nil {
+		return nil, errDelegationFormat
+	}
+
+	d := &delegation{key: key, signature: signature}
+	if expiry != 0 {
+		d.expires = time.Unix(expiry, 0)
+	}
+
+	pub, err := parseRawKey(key)
+	if err != nil {
+		return nil, err
+	}
+	pub.delegation = d
+	return pub, nil
+}
+
+// parseRawKey parses an uncompressed P-256 point or an Ed25519 key, telling
+// them apart by their length.
+func parseRawKey(key []byte) (*publicKey, error) {
+	switch len(key) {
+	case 65:
+		if key[0] != 4 {
+			return nil, errors.New("expected P-256 key to have 0x04 as the first byte")
+		}
+		if _, err := ecdh.P256().NewPublicKey(key); err != nil {
+			return nil, ErrInvalidPublicKey
+		}
+		return &publicKey{ecdsa: &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(key[1:33]),
+			Y:     new(big.Int).SetBytes(key[33:]),
+		}}, nil
+	case ed25519.PublicKeySize:
+		return &publicKey{ed25519: ed25519.PublicKey(key)}, nil
+	default:
+		return nil, errors.New("expected key to be a 65 byte P-256 key or a 32 byte Ed25519 key")
+	}
+}
+
+var (
+	errDelegationExpired   = errors.New("wskeyauth: delegated key has expired")
+	errDelegationUntrusted = errors.New("wskeyauth: delegated key isn't signed by a trusted root")
+)
+
+// verify checks that the key of pub is vouched for by one of our roots at
+// now, and isn't revoked. In FIPS mode, Ed25519 roots aren't trusted.
+func (d *Delegation) verify(pub *publicKey, now time.Time, fips bool) error {
+	if !pub.delegation.expires.IsZero() && now.After(pub.delegation.expires) {
+		return errDelegationExpired
+	}
+
+	message := DelegationSigningInput(pub.delegation.key, pub.delegation.expires)
+	trusted, refused := false, false
+	for i, root := range d.Roots {
+		rootKey, err := parseClientID(root, Base64Any)
+		if err != nil {
+			return fmt.Errorf("wskeyauth: failed to parse root %d of Delegation: %w", i, err)
+		}
+		if !signsMessages(rootKey) {
+			return fmt.Errorf("wskeyauth: root %d of Delegation has no key to sign with", i)
+		}
+		if fips && rootKey.ed25519 != nil {
+			refused = true
+			continue
+		}
+		if verifyWithKey(rootKey, message, pub.delegation.signature) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted && refused {
+		return fmt.Errorf("%w: %w", errDelegationUntrusted, notApproved("Ed25519 delegation roots"))
+	}
+	if !trusted {
+		return errDelegationUntrusted
+	}
+
+	if d.CheckRevocation != nil {
+		return d.CheckRevocation(fingerprint(pub))
+	}
+	return nil
+}
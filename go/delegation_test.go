@@ -0,0 +1,85 @@
+package wskeyauth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	wskeyauthclient "github.com/castcam-live/ws-key-auth/go/client"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+func TestDelegationRefusesEd25519RootsInFIPSMode(t *testing.T) {
+	_, rootKey, _ := ed25519.GenerateKey(rand.Reader)
+	root, err := wskeyauthclient.New(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	clientID, err := root.Delegate(deviceKey.Public(), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	device, err := wskeyauthclient.NewDelegated(deviceKey, clientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegation := wskeyauth.WithDelegation(wskeyauth.Delegation{Roots: []string{root.ClientID()}})
+
+	for _, fips := range []bool{false, true} {
+		server, conn := wskeyauthtest.Pipe()
+		go device.Handshake(conn)
+		opts := []wskeyauth.Option{delegation}
+		if fips {
+			opts = append(opts, wskeyauth.WithFIPS())
+		}
+		result, err := wskeyauth.HandshakeResult(server, opts...)
+		server.Close()
+
+		if !fips && !result.Authenticated {
+			t.Fatalf("handshake outside FIPS mode failed with %q, %v", result.Reason, err)
+		}
+		if fips && (result.Reason != wskeyauth.ReasonUntrustedDelegation || !errors.Is(err, wskeyauth.ErrNotApproved)) {
+			t.Fatalf("handshake in FIPS mode = %q, %v, want an untrusted delegation not approved", result.Reason, err)
+		}
+	}
+}
+
+func TestDelegationBase64(t *testing.T) {
+	rootKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	root, err := wskeyauthclient.New(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device := wskeyauthtest.MustGenerateKey()
+	clientID, err := root.Delegate(device.Private.Public(), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key and signature, in unpadded URL-safe base64
+	fields := strings.Split(strings.TrimPrefix(clientID, "Delegated$"), ",")
+	for _, i := range []int{0, 2} {
+		b, err := base64.StdEncoding.DecodeString(fields[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	hs := wskeyauth.NewServerHandshake(
+		wskeyauth.WithDelegation(wskeyauth.Delegation{Roots: []string{root.ClientID()}}),
+		wskeyauth.WithBase64(wskeyauth.Base64Std|wskeyauth.Base64RawURL))
+	hs.Start()
+	out, _, _ := hs.Feed(marshal(t, map[string]any{"type": "CLIENT_ID", "data": "Delegated$" + strings.Join(fields, ",")}))
+	if out, state, err := hs.Feed(respond(t, device, only(t, out))); state != wskeyauth.StateAuthenticated {
+		t.Fatalf("handshake with an unpadded URL-safe delegation = %s, %v, %v", out, state, err)
+	}
+}
@@ -0,0 +1,70 @@
+package wskeyauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"errors"
+	"strings"
+
+	"github.com/castcam-live/ws-key-auth/go/internal/base58"
+)
+
+// did:key identifiers are accepted as client IDs too:
+//
+//	did:key:z<base58btc encoded, multicodec prefixed public key>
+//
+// for P-256 keys (multicodec p256-pub, compressed) and Ed25519 keys
+// (ed25519-pub). P-256 clients sign as WebCrypto clients do. Ed25519 clients
+// sign the challenge itself, as Ed25519 hashes internally; the hash they
+// report is ignored.
+
+const didKeyPrefix = "did:key:"
+
+// multicodec prefixes, as unsigned varints
+var (
+	multicodecP256    = []byte{0x80, 0x24}
+	multicodecEd25519 = []byte{0xed, 0x01}
+)
+
+// maxDIDKeyLength bounds the identifiers we'll decode, as base58 decoding
+// takes time quadratic in the length. Keys we accept are far shorter.
+const maxDIDKeyLength = 128
+
+func parseDIDKey(clientID string) (*publicKey, error) {
+	encoded := strings.TrimPrefix(clientID, didKeyPrefix)
+	if len(encoded) > maxDIDKeyLength {
+		return nil, errors.New("expected did:key to be at most 128 characters long")
+	}
+
+	// only base58btc is allowed by the did:key spec
+	if !strings.HasPrefix(encoded, "z") {
+		return nil, errors.New("expected did:key to be base58btc encoded, starting with z")
+	}
+
+	b, err := base58.Decode(encoded[1:])
+	if err != nil {
+		return nil, errors.New("did:key is not valid base58")
+	}
+
+	switch {
+	case hasPrefix(b, multicodecP256):
+		x, y := elliptic.UnmarshalCompressed(elliptic.P256(), b[len(multicodecP256):])
+		if x == nil {
+			return nil, ErrInvalidPublicKey
+		}
+		return &publicKey{ecdsa: &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}}, nil
+	case hasPrefix(b, multicodecEd25519):
+		key := b[len(multicodecEd25519):]
+		if len(key) != ed25519.PublicKeySize {
+			return nil, errors.New("expected Ed25519 key of did:key to be 32 bytes long")
+		}
+		return &publicKey{ed25519: ed25519.PublicKey(key)}, nil
+	default:
+		return nil, errors.New("expected did:key to hold a P-256 or Ed25519 key")
+	}
+}
+
+func hasPrefix(b, prefix []byte) bool {
+	return len(b) >= len(prefix) && string(b[:len(prefix)]) == string(prefix)
+}
@@ -0,0 +1,115 @@
+package wskeyauth
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Encoding encodes messages for the wire, for deployments that want them in
+// something other than JSON, such as protobuf, with the Encoding in
+// wskeyauthpb, CBOR or MessagePack. Everything else works with messages as
+// JSON all the same: they are converted to and from it at the wire, so that a
+// Codec, interceptors and transcripts need no changes. Messages are converted
+// to JSON's generic values, rather than encoded straight from Go's, so that
+// the names of their fields are as they are in JSON.
+type Encoding interface {
+	// Encode encodes v, which is of JSON's generic values: map[string]any,
+	// []any, string, float64, bool and nil.
+	Encode(v any) ([]byte, error)
+
+	// Decode decodes data into v, which is an *any, to JSON's generic values,
+	// as Encode is given them. Most CBOR and MessagePack libraries have to be
+	// told to decode maps to map[string]any.
+	Decode(data []byte, v *any) error
+
+	// Binary reports whether messages are sent as binary WebSocket messages,
+	// rather than as text.
+	Binary() bool
+}
+
+// JSONEncoding encodes messages as JSON, as they are without an Encoding. It
+// is mostly of use as an example.
+var JSONEncoding Encoding = jsonEncoding{}
+
+type jsonEncoding struct{}
+
+func (jsonEncoding) Encode(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonEncoding) Decode(data []byte, v *any) error { return json.Unmarshal(data, v) }
+
+func (jsonEncoding) Binary() bool { return false }
+
+// WithEncoding encodes the handshake's messages with enc, rather than as JSON.
+// The connection must be a MessageConn, as gorilla/websocket's is, and clients
+// must use the same encoding, as with the Encoding of the Go client.
+func WithEncoding(enc Encoding) Option {
+	return func(cfg *config) {
+		cfg.encoding = enc
+	}
+}
+
+// MessageConn is a connection that sends and receives whole messages, of a
+// WebSocket message type, as gorilla/websocket's does.
+type MessageConn interface {
+	ReadMessage() (messageType int, data []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+}
+
+const (
+	textMessage   = 1
+	binaryMessage = 2
+)
+
+// errNotMessageConn is returned by handshakes with WithEncoding over
+// connections that aren't MessageConns.
+var errNotMessageConn = errors.New("wskeyauth: WithEncoding needs a connection with ReadMessage and WriteMessage methods")
+
+// NewEncodedConn returns a Conn that reads and writes messages over conn
+// encoded with enc, for the application to keep talking to the client in the
+// same encoding once the handshake is done.
+func NewEncodedConn(conn MessageConn, enc Encoding) Conn {
+	return &encodedConn{conn: conn, enc: enc}
+}
+
+type encodedConn struct {
+	conn MessageConn
+	enc  Encoding
+}
+
+func (c *encodedConn) ReadJSON(v any) error {
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+
+	var generic any
+	if err := c.enc.Decode(data, &generic); err != nil {
+		return err
+	}
+	b, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (c *encodedConn) WriteJSON(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var generic any
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return err
+	}
+
+	data, err := c.enc.Encode(generic)
+	if err != nil {
+		return err
+	}
+	messageType := textMessage
+	if c.enc.Binary() {
+		messageType = binaryMessage
+	}
+	return c.conn.WriteMessage(messageType, data)
+}
@@ -0,0 +1,298 @@
+package wskeyauth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Once a client and a server with a ServerKey have authenticated each other,
+// they may agree on keys to encrypt the rest of the connection with, so that
+// it stays confidential through proxies that terminate TLS. Each side sends
+// an ephemeral X25519 key, signed with its own key:
+//
+//	<- {"type": "KEY_EXCHANGE", "data": {"publicKey": "<base64>", "signature": "<base64>"}}
+//	-> {"type": "KEY_EXCHANGE", "data": {"publicKey": "<base64>", "signature": "<base64>"}}
+//
+// The client signs KeyExchangePrefix, "client" and its key, and the server
+// KeyExchangePrefix, "server", the client's key and its own, as they sign
+// challenges. Both then derive a key for each direction with HKDF-SHA-256,
+// from the X25519 shared secret, salted with both keys. Every message after
+// that is sealed with AES-256-GCM, under a nonce counting the messages sent in
+// that direction:
+//
+//	{"type": "ENCRYPTED", "data": "<base64 ciphertext>"}
+//
+// The keys are ephemeral, so recorded traffic stays confidential even if
+// either side's key leaks later.
+
+// KeyExchangePrefix starts everything signed for a KEY_EXCHANGE. Its
+// messages are of a fixed length, shorter than any challenge, so they can't
+// pass for a challenge response either.
+const KeyExchangePrefix = "wskeyauth key exchange\x00"
+
+type keyExchangeData struct {
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+}
+
+type keyExchangeMessage struct {
+	Type string          `json:"type"`
+	Data keyExchangeData `json:"data"`
+}
+
+func keyExchangeInput(client, server []byte) []byte {
+	role := "client"
+	if server != nil {
+		role = "server"
+	}
+	b := append([]byte(KeyExchangePrefix), role...)
+	return append(append(b, client...), server...)
+}
+
+// EncryptServerSession agrees on keys with the client authenticated as
+// clientID, proving the server's identity with key, and returns conn
+// encrypted with them. Call it right after Handshake, if the client asks
+// for encryption; how it does is up to the application.
+func EncryptServerSession(conn Conn, clientID string, key *ServerKey) (*EncryptedConn, error) {
+	if FIPS() {
+		return nil, notApproved("X25519 key agreement")
+	}
+
+	clientKey, err := parseClientID(clientID, Base64Any)
+	if err != nil {
+		return nil, err
+	}
+	if !signsMessages(clientKey) {
+		return nil, errNoMessageKey
+	}
+
+	fail := func(reason FailureReason, message string, err error) (*EncryptedConn, error) {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", reason, message, err))
+		if err == nil {
+			return nil, errors.New("wskeyauth: " + message)
+		}
+		return nil, fmt.Errorf("wskeyauth: %s: %w", message, err)
+	}
+
+	var msg keyExchangeMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		return nil, err
+	}
+	if msg.Type != "KEY_EXCHANGE" {
+		return fail(ReasonUnexpectedMessage, "Expected a KEY_EXCHANGE event, but got "+msg.Type, nil)
+	}
+	clientPub, sig, err := decodeKeyExchange(msg.Data)
+	if err != nil {
+		return fail(ReasonMalformedMessage, "Failed to parse KEY_EXCHANGE", err)
+	}
+	if !verifyWithKey(clientKey, keyExchangeInput(clientPub.Bytes(), nil), sig) {
+		return fail(ReasonSignatureMismatch, "KEY_EXCHANGE signature doesn't match", errMessageSignatureMismatch)
+	}
+
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serverPub := private.PublicKey().Bytes()
+	sig, err = key.sign(keyExchangeInput(clientPub.Bytes(), serverPub))
+	if err != nil {
+		return nil, err
+	}
+	err = conn.WriteJSON(&keyExchangeMessage{Type: "KEY_EXCHANGE", Data: keyExchangeData{
+		PublicKey: base64.StdEncoding.EncodeToString(serverPub),
+		Signature: base64.StdEncoding.EncodeToString(sig),
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	return newEncryptedConn(conn, private, clientPub, false)
+}
+
+// EncryptClientSession is the client's side of EncryptServerSession, for
+// clients such as wskeyauthclient's. sign signs what the client's key signs,
+// and serverID is the ID of the server's key.
+func EncryptClientSession(conn Conn, serverID string, sign func(message []byte) ([]byte, error)) (*EncryptedConn, error) {
+	if FIPS() {
+		return nil, notApproved("X25519 key agreement")
+	}
+
+	serverKey, err := parseClientID(serverID, Base64Std)
+	if err != nil {
+		return nil, err
+	}
+	if !signsMessages(serverKey) {
+		return nil, errors.New("wskeyauth: not a server ID")
+	}
+
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	clientPub := private.PublicKey().Bytes()
+	sig, err := sign(keyExchangeInput(clientPub, nil))
+	if err != nil {
+		return nil, err
+	}
+	err = conn.WriteJSON(&keyExchangeMessage{Type: "KEY_EXCHANGE", Data: keyExchangeData{
+		PublicKey: base64.StdEncoding.EncodeToString(clientPub),
+		Signature: base64.StdEncoding.EncodeToString(sig),
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	var msg keyExchangeMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		return nil, err
+	}
+	if msg.Type != "KEY_EXCHANGE" {
+		return nil, fmt.Errorf("wskeyauth: expected KEY_EXCHANGE, but got %s", msg.Type)
+	}
+	serverPub, sig, err := decodeKeyExchange(msg.Data)
+	if err != nil {
+		return nil, fmt.Errorf("wskeyauth: failed to parse KEY_EXCHANGE: %w", err)
+	}
+	if !verifyWithKey(serverKey, keyExchangeInput(clientPub, serverPub.Bytes()), sig) {
+		return nil, errors.New("wskeyauth: server's KEY_EXCHANGE signature doesn't match its ID")
+	}
+
+	return newEncryptedConn(conn, private, serverPub, true)
+}
+
+func decodeKeyExchange(data keyExchangeData) (*ecdh.PublicKey, []byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(data.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	pub, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(data.Signature)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pub, sig, nil
+}
+
+// EncryptedConn is a connection whose messages are encrypted with keys agreed
+// on by EncryptServerSession and EncryptClientSession. Reads and writes may
+// happen concurrently with each other, but writes are serialized, and so are
+// reads.
+type EncryptedConn struct {
+	conn Conn
+
+	writeMu sync.Mutex
+	seal    cipher.AEAD
+	sent    uint64
+
+	readMu   sync.Mutex
+	open     cipher.AEAD
+	received uint64
+}
+
+func newEncryptedConn(conn Conn, private *ecdh.PrivateKey, peer *ecdh.PublicKey, client bool) (*EncryptedConn, error) {
+	shared, err := private.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	clientPub, serverPub := private.PublicKey().Bytes(), peer.Bytes()
+	if !client {
+		clientPub, serverPub = serverPub, clientPub
+	}
+	prk := hkdfExtract(append(clientPub, serverPub...), shared)
+	toServer, err := newGCM(hkdfExpand(prk, "wskeyauth client to server"))
+	if err != nil {
+		return nil, err
+	}
+	toClient, err := newGCM(hkdfExpand(prk, "wskeyauth server to client"))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &EncryptedConn{conn: conn, seal: toClient, open: toServer}
+	if client {
+		c.seal, c.open = toServer, toClient
+	}
+	return c, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// hkdfExtract and hkdfExpand are HKDF-SHA-256, as in RFC 5869, expanding to
+// exactly one block, which is all the key we need.
+func hkdfExtract(salt, secret []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(secret)
+	return mac.Sum(nil)
+}
+
+func hkdfExpand(prk []byte, info string) []byte {
+	mac := hmac.New(sha256.New, prk)
+	mac.Write([]byte(info))
+	mac.Write([]byte{1})
+	return mac.Sum(nil)
+}
+
+func nonce(n uint64) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b[4:], n)
+	return b
+}
+
+var errDecrypt = errors.New("wskeyauth: failed to decrypt message")
+
+func (c *EncryptedConn) WriteJSON(v any) error {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	ciphertext := c.seal.Seal(nil, nonce(c.sent), plaintext, nil)
+	c.sent++
+	return c.conn.WriteJSON(&stringMessage{Type: "ENCRYPTED", Data: base64.StdEncoding.EncodeToString(ciphertext)})
+}
+
+func (c *EncryptedConn) ReadJSON(v any) error {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	var msg stringMessage
+	if err := c.conn.ReadJSON(&msg); err != nil {
+		return err
+	}
+	if msg.Type != "ENCRYPTED" {
+		return fmt.Errorf("wskeyauth: expected an ENCRYPTED message, but got %s", msg.Type)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(msg.Data)
+	if err != nil {
+		return errDecrypt
+	}
+	plaintext, err := c.open.Open(nil, nonce(c.received), ciphertext, nil)
+	if err != nil {
+		return errDecrypt
+	}
+	c.received++
+	return json.Unmarshal(plaintext, v)
+}
@@ -0,0 +1,111 @@
+package wskeyauth
+
+import (
+	"crypto/ecdh"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+)
+
+// queueConn is a Conn that reads back what was written to it, in order.
+type queueConn struct {
+	msgs []json.RawMessage
+}
+
+func (c *queueConn) WriteJSON(v any) error {
+	msg, err := json.Marshal(v)
+	c.msgs = append(c.msgs, msg)
+	return err
+}
+
+func (c *queueConn) ReadJSON(v any) error {
+	if len(c.msgs) == 0 {
+		return io.EOF
+	}
+	msg := c.msgs[0]
+	c.msgs = c.msgs[1:]
+	return json.Unmarshal(msg, v)
+}
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestHKDF checks HKDF against test case 1 of RFC 5869, Appendix A, up to the
+// one block hkdfExpand returns.
+func TestHKDF(t *testing.T) {
+	prk := hkdfExtract(unhex(t, "000102030405060708090a0b0c"), unhex(t, "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b"))
+	if got := hex.EncodeToString(prk); got != "077709362c2e32df0ddc3f0dc47bba6390b6c73bb50f9c3122ec844ad7c2b3e5" {
+		t.Fatalf("PRK = %s", got)
+	}
+	okm := hkdfExpand(prk, string(unhex(t, "f0f1f2f3f4f5f6f7f8f9")))
+	if got := hex.EncodeToString(okm); got != "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf" {
+		t.Fatalf("OKM = %s", got)
+	}
+}
+
+// TestEncryptedConn checks the keys and ciphertexts of an encrypted session
+// between the X25519 keys of RFC 7748, section 6.1, the client's being
+// Alice's and the server's Bob's, against known answers computed with Node's
+// crypto.
+func TestEncryptedConn(t *testing.T) {
+	alice, err := ecdh.X25519().NewPrivateKey(unhex(t, "77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := ecdh.X25519().NewPrivateKey(unhex(t, "5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := &queueConn{}
+	client, err := newEncryptedConn(conn, alice, bob.PublicKey(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := newEncryptedConn(conn, bob, alice.PublicKey(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct {
+		from, to *EncryptedConn
+		typ      string
+		want     string
+	}{
+		{client, server, "HELLO", "BMvdczZjVbjXitDkUEMYyT7moc7jQOI5Wc5CfYfahpo="},
+		{client, server, "HELLO", "+SQzi7ZrJDnLoY8wZGCkqvkpMWAzUzogsu4uvJl/qjA="},
+		{server, client, "WELCOME", "DQhiB+B94R/hVdsfiP0cFktfNfBHnj5njPv28t1Di4lPYQ=="},
+	} {
+		if err := step.from.WriteJSON(map[string]string{"type": step.typ}); err != nil {
+			t.Fatal(err)
+		}
+		var sent stringMessage
+		if err := json.Unmarshal(conn.msgs[0], &sent); err != nil || sent.Type != "ENCRYPTED" || sent.Data != step.want {
+			t.Fatalf("%s was sent as %s, want %s", step.typ, conn.msgs[0], step.want)
+		}
+
+		var got TypeData
+		if err := step.to.ReadJSON(&got); err != nil || got.Type != step.typ {
+			t.Fatalf("%s was read as %+v, %v", step.typ, got, err)
+		}
+	}
+
+	// a message replayed is under the wrong nonce, and one tampered with
+	// fails to authenticate
+	conn.WriteJSON(&stringMessage{Type: "ENCRYPTED", Data: "BMvdczZjVbjXitDkUEMYyT7moc7jQOI5Wc5CfYfahpo="})
+	conn.WriteJSON(&stringMessage{Type: "ENCRYPTED", Data: "AMvdczZjVbjXitDkUEMYyT7moc7jQOI5Wc5CfYfahpo="})
+	for i := 0; i < 2; i++ {
+		var got TypeData
+		if err := server.ReadJSON(&got); !errors.Is(err, errDecrypt) {
+			t.Fatalf("ReadJSON() = %v, want errDecrypt", err)
+		}
+	}
+}
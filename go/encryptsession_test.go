@@ -0,0 +1,56 @@
+package wskeyauth_test
+
+import (
+	"testing"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+func TestEncryptSession(t *testing.T) {
+	clientKey := wskeyauthtest.MustGenerateKey()
+	serverKey, err := wskeyauth.NewServerKey(wskeyauthtest.MustGenerateKey().Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverConn, clientConn := wskeyauthtest.Pipe()
+	done := make(chan *wskeyauth.EncryptedConn, 1)
+	go func() {
+		conn, err := wskeyauth.EncryptClientSession(clientConn, serverKey.ID(), clientKey.Sign)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- conn
+	}()
+	server, err := wskeyauth.EncryptServerSession(serverConn, clientKey.ClientID(), serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := <-done
+	if client == nil {
+		t.FailNow()
+	}
+
+	if err := client.WriteJSON(map[string]string{"type": "HELLO"}); err != nil {
+		t.Fatal(err)
+	}
+	var msg wskeyauth.TypeData
+	if err := server.ReadJSON(&msg); err != nil || msg.Type != "HELLO" {
+		t.Fatalf("server read %+v, %v", msg, err)
+	}
+	if err := server.WriteJSON(map[string]string{"type": "WELCOME"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ReadJSON(&msg); err != nil || msg.Type != "WELCOME" {
+		t.Fatalf("client read %+v, %v", msg, err)
+	}
+
+	// what goes over the wire is sealed
+	if err := client.WriteJSON(map[string]string{"type": "HELLO"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := serverConn.ReadJSON(&msg); err != nil || msg.Type != "ENCRYPTED" {
+		t.Fatalf("wire carried %+v, %v", msg, err)
+	}
+}
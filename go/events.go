@@ -0,0 +1,149 @@
+package wskeyauth
+
+import (
+	"sync"
+	"time"
+)
+
+// EventKind is a kind of lifecycle event, or, or'd together, a set of them,
+// as given to EventBus.Subscribe.
+type EventKind uint8
+
+const (
+	// EventAuthenticated is published when a handshake authenticated a
+	// client.
+	EventAuthenticated EventKind = 1 << iota
+	// EventFailed is published when a handshake failed.
+	EventFailed
+	// EventDisconnected is published when a session left a registry.
+	EventDisconnected
+
+	// EventAll is every kind of event.
+	EventAll = EventAuthenticated | EventFailed | EventDisconnected
+)
+
+// Event is a lifecycle event, as delivered to EventBus subscribers.
+type Event struct {
+	Kind EventKind
+	Time time.Time
+
+	ClientID    string
+	Fingerprint string
+	RemoteAddr  string
+
+	// Result is the outcome of the handshake of EventAuthenticated and
+	// EventFailed, and Err the error it failed with, if any.
+	Result *Result
+	Err    error
+
+	// Session is the session of EventDisconnected.
+	Session *Session
+}
+
+// EventBus fans lifecycle events out to any number of subscribers, for
+// consumers such as metrics, webhooks and registries to each get every
+// event, rather than share the callbacks of Hooks. Handshakes publish to it
+// with WithEventBus, and registries with WithRegistryEventBus:
+//
+//	bus := wskeyauth.NewEventBus()
+//	registry := wskeyauth.NewRegistry(wskeyauth.WithRegistryEventBus(bus))
+//	bus.Subscribe(wskeyauth.EventFailed|wskeyauth.EventDisconnected, func(e wskeyauth.Event) {
+//		...
+//	})
+//	wskeyauth.Handshake(conn, wskeyauth.WithEventBus(bus))
+type EventBus struct {
+	// Clock stamps the events published without a Time. It defaults to
+	// SystemClock.
+	Clock Clock
+
+	mu          sync.RWMutex
+	subscribers map[int]eventSubscriber
+	nextID      int
+}
+
+type eventSubscriber struct {
+	kinds EventKind
+	f     func(Event)
+}
+
+// NewEventBus creates an event bus without subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: map[int]eventSubscriber{}}
+}
+
+// Subscribe calls f with every event of kinds published, until unsubscribe
+// is called. f is called from the goroutine that published the event, such
+// as the one running the handshake, so it should hand slow work off rather
+// than block.
+func (b *EventBus) Subscribe(kinds EventKind, f func(Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = eventSubscriber{kinds: kinds, f: f}
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		delete(b.subscribers, id)
+		b.mu.Unlock()
+	}
+}
+
+// Publish delivers e to the subscribers of its kind, setting its Time if it
+// has none.
+func (b *EventBus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = clockOr(b.Clock).Now()
+	}
+
+	b.mu.RLock()
+	subscribers := make([]func(Event), 0, len(b.subscribers))
+	for _, s := range b.subscribers {
+		if s.kinds&e.Kind != 0 {
+			subscribers = append(subscribers, s.f)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, f := range subscribers {
+		f(e)
+	}
+}
+
+// WithEventBus publishes the outcome of the handshake to bus, as an
+// EventAuthenticated or an EventFailed.
+func WithEventBus(bus *EventBus) Option {
+	return func(cfg *config) {
+		cfg.events = bus
+	}
+}
+
+// WithRegistryEventBus publishes an EventDisconnected to bus whenever a
+// session leaves the registry.
+func WithRegistryEventBus(bus *EventBus) RegistryOption {
+	return func(r *Registry) {
+		r.events = bus
+	}
+}
+
+// publish publishes the outcome of the handshake to the event bus, if there
+// is one.
+func (h *handshakeState) publish(result *Result, err error) {
+	if h.cfg.events == nil {
+		return
+	}
+
+	kind := EventFailed
+	if result.Authenticated {
+		kind = EventAuthenticated
+	}
+	h.cfg.events.Publish(Event{
+		Kind:        kind,
+		Time:        h.cfg.clock.Now(),
+		ClientID:    result.ClientID,
+		Fingerprint: result.Fingerprint,
+		RemoteAddr:  h.remoteAddr,
+		Result:      result,
+		Err:         err,
+	})
+}
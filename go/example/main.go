@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -12,6 +13,11 @@ import (
 )
 
 func main() {
+	registry := wskeyauth.NewRegistry()
+	registry.Subscribe(func(e wskeyauth.RegistryEvent) {
+		log.Printf("%s (%d connected)", e.Session.Fingerprint(), registry.Count())
+	})
+
 	router := mux.NewRouter()
 	router.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		upgrader := websocket.Upgrader{
@@ -24,15 +30,17 @@ func main() {
 		}
 		defer conn.Close()
 
-		authenticated, clientID, err := wskeyauth.Handshake(conn)
-
+		authenticated, clientID, err := wskeyauth.Handshake(conn, wskeyauth.WithLogger(slog.Default()))
 		if !authenticated || err != nil {
-			log.Println(err)
-			log.Println("Failed to authenticate")
 			return
 		}
 
-		log.Println("Authenticated")
+		session, err := registry.Add(clientID, conn)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		defer session.Leave()
 
 		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Client ID: %s", clientID)))
 
@@ -0,0 +1,108 @@
+package wskeyauth
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SessionExpiredCloseCode is the WebSocket close code sent to clients whose
+// session expired. It is in the range reserved for applications, after
+// HTTP's 401 Unauthorized, as the client has to authenticate again.
+const SessionExpiredCloseCode = 4401
+
+// SessionExpiry bounds how long sessions last.
+type SessionExpiry struct {
+	// Idle is how long a session may go without activity, as reported with
+	// Session.Touch. 0 means sessions don't expire for being idle.
+	Idle time.Duration
+
+	// Lifetime is how long a session may last at most, however active. 0
+	// means sessions don't expire for being old.
+	Lifetime time.Duration
+}
+
+// WithSessionExpiry has the registry expire sessions as e says. Expired
+// sessions are sent a SESSION_EXPIRED message, and disconnected with
+// SessionExpiredCloseCode.
+func WithSessionExpiry(e SessionExpiry) RegistryOption {
+	return func(r *Registry) {
+		r.expiry = e
+	}
+}
+
+// WithRegistryClock has the registry tell when sessions connected, and time
+// their expiry, by clock, instead of SystemClock.
+func WithRegistryClock(clock Clock) RegistryOption {
+	return func(r *Registry) {
+		r.clock = clock
+	}
+}
+
+// Touch records activity on the session, such as a message from the client,
+// postponing its expiry for being idle. It is cheap enough to call for every
+// message.
+func (s *Session) Touch() {
+	s.lastActive.Store(s.registry.clock.Now().UnixNano())
+}
+
+// startExpiry starts the clock on s, if sessions expire.
+func (r *Registry) startExpiry(s *Session) {
+	if r.expiry.Idle <= 0 && r.expiry.Lifetime <= 0 {
+		return
+	}
+	s.lastActive.Store(s.ConnectedAt.UnixNano())
+	s.expiry = r.clock.AfterFunc(r.nextExpiry(s), func() { r.checkExpiry(s) })
+}
+
+// stopExpiry stops the clock on s for good.
+func (s *Session) stopExpiry() {
+	s.expiryMu.Lock()
+	defer s.expiryMu.Unlock()
+
+	if s.expiry != nil {
+		s.expiry.Stop()
+		s.expiry = nil
+	}
+}
+
+// nextExpiry returns how long until s expires, if it isn't touched in the
+// meantime.
+func (r *Registry) nextExpiry(s *Session) time.Duration {
+	var at time.Time
+	if r.expiry.Idle > 0 {
+		at = time.Unix(0, s.lastActive.Load()).Add(r.expiry.Idle)
+	}
+	if r.expiry.Lifetime > 0 {
+		if end := s.ConnectedAt.Add(r.expiry.Lifetime); at.IsZero() || end.Before(at) {
+			at = end
+		}
+	}
+	return at.Sub(r.clock.Now())
+}
+
+func (r *Registry) checkExpiry(s *Session) {
+	if d := r.nextExpiry(s); d > 0 {
+		s.expiryMu.Lock()
+		if s.expiry != nil {
+			s.expiry = r.clock.AfterFunc(d, func() { r.checkExpiry(s) })
+		}
+		s.expiryMu.Unlock()
+		return
+	}
+
+	s.Send(&stringMessage{Type: "SESSION_EXPIRED", Data: "The session expired"})
+	if s.Disconnect(SessionExpiredCloseCode, "session expired") != nil {
+		// the connection can't be closed, but the session is over all the
+		// same
+		s.Leave()
+	}
+}
+
+// sessionExpiry is what a session needs to expire.
+type sessionExpiry struct {
+	// expiry is nil once the session left
+	expiryMu   sync.Mutex
+	expiry     Timer
+	lastActive atomic.Int64
+}
@@ -0,0 +1,47 @@
+package wskeyauth_test
+
+import (
+	"testing"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+func TestSessionExpiryByClock(t *testing.T) {
+	clock := wskeyauthtest.NewClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	registry := wskeyauth.NewRegistry(
+		wskeyauth.WithRegistryClock(clock),
+		wskeyauth.WithSessionExpiry(wskeyauth.SessionExpiry{Idle: time.Minute}),
+	)
+	conn := &closeConn{closed: make(chan struct{})}
+	s, err := registry.Add(wskeyauthtest.MustGenerateKey().ClientID(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(30 * time.Second)
+	s.Touch()
+	// the first timer comes due, and finds the session was touched since
+	clock.Advance(40 * time.Second)
+	for deadline := time.Now().Add(time.Second); clock.Timers() != 1; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the expiry wasn't postponed")
+		}
+	}
+	if registry.Count() != 1 {
+		t.Fatal("a session that was touched expired")
+	}
+
+	clock.Advance(30 * time.Second)
+	select {
+	case <-conn.closed:
+	case <-time.After(time.Second):
+		t.Fatal("an idle session didn't expire")
+	}
+	for deadline := time.Now().Add(time.Second); registry.Count() != 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("an expired session is still registered")
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package wskeyauth
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotApproved is wrapped by the errors of everything FIPS mode refuses to
+// do, as its algorithms aren't approved: Noise handshakes, the key agreement
+// of EncryptServerSession and EncryptClientSession, which are built on
+// X25519, and signing with Ed25519 keys. As they take no options, the
+// functions of clients and of EncryptServerSession only refuse when FIPS
+// reports true.
+var ErrNotApproved = errors.New("wskeyauth: not approved in FIPS mode")
+
+func notApproved(what string) error {
+	return fmt.Errorf("%w: %s", ErrNotApproved, what)
+}
+
+// fipsForced is set by the files built for FIPS-only programs, as told by
+// FIPS.
+var fipsForced bool
+
+// FIPS reports whether every handshake runs in FIPS mode, as if WithFIPS were
+// given, because of how the program was built or run: with
+// GOEXPERIMENT=boringcrypto, with the wskeyauth_fips build tag, or, from Go
+// 1.24 on, with GODEBUG=fips140=on or fips140=only.
+func FIPS() bool {
+	return fipsForced
+}
+
+// WithFIPS only lets the handshake use algorithms FIPS 140 approves: client
+// keys on P-256, signed over SHA-256, and X.509 client certificates signed
+// over SHA-256, SHA-384 or SHA-512, with RSA keys of at least 2048 bits, on
+// top of whatever WithAlgorithmPolicy allows. Ed25519 and password client
+// IDs are turned away with UNSUPPORTED_ALGORITHM, and NoiseHandshake fails
+// with ErrNotApproved, as does a handshake proving the server's identity
+// with an Ed25519 server key. Delegated client IDs vouched for by Ed25519
+// roots of WithDelegation aren't trusted, and ID tokens of WithOIDC signed
+// with RSA keys of fewer than 2048 bits don't verify; those of Ed25519 keys
+// never do.
+//
+// HMACs keep using SHA-1 where their standards say so, as for TOTP codes and
+// TURN credentials: FIPS approves HMAC-SHA-1.
+func WithFIPS() Option {
+	return func(cfg *config) {
+		cfg.fips = true
+	}
+}
@@ -0,0 +1,11 @@
+//go:build go1.24
+
+package wskeyauth
+
+import "crypto/fips140"
+
+func init() {
+	if fips140.Enabled() {
+		fipsForced = true
+	}
+}
@@ -0,0 +1,11 @@
+//go:build boringcrypto
+
+package wskeyauth
+
+import "crypto/boring"
+
+func init() {
+	if boring.Enabled() {
+		fipsForced = true
+	}
+}
@@ -0,0 +1,7 @@
+//go:build wskeyauth_fips
+
+package wskeyauth
+
+func init() {
+	fipsForced = true
+}
@@ -0,0 +1,79 @@
+package wskeyauth
+
+import (
+	"strings"
+)
+
+// clientIDFormatParser parses the client IDs of one format.
+type clientIDFormatParser struct {
+	format ClientIDFormat
+	match  func(clientID string) bool
+	parse  func(clientID string, accept Base64) (*publicKey, error)
+}
+
+func hasClientIDPrefix(prefix string) func(string) bool {
+	return func(clientID string) bool {
+		return strings.HasPrefix(clientID, prefix)
+	}
+}
+
+// clientIDFormats are the formats client IDs are told apart by, in the order
+// they are tried, each recognized by its prefix.
+var clientIDFormats = []clientIDFormatParser{
+	{FormatDIDKey, hasClientIDPrefix(didKeyPrefix), ignoreBase64(parseDIDKey)},
+	{FormatSSH, isSSHKey, ignoreBase64(parseSSHKey)},
+	{FormatX509, hasClientIDPrefix(x509Prefix), parseX509ClientID},
+	{FormatDelegated, hasClientIDPrefix(delegationPrefix), parseDelegatedClientID},
+	{FormatJWK, hasClientIDPrefix(jwkPrefix), ignoreBase64(parseJWKClientID)},
+	{FormatSPKI, hasClientIDPrefix(spkiPrefix), parseSPKIClientID},
+	{FormatHMAC, hasClientIDPrefix(hmacPrefix), ignoreBase64(parseKeyID)},
+	{FormatSRP, hasClientIDPrefix(srpPrefix), ignoreBase64(parseSRPClientID)},
+	{FormatWebCrypto, hasClientIDPrefix("WebCrypto-raw.EC.P-256$"), parseRawClientID},
+	{FormatWebAuthn, hasClientIDPrefix("WebAuthn-raw.EC.P-256$"), parseRawClientID},
+}
+
+func ignoreBase64(parse func(string) (*publicKey, error)) func(string, Base64) (*publicKey, error) {
+	return func(clientID string, _ Base64) (*publicKey, error) {
+		return parse(clientID)
+	}
+}
+
+// detectClientIDFormat returns the parser of the format of clientID, which
+// must be in no namespace, or nil if it is of none.
+func detectClientIDFormat(clientID string) *clientIDFormatParser {
+	for i := range clientIDFormats {
+		if clientIDFormats[i].match(clientID) {
+			return &clientIDFormats[i]
+		}
+	}
+	return nil
+}
+
+// clientIDFormat returns the format clientID claims to be of, by its prefix,
+// or "" if it is of none.
+func clientIDFormat(clientID string) ClientIDFormat {
+	stripped, _ := splitNamespace(clientID)
+	if f := detectClientIDFormat(stripped); f != nil {
+		return f.format
+	}
+	return ""
+}
+
+// WithClientIDFormats only accepts client IDs of formats, rejecting the
+// others before they are parsed. Without it, client IDs of every format are
+// accepted, as far as the server is configured for them: X.509 client IDs
+// still need WithX509, say.
+func WithClientIDFormats(formats ...ClientIDFormat) Option {
+	return func(cfg *config) {
+		cfg.formats = map[ClientIDFormat]bool{}
+		for _, f := range formats {
+			cfg.formats[f] = true
+		}
+	}
+}
+
+// acceptsFormat reports whether the server accepts client IDs that claim to
+// be of format. Client IDs of no format are left to fail parsing.
+func (cfg *config) acceptsFormat(format ClientIDFormat) bool {
+	return cfg.formats == nil || format == "" || cfg.formats[format]
+}
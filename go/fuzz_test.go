@@ -0,0 +1,89 @@
+package wskeyauth
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var fuzzClientIDs = []string{
+	"WebCrypto-raw.EC.P-256$BPd6f9Lc2Y2B6D2uPZeP1r4kQvzCy0OQ7FQ4EoQ9e0w1yqQ8m6/mYcJ0xk8K4RfrZ2pQ3V7zW8T1q6JZ1kQ0AAA=",
+	"did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK",
+	"did:key:zDnaerDaTF5BXEavCrfRZEk316dpbLsfPDZ3WJ5hRTPFU2169",
+	"HMAC-SHA-256$key-1",
+	"SRP-6a$alice",
+	"ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBA==",
+	`{"kty":"EC","crv":"P-256","x":"AAAA","y":"AAAA"}`,
+	"ns:example$WebCrypto-raw.EC.P-256$AAAA",
+	"",
+	"$",
+}
+
+func FuzzParseClientID(f *testing.F) {
+	for _, clientID := range fuzzClientIDs {
+		f.Add(clientID)
+	}
+	f.Fuzz(func(t *testing.T, clientID string) {
+		pubKey, err := parseClientID(clientID, Base64Any)
+		if err != nil {
+			return
+		}
+		if pubKey == nil {
+			t.Fatalf("parseClientID(%q) returned neither a key nor an error", clientID)
+		}
+		// everything parseClientID accepts can be fingerprinted
+		fp, err := Fingerprint(clientID)
+		if err != nil || fp != fingerprint(pubKey) {
+			t.Fatalf("Fingerprint(%q) = %q, %v, but parseClientID's key is %q", clientID, fp, err, fingerprint(pubKey))
+		}
+	})
+}
+
+// rawConn is a Conn whose one message is itself.
+type rawConn []byte
+
+func (c rawConn) ReadJSON(v any) error  { return json.Unmarshal(c, v) }
+func (c rawConn) WriteJSON(v any) error { return nil }
+
+var fuzzMessages = []string{
+	`{"type":"CLIENT_ID","data":"did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"}`,
+	`{"type":"CLIENT_ID","data":"x","challenge":"AAAA","timestamp":{"time":1,"signature":"AAAA"}}`,
+	`{"type":"CHALLENGE_RESPONSE","data":{"signature":"AAAA","hash":"SHA-256"}}`,
+	`{"type":"CHALLENGE_RESPONSE","data":{"signature":"AAAA","hash":"SHA-256","idToken":"a.b.c","proofOfWork":"AA"}}`,
+	`{"data":{"signature":"é"},"type":"CHALLENGE_RESPONSE","data":"x"}`,
+	`{"type":"SECOND_FACTOR","data":"123456"}`,
+	`{"type":"CLIENT_ID","data":null}`,
+	`{"type":"CLIENT_ID","data":"\xff"}`,
+	`{"type":"CLIENT_ID"}`,
+	`[]`,
+}
+
+func FuzzCodecs(f *testing.F) {
+	for _, msg := range fuzzMessages {
+		f.Add([]byte(msg))
+	}
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		var want, compact, strict ClientMessage
+		wantErr := JSONCodec.ReadMessage(rawConn(raw), &want)
+		compactErr := CompactJSONCodec.ReadMessage(rawConn(raw), &compact)
+
+		// CompactJSONCodec accepts exactly what JSONCodec does, and decodes
+		// it the same
+		if (wantErr == nil) != (compactErr == nil) {
+			t.Fatalf("JSONCodec: %v, CompactJSONCodec: %v", wantErr, compactErr)
+		}
+		if wantErr == nil && !reflect.DeepEqual(want, compact) {
+			t.Fatalf("JSONCodec decoded %+v, CompactJSONCodec %+v", want, compact)
+		}
+
+		// StrictJSONCodec accepts no more than JSONCodec does
+		if err := StrictJSONCodec.ReadMessage(rawConn(raw), &strict); err == nil {
+			if wantErr != nil {
+				t.Fatalf("StrictJSONCodec accepted what JSONCodec didn't: %v", wantErr)
+			}
+			if !reflect.DeepEqual(want, strict) {
+				t.Fatalf("JSONCodec decoded %+v, StrictJSONCodec %+v", want, strict)
+			}
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package wskeyauth
+
+import "strings"
+
+// Guests configures the server to let clients whose keys aren't in the
+// KeyStore in as guests, for applications that offer some access, such as
+// read-only access, to anyone, without a separate endpoint for them. Guests
+// prove they hold their key as any client does, so they can be told apart
+// from one another, and recognized when they come back.
+//
+// The client is told it is a guest in its SIGNATURE_MATCHES, along with the
+// scopes it was granted:
+//
+//	{"type": "SIGNATURE_MATCHES", "guest": true, "scopes": ["read"]}
+//
+// The server is told in the Result of HandshakeResult. It is up to the
+// application to hold guests to their scopes.
+type Guests struct {
+	// Scopes are what guests may do, such as "read". With WithTokenExchange,
+	// they are the scope requested for guests' access tokens, in place of
+	// TokenExchange.Scope, and with WithMacaroons, guests' macaroons carry a
+	// "scope = <scopes>" caveat, of the scopes separated by spaces. Give
+	// Refresh the same Guests, or guests' refreshed credentials aren't
+	// restricted to them.
+	Scopes []string
+}
+
+// WithGuests lets clients whose keys aren't in the KeyStore in as guests,
+// with g's scopes. Clients whose keys aren't known are still paired with
+// WithPairing, if it is used too, as they would be otherwise. Shared secrets
+// can't be guests, as without the secret their HMAC can't be checked, and
+// neither can Noise clients, which NoiseHandshake has no way to report as
+// guests.
+func WithGuests(g Guests) Option {
+	return func(cfg *config) {
+		cfg.guests = &g
+	}
+}
+
+// scope returns the scopes of g as one string, separated by spaces, as
+// OAuth has them.
+func (g *Guests) scope() string {
+	return strings.Join(g.Scopes, " ")
+}
+
+// caveat returns the caveat guests' macaroons carry.
+func (g *Guests) caveat() string {
+	return "scope = " + g.scope()
+}
@@ -0,0 +1,22 @@
+package wskeyauth_test
+
+import (
+	"testing"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+func BenchmarkHandshake(b *testing.B) {
+	client := &wskeyauthtest.Client{Key: wskeyauthtest.MustGenerateKey()}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		server, conn := wskeyauthtest.Pipe()
+		go client.Run(conn)
+		if authenticated, _, err := wskeyauth.Handshake(server); !authenticated {
+			b.Fatal(err)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,55 @@
+package wskeyauth
+
+// Hooks are called as a handshake progresses, from the goroutine running it.
+// Any of them may be left nil.
+type Hooks struct {
+	// OnClientID is called once the client ID has been parsed, before the
+	// client is challenged. Returning an error rejects the client, failing the
+	// handshake with ReasonRejected.
+	OnClientID func(clientID string) error
+
+	// OnChallengeSent is called after the challenge has been sent to the
+	// client.
+	OnChallengeSent func(clientID string)
+
+	// OnAuthenticated is called after the client's signature was verified, and
+	// SIGNATURE_MATCHES was sent.
+	OnAuthenticated func(clientID string)
+
+	// OnFailure is called when the handshake fails. clientID is empty if the
+	// client didn't get as far as sending one.
+	OnFailure func(clientID string, reason FailureReason, err error)
+}
+
+// WithHooks calls hooks at each stage of the handshake. Only the last
+// WithHooks option passed to a handshake takes effect.
+func WithHooks(hooks Hooks) Option {
+	return func(cfg *config) {
+		cfg.hooks = hooks
+	}
+}
+
+func (h Hooks) clientID(clientID string) error {
+	if h.OnClientID == nil {
+		return nil
+	}
+	return h.OnClientID(clientID)
+}
+
+func (h Hooks) challengeSent(clientID string) {
+	if h.OnChallengeSent != nil {
+		h.OnChallengeSent(clientID)
+	}
+}
+
+func (h Hooks) authenticated(clientID string) {
+	if h.OnAuthenticated != nil {
+		h.OnAuthenticated(clientID)
+	}
+}
+
+func (h Hooks) failure(clientID string, reason FailureReason, err error) {
+	if h.OnFailure != nil {
+		h.OnFailure(clientID, reason, err)
+	}
+}
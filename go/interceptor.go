@@ -0,0 +1,91 @@
+package wskeyauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Interceptor sees every message of a handshake, as it goes over the wire,
+// such as to capture handshakes for compliance, or to add fields for an
+// extension of the protocol. fromClient tells which way the message is going.
+// It returns the message to pass on, which is msg unless it changes it, or an
+// error to veto it.
+//
+// A vetoed message isn't passed on, and the handshake fails with ReasonVetoed
+// and the error. Nothing more is sent to the client; the connection should be
+// closed, as after any failed handshake.
+type Interceptor func(msg json.RawMessage, fromClient bool) (json.RawMessage, error)
+
+// WithInterceptors passes every message of the handshake through
+// interceptors, after those of earlier WithInterceptors options. The first
+// interceptor is closest to the client: it sees messages from the client
+// first, and messages to it last. Messages to the client are intercepted once
+// WithMessageCatalog and WithErrorVerbosity have had their say, and before
+// WithTranscript records them, so that transcripts hold what went over the
+// wire.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(cfg *config) {
+		cfg.interceptors = append(cfg.interceptors, interceptors...)
+	}
+}
+
+// interceptingConn passes messages through interceptors as raw JSON.
+type interceptingConn struct {
+	Conn
+	interceptors []Interceptor
+
+	mu  sync.Mutex
+	err error
+}
+
+func (c *interceptingConn) ReadJSON(v any) error {
+	var msg json.RawMessage
+	if err := c.Conn.ReadJSON(&msg); err != nil {
+		return err
+	}
+
+	var err error
+	for _, intercept := range c.interceptors {
+		if msg, err = intercept(msg, true); err != nil {
+			return c.veto(err)
+		}
+	}
+	return json.Unmarshal(msg, v)
+}
+
+func (c *interceptingConn) WriteJSON(v any) error {
+	if err := c.vetoed(); err != nil {
+		return err
+	}
+
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		if msg, err = c.interceptors[i](msg, false); err != nil {
+			return c.veto(err)
+		}
+	}
+	return c.Conn.WriteJSON(json.RawMessage(msg))
+}
+
+// veto records that err vetoed a message, and returns the error the
+// handshake fails with.
+func (c *interceptingConn) veto(err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err == nil {
+		c.err = fmt.Errorf("wskeyauth: message was vetoed: %w", err)
+	}
+	return c.err
+}
+
+// vetoed returns the error the handshake fails with, if a message was vetoed.
+func (c *interceptingConn) vetoed() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
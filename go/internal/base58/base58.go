@@ -0,0 +1,74 @@
+// Package base58 encodes and decodes base58btc, as did:keys hold keys in.
+package base58
+
+import (
+	"errors"
+	"strings"
+)
+
+const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// ErrInvalid is returned for strings with characters outside the alphabet.
+var ErrInvalid = errors.New("base58: invalid character")
+
+// Encode returns b in base58btc.
+func Encode(b []byte) string {
+	// each leading zero byte is a leading 1
+	zeros := 0
+	for zeros < len(b) && b[zeros] == 0 {
+		zeros++
+	}
+
+	// little-endian base 58 digits
+	var digits []byte
+	for _, v := range b[zeros:] {
+		carry := int(v)
+		for i := range digits {
+			carry += int(digits[i]) << 8
+			digits[i] = byte(carry % 58)
+			carry /= 58
+		}
+		for ; carry > 0; carry /= 58 {
+			digits = append(digits, byte(carry%58))
+		}
+	}
+
+	s := make([]byte, zeros, zeros+len(digits))
+	for i := range s {
+		s[i] = '1'
+	}
+	for i := len(digits) - 1; i >= 0; i-- {
+		s = append(s, alphabet[digits[i]])
+	}
+	return string(s)
+}
+
+// Decode returns the bytes s holds in base58btc.
+func Decode(s string) ([]byte, error) {
+	// each leading 1 is a leading zero byte
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+
+	// big-endian, grown to the left as digits come in
+	var b []byte
+	for i := zeros; i < len(s); i++ {
+		digit := strings.IndexByte(alphabet, s[i])
+		if digit < 0 {
+			return nil, ErrInvalid
+		}
+
+		carry := digit
+		for j := len(b) - 1; j >= 0; j-- {
+			carry += int(b[j]) * 58
+			b[j] = byte(carry)
+			carry >>= 8
+		}
+		for ; carry > 0; carry >>= 8 {
+			b = append([]byte{byte(carry)}, b...)
+		}
+	}
+
+	return append(make([]byte, zeros, zeros+len(b)), b...), nil
+}
@@ -0,0 +1,298 @@
+// Package noise implements the Noise_XX_25519_AESGCM_SHA256 handshake, from
+// revision 34 of the Noise Protocol Framework, and nothing else of it.
+//
+//	-> e
+//	<- e, ee, s, es
+//	-> s, se
+package noise
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const protocolName = "Noise_XX_25519_AESGCM_SHA256"
+
+const (
+	dhLen  = 32
+	tagLen = 16
+)
+
+// ErrDecrypt is returned when a handshake message fails to authenticate.
+var ErrDecrypt = errors.New("noise: failed to decrypt handshake message")
+
+var errShort = errors.New("noise: handshake message is too short")
+
+// Cipher is an AES-GCM key, with the nonce it is at, as Noise uses it for
+// transport messages after the handshake.
+type Cipher struct {
+	aead cipher.AEAD
+	n    uint64
+}
+
+func newCipher(key []byte) *Cipher {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err) // keys are always 32 bytes
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &Cipher{aead: aead}
+}
+
+// AEAD returns the cipher, for sealing with a nonce of 32 zero bits followed
+// by the 64 bit big-endian message counter, starting at 0.
+func (c *Cipher) AEAD() cipher.AEAD {
+	return c.aead
+}
+
+func (c *Cipher) nonce() []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b[4:], c.n)
+	return b
+}
+
+func (c *Cipher) seal(ad, plaintext []byte) []byte {
+	ct := c.aead.Seal(nil, c.nonce(), plaintext, ad)
+	c.n++
+	return ct
+}
+
+func (c *Cipher) open(ad, ciphertext []byte) ([]byte, error) {
+	pt, err := c.aead.Open(nil, c.nonce(), ciphertext, ad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	c.n++
+	return pt, nil
+}
+
+// symmetric is the SymmetricState of the spec.
+type symmetric struct {
+	ck, h  []byte
+	cipher *Cipher
+}
+
+func (s *symmetric) mixHash(data []byte) {
+	sum := sha256.Sum256(append(s.h, data...))
+	s.h = sum[:]
+}
+
+func (s *symmetric) mixKey(ikm []byte) {
+	var k []byte
+	s.ck, k = hkdf(s.ck, ikm)
+	s.cipher = newCipher(k)
+}
+
+func (s *symmetric) encryptAndHash(plaintext []byte) []byte {
+	ct := plaintext
+	if s.cipher != nil {
+		ct = s.cipher.seal(s.h, plaintext)
+	}
+	s.mixHash(ct)
+	return ct
+}
+
+func (s *symmetric) decryptAndHash(ciphertext []byte) ([]byte, error) {
+	pt := ciphertext
+	if s.cipher != nil {
+		var err error
+		if pt, err = s.cipher.open(s.h, ciphertext); err != nil {
+			return nil, err
+		}
+	}
+	s.mixHash(ciphertext)
+	return pt, nil
+}
+
+// hkdf is the HKDF of the spec, with two outputs.
+func hkdf(ck, ikm []byte) ([]byte, []byte) {
+	mac := hmac.New(sha256.New, ck)
+	mac.Write(ikm)
+	temp := mac.Sum(nil)
+
+	mac = hmac.New(sha256.New, temp)
+	mac.Write([]byte{1})
+	out1 := mac.Sum(nil)
+
+	mac.Reset()
+	mac.Write(out1)
+	mac.Write([]byte{2})
+	return out1, mac.Sum(nil)
+}
+
+// Handshake is one side of an XX handshake. The initiator writes the first
+// and third message, and reads the second; the responder does the opposite.
+type Handshake struct {
+	sym       symmetric
+	initiator bool
+	rand      io.Reader
+
+	s, e   *ecdh.PrivateKey
+	rs, re *ecdh.PublicKey
+}
+
+// New starts a handshake with the static key s, bound to prologue.
+func New(initiator bool, s *ecdh.PrivateKey, prologue []byte, rand io.Reader) *Handshake {
+	h := make([]byte, sha256.Size)
+	copy(h, protocolName)
+	hs := &Handshake{
+		sym:       symmetric{ck: h, h: h},
+		initiator: initiator,
+		rand:      rand,
+		s:         s,
+	}
+	hs.sym.mixHash(prologue)
+	return hs
+}
+
+// PeerStatic returns the peer's static key, once it was received.
+func (hs *Handshake) PeerStatic() []byte {
+	if hs.rs == nil {
+		return nil
+	}
+	return hs.rs.Bytes()
+}
+
+func (hs *Handshake) dh(private *ecdh.PrivateKey, public *ecdh.PublicKey) error {
+	shared, err := private.ECDH(public)
+	if err != nil {
+		return err
+	}
+	hs.sym.mixKey(shared)
+	return nil
+}
+
+func (hs *Handshake) writeE(msg []byte) ([]byte, error) {
+	// the ephemeral is read off rand rather than generated, so that it is
+	// drawn from rand even where GenerateKey would ignore it
+	b := make([]byte, dhLen)
+	if _, err := io.ReadFull(hs.rand, b); err != nil {
+		return nil, err
+	}
+	var err error
+	if hs.e, err = ecdh.X25519().NewPrivateKey(b); err != nil {
+		return nil, err
+	}
+	pub := hs.e.PublicKey().Bytes()
+	hs.sym.mixHash(pub)
+	return append(msg, pub...), nil
+}
+
+func (hs *Handshake) readE(msg []byte) ([]byte, error) {
+	if len(msg) < dhLen {
+		return nil, errShort
+	}
+	var err error
+	if hs.re, err = ecdh.X25519().NewPublicKey(msg[:dhLen]); err != nil {
+		return nil, err
+	}
+	hs.sym.mixHash(msg[:dhLen])
+	return msg[dhLen:], nil
+}
+
+func (hs *Handshake) readS(msg []byte) ([]byte, error) {
+	if len(msg) < dhLen+tagLen {
+		return nil, errShort
+	}
+	pub, err := hs.sym.decryptAndHash(msg[:dhLen+tagLen])
+	if err != nil {
+		return nil, err
+	}
+	if hs.rs, err = ecdh.X25519().NewPublicKey(pub); err != nil {
+		return nil, err
+	}
+	return msg[dhLen+tagLen:], nil
+}
+
+// WriteMessage1 writes "-> e", with payload.
+func (hs *Handshake) WriteMessage1(payload []byte) ([]byte, error) {
+	msg, err := hs.writeE(nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(msg, hs.sym.encryptAndHash(payload)...), nil
+}
+
+// ReadMessage1 reads "-> e", returning its payload.
+func (hs *Handshake) ReadMessage1(msg []byte) ([]byte, error) {
+	msg, err := hs.readE(msg)
+	if err != nil {
+		return nil, err
+	}
+	return hs.sym.decryptAndHash(msg)
+}
+
+// WriteMessage2 writes "<- e, ee, s, es", with payload.
+func (hs *Handshake) WriteMessage2(payload []byte) ([]byte, error) {
+	msg, err := hs.writeE(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := hs.dh(hs.e, hs.re); err != nil {
+		return nil, err
+	}
+	msg = append(msg, hs.sym.encryptAndHash(hs.s.PublicKey().Bytes())...)
+	if err := hs.dh(hs.s, hs.re); err != nil {
+		return nil, err
+	}
+	return append(msg, hs.sym.encryptAndHash(payload)...), nil
+}
+
+// ReadMessage2 reads "<- e, ee, s, es", returning its payload.
+func (hs *Handshake) ReadMessage2(msg []byte) ([]byte, error) {
+	msg, err := hs.readE(msg)
+	if err != nil {
+		return nil, err
+	}
+	if err := hs.dh(hs.e, hs.re); err != nil {
+		return nil, err
+	}
+	if msg, err = hs.readS(msg); err != nil {
+		return nil, err
+	}
+	if err := hs.dh(hs.e, hs.rs); err != nil {
+		return nil, err
+	}
+	return hs.sym.decryptAndHash(msg)
+}
+
+// WriteMessage3 writes "-> s, se", with payload.
+func (hs *Handshake) WriteMessage3(payload []byte) ([]byte, error) {
+	msg := hs.sym.encryptAndHash(hs.s.PublicKey().Bytes())
+	if err := hs.dh(hs.s, hs.re); err != nil {
+		return nil, err
+	}
+	return append(msg, hs.sym.encryptAndHash(payload)...), nil
+}
+
+// ReadMessage3 reads "-> s, se", returning its payload.
+func (hs *Handshake) ReadMessage3(msg []byte) ([]byte, error) {
+	msg, err := hs.readS(msg)
+	if err != nil {
+		return nil, err
+	}
+	if err := hs.dh(hs.e, hs.rs); err != nil {
+		return nil, err
+	}
+	return hs.sym.decryptAndHash(msg)
+}
+
+// Split returns the ciphers for transport messages, once the handshake is
+// over: the one for sending, and the one for receiving.
+func (hs *Handshake) Split() (send, receive *Cipher) {
+	k1, k2 := hkdf(hs.sym.ck, nil)
+	c1, c2 := newCipher(k1), newCipher(k2)
+	if hs.initiator {
+		return c1, c2
+	}
+	return c2, c1
+}
@@ -0,0 +1,108 @@
+package noise
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func private(t *testing.T, s string) *ecdh.PrivateKey {
+	t.Helper()
+	k, err := ecdh.X25519().NewPrivateKey(unhex(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// TestXX checks the handshake against known answers. The keys, prologue and
+// payloads are those of the cacophony test vectors; the messages were
+// computed with an implementation of the spec on Node's crypto, independent
+// of this one.
+func TestXX(t *testing.T) {
+	prologue := []byte("John Galt")
+	initiator := New(true, private(t, "e61ef9919cde45dd5f82166404bd08e38bceb5dfdfded0a34c8df7ed542214d1"), prologue,
+		bytes.NewReader(unhex(t, "893e28b9dc6ca8d611ab664754b8ceb7bac5117349a4439a6b0569da977c464a")))
+	responder := New(false, private(t, "4a3acbfdb163dec651dfa3194dece676d437029c62a408b4c5ea9114246e4893"), prologue,
+		bytes.NewReader(unhex(t, "bbdb4cdbd309f1a1f2e1456967fe288cadd6f712d65dc7b7793d5e63da6b375b")))
+
+	for i, step := range []struct {
+		write   func([]byte) ([]byte, error)
+		read    func([]byte) ([]byte, error)
+		payload string
+		want    string
+	}{
+		{initiator.WriteMessage1, responder.ReadMessage1, "Ludwig von Mises",
+			"ca35def5ae56cec33dc2036731ab14896bc4c75dbb07a61f879f8e3afa4c79444c756477696720766f6e204d69736573"},
+		{responder.WriteMessage2, initiator.ReadMessage2, "Murray Rothbard",
+			"95ebc60d2b1fa672c1f46a8aa265ef51bfe38e7ccb39ec5be34069f144808843757117acceb05bd7a45733bc22015c97a9d0cbaf41b80446d5988ff5127235d76b79eade70f473d6a4ef521fdcbeda5340d01e028ba793fc059f2724a83af05f12dda0448a7621a926b379a92477fd"},
+		{initiator.WriteMessage3, responder.ReadMessage3, "F. A. Hayek",
+			"c90f1cf77eba4e50edb038991565e36c9758943a989229b6051244dc4fbecb6946744b401af2ee1a5881b65fbb87fd07cb6a328ececc9ce6ce84c399dc332d4fd521fa4bb7f467ce909395"},
+	} {
+		msg, err := step.write([]byte(step.payload))
+		if err != nil {
+			t.Fatalf("message %d: %v", i+1, err)
+		}
+		if got := hex.EncodeToString(msg); got != step.want {
+			t.Fatalf("message %d = %s, want %s", i+1, got, step.want)
+		}
+		payload, err := step.read(msg)
+		if err != nil || string(payload) != step.payload {
+			t.Fatalf("message %d read as %q, %v", i+1, payload, err)
+		}
+	}
+
+	if !bytes.Equal(initiator.sym.h, responder.sym.h) ||
+		hex.EncodeToString(initiator.sym.h) != "1b7aefb1125762aa21a252890d00af54519638b76437444538f9a52f21e2e0dc" {
+		t.Fatalf("handshake hash = %x, %x", initiator.sym.h, responder.sym.h)
+	}
+	if !bytes.Equal(initiator.PeerStatic(), responder.s.PublicKey().Bytes()) ||
+		!bytes.Equal(responder.PeerStatic(), initiator.s.PublicKey().Bytes()) {
+		t.Fatal("peers' static keys don't match")
+	}
+
+	iSend, iReceive := initiator.Split()
+	rSend, rReceive := responder.Split()
+	for _, transport := range []struct {
+		send, receive *Cipher
+		payload, want string
+	}{
+		{iSend, rReceive, "Carl Menger", "2c0f120541d0e4c13dc38dff2d783c6dbe77dea872b87bf628802e"},
+		{rSend, iReceive, "Jean-Baptiste Say", "b53bb47d67c53a9a75dbddf7eb2ba6b2848ce3a9bad1dcabf54bdbb6b7e831a6b7"},
+	} {
+		ct := transport.send.seal(nil, []byte(transport.payload))
+		if got := hex.EncodeToString(ct); got != transport.want {
+			t.Fatalf("%q sealed as %s, want %s", transport.payload, got, transport.want)
+		}
+		if pt, err := transport.receive.open(nil, ct); err != nil || string(pt) != transport.payload {
+			t.Fatalf("%s opened as %q, %v", transport.want, pt, err)
+		}
+	}
+}
+
+func TestTampered(t *testing.T) {
+	initiator := New(true, private(t, "e61ef9919cde45dd5f82166404bd08e38bceb5dfdfded0a34c8df7ed542214d1"), nil,
+		bytes.NewReader(unhex(t, "893e28b9dc6ca8d611ab664754b8ceb7bac5117349a4439a6b0569da977c464a")))
+	responder := New(false, private(t, "4a3acbfdb163dec651dfa3194dece676d437029c62a408b4c5ea9114246e4893"), []byte("another prologue"),
+		bytes.NewReader(unhex(t, "bbdb4cdbd309f1a1f2e1456967fe288cadd6f712d65dc7b7793d5e63da6b375b")))
+
+	msg, _ := initiator.WriteMessage1(nil)
+	if _, err := responder.ReadMessage1(msg); err != nil {
+		t.Fatal(err)
+	}
+	msg, _ = responder.WriteMessage2(nil)
+	if _, err := initiator.ReadMessage2(msg); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("ReadMessage2() bound to another prologue = %v, want ErrDecrypt", err)
+	}
+}
@@ -0,0 +1,225 @@
+// Package srp implements SRP-6a, as in RFC 5054, with SHA-256 and the
+// 2048-bit group, for both sides of the password handshake.
+//
+// A and B are padded to the length of N wherever they are hashed, and
+//
+//	x  = H(salt | H(username | ":" | password))
+//	M1 = H(H(N) xor H(g) | H(username) | salt | A | B | K)
+//	M2 = H(A | M1 | K)
+//
+// where K = H(S).
+package srp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"hash"
+	"io"
+	"math/big"
+)
+
+// Len is the length of N, and so of A, B and verifiers, in bytes.
+const Len = 256
+
+// SaltLen is the length of the salts NewVerifier generates.
+const SaltLen = 16
+
+// ErrInvalidValue is returned for a public value that is 0 mod N, which
+// would let the other side authenticate without knowing the password.
+var ErrInvalidValue = errors.New("srp: invalid public value")
+
+// ErrMismatch is returned for a client proof made with the wrong password.
+var ErrMismatch = errors.New("srp: proof doesn't match")
+
+// group is a group of SRP, with the hash it is used with. The handshake uses
+// only std; the test vectors of RFC 5054 are for another.
+type group struct {
+	n, g, k *big.Int
+	len     int
+	hash    func() hash.Hash
+}
+
+func newGroup(n, g *big.Int, h func() hash.Hash) *group {
+	grp := &group{n: n, g: g, len: (n.BitLen() + 7) / 8, hash: h}
+	grp.k = new(big.Int).SetBytes(grp.sum(n.Bytes(), grp.pad(g)))
+	return grp
+}
+
+var std = newGroup(mustHex(""+
+	"AC6BDB41324A9A9BF166DE5E1389582FAF72B6651987EE07FC3192943DB56050"+
+	"A37329CBB4A099ED8193E0757767A13DD52312AB4B03310DCD7F48A9DA04FD50"+
+	"E8083969EDB767B0CF6095179A163AB3661A05FBD5FAAAE82918A9962F0B93B8"+
+	"55F97993EC975EEAA80D740ADBF4FF747359D041D5C33EA71D281E446B14773B"+
+	"CA97B43A23FB801676BD207A436C6481F1D2B9078717461A5B9D32E688F87748"+
+	"544523B524B0D57D5EA77A2775D2ECFA032CFBDBF52FB3786160279004E57AE6"+
+	"AF874E7303CE53299CCC041C7BC308D82A5698F3A8D0C38271AE35F8E9DBFBB6"+
+	"94B5C803D89F7AE435DE236D525F54759B65E372FCD68EF20FA7111F9E4AFF73"), big.NewInt(2), sha256.New)
+
+func mustHex(s string) *big.Int {
+	i, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("srp: invalid constant")
+	}
+	return i
+}
+
+func (grp *group) sum(parts ...[]byte) []byte {
+	h := grp.hash()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+func (grp *group) pad(i *big.Int) []byte {
+	return i.FillBytes(make([]byte, grp.len))
+}
+
+func (grp *group) private(salt []byte, username, password string) *big.Int {
+	inner := grp.sum([]byte(username), []byte(":"), []byte(password))
+	return new(big.Int).SetBytes(grp.sum(salt, inner))
+}
+
+// NewVerifier returns a new salt and the verifier of password for username,
+// for the server to store in place of the password.
+func NewVerifier(username, password string) (salt, verifier []byte, err error) {
+	salt = make([]byte, SaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, nil, err
+	}
+	x := std.private(salt, username, password)
+	return salt, std.pad(new(big.Int).Exp(std.g, x, std.n)), nil
+}
+
+func (grp *group) proofs(username string, salt []byte, A, B, S *big.Int) (m1, m2 []byte) {
+	hn, hg := grp.sum(grp.n.Bytes()), grp.sum(grp.g.Bytes())
+	for i := range hn {
+		hn[i] ^= hg[i]
+	}
+	key := grp.sum(grp.pad(S))
+	m1 = grp.sum(hn, grp.sum([]byte(username)), salt, grp.pad(A), grp.pad(B), key)
+	m2 = grp.sum(grp.pad(A), m1, key)
+	return m1, m2
+}
+
+func (grp *group) scramble(A, B *big.Int) *big.Int {
+	return new(big.Int).SetBytes(grp.sum(grp.pad(A), grp.pad(B)))
+}
+
+// valid reports whether the public value pub, as sent, is of the group and
+// not 0 mod N.
+func (grp *group) valid(pub []byte) bool {
+	return len(pub) <= grp.len && new(big.Int).Mod(new(big.Int).SetBytes(pub), grp.n).Sign() != 0
+}
+
+func ephemeral(random io.Reader) (*big.Int, error) {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(random, b); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// Server is the server's side of one handshake.
+type Server struct {
+	grp      *group
+	username string
+	salt     []byte
+	v, b     *big.Int
+	pub      *big.Int
+}
+
+// NewServer starts a handshake with the client username, whose salt and
+// verifier are those NewVerifier returned, drawing the server's ephemeral
+// from random.
+func NewServer(username string, salt, verifier []byte, random io.Reader) (*Server, error) {
+	return std.newServer(username, salt, verifier, random)
+}
+
+func (grp *group) newServer(username string, salt, verifier []byte, random io.Reader) (*Server, error) {
+	b, err := ephemeral(random)
+	if err != nil {
+		return nil, err
+	}
+	v := new(big.Int).SetBytes(verifier)
+
+	// B = kv + g^b
+	B := new(big.Int).Mul(grp.k, v)
+	B.Add(B, new(big.Int).Exp(grp.g, b, grp.n))
+	B.Mod(B, grp.n)
+
+	return &Server{grp: grp, username: username, salt: salt, v: v, b: b, pub: B}, nil
+}
+
+// B returns the server's public value, padded to Len bytes.
+func (s *Server) B() []byte {
+	return s.grp.pad(s.pub)
+}
+
+// premaster returns S, the secret the client shares, for its public value A.
+func (s *Server) premaster(A *big.Int) *big.Int {
+	grp := s.grp
+	u := grp.scramble(A, s.pub)
+
+	// S = (A v^u)^b
+	S := new(big.Int).Exp(s.v, u, grp.n)
+	S.Mul(S, A)
+	return S.Exp(S, s.b, grp.n)
+}
+
+// Verify checks the client's proof m1 for its public value a, and returns
+// the server's proof to send back if it is correct, or ErrMismatch if it
+// isn't.
+func (s *Server) Verify(a, m1 []byte) ([]byte, error) {
+	if !s.grp.valid(a) {
+		return nil, ErrInvalidValue
+	}
+	A := new(big.Int).SetBytes(a)
+
+	want, m2 := s.grp.proofs(s.username, s.salt, A, s.pub, s.premaster(A))
+	if subtle.ConstantTimeCompare(want, m1) != 1 {
+		return nil, ErrMismatch
+	}
+	return m2, nil
+}
+
+// Client computes the client's side of a handshake, given the salt and public
+// value b the server sent. It returns the client's public value and proof,
+// and the proof to expect from the server.
+func Client(username, password string, salt, b []byte, random io.Reader) (A, m1, m2 []byte, err error) {
+	return std.client(username, password, salt, b, random)
+}
+
+func (grp *group) client(username, password string, salt, b []byte, random io.Reader) (A, m1, m2 []byte, err error) {
+	if !grp.valid(b) {
+		return nil, nil, nil, ErrInvalidValue
+	}
+	B := new(big.Int).SetBytes(b)
+
+	a, err := ephemeral(random)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pubA := new(big.Int).Exp(grp.g, a, grp.n)
+	S := grp.clientPremaster(pubA, B, grp.private(salt, username, password), a)
+
+	m1, m2 = grp.proofs(username, salt, pubA, B, S)
+	return grp.pad(pubA), m1, m2, nil
+}
+
+// clientPremaster returns S, the secret the client with private value x and
+// ephemeral a shares with the server of B.
+func (grp *group) clientPremaster(A, B, x, a *big.Int) *big.Int {
+	u := grp.scramble(A, B)
+
+	// S = (B - kg^x)^(a + ux)
+	base := new(big.Int).Exp(grp.g, x, grp.n)
+	base.Mul(base, grp.k)
+	base.Sub(B, base)
+	base.Mod(base, grp.n)
+	exp := new(big.Int).Mul(u, x)
+	exp.Add(exp, a)
+	return base.Exp(base, exp, grp.n)
+}
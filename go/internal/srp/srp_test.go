@@ -0,0 +1,120 @@
+package srp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// rfc5054 is the group of the test vectors of RFC 5054, Appendix B: the
+// 1024-bit group, with SHA-1.
+var rfc5054 = newGroup(mustHex(""+
+	"EEAF0AB9ADB38DD69C33F80AFA8FC5E86072618775FF3C0B9EA2314C9C256576"+
+	"D674DF7496EA81D3383B4813D692C6E0E0D5D8E250B98BE48E495C1D6089DAD1"+
+	"5DC7D7B46154D6B6CE8EF4AD69B15D4982559B297BCF1885C529F566660E57EC"+
+	"68EDBC3C05726CC02FD4CBF4976EAA9AFD5138FE8376435B9FC61D2FC0EB06E3"), big.NewInt(2), sha1.New)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestRFC5054(t *testing.T) {
+	const username, password = "alice", "password123"
+	salt := unhex(t, "BEB25379 D1A8581E B5A72767 3A2441EE")
+	a := unhex(t, "60975527 035CF2AD 1989806F 0407210B C81EDC04 E2762A56 AFD529DD DA2D4393")
+	b := unhex(t, "E487CB59 D31AC550 471E81F0 0F6928E0 1DDA08E9 74A004F4 9E61F5D1 05284D20")
+
+	want := map[string]string{
+		"k": "7556AA04 5AEF2CDD 07ABAF0F 665C3E81 8913186F",
+		"x": "94B7555A ABE9127C C58CCF49 93DB6CF8 4D16C124",
+		"v": `7E273DE8 696FFC4F 4E337D05 B4B375BE B0DDE156 9E8FA00A 9886D812
+		      9BADA1F1 822223CA 1A605B53 0E379BA4 729FDC59 F105B478 7E5186F5
+		      C671085A 1447B52A 48CF1970 B4FB6F84 00BBF4CE BFBB1681 52E08AB5
+		      EA53D15C 1AFF87B2 B9DA6E04 E058AD51 CC72BFC9 033B564E 26480D78
+		      E955A5E2 9E7AB245 DB2BE315 E2099AFB`,
+		"A": `61D5E490 F6F1B795 47B0704C 436F523D D0E560F0 C64115BB 72557EC4
+		      4352E890 3211C046 92272D8B 2D1A5358 A2CF1B6E 0BFCF99F 921530EC
+		      8E393561 79EAE45E 42BA92AE ACED8251 71E1E8B9 AF6D9C03 E1327F44
+		      BE087EF0 6530E69F 66615261 EEF54073 CA11CF58 58F0EDFD FE15EFEA
+		      B349EF5D 76988A36 72FAC47B 0769447B`,
+		"B": `BD0C6151 2C692C0C B6D041FA 01BB152D 4916A1E7 7AF46AE1 05393011
+		      BAF38964 DC46A067 0DD125B9 5A981652 236F99D9 B681CBF8 7837EC99
+		      6C6DA044 53728610 D0C6DDB5 8B318885 D7D82C7F 8DEB75CE 7BD4FBAA
+		      37089E6F 9C6059F3 88838E7A 00030B33 1EB76840 910440B1 B27AAEAE
+		      EB4012B7 D7665238 A8E3FB00 4B117B58`,
+		"u": "CE38B959 3487DA98 554ED47D 70A7AE5F 462EF019",
+		"S": `B0DC82BA BCF30674 AE450C02 87745E79 90A3381F 63B387AA F271A10D
+		      233861E3 59B48220 F7C4693C 9AE12B0A 6F67809F 0876E2D0 13800D6C
+		      41BB59B6 D5979B5C 00A172B4 A2A5903A 0BDCAF8A 709585EB 2AFAFA8F
+		      3499B200 210DCC1F 10EB3394 3CD67FC8 8A2F39A4 BE5BEC4E C0A3212D
+		      C346D7E4 74B29EDE 8A469FFE CA686E5A`,
+	}
+
+	grp := rfc5054
+	x := grp.private(salt, username, password)
+	v := new(big.Int).Exp(grp.g, x, grp.n)
+	server, err := grp.newServer(username, salt, grp.pad(v), bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	A := new(big.Int).Exp(grp.g, new(big.Int).SetBytes(a), grp.n)
+	S := server.premaster(A)
+
+	for name, got := range map[string]*big.Int{
+		"k": grp.k,
+		"x": x,
+		"v": v,
+		"A": A,
+		"B": server.pub,
+		"u": grp.scramble(A, server.pub),
+		"S": S,
+	} {
+		if w := new(big.Int).SetBytes(unhex(t, want[name])); got.Cmp(w) != 0 {
+			t.Errorf("%s = %X, want %X", name, got, w)
+		}
+	}
+	if clientS := grp.clientPremaster(A, server.pub, x, new(big.Int).SetBytes(a)); clientS.Cmp(S) != 0 {
+		t.Errorf("client's S = %X, want %X", clientS, S)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	salt, verifier, err := NewVerifier("alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer("alice", salt, verifier, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, m1, wantM2, err := Client("alice", "password123", salt, server.B(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, err := server.Verify(a, m1)
+	if err != nil || !bytes.Equal(m2, wantM2) {
+		t.Fatalf("Verify() = %x, %v, want %x", m2, err, wantM2)
+	}
+
+	a, m1, _, err = Client("alice", "password124", salt, server.B(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Verify(a, m1); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("Verify() with the wrong password = %v, want ErrMismatch", err)
+	}
+	if _, err := server.Verify(make([]byte, Len), m1); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("Verify() of A = 0 = %v, want ErrInvalidValue", err)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+const header = `// Code generated by go run ./internal/tsgen; DO NOT EDIT.
+//
+// The types of the handshake's messages, generated from the Go structs the
+// server encodes and decodes them with, and a minimal client built on them.
+// Run go generate in the go directory to update it.
+
+`
+
+// client is the minimal client, which only relies on the generated types, so
+// that a change to the wire format that breaks it fails to compile.
+const client = `
+/**
+ * A minimal client of the handshake, over a WebSocket that is open, or
+ * opening. Messages are queued as they arrive, so none are missed between
+ * calls to next
+ */
+export class Client {
+	private readonly queue: ServerMessage[] = [];
+	private readonly waiting: {
+		resolve: (message: ServerMessage) => void;
+		reject: (error: Error) => void;
+	}[] = [];
+	private closed = false;
+
+	constructor(readonly ws: WebSocket) {
+		ws.addEventListener("message", (event: MessageEvent) => {
+			const message = JSON.parse(event.data) as ServerMessage;
+			const waiter = this.waiting.shift();
+			if (waiter) {
+				waiter.resolve(message);
+			} else {
+				this.queue.push(message);
+			}
+		});
+		ws.addEventListener("close", () => {
+			this.closed = true;
+			for (const waiter of this.waiting.splice(0)) {
+				waiter.reject(new Error("Connection closed"));
+			}
+		});
+	}
+
+	/**
+	 * Sends a message to the server, once the WebSocket is open
+	 * @param message The message to send
+	 */
+	async send(message: ClientMessage): Promise<void> {
+		if (this.ws.readyState === WebSocket.CONNECTING) {
+			await new Promise((resolve) =>
+				this.ws.addEventListener("open", resolve, { once: true })
+			);
+		}
+		this.ws.send(JSON.stringify(message));
+	}
+
+	/**
+	 * Reads the next message from the server
+	 * @returns A promise of the message, rejected if the connection closes
+	 *   first
+	 */
+	next(): Promise<ServerMessage> {
+		const message = this.queue.shift();
+		if (message) {
+			return Promise.resolve(message);
+		}
+		if (this.closed) {
+			return Promise.reject(new Error("Connection closed"));
+		}
+		return new Promise((resolve, reject) => {
+			this.waiting.push({ resolve, reject });
+		});
+	}
+
+	/**
+	 * Performs the handshake
+	 * @param clientId The client ID to authenticate as
+	 * @param sign Signs the bytes the server asked us to, returning the data
+	 *   of the CHALLENGE_RESPONSE
+	 * @param options.audience The audience to sign challenges for, for servers
+	 *   that bind signatures to an audience. Defaults to the scheme and host of
+	 *   the WebSocket's URL
+	 * @returns The server's SIGNATURE_MATCHES
+	 */
+	async handshake(
+		clientId: string,
+		sign: (
+			payload: Uint8Array,
+			challenge: ChallengeMessage | AudienceChallengeMessage
+		) => Promise<ChallengeResponse>,
+		options: { audience?: string } = {}
+	): Promise<MatchesMessage> {
+		const url = new URL(this.ws.url);
+		const audience = options.audience ?? ` + "`${url.protocol}//${url.host}`" + `;
+
+		await this.send({ type: "CLIENT_ID", data: clientId });
+
+		const challenge = (await this.expect("CHALLENGE")) as
+			| ChallengeMessage
+			| AudienceChallengeMessage;
+		const data = challenge.data;
+		let payload: Uint8Array;
+		if (typeof data === "string") {
+			payload = decode(data);
+		} else {
+			// sign for the audience we know we're connected to, rather than the
+			// one the server claims, so that our signature is of no use to
+			// anyone else
+			if (data.audience !== audience) {
+				throw new Error(
+					` + "`Server asked us to sign for ${data.audience}, but we are connected to ${audience}`" + `
+				);
+			}
+			const bytes = decode(data.challenge);
+			const encoded = new TextEncoder().encode(audience);
+			payload = new Uint8Array(bytes.length + encoded.length);
+			payload.set(bytes);
+			payload.set(encoded, bytes.length);
+		}
+
+		const response = await sign(payload, challenge);
+		await this.send({ type: "CHALLENGE_RESPONSE", data: response });
+
+		return (await this.expect("SIGNATURE_MATCHES")) as MatchesMessage;
+	}
+
+	/**
+	 * Reads the next message, failing unless it is of the type expected
+	 */
+	private async expect(type: string): Promise<ServerMessage> {
+		const message = await this.next();
+		if (message.type === type) {
+			return message;
+		}
+		this.ws.close();
+		const data = (message as { data?: unknown }).data;
+		const detail =
+			typeof data === "string" ? data : (data as ErrorData | undefined)?.message;
+		throw new Error(
+			detail
+				? ` + "`Expected ${type}, but got ${message.type}: ${detail}`" + `
+				: ` + "`Expected ${type}, but got ${message.type}`" + `
+		);
+	}
+}
+
+function decode(base64: string): Uint8Array {
+	return Uint8Array.from(atob(base64), (c) => c.charCodeAt(0));
+}
+`
@@ -0,0 +1,344 @@
+// Command tsgen generates the TypeScript types of the handshake's messages,
+// along with a minimal browser client built on them, from the Go structs the
+// server encodes and decodes them with. It is run by go generate in the
+// wskeyauth package:
+//
+//	go run ./internal/tsgen -o ../browser/src/protocol.gen.ts
+//
+// The structs are read from the package's source, rather than by reflection,
+// so that the unexported message types, and the doc comments of their fields,
+// are available to it.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// message is a root of the generated types.
+type message struct {
+	goName, tsName string
+
+	// fromClient is set for the messages clients send, whose fields servers
+	// don't require, and so are optional in TypeScript.
+	fromClient bool
+}
+
+// messages are the messages of the handshake, in the order they are
+// generated. The structs they refer to are generated after them.
+var messages = []message{
+	{goName: "TypeData", tsName: "TypeData"},
+	{goName: "envelope", tsName: "ClientMessage", fromClient: true},
+	{goName: "challengeResponse", tsName: "ChallengeResponse", fromClient: true},
+	{goName: "typeMessage", tsName: "TypeMessage"},
+	{goName: "stringMessage", tsName: "StringMessage"},
+	{goName: "errorMessage", tsName: "ErrorMessage"},
+	{goName: "challengeMessage", tsName: "ChallengeMessage"},
+	{goName: "audienceChallengeMessage", tsName: "AudienceChallengeMessage"},
+	{goName: "matchesMessage", tsName: "MatchesMessage"},
+}
+
+// serverMessages are the messages that make up the ServerMessage union.
+var serverMessages = []string{
+	"TypeMessage",
+	"StringMessage",
+	"ErrorMessage",
+	"ChallengeMessage",
+	"AudienceChallengeMessage",
+	"MatchesMessage",
+}
+
+func main() {
+	out := flag.String("o", "", "file to write the TypeScript to, rather than standard output")
+	dir := flag.String("dir", ".", "directory of the wskeyauth package")
+	flag.Parse()
+
+	specs, err := parse(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(specs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// typeSpec is a struct type of the package, with its doc comment.
+type typeSpec struct {
+	doc *ast.CommentGroup
+	typ *ast.StructType
+}
+
+// parse returns the struct types of the wskeyauth package in dir.
+func parse(dir string) (map[string]typeSpec, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	pkg, ok := pkgs["wskeyauth"]
+	if !ok {
+		return nil, fmt.Errorf("no wskeyauth package in %s", dir)
+	}
+
+	specs := map[string]typeSpec{}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				doc := ts.Doc
+				if doc == nil && len(gen.Specs) == 1 {
+					doc = gen.Doc
+				}
+				specs[ts.Name.Name] = typeSpec{doc: doc, typ: st}
+			}
+		}
+	}
+	return specs, nil
+}
+
+// generator accumulates the generated types.
+type generator struct {
+	specs map[string]typeSpec
+	buf   bytes.Buffer
+
+	// names are the TypeScript names of the structs generated or queued.
+	names map[string]string
+	queue []string
+}
+
+func generate(specs map[string]typeSpec) ([]byte, error) {
+	g := &generator{specs: specs, names: map[string]string{}}
+	for _, m := range messages {
+		g.names[m.goName] = m.tsName
+	}
+
+	g.buf.WriteString(header)
+	for _, m := range messages {
+		if err := g.emit(m.goName, m.fromClient); err != nil {
+			return nil, err
+		}
+	}
+	for len(g.queue) > 0 {
+		name := g.queue[0]
+		g.queue = g.queue[1:]
+		if err := g.emit(name, false); err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Fprintf(&g.buf, "/**\n * Any message a server sends during the handshake\n */\nexport type ServerMessage =\n")
+	for i, name := range serverMessages {
+		end := ""
+		if i == len(serverMessages)-1 {
+			end = ";"
+		}
+		fmt.Fprintf(&g.buf, "\t| %s%s\n", name, end)
+	}
+	g.buf.WriteString(client)
+	return g.buf.Bytes(), nil
+}
+
+// emit generates the interface of the struct goName.
+func (g *generator) emit(goName string, fromClient bool) error {
+	spec, ok := g.specs[goName]
+	if !ok {
+		return fmt.Errorf("no struct %s", goName)
+	}
+
+	writeDoc(&g.buf, "", spec.doc, goName)
+	fmt.Fprintf(&g.buf, "export interface %s {\n", g.names[goName])
+	if err := g.fields(spec.typ, fromClient); err != nil {
+		return fmt.Errorf("%s: %w", goName, err)
+	}
+	g.buf.WriteString("}\n\n")
+	return nil
+}
+
+// fields generates the fields of st, inlining those of embedded structs, as
+// encoding/json does.
+func (g *generator) fields(st *ast.StructType, fromClient bool) error {
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 {
+			ident, ok := field.Type.(*ast.Ident)
+			if !ok {
+				return fmt.Errorf("unsupported embedded field %s", types(field.Type))
+			}
+			spec, ok := g.specs[ident.Name]
+			if !ok {
+				return fmt.Errorf("no struct %s", ident.Name)
+			}
+			if err := g.fields(spec.typ, fromClient); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			key, omitempty := name.Name, false
+			if field.Tag != nil {
+				tag := reflect.StructTag(strings.Trim(field.Tag.Value, "`")).Get("json")
+				if tag == "-" {
+					continue
+				}
+				parts := strings.Split(tag, ",")
+				if parts[0] != "" {
+					key = parts[0]
+				}
+				for _, opt := range parts[1:] {
+					omitempty = omitempty || opt == "omitempty"
+				}
+			}
+
+			typ, err := g.tsType(field.Type)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name.Name, err)
+			}
+			_, pointer := field.Type.(*ast.StarExpr)
+			optional := omitempty || (fromClient && key != "type")
+			if pointer && !optional {
+				typ += " | null"
+			}
+
+			writeDoc(&g.buf, "\t", field.Doc, "")
+			mark := ""
+			if optional {
+				mark = "?"
+			}
+			fmt.Fprintf(&g.buf, "\t%s%s: %s;\n", key, mark, typ)
+		}
+	}
+	return nil
+}
+
+// tsType returns the TypeScript type of the values encoding/json encodes the
+// Go type expr as, queueing the structs it refers to.
+func (g *generator) tsType(expr ast.Expr) (string, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return "string", nil
+		case "bool":
+			return "boolean", nil
+		case "int", "int8", "int16", "int32", "int64",
+			"uint", "uint8", "uint16", "uint32", "uint64",
+			"float32", "float64":
+			return "number", nil
+		case "any":
+			return "unknown", nil
+		}
+		if _, ok := g.specs[t.Name]; !ok {
+			return "", fmt.Errorf("unsupported type %s", t.Name)
+		}
+		name, ok := g.names[t.Name]
+		if !ok {
+			name = strings.ToUpper(t.Name[:1]) + t.Name[1:]
+			g.names[t.Name] = name
+			g.queue = append(g.queue, t.Name)
+		}
+		return name, nil
+	case *ast.StarExpr:
+		return g.tsType(t.X)
+	case *ast.ArrayType:
+		if ident, ok := t.Elt.(*ast.Ident); ok && ident.Name == "byte" {
+			// encoded as base64
+			return "string", nil
+		}
+		elem, err := g.tsType(t.Elt)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]", nil
+	case *ast.MapType:
+		elem, err := g.tsType(t.Value)
+		if err != nil {
+			return "", err
+		}
+		return "Record<string, " + elem + ">", nil
+	case *ast.InterfaceType:
+		return "unknown", nil
+	case *ast.SelectorExpr:
+		switch types(t) {
+		case "json.RawMessage":
+			return "unknown", nil
+		case "time.Time":
+			return "string", nil
+		}
+	}
+	return "", fmt.Errorf("unsupported type %s", types(expr))
+}
+
+// types returns the Go source of expr, for errors.
+func types(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return types(t.X) + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + types(t.X)
+	}
+	return fmt.Sprintf("%T", expr)
+}
+
+// writeDoc writes doc as a JSDoc comment, noting the Go struct it came from
+// if goName is set.
+func writeDoc(buf *bytes.Buffer, indent string, doc *ast.CommentGroup, goName string) {
+	var lines []string
+	if doc != nil {
+		lines = strings.Split(strings.TrimSpace(doc.Text()), "\n")
+	}
+	if goName != "" {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "Generated from the Go struct "+goName+".")
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Fprintf(buf, "%s/**\n", indent)
+	for _, line := range lines {
+		line = strings.ReplaceAll(line, "*/", "*\\/")
+		if line == "" {
+			fmt.Fprintf(buf, "%s *\n", indent)
+		} else {
+			fmt.Fprintf(buf, "%s * %s\n", indent, line)
+		}
+	}
+	fmt.Fprintf(buf, "%s */\n", indent)
+}
@@ -0,0 +1,141 @@
+package wskeyauth
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// ForbiddenCloseCode is the WebSocket close code sent to clients whose
+// address WithIPFilter doesn't let in. It is in the range reserved for
+// applications, after HTTP's 403 Forbidden.
+const ForbiddenCloseCode = 4403
+
+// IPFilter lets clients in, or turns them away, by their address, before
+// anything is read from them. Clients that are turned away are sent
+// FORBIDDEN, and a close frame with ForbiddenCloseCode if the connection has a
+// WriteControl method:
+//
+//	{"type": "FORBIDDEN", "data": "Connections from this address are not allowed"}
+//
+// The address is the connection's RemoteAddr, or that of the request given to
+// WithRequest. Servers behind proxies want WithTrustedProxies too, or every
+// client has the address of the proxy.
+type IPFilter struct {
+	// Allow, if not empty, lets in only clients with an address in one of
+	// its prefixes. Clients whose address isn't known, over connections
+	// without a RemoteAddr method and without WithRequest, are then turned
+	// away.
+	Allow []netip.Prefix
+
+	// Deny turns away clients with an address in one of its prefixes, even
+	// those that Allow lets in.
+	Deny []netip.Prefix
+}
+
+// WithIPFilter lets clients in, or turns them away, by their address, as f
+// says.
+func WithIPFilter(f IPFilter) Option {
+	return func(cfg *config) {
+		cfg.ipFilter = &f
+	}
+}
+
+// WithRequest gives the handshake the HTTP request that the connection was
+// upgraded from, for the client's address to be taken from, in place of the
+// connection's RemoteAddr. The address is then the request's RemoteAddr, or,
+// with WithTrustedProxies, the one its proxies forwarded it for. It is the
+// address that IP filters, rate limits, bans and audit records go by. With an
+// Authenticator, it is given to each handshake:
+//
+//	authenticated, clientID, err := auth.Handshake(conn, wskeyauth.WithRequest(r))
+func WithRequest(r *http.Request) Option {
+	return func(cfg *config) {
+		cfg.request = r
+	}
+}
+
+// WithTrustedProxies trusts proxies with an address in one of prefixes, such
+// as the load balancers in front of the server, to tell the address of the
+// client in the X-Forwarded-For header of the request given to WithRequest.
+// Walking X-Forwarded-For back from the request's RemoteAddr, the client's
+// address is the first one that isn't a trusted proxy. Without trusted
+// proxies, X-Forwarded-For is ignored, as anyone can set it.
+func WithTrustedProxies(prefixes ...netip.Prefix) Option {
+	return func(cfg *config) {
+		cfg.trustedProxies = prefixes
+	}
+}
+
+// clientAddr returns the address of the client that sent r, as told by the
+// proxies in trusted.
+func clientAddr(r *http.Request, trusted []netip.Prefix) string {
+	addr := r.RemoteAddr
+	if peer, ok := parseAddr(addr); !ok || !inPrefixes(trusted, peer) {
+		return addr
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		hopAddr, ok := parseAddr(hop)
+		if !ok {
+			// whoever added this can't be trusted to have added the rest
+			break
+		}
+		addr = hop
+		if !inPrefixes(trusted, hopAddr) {
+			break
+		}
+	}
+	return addr
+}
+
+// parseAddr parses an IP address, with or without a port.
+func parseAddr(s string) (netip.Addr, bool) {
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	return addr.Unmap(), err == nil
+}
+
+func inPrefixes(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether f lets in a client with addr, or with an address
+// that isn't known if known is false.
+func (f *IPFilter) allows(addr netip.Addr, known bool) bool {
+	if !known {
+		return len(f.Allow) == 0
+	}
+	if inPrefixes(f.Deny, addr) {
+		return false
+	}
+	return len(f.Allow) == 0 || inPrefixes(f.Allow, addr)
+}
+
+var forbiddenMessage = &stringMessage{Type: "FORBIDDEN", Data: "Connections from this address are not allowed"}
+
+// checkAddr turns the client away if the IP filter doesn't let its address
+// in.
+func (h *handshakeState) checkAddr() (bool, FailureReason, error) {
+	addr, known := parseAddr(h.remoteAddr)
+	if h.cfg.ipFilter.allows(addr, known) {
+		return true, "", nil
+	}
+
+	h.log.Debug("wskeyauth: address is not allowed")
+	h.conn.WriteJSON(forbiddenMessage)
+	if c, ok := h.raw.(controlWriter); ok {
+		c.WriteControl(closeMessage, closeFrame(ForbiddenCloseCode, "address not allowed"), time.Now().Add(time.Second))
+	}
+	return false, ReasonAddressDenied, nil
+}
@@ -0,0 +1,60 @@
+package wskeyauth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Keys may be given as JWKs too, as WebCrypto exports them with
+// exportKey("jwk"), encoded in base64url:
+//
+//	JWK$<base64url encoded JWK>
+//
+// for EC keys on P-256, and OKP keys on Ed25519. Members other than those of
+// the public key are ignored. P-256 clients sign as WebCrypto clients do, and
+// Ed25519 ones as did:key ones do.
+
+const jwkPrefix = "JWK$"
+
+// maxJWKLength bounds the JWKs we'll decode. Keys we accept are far shorter,
+// even with the members we ignore.
+const maxJWKLength = 1024
+
+func parseJWKClientID(clientID string) (*publicKey, error) {
+	encoded := strings.TrimRight(strings.TrimPrefix(clientID, jwkPrefix), "=")
+	if len(encoded) > maxJWKLength {
+		return nil, errors.New("expected JWK of ID to be at most 1024 characters long")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("expected JWK of ID to be base64url: " + err.Error())
+	}
+
+	var k struct {
+		Kty string `json:"kty"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+	if err := json.Unmarshal(b, &k); err != nil {
+		return nil, errors.New("expected JWK of ID to be JSON: " + err.Error())
+	}
+
+	x, errX := base64.RawURLEncoding.DecodeString(k.X)
+	switch {
+	case k.Kty == "EC" && k.Crv == "P-256":
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("expected x and y of JWK to be 32 bytes of base64url")
+		}
+		return parseRawKey(append(append([]byte{4}, x...), y...))
+	case k.Kty == "OKP" && k.Crv == "Ed25519":
+		if errX != nil || len(x) != 32 {
+			return nil, errors.New("expected x of JWK to be 32 bytes of base64url")
+		}
+		return parseRawKey(x)
+	}
+	return nil, errors.New("expected JWK to be an EC key on P-256 or an OKP key on Ed25519")
+}
@@ -0,0 +1,128 @@
+package wskeyauth
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPingInterval is how often Keepalive pings unless Interval says
+	// otherwise.
+	DefaultPingInterval = 30 * time.Second
+
+	// DefaultPongTimeout is how long Keepalive waits to hear back unless
+	// Timeout says otherwise.
+	DefaultPongTimeout = 60 * time.Second
+)
+
+// ErrKeepaliveTimeout is passed to Keepalive.OnDead when the client stopped
+// answering pings.
+var ErrKeepaliveTimeout = errors.New("wskeyauth: client stopped answering pings")
+
+// KeepaliveConn is a WebSocket connection Keepalive can ping, such as
+// gorilla/websocket's.
+type KeepaliveConn interface {
+	SetReadDeadline(t time.Time) error
+	SetPongHandler(h func(appData string) error)
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	Close() error
+}
+
+// Keepalive pings authenticated clients, and closes the connections of those
+// that stopped answering. Start it once the handshake is over:
+//
+//	stop := wskeyauth.Keepalive{}.Start(conn)
+//	defer stop()
+//
+// Pongs are only seen while the connection is read from, as gorilla/websocket
+// handles control frames in its reads, so the application must keep reading.
+// Every pong pushes the connection's read deadline out by Timeout, so a read
+// blocked on a dead connection fails, too.
+type Keepalive struct {
+	// Interval is how often to ping. It defaults to DefaultPingInterval.
+	Interval time.Duration
+
+	// Timeout is how long to wait for a pong before giving up on the client.
+	// It defaults to DefaultPongTimeout, and should be longer than Interval.
+	Timeout time.Duration
+
+	// OnDead, if set, is called once the connection was given up on, and
+	// closed, with ErrKeepaliveTimeout or the error a ping failed with.
+	OnDead func(err error)
+
+	// Clock times pings, and tells how long ago the last pong came. It
+	// defaults to SystemClock. Read deadlines are still set in real time.
+	Clock Clock
+}
+
+const pingMessage = 9
+
+// Start pings over conn until the returned function is called, or the
+// connection is given up on.
+func (k Keepalive) Start(conn KeepaliveConn) (stop func()) {
+	interval, timeout := k.Interval, k.Timeout
+	if interval <= 0 {
+		interval = DefaultPingInterval
+	}
+	if timeout <= 0 {
+		timeout = DefaultPongTimeout
+	}
+
+	clock := clockOr(k.Clock)
+
+	var mu sync.Mutex
+	lastPong := clock.Now()
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	conn.SetPongHandler(func(string) error {
+		now := clock.Now()
+		mu.Lock()
+		lastPong = now
+		mu.Unlock()
+		return conn.SetReadDeadline(time.Now().Add(timeout))
+	})
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		// one tick is outstanding at a time, so it never blocks
+		tick := make(chan time.Time, 1)
+		for {
+			timer := clock.AfterFunc(interval, func() { tick <- clock.Now() })
+			select {
+			case <-done:
+				timer.Stop()
+				return
+			case now := <-tick:
+				mu.Lock()
+				dead := now.Sub(lastPong) > timeout
+				mu.Unlock()
+
+				err := ErrKeepaliveTimeout
+				if !dead {
+					err = conn.WriteControl(pingMessage, nil, time.Now().Add(interval))
+				}
+				if err != nil {
+					conn.Close()
+					if k.OnDead != nil {
+						k.OnDead(err)
+					}
+					return
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+			conn.SetPongHandler(nil)
+			conn.SetReadDeadline(time.Time{})
+		})
+	}
+}
@@ -0,0 +1,177 @@
+// Package wskeyauthkeys converts client keys between the forms ws-key-auth
+// identifies them by and those of other tooling: JWKs, SPKI PEM, as openssl
+// writes public keys, and OpenSSH public keys. Keys are P-256 ECDSA or
+// Ed25519 keys, as *ecdsa.PublicKey and ed25519.PublicKey:
+//
+//	pub, err := wskeyauthkeys.FromPEM(pemBytes)
+//	if err != nil {
+//		return err
+//	}
+//	clientID, err := wskeyauthkeys.ClientID(pub)
+package wskeyauthkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// ClientID returns the client ID of pub: a WebCrypto client ID for P-256
+// keys, in the form the browser client uses, and a did:key for Ed25519 keys.
+func ClientID(pub crypto.PublicKey) (string, error) {
+	if err := check(pub); err != nil {
+		return "", err
+	}
+	return wskeyauth.ServerID(pub)
+}
+
+// FromClientID returns the key of clientID, which may be of any format that
+// has a public key.
+func FromClientID(clientID string) (crypto.PublicKey, error) {
+	parsed, err := wskeyauth.ParseClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.PublicKey == nil {
+		return nil, fmt.Errorf("wskeyauthkeys: %s client IDs have no public key", parsed.Format)
+	}
+	return parsed.PublicKey, nil
+}
+
+// jwk is a public key as RFC 7517 and RFC 8037 have it.
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWK returns pub as a JWK: an EC key on P-256, or an OKP key on Ed25519.
+func JWK(pub crypto.PublicKey) ([]byte, error) {
+	if err := check(pub); err != nil {
+		return nil, err
+	}
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		x, y := make([]byte, 32), make([]byte, 32)
+		pub.X.FillBytes(x)
+		pub.Y.FillBytes(y)
+		return json.Marshal(&jwk{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(x),
+			Y:   base64.RawURLEncoding.EncodeToString(y),
+		})
+	default:
+		return json.Marshal(&jwk{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub.(ed25519.PublicKey)),
+		})
+	}
+}
+
+// FromJWK returns the key of a JWK, as the server parses JWK client IDs.
+// Members other than those of the key, such as any private key, are ignored,
+// but count towards the server's bound on JWKs of 768 bytes.
+func FromJWK(data []byte) (crypto.PublicKey, error) {
+	return FromClientID("JWK$" + base64.RawURLEncoding.EncodeToString(data))
+}
+
+// PEM returns pub as a PEM encoded SubjectPublicKeyInfo, a "PUBLIC KEY"
+// block.
+func PEM(pub crypto.PublicKey) ([]byte, error) {
+	if err := check(pub); err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// FromPEM returns the key of the first "PUBLIC KEY" block in data.
+func FromPEM(data []byte) (crypto.PublicKey, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("wskeyauthkeys: no PUBLIC KEY block found")
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		return FromClientID("SPKI$" + base64.StdEncoding.EncodeToString(block.Bytes))
+	}
+}
+
+// SSH returns pub as an OpenSSH public key, as in authorized_keys, with no
+// comment. Servers accept these as client IDs too.
+func SSH(pub crypto.PublicKey) (string, error) {
+	if err := check(pub); err != nil {
+		return "", err
+	}
+
+	var keyType string
+	var blob []byte
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		keyType = "ecdsa-sha2-nistp256"
+		point := make([]byte, 65)
+		point[0] = 4
+		pub.X.FillBytes(point[1:33])
+		pub.Y.FillBytes(point[33:])
+		blob = sshString(sshString(sshString(nil, keyType), "nistp256"), string(point))
+	default:
+		keyType = "ssh-ed25519"
+		blob = sshString(sshString(nil, keyType), string(pub.(ed25519.PublicKey)))
+	}
+	return keyType + " " + base64.StdEncoding.EncodeToString(blob), nil
+}
+
+// sshString appends s to b as the SSH wire format has strings: prefixed with
+// their length.
+func sshString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// FromSSH returns the key of an OpenSSH public key.
+func FromSSH(line string) (crypto.PublicKey, error) {
+	parsed, err := wskeyauth.ParseClientID(line)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Format != wskeyauth.FormatSSH {
+		return nil, errors.New("wskeyauthkeys: not an OpenSSH public key")
+	}
+	return parsed.PublicKey, nil
+}
+
+// check checks that pub is a key ws-key-auth supports.
+func check(pub crypto.PublicKey) error {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return errors.New("wskeyauthkeys: ECDSA keys must be on P-256")
+		}
+		return nil
+	case ed25519.PublicKey:
+		if len(pub) != ed25519.PublicKeySize {
+			return errors.New("wskeyauthkeys: Ed25519 keys must be 32 bytes long")
+		}
+		return nil
+	}
+	return fmt.Errorf("wskeyauthkeys: unsupported key type %T", pub)
+}
@@ -0,0 +1,104 @@
+package wskeyauth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// KeyStore decides which keys may authenticate at all. Without one, any
+// client that proves it holds the key for its client ID is authenticated.
+type KeyStore interface {
+	// Lookup reports whether the key with fingerprint, as returned by
+	// Fingerprint, is known. An error fails the handshake with
+	// ReasonServerError.
+	Lookup(ctx context.Context, fingerprint string) (bool, error)
+}
+
+// WithKeyStore only authenticates clients whose keys are in store. Clients
+// with unknown keys are rejected before they are challenged, failing the
+// handshake with ReasonUnknownKey.
+func WithKeyStore(store KeyStore) Option {
+	return func(cfg *config) {
+		cfg.keyStore = store
+	}
+}
+
+// AuthorizedKeys is a KeyStore of the keys in an OpenSSH authorized_keys
+// file. Only key types that can be client IDs are loaded, and the rest are
+// skipped; so are options in front of keys, which aren't enforced.
+type AuthorizedKeys struct {
+	// comments by fingerprint
+	keys map[string]string
+}
+
+// ParseAuthorizedKeys reads the keys in authorized_keys format from r.
+func ParseAuthorizedKeys(r io.Reader) (*AuthorizedKeys, error) {
+	a := &AuthorizedKeys{keys: map[string]string{}}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, comment, ok := findSSHKey(line)
+		if !ok {
+			continue
+		}
+		pub, err := parseSSHKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("authorized keys line %d: %w", n, err)
+		}
+		a.keys[fingerprint(pub)] = comment
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// LoadAuthorizedKeys reads the authorized_keys file at path.
+func LoadAuthorizedKeys(path string) (*AuthorizedKeys, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseAuthorizedKeys(f)
+}
+
+// findSSHKey finds the key in an authorized_keys line, after any options,
+// and returns it without its comment.
+func findSSHKey(line string) (key, comment string, ok bool) {
+	fields := strings.Fields(line)
+	for i, field := range fields {
+		if (field == sshECDSAP256 || field == sshEd25519) && i+1 < len(fields) {
+			return field + " " + fields[i+1], strings.Join(fields[i+2:], " "), true
+		}
+	}
+	return "", "", false
+}
+
+func (a *AuthorizedKeys) Lookup(_ context.Context, fingerprint string) (bool, error) {
+	_, ok := a.keys[fingerprint]
+	return ok, nil
+}
+
+// Comment returns the comment of the key with fingerprint, which is usually
+// who it belongs to.
+func (a *AuthorizedKeys) Comment(fingerprint string) (string, bool) {
+	comment, ok := a.keys[fingerprint]
+	return comment, ok
+}
+
+// Len returns the number of keys loaded.
+func (a *AuthorizedKeys) Len() int {
+	return len(a.keys)
+}
@@ -0,0 +1,10 @@
+CREATE TABLE wskeyauth_keys (
+	fingerprint       TEXT PRIMARY KEY,
+	client_id         TEXT NOT NULL,
+	owner             TEXT NOT NULL,
+	created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+	revoked_at        TIMESTAMPTZ,
+	revocation_reason TEXT
+);
+
+CREATE INDEX wskeyauth_keys_owner ON wskeyauth_keys (owner);
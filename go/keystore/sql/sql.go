@@ -0,0 +1,206 @@
+// Package wskeyauthsql is a wskeyauth.KeyStore in a SQL database, with a
+// schema for the keys' client IDs, fingerprints, owners and revocation
+// status, so that applications don't each invent their own. Queries are
+// written for PostgreSQL.
+//
+//	db, err := sql.Open("pgx", dsn)
+//	...
+//	if err := wskeyauthsql.Migrate(ctx, db); err != nil {
+//		...
+//	}
+//	store := wskeyauthsql.New(db)
+//	wskeyauth.Handshake(conn, wskeyauth.WithKeyStore(store))
+package wskeyauthsql
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// ErrNotFound is returned for keys that aren't in the store.
+var ErrNotFound = errors.New("wskeyauthsql: key not found")
+
+// Migrate brings the schema up to date, applying each migration that hasn't
+// been yet in a transaction of its own. Servers sharing a database shouldn't
+// migrate it at the same time.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS wskeyauth_schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	err = db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM wskeyauth_schema_migrations`).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		version, err := strconv.Atoi(strings.SplitN(strings.TrimPrefix(name, "migrations/"), "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("wskeyauthsql: malformed migration name %s", name)
+		}
+		if version <= current {
+			continue
+		}
+
+		script, err := migrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := migrate(ctx, db, version, string(script)); err != nil {
+			return fmt.Errorf("wskeyauthsql: migration %s failed: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func migrate(ctx context.Context, db *sql.DB, version int, script string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO wskeyauth_schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Key is a key in the store.
+type Key struct {
+	Fingerprint string
+	ClientID    string
+	Owner       string
+	CreatedAt   time.Time
+
+	// RevokedAt and RevocationReason are set once the key was revoked.
+	RevokedAt        *time.Time
+	RevocationReason string
+}
+
+// Store is a wskeyauth.KeyStore of the keys in a database. Revoked keys
+// aren't known to it.
+type Store struct {
+	db *sql.DB
+}
+
+var _ wskeyauth.KeyStore = (*Store)(nil)
+
+// New creates a Store of the keys in db, whose schema must be up to date.
+func New(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Lookup(ctx context.Context, fingerprint string) (bool, error) {
+	var known bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM wskeyauth_keys WHERE fingerprint = $1 AND revoked_at IS NULL)`,
+		fingerprint).Scan(&known)
+	return known, err
+}
+
+// Add adds the key with clientID, belonging to owner, and returns its
+// fingerprint. Adding a key that is already in the store, revoked or not,
+// fails.
+func (s *Store) Add(ctx context.Context, clientID, owner string) (string, error) {
+	fingerprint, err := wskeyauth.Fingerprint(clientID)
+	if err != nil {
+		return "", err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO wskeyauth_keys (fingerprint, client_id, owner) VALUES ($1, $2, $3)`,
+		fingerprint, clientID, owner)
+	if err != nil {
+		return "", err
+	}
+	return fingerprint, nil
+}
+
+// Revoke revokes the key with fingerprint, for reason. Revoking a key that
+// was already revoked keeps its original revocation.
+func (s *Store) Revoke(ctx context.Context, fingerprint, reason string) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE wskeyauth_keys SET revoked_at = COALESCE(revoked_at, now()), revocation_reason = COALESCE(revocation_reason, $2)
+		WHERE fingerprint = $1`,
+		fingerprint, reason)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Key returns the key with fingerprint.
+func (s *Store) Key(ctx context.Context, fingerprint string) (*Key, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+keyColumns+` FROM wskeyauth_keys WHERE fingerprint = $1`, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := scanKeys(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNotFound
+	}
+	return &keys[0], nil
+}
+
+// KeysOf returns every key of owner, revoked or not, oldest first.
+func (s *Store) KeysOf(ctx context.Context, owner string) ([]Key, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+keyColumns+` FROM wskeyauth_keys WHERE owner = $1 ORDER BY created_at, fingerprint`, owner)
+	if err != nil {
+		return nil, err
+	}
+	return scanKeys(rows)
+}
+
+const keyColumns = `fingerprint, client_id, owner, created_at, revoked_at, COALESCE(revocation_reason, '')`
+
+func scanKeys(rows *sql.Rows) ([]Key, error) {
+	defer rows.Close()
+
+	var keys []Key
+	for rows.Next() {
+		var k Key
+		var revokedAt sql.NullTime
+		if err := rows.Scan(&k.Fingerprint, &k.ClientID, &k.Owner, &k.CreatedAt, &revokedAt, &k.RevocationReason); err != nil {
+			return nil, err
+		}
+		if revokedAt.Valid {
+			k.RevokedAt = &revokedAt.Time
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
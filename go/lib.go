@@ -25,21 +25,35 @@ SOFTWARE.
 package wskeyauth
 
 import (
+	"crypto/ecdh"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"math/big"
+	"net"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/gorilla/websocket"
+	"github.com/castcam-live/ws-key-auth/go/internal/srp"
 )
 
+// Conn is the subset of a WebSocket connection that the handshake needs. A
+// *websocket.Conn from gorilla/websocket (or any of its forks, such as
+// fasthttp/websocket) satisfies it.
+type Conn interface {
+	ReadJSON(v any) error
+	WriteJSON(v any) error
+}
+
 type TypeData struct {
 	Type string          `json:"type"`
 	Data json.RawMessage `json:"data"`
@@ -52,10 +66,98 @@ type TypeData struct {
 //   -> SIGNATURE_MATCHES
 //   or
 //   -> SIGNATURE_MISMATCH
+//
+// The CHALLENGE also advertises the server's Capabilities.
+//
+// With WithAlgorithmPolicy, clients whose keys it doesn't allow get
+// UNSUPPORTED_ALGORITHM in place of the CHALLENGE; see algorithms.go.
+//
+// With WithAudience, the CHALLENGE carries the server's audience, and the
+// client signs it along with the challenge.
+//
+// With WithTOTP, the server asks for a one-time code with
+// SECOND_FACTOR_REQUIRED before it sends SIGNATURE_MATCHES; see totp.go.
+//
+// With WithOIDC, the CHALLENGE_RESPONSE may carry an ID token to link to the
+// client's key; see oidc.go.
+//
+// With WithTokenExchange, SIGNATURE_MATCHES carries an access token for the
+// client; see tokenexchange.go. With WithMacaroons, it carries a macaroon;
+// see macaroon.go. With WithTURN, it carries credentials for TURN servers;
+// see turn.go.
+//
+// Once authenticated, clients may renew those with REFRESH; see refresh.go.
+// They may also run the handshake again, such as with a new key, after a
+// RE_AUTH; see reauth.go.
+//
+// Servers with Channels let them JOIN and LEAVE named channels; see
+// channels.go.
+//
+// Clients may send a challenge of their own with their CLIENT_ID, for servers
+// with WithServerKey to prove their identity with, in the CHALLENGE.
+//
+// With WithIPFilter, clients whose address isn't let in are sent FORBIDDEN
+// before anything else, and the connection is closed; see ipfilter.go.
+//
+// With WithStatelessChallenges, the challenge proves it was issued by the
+// cluster, and clients may echo it back in their CHALLENGE_RESPONSE, for any
+// server of the cluster to accept; see stateless.go.
+//
+// With WithProofOfWork, the CHALLENGE may ask for a proof of work, to be sent
+// along with the CHALLENGE_RESPONSE; see proofofwork.go.
+//
+// With WithRateLimiter, the server sends RATE_LIMITED in place of whatever it
+// would have sent next, once a client has made too many handshakes.
+//
+// With WithBans, the server sends RETRY_AFTER in place of whatever it would
+// have sent next, once too many of a client's handshakes failed; see ban.go.
+//
+// If the client takes too long, the server sends TIMEOUT, in place of
+// whatever it would have sent next.
+//
+// With WithErrorVerbosity(TerseErrors), errors carry their type only, and
+// no data; see verbosity.go.
+//
+// With WithRetries, a CLIENT_ERROR for a message that failed to parse, or
+// wasn't of the type expected, may have "retry" set, in which case the
+// connection stays open for the client to resend the message; see retries.go.
+//
+// NoiseHandshake replaces the CLIENT_ID, CHALLENGE and CHALLENGE_RESPONSE
+// with the three messages of a Noise handshake; see noise.go.
+//
+// With WithChallengeFirst, the server sends the CHALLENGE first, and the
+// client its CLIENT_ID and response together; see challengefirst.go.
+//
+// With WithSignedTimestamps, clients may sign the time along with their
+// CLIENT_ID, and get SIGNATURE_MATCHES in place of the CHALLENGE; see
+// timestamp.go.
+//
+// With WithTLSClientAuth, clients whose TLS client certificate holds their
+// key skip the challenge, and get SIGNATURE_MATCHES in place of it.
+//
+// With WithPairing, clients whose key isn't known get PAIRING_PENDING in
+// place of SIGNATURE_MATCHES; see pairing.go.
+//
+// With WithGuests, clients whose keys aren't known get a SIGNATURE_MATCHES
+// that marks them as guests; see guest.go.
+//
+// Servers shutting down with Authenticator.Shutdown send GOING_AWAY, in place
+// of the CHALLENGE, and to clients that were already authenticated.
 
 // A client ID will be of the format
 //
 // WebCrypto-raw.EC.<named curve>$<base64 encoded public key>
+//
+// or, for passkeys, whose CHALLENGE_RESPONSE is a WebAuthn assertion,
+//
+// WebAuthn-raw.EC.<named curve>$<base64 encoded public key>
+//
+// or a did:key, an OpenSSH public key, a JWK, an SPKI, an X.509 certificate, a
+// key delegated by a root key, the name of a pre-shared secret, or a password
+// user; see didkey.go, sshkey.go, jwk.go, spki.go, x509.go, delegation.go,
+// psk.go and password.go. formats.go tells them apart, and WithClientIDFormats
+// picks the ones accepted. Those that start with a name and a $ may carry a
+// namespace; see namespace.go.
 
 func ErrInvalidClientID() error {
 	return errors.New("invalid client ID")
@@ -65,17 +167,79 @@ func ErrFailedToReadRandomNumbers() error {
 	return errors.New("failed to read random numbers")
 }
 
-func parseClientID(clientID string) (*ecdsa.PublicKey, error) {
-	s := strings.Split(clientID, "$")
-	if len(s) != 2 {
+// ErrInvalidPublicKey is returned for client IDs whose key is well-formed but
+// isn't a point on the curve, including the point at infinity.
+var ErrInvalidPublicKey = errors.New("public key is not a valid point on the curve")
+
+// publicKey is a client's public key, as its client ID describes it. Exactly
+// one of ecdsa, ed25519, keyID and username is set.
+type publicKey struct {
+	ecdsa   *ecdsa.PublicKey
+	ed25519 ed25519.PublicKey
+
+	// webauthn is set for passkeys, which sign WebAuthn assertions rather
+	// than the challenge itself.
+	webauthn bool
+
+	// certificates are the certificate of an X.509 client ID, and its
+	// intermediates.
+	certificates []*x509.Certificate
+
+	// delegation is the root's signature of a delegated client ID.
+	delegation *delegation
+
+	// namespace is the namespace of the client ID, if any, and format its
+	// format.
+	namespace string
+	format    ClientIDFormat
+
+	// keyID names the pre-shared secret of an HMAC client ID, and username
+	// the user of an SRP client ID, neither of which has a public key at all.
+	keyID    string
+	username string
+}
+
+func parseClientID(clientID string, accept Base64) (*publicKey, error) {
+	stripped, namespace := splitNamespace(clientID)
+	pubKey, err := parseBareClientID(stripped, accept)
+	if pubKey != nil {
+		pubKey.namespace = namespace
+	}
+	return pubKey, err
+}
+
+// parseBareClientID parses a client ID that is in no namespace, with the
+// parser of its format.
+func parseBareClientID(clientID string, accept Base64) (*publicKey, error) {
+	if f := detectClientIDFormat(clientID); f != nil {
+		pubKey, err := f.parse(clientID, accept)
+		if pubKey != nil {
+			pubKey.format = f.format
+		}
+		return pubKey, err
+	}
+	// fails, explaining what is wrong with the client ID
+	return parseRawClientID(clientID, accept)
+}
+
+// parseRawClientID parses a WebCrypto or WebAuthn client ID.
+func parseRawClientID(clientID string, accept Base64) (*publicKey, error) {
+	prefix, encoded, ok := strings.Cut(clientID, "$")
+	if !ok || strings.Contains(encoded, "$") {
 		return nil, fmt.Errorf("expected client ID to have exactly one $. The client ID: %s", clientID)
 	}
 
-	if s[0] != "WebCrypto-raw.EC.P-256" {
-		return nil, fmt.Errorf("expected client ID to have prefix WebCrypto-raw.EC.P-256. The client ID: %s", clientID)
+	var webauthn bool
+	switch prefix {
+	case "WebCrypto-raw.EC.P-256":
+	case "WebAuthn-raw.EC.P-256":
+		webauthn = true
+	default:
+		return nil, fmt.Errorf("expected client ID to have prefix WebCrypto-raw.EC.P-256 or WebAuthn-raw.EC.P-256. The client ID: %s", clientID)
 	}
 
-	buff, err := base64.StdEncoding.DecodeString(s[1])
+	var keyBuf [66]byte
+	buff, err := accept.decode(keyBuf[:], encoded)
 	if err != nil {
 		return nil, err
 	}
@@ -88,193 +252,783 @@ func parseClientID(clientID string) (*ecdsa.PublicKey, error) {
 		return nil, errors.New("expected P-256 key of ID to have 0x04 as the first byte")
 	}
 
+	// ecdh rejects points that aren't on the curve, as well as the identity,
+	// neither of which ecdsa.PublicKey checks for on its own
+	if _, err := ecdh.P256().NewPublicKey(buff); err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+
 	x := &big.Int{}
 	y := &big.Int{}
 
 	x.SetBytes(buff[1:33])
 	y.SetBytes(buff[33:])
 
-	return &ecdsa.PublicKey{
-		X:     x,
-		Y:     y,
-		Curve: elliptic.P256(),
+	return &publicKey{
+		ecdsa: &ecdsa.PublicKey{
+			X:     x,
+			Y:     y,
+			Curve: elliptic.P256(),
+		},
+		webauthn: webauthn,
 	}, nil
 }
 
 const challengeByteLength = 128
 
-// It will be safe to assume that any error coming from this function is a client
-func getChallengePayload() (b []byte, err error) {
-	b = make([]byte, challengeByteLength)
-	n, err := rand.Read(b)
-	if err != nil {
-		return []byte{}, err
-	}
-	if n < challengeByteLength {
-		return []byte{}, ErrFailedToReadRandomNumbers()
-	}
-	return b, nil
+// readChallengePayload fills b with random bytes. It will be safe to assume
+// that any error coming from this function is a server error.
+func readChallengePayload(b []byte) error {
+	return readRandom(rand.Reader, b)
 }
 
 // Handshake will perform the handshake with the client and return true if the
 // client is authenticated and false if not. If an error is returned, the
 // connection should be closed.
-func Handshake(conn *websocket.Conn) (bool, string, error) {
-	var td TypeData
-	err := conn.ReadJSON(&td)
+func Handshake(conn Conn, opts ...Option) (bool, string, error) {
+	result, err := HandshakeResult(conn, opts...)
+	return result.Authenticated, result.ClientID, err
+}
+
+// Result is the outcome of a handshake.
+type Result struct {
+	Authenticated bool
+	ClientID      string
+	Fingerprint   string
+
+	// Guest is set for clients let in with WithGuests, whose keys aren't
+	// known, and Scopes are the scopes they were granted.
+	Guest  bool
+	Scopes []string
+
+	// Reason is why the handshake failed, if it did.
+	Reason FailureReason
+
+	// MessageBinding binds the messages the client signs over the
+	// connection to it, for NewMessageVerifier. It is nil for handshakes
+	// without a challenge.
+	MessageBinding []byte
+}
+
+// HandshakeResult performs the handshake as Handshake does, but returns all
+// there is to know about its outcome. If an error is returned, the
+// connection should be closed.
+func HandshakeResult(conn Conn, opts ...Option) (*Result, error) {
+	h := newHandshakeState(conn, opts)
+	return h.handshake((*handshakeState).run)
+}
+
+func newHandshakeState(conn Conn, opts []Option) *handshakeState {
+	cfg := newConfig(opts)
+	return &handshakeState{conn: conn, raw: conn, wire: conn, cfg: cfg, log: cfg.logger, startedAt: cfg.clock.Now()}
+}
+
+// handshake runs the handshake from its first step, start, to its end, over
+// a connection whose reads block until the client's next message arrives,
+// so that every step runs as soon as the one before it is done.
+func (h *handshakeState) handshake(start step) (*Result, error) {
+	if result, err := h.begin(); result != nil {
+		return result, err
+	}
+	h.next = start
+	return h.finish(h.next(h))
+}
+
+// begin sets the handshake up, tracing, limiting and timing it. It returns
+// the result of the handshake if it fails before it starts.
+func (h *handshakeState) begin() (*Result, error) {
+	conn, cfg := h.conn, h.cfg
+
+	h.trace = startTracing(cfg)
+
+	if cfg.request != nil {
+		h.remoteAddr = clientAddr(cfg.request, cfg.trustedProxies)
+	} else if a, ok := conn.(interface{ RemoteAddr() net.Addr }); ok {
+		h.remoteAddr = a.RemoteAddr().String()
+	}
+	if h.remoteAddr != "" {
+		h.log = h.log.With("remote_addr", h.remoteAddr)
+	}
+	h.restoreReads = limitReads(conn, cfg)
+
+	if cfg.encoding != nil {
+		c, ok := conn.(MessageConn)
+		if !ok {
+			h.restoreReads()
+			h.trace.end()
+			return &Result{Reason: ReasonServerError}, errNotMessageConn
+		}
+		h.wire = NewEncodedConn(c, cfg.encoding)
+		h.conn = h.wire
+	}
+	if cfg.recorder != nil {
+		h.conn = cfg.recorder.wrap(h.conn)
+	}
+	if len(cfg.interceptors) > 0 {
+		h.intercepting = &interceptingConn{Conn: h.conn, interceptors: cfg.interceptors}
+		h.conn = h.intercepting
+	}
+	h.conn = cfg.errorVerbosity.wrap(wrapCatalog(h.conn, cfg.catalog))
+	h.deadline = startDeadline(h.conn, conn, cfg)
+	if h.deadline != nil {
+		h.conn = h.deadline
+	}
+	h.log.Debug("wskeyauth: handshake started")
+
+	cfg.metrics.HandshakeStarted()
+	return nil, nil
+}
+
+// finish ends the handshake with the outcome of its last step, reporting it,
+// and returns its result.
+func (h *handshakeState) finish(authenticated bool, clientID string, reason FailureReason, err error) (*Result, error) {
+	cfg := h.cfg
+	defer h.trace.end()
+	defer h.restoreReads()
+	if h.buf != nil {
+		putBuffers(h.buf)
+		h.buf = nil
+	}
+
+	if h.intercepting != nil {
+		if vetoErr := h.intercepting.vetoed(); vetoErr != nil {
+			authenticated, reason, err = false, ReasonVetoed, vetoErr
+		}
+	}
+	if h.deadline != nil && h.deadline.stop() {
+		authenticated, reason = false, ReasonTimeout
+		err = &TimeoutError{Step: h.trace.name, After: cfg.clock.Now().Sub(h.startedAt)}
+	}
+	if authenticated {
+		cfg.metrics.HandshakeSucceeded()
+		h.log.Info("wskeyauth: client authenticated", "client_id", clientID)
+		cfg.hooks.authenticated(clientID)
+	} else {
+		cfg.metrics.HandshakeFailed(reason)
+		h.trace.fail(reason, err)
+		h.log.Warn("wskeyauth: handshake failed", "client_id", clientID, "reason", reason, "error", err)
+		cfg.hooks.failure(clientID, reason, err)
+	}
+	h.audit(authenticated, clientID, reason, err)
+	if cfg.bans != nil {
+		h.recordBan(authenticated, reason)
+	}
+
+	result := &Result{Authenticated: authenticated, ClientID: clientID, Fingerprint: h.fingerprint, Reason: reason}
+	if authenticated && h.guest {
+		result.Guest, result.Scopes = true, h.cfg.guests.Scopes
+	}
+	if authenticated {
+		result.MessageBinding = h.binding
+	}
+	h.publish(result, err)
+	return result, err
+}
+
+// handshakeState is what Handshake knows about the handshake in progress.
+type handshakeState struct {
+	conn  Conn
+	cfg   *config
+	trace *tracing
+	log   *slog.Logger
+
+	// raw is the connection as it was given, before its encoding,
+	// recording, interceptors, the message catalog, error verbosity and the
+	// deadline wrapped it, and wire is raw in its encoding.
+	raw  Conn
+	wire Conn
+
+	startedAt   time.Time
+	remoteAddr  string
+	fingerprint string
+
+	// audience is what the client signs after the challenge, as
+	// signedAudience returns.
+	audience string
+
+	// workDifficulty is the difficulty of the proof of work the client was
+	// asked for in the CHALLENGE, if any.
+	workDifficulty int
+
+	// retries is how many messages the client resent, of WithRetries.
+	retries int
+
+	// encrypted is the connection encrypted with the keys a Noise handshake
+	// agreed on.
+	encrypted *EncryptedConn
+
+	// pairing is set for clients whose key isn't known, and who are to pair
+	// it once they proved they hold it, and guest for those that are to be
+	// let in as guests.
+	pairing bool
+	guest   bool
+
+	// binding is the MessageBinding of the challenge the client signed.
+	binding []byte
+
+	// next is the step the handshake is at, and the rest is what its steps
+	// hand on to each other.
+	next       step
+	buf        *buffers
+	payload    []byte
+	msg        ClientMessage
+	clientID   string
+	pubKey     *publicKey
+	secret     []byte
+	srp        *srp.Server
+	token      *IDToken
+	proofData  string
+	totpSecret []byte
+
+	// begin sets these up for finish.
+	restoreReads func()
+	intercepting *interceptingConn
+	deadline     *deadline
+}
+
+// A step is a state of the handshake. Steps that wait for the client start
+// by reading its next message, and do nothing before, so that a step whose
+// read is suspended, as ServerHandshake's are until it is fed, can be run
+// again from the start once the message arrives. Every step ends the
+// handshake, with its outcome, or hands on to the next with then.
+type step func(h *handshakeState) (bool, string, FailureReason, error)
+
+// then makes next the handshake's step, and runs it.
+func (h *handshakeState) then(next step) (bool, string, FailureReason, error) {
+	h.next = next
+	return next(h)
+}
+
+func (h *handshakeState) audit(authenticated bool, clientID string, reason FailureReason, err error) {
+	if h.cfg.audit == nil {
+		return
+	}
+
+	record := AuditRecord{
+		Time:          h.startedAt,
+		RemoteAddr:    h.remoteAddr,
+		ClientID:      clientID,
+		Fingerprint:   h.fingerprint,
+		Authenticated: authenticated,
+		Reason:        reason,
+	}
 	if err != nil {
-		return false, "", err
+		record.Error = err.Error()
 	}
 
-	if td.Type != "CLIENT_ID" {
-		conn.WriteJSON(map[string]string{
-			"type": "CLIENT_ERROR",
-			"data": "Expected a CLIENT_ID event, but got " + td.Type + "",
-		})
-		return false, "", nil
+	if err := h.cfg.audit.Record(record); err != nil {
+		h.log.Error("wskeyauth: failed to write audit record", "error", err)
 	}
+}
+
+func (h *handshakeState) run() (bool, string, FailureReason, error) {
+	conn, cfg, trace := h.conn, h.cfg, h.trace
 
-	var clientID string
-	err = json.Unmarshal(td.Data, &clientID)
+	buf, err := getBuffers(cfg.lockedMemory)
 	if err != nil {
-		conn.WriteJSON(map[string]any{
-			"type": "CLIENT_ERROR",
-			"data": map[string]string{
-				"message": "Failed to parse CLIENT_ID",
-				"error":   err.Error(),
-			},
-		})
-		return false, "", err
+		conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to allocate locked memory", err))
+		return false, "", ReasonServerError, err
+	}
+	h.buf = buf
+
+	if cfg.ipFilter != nil {
+		if ok, reason, err := h.checkAddr(); !ok {
+			return false, "", reason, err
+		}
+	}
+	if h.remoteAddr != "" {
+		if ok, reason, err := h.allow(addrKey(h.remoteAddr)); !ok {
+			return false, "", reason, err
+		}
+	}
+
+	if cfg.challengeFirst {
+		trace.step("SendChallenge")
+
+		h.payload = buf.challenge[:]
+		if ok, reason, err := h.sendChallenge(buf, nil); !ok {
+			return false, "", reason, err
+		}
+	}
+
+	trace.step("ReadClientID")
+	return h.then((*handshakeState).readClientID)
+}
+
+// readClientID reads the client's CLIENT_ID, and checks the client ID,
+// challenging the client if it holds up.
+func (h *handshakeState) readClientID() (bool, string, FailureReason, error) {
+	conn, cfg, trace, buf := h.conn, h.cfg, h.trace, h.buf
+
+	msg := &h.msg
+	if reason, err := h.readMessage(msg, "CLIENT_ID"); reason != "" {
+		return false, "", reason, err
+	}
+
+	clientID, timestamp := msg.ClientID, msg.Timestamp
+	h.clientID = clientID
+
+	var clientChallenge []byte
+	if msg.Challenge != "" {
+		var err error
+		clientChallenge, err = decodeClientChallenge(msg.Challenge)
+		if err != nil {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Failed to parse CLIENT_ID", err))
+			return false, clientID, ReasonMalformedMessage, err
+		}
+	}
+
+	if format := clientIDFormat(clientID); !cfg.acceptsFormat(format) {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, string(format)+" client IDs are not accepted", nil))
+		return false, clientID, ReasonInvalidClientID, nil
 	}
 
-	pubKey, err := parseClientID(clientID)
+	pubKey, err := parseClientID(clientID, cfg.base64)
+
+	if errors.Is(err, ErrInvalidPublicKey) {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidPublicKey, "CLIENT_ID is not a valid public key", err))
+		return false, clientID, ReasonInvalidPublicKey, err
+	}
 
 	if err != nil {
-		conn.WriteJSON(map[string]any{
-			"type": "CLIENT_ERROR",
-			"data": map[string]string{
-				"message": "Failed to parse CLIENT_ID",
-				"error":   err.Error(),
-			},
-		})
-		return false, clientID, err
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, "Failed to parse CLIENT_ID", err))
+		return false, clientID, ReasonInvalidClientID, err
 	}
 
 	if pubKey == nil {
-		conn.WriteJSON(map[string]any{
-			"type": "CLIENT_ERROR",
-			"data": map[string]string{
-				"message": "Failed to parse CLIENT_ID",
-			},
-		})
-		return false, clientID, nil
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, "Failed to parse CLIENT_ID", nil))
+		return false, clientID, ReasonInvalidClientID, nil
 	}
 
-	payload, err := getChallengePayload()
-	if err != nil {
-		return false, clientID, err
+	if cfg.namespace != "" && pubKey.namespace != cfg.namespace {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, "CLIENT_ID is not in this server's namespace", nil))
+		return false, clientID, ReasonInvalidClientID, nil
 	}
+	h.audience = signedAudience(cfg.audience, pubKey.namespace)
 
-	challenge := base64.StdEncoding.EncodeToString(payload)
+	if pubKey.webauthn && cfg.webauthn == nil {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, "WebAuthn client IDs are not accepted", nil))
+		return false, clientID, ReasonInvalidClientID, nil
+	}
 
-	if err != nil {
-		conn.WriteJSON(map[string]any{
-			"type": "SERVER_ERROR",
-			"data": map[string]string{
-				"message": "Failed to generate challenge",
-				"error":   err.Error(),
-			},
+	if pubKey.username != "" && cfg.passwords == nil {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, "Password client IDs are not accepted", nil))
+		return false, clientID, ReasonInvalidClientID, nil
+	}
+
+	if err := cfg.algorithms.check(pubKey); err != nil {
+		conn.WriteJSON(newErrorMessage("UNSUPPORTED_ALGORITHM", ReasonUnsupportedAlgorithm, err.Error(), nil))
+		return false, clientID, ReasonUnsupportedAlgorithm, nil
+	}
+
+	if pubKey.certificates != nil {
+		if cfg.x509 == nil {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, "X.509 client IDs are not accepted", nil))
+			return false, clientID, ReasonInvalidClientID, nil
+		}
+		if err := cfg.x509.verify(pubKey, cfg.clock.Now()); err != nil {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonUntrustedCertificate, "Client certificate is not trusted", err))
+			return false, clientID, ReasonUntrustedCertificate, err
+		}
+	}
+
+	if pubKey.delegation != nil {
+		if cfg.delegation == nil {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, "Delegated client IDs are not accepted", nil))
+			return false, clientID, ReasonInvalidClientID, nil
+		}
+		if err := cfg.delegation.verify(pubKey, cfg.clock.Now(), cfg.fips); err != nil {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonUntrustedDelegation, "Delegated key is not trusted", err))
+			return false, clientID, ReasonUntrustedDelegation, err
+		}
+	}
+
+	fp := fingerprint(pubKey)
+	h.fingerprint = fp
+	h.log = h.log.With("fingerprint", fp)
+	h.log.Debug("wskeyauth: received client ID")
+
+	trace.setFingerprint(fp)
+
+	if ok, reason, err := h.allow("key:" + fp); !ok {
+		return false, clientID, reason, err
+	}
+	if ok, reason, err := h.checkRevoked(); !ok {
+		return false, clientID, reason, err
+	}
+
+	h.pubKey = pubKey
+
+	var secret []byte
+	if pubKey.keyID != "" {
+		secrets, ok := cfg.keyStore.(SecretStore)
+		if !ok {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, "HMAC client IDs are not accepted", nil))
+			return false, clientID, ReasonInvalidClientID, nil
+		}
+		secret, err = secrets.Secret(cfg.ctx, pubKey.keyID)
+		if err != nil {
+			conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to look up client key", err))
+			return false, clientID, ReasonServerError, err
+		}
+		if secret == nil {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonUnknownKey, "Client key is not known", nil))
+			return false, clientID, ReasonUnknownKey, nil
+		}
+	} else if pubKey.username == "" && pubKey.delegation == nil {
+		if ok, reason, err := h.lookup(); !ok {
+			return false, clientID, reason, err
+		}
+	}
+	h.secret = secret
+
+	if err := cfg.hooks.clientID(clientID); err != nil {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonRejected, "Client ID was rejected", err))
+		return false, clientID, ReasonRejected, err
+	}
+
+	if pubKey.username != "" {
+		if cfg.challengeFirst {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, "Password client IDs are not accepted", nil))
+			return false, clientID, ReasonInvalidClientID, nil
+		}
+		return h.runSRP(pubKey.username)
+	}
+
+	if cfg.tlsClientAuth != nil && cfg.tlsClientAuth.matches(pubKey) {
+		h.log.Debug("wskeyauth: client certificate holds the client's key")
+		return h.authenticate("", "", nil)
+	}
+
+	if timestamp != nil && cfg.timestamps != nil {
+		ok, reason, err := h.checkTimestamp(pubKey, timestamp)
+		if ok {
+			return h.authenticate("", "", nil)
+		}
+		if reason != "" {
+			return false, clientID, reason, err
+		}
+	}
+
+	if !cfg.challengeFirst {
+		trace.step("SendChallenge")
+
+		h.payload = buf.challenge[:]
+		if ok, reason, err := h.sendChallenge(buf, clientChallenge); !ok {
+			return false, clientID, reason, err
+		}
+	}
+	h.log.Debug("wskeyauth: sent challenge")
+	cfg.hooks.challengeSent(clientID)
+
+	if cfg.challengeFirst {
+		// the CLIENT_ID carried the response
+		if msg.Signature == "" {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Expected CLIENT_ID to carry a response to the CHALLENGE", nil))
+			return false, clientID, ReasonMalformedMessage, nil
+		}
+		return h.verifyResponse()
+	}
+
+	trace.step("ReadChallengeResponse")
+	return h.then((*handshakeState).readChallengeResponse)
+}
+
+// readChallengeResponse reads the client's CHALLENGE_RESPONSE.
+func (h *handshakeState) readChallengeResponse() (bool, string, FailureReason, error) {
+	if reason, err := h.readMessage(&h.msg, "CHALLENGE_RESPONSE"); reason != "" {
+		return false, h.clientID, reason, err
+	}
+	return h.verifyResponse()
+}
+
+// verifyResponse checks the client's response to the challenge.
+func (h *handshakeState) verifyResponse() (bool, string, FailureReason, error) {
+	conn, cfg, trace := h.conn, h.cfg, h.trace
+	buf, payload, msg := h.buf, h.payload, &h.msg
+	clientID, pubKey, secret := h.clientID, h.pubKey, h.secret
+
+	if cfg.stateless != nil {
+		if ok, reason, err := h.checkChallenge(payload, msg.EchoedChallenge); !ok {
+			return false, clientID, reason, err
+		}
+	}
+
+	if ok, reason, err := h.checkProofOfWork(payload, msg.ProofOfWork); !ok {
+		return false, clientID, reason, err
+	}
+
+	response := challengeResponse{Signature: msg.Signature, Hash: msg.Hash}
+
+	h.log.Debug("wskeyauth: received challenge response", "hash", response.Hash)
+
+	if response.Hash != "SHA-256" && pubKey.ed25519 == nil {
+		conn.WriteJSON(&stringMessage{
+			Type: "UNSUPPORTED_HASH",
+			Data: "Got hash of type " + response.Hash + ", but the only supported hash currently is SHA-256 (more coming soon!)",
 		})
-		return false, clientID, err
+		return false, clientID, ReasonUnsupportedHash, nil
 	}
 
-	conn.WriteJSON(map[string]string{
-		"type": "CHALLENGE",
-		"data": challenge,
-	})
+	if pubKey.ed25519 == nil && !cfg.algorithms.allowsHash(response.Hash) {
+		conn.WriteJSON(&stringMessage{Type: "UNSUPPORTED_HASH", Data: response.Hash + " signatures are not accepted"})
+		return false, clientID, ReasonUnsupportedHash, nil
+	}
 
-	err = conn.ReadJSON(&td)
+	decodedChallengeResponse, err := cfg.base64.decode(buf.signature[:], response.Signature)
 	if err != nil {
-		conn.WriteJSON(map[string]any{
-			"type": "SERVER_ERROR",
-			"data": map[string]string{
-				"message": "Failed to read CHALLENGE_RESPONSE",
-				"error":   err.Error(),
-			},
-		})
-		return false, clientID, err
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Failed to parse CHALLENGE_RESPONSE", err))
+		return false, clientID, ReasonMalformedMessage, err
 	}
 
-	if td.Type != "CHALLENGE_RESPONSE" {
-		conn.WriteJSON(map[string]string{
-			"type": "CLIENT_ERROR",
-			"data": "Expected a CHALLENGE_RESPONSE event, but got " + td.Type + "",
+	var passkeyAssertion *assertion
+	if pubKey.webauthn {
+		passkeyAssertion, err = parseAssertion(msg, cfg.base64)
+		if err != nil {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Failed to parse CHALLENGE_RESPONSE", err))
+			return false, clientID, ReasonMalformedMessage, err
+		}
+	} else if secret != nil && len(decodedChallengeResponse) != sha256.Size {
+		conn.WriteJSON(&stringMessage{
+			Type: "SIGNATURE_MISMATCH",
+			Data: "Expected a 32 byte HMAC, but got " + strconv.Itoa(len(decodedChallengeResponse)) + " bytes",
 		})
-		return false, clientID, nil
+		return false, clientID, ReasonSignatureMismatch, nil
+	} else if secret == nil && len(decodedChallengeResponse) != 64 {
+		conn.WriteJSON(&stringMessage{
+			Type: "SIGNATURE_MISMATCH",
+			Data: "Expected a 64 byte signature, but got " + strconv.Itoa(len(decodedChallengeResponse)) + " bytes",
+		})
+		return false, clientID, ReasonSignatureMismatch, nil
 	}
 
-	var challengeResponse struct {
-		Signature string `json:"signature"`
-		Hash      string `json:"hash"`
+	trace.step("VerifySignature")
+
+	start := time.Now()
+	var verified bool
+	if passkeyAssertion != nil {
+		// the authenticator signs what the client would have
+		challenge := signedChallenge(payload, h.audience)
+		verified, err = cfg.webauthn.verify(pubKey.ecdsa, challenge, passkeyAssertion, decodedChallengeResponse)
+		clear(challenge)
+	} else if secret != nil {
+		verified = verifyHMAC(secret, payload, h.audience, decodedChallengeResponse)
+	} else if pubKey.ed25519 != nil {
+		message := signedChallenge(payload, h.audience)
+		verified = ed25519.Verify(pubKey.ed25519, message, decodedChallengeResponse)
+		clear(message)
+	} else {
+		hashedPayload := signedHash(payload, h.audience)
+		verified = verifySignature(pubKey.ecdsa, hashedPayload[:], decodedChallengeResponse)
 	}
-	err = json.Unmarshal(td.Data, &challengeResponse)
+	cfg.metrics.VerificationDuration(time.Since(start))
+
 	if err != nil {
-		conn.WriteJSON(map[string]any{
-			"type": "CLIENT_ERROR",
-			"data": map[string]string{
-				"message": "Failed to parse CHALLENGE_RESPONSE",
-				"error":   err.Error(),
-			},
-		})
-		return false, clientID, err
+		conn.WriteJSON(&stringMessage{Type: "SIGNATURE_MISMATCH", Data: "Invalid WebAuthn assertion: " + err.Error()})
+		return false, clientID, ReasonSignatureMismatch, err
 	}
 
-	if challengeResponse.Hash != "SHA-256" {
-		conn.WriteJSON(map[string]string{
-			"type": "UNSUPPORTED_HASH",
-			"data": "Got hash of type " + challengeResponse.Hash + ", but the only supported hash currently is SHA-256 (more coming soon!)",
-		})
-		return false, clientID, nil
+	if !verified {
+		conn.WriteJSON(&typeMessage{Type: "SIGNATURE_MISMATCH"})
+		return false, clientID, ReasonSignatureMismatch, nil
 	}
 
-	decodedChallengeResponse, err := base64.StdEncoding.DecodeString(challengeResponse.Signature)
+	if cfg.stateless != nil {
+		if ok, reason, err := h.useChallenge(payload); !ok {
+			return false, clientID, reason, err
+		}
+	}
+
+	return h.authenticate("", msg.IDToken, signedChallenge(payload, h.audience))
+}
+
+// sendChallenge sends a CHALLENGE of a fresh challenge, which it leaves in
+// buf, proving the server's identity with it if the client sent a challenge
+// of its own.
+func (h *handshakeState) sendChallenge(buf *buffers, clientChallenge []byte) (bool, FailureReason, error) {
+	conn, cfg := h.conn, h.cfg
+
+	var err error
+	payload := buf.challenge[:]
+	if cfg.challengePool != nil && cfg.challengePool.take(payload) {
+		err = nil
+	} else {
+		err = readRandom(cfg.random, payload)
+	}
 	if err != nil {
-		conn.WriteJSON(map[string]any{
-			"type": "CLIENT_ERROR",
-			"data": map[string]string{
-				"message": "Failed to parse CHALLENGE_RESPONSE",
-				"error":   err.Error(),
-			},
-		})
-		return false, clientID, err
+		conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to generate challenge", err))
+		return false, ReasonServerError, err
+	}
+	if cfg.stateless != nil {
+		if err := cfg.stateless.seal(payload, cfg.audience, h.remoteAddr, cfg.clock.Now()); err != nil {
+			conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to generate challenge", err))
+			return false, ReasonServerError, err
+		}
+	}
+
+	var server *serverProof
+	if cfg.serverKey != nil && clientChallenge != nil {
+		if cfg.fips && cfg.serverKey.ed25519() {
+			err = notApproved("Ed25519 server keys")
+		} else {
+			server, err = cfg.serverKey.prove(clientChallenge, payload, cfg.audience)
+		}
+		if err != nil {
+			conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to sign client challenge", err))
+			return false, ReasonServerError, err
+		}
 	}
 
-	if len(decodedChallengeResponse) != 64 {
-		conn.WriteJSON(map[string]string{
-			"type": "SIGNATURE_MISMATCH",
-			"data": "Expected a 64 byte signature, but got " + strconv.Itoa(len(decodedChallengeResponse)) + " bytes",
+	var work *proofOfWorkData
+	if h.workDifficulty = cfg.proofOfWork.difficulty(h.remoteAddr); h.workDifficulty > 0 {
+		work = &proofOfWorkData{Difficulty: h.workDifficulty}
+	}
+
+	base64.StdEncoding.Encode(buf.encoded[:], payload)
+
+	if cfg.audience == "" {
+		conn.WriteJSON(&challengeMessage{
+			Type:         "CHALLENGE",
+			Data:         string(buf.encoded[:]),
+			Capabilities: cfg.capabilities(),
+			Server:       server,
+			ProofOfWork:  work,
+		})
+	} else {
+		conn.WriteJSON(&audienceChallengeMessage{
+			Type:         "CHALLENGE",
+			Data:         challengeData{Challenge: string(buf.encoded[:]), Audience: cfg.audience},
+			Capabilities: cfg.capabilities(),
+			Server:       server,
+			ProofOfWork:  work,
 		})
-		return false, clientID, nil
 	}
+	return true, "", nil
+}
+
+// lookup checks that the client's key is in the key store, if there is one.
+func (h *handshakeState) lookup() (bool, FailureReason, error) {
+	if h.cfg.keyStore == nil {
+		return true, "", nil
+	}
+
+	known, err := h.cfg.keyStore.Lookup(h.cfg.ctx, h.fingerprint)
+	if err != nil {
+		h.conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to look up client key", err))
+		return false, ReasonServerError, err
+	}
+	if !known && h.cfg.pairing != nil {
+		// the client has to prove it holds the key before it may pair it
+		h.pairing = true
+		return true, "", nil
+	}
+	if !known && h.cfg.guests != nil {
+		h.log.Debug("wskeyauth: client key is not known, so the client is a guest")
+		h.guest = true
+		return true, "", nil
+	}
+	if !known {
+		h.conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonUnknownKey, "Client key is not known", nil))
+		return false, ReasonUnknownKey, nil
+	}
+	return true, "", nil
+}
 
-	r := &big.Int{}
-	s := &big.Int{}
+// authenticate finishes the handshake with a client that proved it holds its
+// key, with the steps that follow, and tells the client it is authenticated.
+// data is the data of SIGNATURE_MATCHES, if any, and idToken the ID token the
+// client presented, if any, which must be bound to challenge, what the client
+// was sent to prove it holds its key with.
+func (h *handshakeState) authenticate(data, idToken string, challenge []byte) (bool, string, FailureReason, error) {
+	conn, cfg, clientID := h.conn, h.cfg, h.clientID
 
-	r.SetBytes(decodedChallengeResponse[:32])
-	s.SetBytes(decodedChallengeResponse[32:])
+	if challenge != nil {
+		h.binding = MessageBinding(challenge)
+	}
+	if h.pairing {
+		return h.pair(clientID)
+	}
 
-	hashedPayload := sha256.Sum256(payload)
+	var token *IDToken
+	if cfg.oidc != nil && idToken != "" {
+		h.trace.step("VerifyIDToken")
 
-	if !ecdsa.Verify(pubKey, hashedPayload[:], r, s) {
-		conn.WriteJSON(map[string]string{
-			"type": "SIGNATURE_MISMATCH",
-		})
-		return false, clientID, nil
+		var err error
+		if challenge == nil {
+			err = errors.New("ID tokens can only be presented in response to a challenge")
+		} else {
+			token, err = cfg.oidc.verify(cfg.ctx, idToken, OIDCNonce(challenge, h.fingerprint), cfg.clock.Now(), cfg.fips)
+		}
+		if errors.Is(err, errFetchKeys) {
+			conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to verify ID token", err))
+			return false, clientID, ReasonServerError, err
+		}
+		if err != nil {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidIDToken, "Invalid ID token", err))
+			return false, clientID, ReasonInvalidIDToken, err
+		}
+	} else if cfg.oidc != nil && cfg.oidc.Required {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidIDToken, "An ID token is required", nil))
+		return false, clientID, ReasonInvalidIDToken, nil
 	}
 
-	conn.WriteJSON(map[string]string{
-		"type": "SIGNATURE_MATCHES",
-	})
+	h.token, h.proofData = token, data
+
+	if cfg.totp != nil {
+		return h.secondFactor()
+	}
+	return h.authenticated()
+}
+
+// authenticated tells a client that passed every step of the handshake it is
+// authenticated, along with whatever it was granted.
+func (h *handshakeState) authenticated() (bool, string, FailureReason, error) {
+	conn, cfg, clientID, token := h.conn, h.cfg, h.clientID, h.token
+
+	var accessToken *AccessToken
+	if cfg.tokenExchange != nil {
+		h.trace.step("ExchangeToken")
+
+		var err error
+		scope := cfg.tokenExchange.Scope
+		if h.guest {
+			scope = cfg.guests.scope()
+		}
+		accessToken, err = cfg.tokenExchange.exchange(cfg.ctx, clientID, h.fingerprint, scope)
+		if err != nil {
+			conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to exchange token", err))
+			return false, clientID, ReasonServerError, err
+		}
+	}
+
+	var macaroon string
+	if cfg.macaroons != nil {
+		var err error
+		var caveats []string
+		if h.guest {
+			caveats = append(caveats, cfg.guests.caveat())
+		}
+		if macaroon, err = cfg.macaroons.mint(clientID, h.fingerprint, caveats...); err != nil {
+			conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to mint macaroon", err))
+			return false, clientID, ReasonServerError, err
+		}
+	}
+
+	if token != nil && cfg.oidc.Link != nil {
+		if err := cfg.oidc.Link(cfg.ctx, h.fingerprint, token); err != nil {
+			conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to link ID token", err))
+			return false, clientID, ReasonServerError, err
+		}
+	}
+
+	matches := &matchesMessage{Type: "SIGNATURE_MATCHES", Data: h.proofData, Token: accessToken, Macaroon: macaroon}
+	if cfg.turn != nil {
+		matches.TURN = cfg.turn.Credentials(h.fingerprint, cfg.clock.Now())
+	}
+	if h.guest {
+		matches.Guest, matches.Scopes = true, cfg.guests.Scopes
+	}
+	conn.WriteJSON(matches)
 
-	return true, clientID, nil
+	return true, clientID, "", nil
 }
@@ -0,0 +1,45 @@
+package wskeyauth
+
+// DefaultReadLimit is the largest message, in bytes, a client may send during
+// the handshake unless WithReadLimit says otherwise. Both client messages fit
+// in a few hundred bytes. Without WithReadLimit the limit stays in place once
+// the handshake is over, so callers that expect larger messages after it set
+// the limit they want themselves.
+const DefaultReadLimit = 4096
+
+// readLimiter is implemented by WebSocket connections that can cap the size of
+// incoming messages, such as gorilla/websocket's.
+type readLimiter interface {
+	SetReadLimit(limit int64)
+}
+
+// WithReadLimit caps the size of the messages the client may send during the
+// handshake at limit bytes, on connections that have a SetReadLimit method.
+// Once the handshake is over, the cap is set to restore, which should be the
+// limit the caller uses for the rest of the connection: WebSocket libraries
+// don't say what their current limit is, so the handshake can't put it back
+// by itself. A restore of 0 means no limit, as it does to gorilla/websocket.
+//
+// A limit of 0 or less leaves the connection's limit alone.
+func WithReadLimit(limit, restore int64) Option {
+	return func(cfg *config) {
+		cfg.readLimit = limit
+		cfg.restoreReadLimit = restore
+		cfg.restoresReads = true
+	}
+}
+
+// limitReads applies the handshake read limit to conn, and returns a function
+// that restores the caller's, if WithReadLimit gave it.
+func limitReads(conn Conn, cfg *config) (restore func()) {
+	l, ok := conn.(readLimiter)
+	if !ok || cfg.readLimit <= 0 {
+		return func() {}
+	}
+
+	l.SetReadLimit(cfg.readLimit)
+	if !cfg.restoresReads {
+		return func() {}
+	}
+	return func() { l.SetReadLimit(cfg.restoreReadLimit) }
+}
@@ -0,0 +1,255 @@
+// Package longpoll runs the ws-key-auth handshake over plain HTTP requests, for
+// clients sitting behind proxies that strip WebSocket upgrades.
+//
+// The client POSTs its protocol messages, and GETs the server's, with both
+// requests correlated by a handshake ID:
+//
+//	POST /           body: {"type":"CLIENT_ID",...}   -> 202 {"handshake":"<id>"}
+//	GET  /?handshake=<id>                              -> 200 {"type":"CHALLENGE",...}
+//	POST /?handshake=<id> body: {"type":"CHALLENGE_RESPONSE",...} -> 202
+//	GET  /?handshake=<id>                              -> 200 {"type":"SIGNATURE_MATCHES"}
+//
+// A GET waits up to PollTimeout for a message, and answers 204 No Content if
+// none arrived, in which case the client simply polls again. Once the
+// handshake is over and its last message has been collected, the handshake ID
+// is forgotten and further requests get 410 Gone.
+//
+// The messages, and the code that validates them, are exactly the ones used
+// on WebSockets: the handler just feeds them to wskeyauth.Handshake.
+package longpoll
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+const (
+	defaultPollTimeout      = 25 * time.Second
+	defaultHandshakeTimeout = 30 * time.Second
+
+	// the protocol messages are tiny, so anything much bigger than this is not
+	// a legitimate client.
+	maxMessageSize = 16 << 10
+)
+
+// ErrHandshakeExpired is returned to the handshake when the client stopped
+// sending messages before HandshakeTimeout elapsed.
+var ErrHandshakeExpired = errors.New("longpoll: handshake expired")
+
+// Handler serves the long-polling transport. The zero value is ready to use.
+type Handler struct {
+	// PollTimeout bounds how long a GET waits for a server message. Defaults to
+	// 25 seconds.
+	PollTimeout time.Duration
+
+	// HandshakeTimeout bounds the whole handshake, after which it is abandoned.
+	// Defaults to 30 seconds.
+	HandshakeTimeout time.Duration
+
+	// Options are the options of the handshake, as for the WebSocket one.
+	// HandshakeTimeout takes the place of any WithTimeout among them.
+	Options []wskeyauth.Option
+
+	// OnAuthenticated, if set, is called with the handshake ID and client ID of
+	// every handshake that succeeds.
+	OnAuthenticated func(handshakeID, clientID string)
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+func (h *Handler) pollTimeout() time.Duration {
+	if h.PollTimeout > 0 {
+		return h.PollTimeout
+	}
+	return defaultPollTimeout
+}
+
+func (h *Handler) handshakeTimeout() time.Duration {
+	if h.HandshakeTimeout > 0 {
+		return h.HandshakeTimeout
+	}
+	return defaultHandshakeTimeout
+}
+
+// options returns the options of the handshake, bounded by
+// handshakeTimeout.
+func (h *Handler) options() []wskeyauth.Option {
+	opts := append([]wskeyauth.Option{}, h.Options...)
+	return append(opts, wskeyauth.WithTimeout(h.handshakeTimeout()))
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.post(w, r)
+	case http.MethodGet:
+		h.get(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) post(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, "failed to read message", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !json.Valid(body) {
+		http.Error(w, "message is not valid JSON", http.StatusBadRequest)
+		return
+	}
+
+	id := r.URL.Query().Get("handshake")
+	if id == "" {
+		id, err = h.start()
+		if err != nil {
+			http.Error(w, "failed to start handshake", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	s := h.session(id)
+	if s == nil {
+		http.Error(w, "unknown handshake", http.StatusGone)
+		return
+	}
+
+	select {
+	case s.toServer <- body:
+	case <-s.finished:
+		http.Error(w, "handshake is over", http.StatusConflict)
+		return
+	default:
+		http.Error(w, "a message is already pending", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"handshake": id})
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("handshake")
+	s := h.session(id)
+	if s == nil {
+		http.Error(w, "unknown handshake", http.StatusGone)
+		return
+	}
+
+	timer := time.NewTimer(h.pollTimeout())
+	defer timer.Stop()
+
+	select {
+	case msg := <-s.toClient:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(msg)
+	case <-s.finished:
+		// the handshake may have queued its final message just before finishing
+		select {
+		case msg := <-s.toClient:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(msg)
+		default:
+			h.forget(id)
+			http.Error(w, "handshake is over", http.StatusGone)
+		}
+	case <-timer.C:
+		w.WriteHeader(http.StatusNoContent)
+	case <-r.Context().Done():
+	}
+}
+
+func (h *Handler) start() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+
+	s := &session{
+		toServer: make(chan []byte, 1),
+		toClient: make(chan []byte, 4),
+		finished: make(chan struct{}),
+		expires:  time.Now().Add(h.handshakeTimeout()),
+	}
+
+	h.mu.Lock()
+	if h.sessions == nil {
+		h.sessions = map[string]*session{}
+	}
+	h.sessions[id] = s
+	h.mu.Unlock()
+
+	go func() {
+		authenticated, clientID, err := wskeyauth.Handshake(s, h.options()...)
+		close(s.finished)
+
+		if authenticated && err == nil && h.OnAuthenticated != nil {
+			h.OnAuthenticated(id, clientID)
+		}
+
+		// give the client a last chance to collect the final message, and then
+		// clean up after clients that never do
+		time.AfterFunc(h.pollTimeout(), func() { h.forget(id) })
+	}()
+
+	return id, nil
+}
+
+func (h *Handler) session(id string) *session {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sessions[id]
+}
+
+func (h *Handler) forget(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions, id)
+}
+
+// session is the wskeyauth.Conn of one long-polling handshake.
+type session struct {
+	toServer chan []byte
+	toClient chan []byte
+	finished chan struct{}
+	expires  time.Time
+}
+
+func (s *session) ReadJSON(v any) error {
+	timer := time.NewTimer(time.Until(s.expires))
+	defer timer.Stop()
+
+	select {
+	case msg := <-s.toServer:
+		return json.Unmarshal(msg, v)
+	case <-timer.C:
+		return ErrHandshakeExpired
+	}
+}
+
+func (s *session) WriteJSON(v any) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case s.toClient <- msg:
+		return nil
+	default:
+		return errors.New("longpoll: client is not collecting messages")
+	}
+}
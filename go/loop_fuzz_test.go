@@ -0,0 +1,74 @@
+package wskeyauth_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+// FuzzHandshake plays the client's side of a handshake from the fuzzer's
+// input, one JSON message per line, over a pipe, and checks that the server
+// sees it through, whatever the messages, without panicking or hanging.
+// Challenges are drawn from a seeded source, so that the seed corpus can hold
+// valid handshakes.
+func FuzzHandshake(f *testing.F) {
+	key := wskeyauthtest.KeyFromSeed("fuzz")
+
+	// a valid handshake, to learn the challenge the seeded source makes
+	server, client := wskeyauthtest.Pipe()
+	done := make(chan wskeyauthtest.Result, 1)
+	go func() {
+		result, _ := (&wskeyauthtest.Client{Key: key}).Run(client)
+		done <- result
+	}()
+	if ok, _, err := wskeyauth.Handshake(server, wskeyauth.WithRandom(wskeyauthtest.NewRandom("fuzz"))); !ok {
+		f.Fatalf("seed handshake failed: %v", err)
+	}
+	var challenge string
+	if err := json.Unmarshal((<-done).Messages[0].Data, &challenge); err != nil {
+		f.Fatal(err)
+	}
+	signature, err := key.SignChallenge(challenge)
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	clientID, _ := json.Marshal(map[string]any{"type": "CLIENT_ID", "data": key.ClientID()})
+	response, _ := json.Marshal(map[string]any{"type": "CHALLENGE_RESPONSE", "data": map[string]string{"signature": signature, "hash": "SHA-256"}})
+	f.Add([]byte(string(clientID) + "\n" + string(response)))
+	f.Add([]byte(string(clientID) + "\n" + string(clientID)))
+	f.Add([]byte(string(response)))
+	f.Add([]byte(`{"type":"CLIENT_ID","data":"did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"}` + "\n" + `{"type":"CHALLENGE_RESPONSE","data":{"signature":"","hash":"SHA-512"}}`))
+	f.Add([]byte(`{"type":"NOISE","data":"AAAA"}`))
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		server, client := wskeyauthtest.Pipe()
+		go func() {
+			for _, line := range bytes.Split(input, []byte("\n")) {
+				if json.Valid(line) {
+					client.WriteJSON(json.RawMessage(line))
+				}
+			}
+			// the server still reads what was written before
+			client.Close()
+		}()
+
+		finished := make(chan struct{})
+		go func() {
+			defer close(finished)
+			wskeyauth.HandshakeResult(server,
+				wskeyauth.WithRandom(wskeyauthtest.NewRandom("fuzz")),
+				wskeyauth.WithTimeout(time.Second))
+			server.Close()
+		}()
+		select {
+		case <-finished:
+		case <-time.After(5 * time.Second):
+			t.Fatal("handshake didn't finish")
+		}
+	})
+}
@@ -0,0 +1,276 @@
+package wskeyauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// With WithMacaroons, the server hands every client it authenticates a
+// macaroon, in a "macaroon" member of SIGNATURE_MATCHES:
+//
+//	{"type": "SIGNATURE_MATCHES", "macaroon": "eyJpZCI6..."}
+//
+// A macaroon is a bearer token for whatever its caveats allow, such as
+//
+//	fingerprint = SHA256:3q2+7w...
+//	expires < 2006-01-02T15:04:05Z
+//	action = read
+//
+// which anyone holding it can narrow further with AttenuateMacaroon, with no
+// need for the server's secret, before handing it on, say, to a service
+// acting on the client's behalf. Every caveat must hold for the macaroon to
+// verify, so caveats can be added, but not taken away: each is chained into
+// its HMAC signature.
+//
+// Macaroons are bound to the fingerprint of the client they were handed to,
+// and expire; downstream services verify them with the same Macaroons, and
+// check the caveats they understand.
+
+// DefaultMacaroonTTL is how long macaroons are valid unless Macaroons.TTL
+// says otherwise.
+const DefaultMacaroonTTL = time.Hour
+
+var (
+	// ErrInvalidMacaroon is returned for macaroons that are malformed, or
+	// weren't minted with the secret they're verified with.
+	ErrInvalidMacaroon = errors.New("wskeyauth: invalid macaroon")
+
+	// ErrMacaroonExpired is returned for macaroons past one of their expires
+	// caveats.
+	ErrMacaroonExpired = errors.New("wskeyauth: macaroon has expired")
+)
+
+// Macaroons mints and verifies macaroons.
+type Macaroons struct {
+	// Secret is the root key macaroons are signed with, at least 32 bytes.
+	// Anyone who knows it can mint macaroons.
+	Secret []byte
+
+	// TTL is how long macaroons are valid. It defaults to
+	// DefaultMacaroonTTL.
+	TTL time.Duration
+
+	// Caveats, if set, returns further caveats for the macaroon of the
+	// client with clientID and fingerprint, such as which of its accounts it
+	// may act on.
+	Caveats func(clientID, fingerprint string) ([]string, error)
+
+	// Random is where macaroon IDs are drawn from. It defaults to
+	// crypto/rand.
+	Random io.Reader
+
+	// Clock tells when macaroons expire. It defaults to SystemClock.
+	Clock Clock
+}
+
+// WithMacaroons hands every authenticated client a macaroon minted by m.
+func WithMacaroons(m *Macaroons) Option {
+	return func(cfg *config) {
+		cfg.macaroons = m
+	}
+}
+
+// Macaroon is a macaroon that verified.
+type Macaroon struct {
+	// Fingerprint is the fingerprint of the client it was minted for.
+	Fingerprint string
+
+	// Expires is the earliest of its expires caveats.
+	Expires time.Time
+
+	// Caveats are all of its caveats, including the fingerprint and expires
+	// ones.
+	Caveats []string
+}
+
+// macaroonToken is a macaroon as it is encoded, before base64url.
+type macaroonToken struct {
+	ID        []byte   `json:"id"`
+	Caveats   []string `json:"caveats"`
+	Signature []byte   `json:"signature"`
+}
+
+func (m *Macaroons) rootKey() ([]byte, error) {
+	if len(m.Secret) < 32 {
+		return nil, errors.New("wskeyauth: Macaroons.Secret must be at least 32 bytes")
+	}
+	// keeps macaroon signatures apart from those of anything else the secret
+	// might be used for
+	return macaroonMAC(m.Secret, []byte("wskeyauth macaroon")), nil
+}
+
+func macaroonMAC(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// Mint mints a macaroon for the client with fingerprint, restricted by
+// caveats.
+func (m *Macaroons) Mint(fingerprint string, caveats ...string) (string, error) {
+	key, err := m.rootKey()
+	if err != nil {
+		return "", err
+	}
+
+	ttl := m.TTL
+	if ttl <= 0 {
+		ttl = DefaultMacaroonTTL
+	}
+
+	t := &macaroonToken{ID: make([]byte, 16)}
+	if err := readRandom(randomOr(m.Random), t.ID); err != nil {
+		return "", err
+	}
+	t.Signature = macaroonMAC(key, t.ID)
+
+	all := append([]string{
+		"fingerprint = " + fingerprint,
+		"expires < " + clockOr(m.Clock).Now().Add(ttl).UTC().Format(time.RFC3339),
+	}, caveats...)
+	if err := t.attenuate(all); err != nil {
+		return "", err
+	}
+	return t.encode()
+}
+
+// mint mints the macaroon handed to a client on authenticating it.
+func (m *Macaroons) mint(clientID, fingerprint string, caveats ...string) (string, error) {
+	if m.Caveats != nil {
+		more, err := m.Caveats(clientID, fingerprint)
+		if err != nil {
+			return "", err
+		}
+		caveats = append(caveats, more...)
+	}
+	return m.Mint(fingerprint, caveats...)
+}
+
+// AttenuateMacaroon narrows macaroon down with caveats.
+func AttenuateMacaroon(macaroon string, caveats ...string) (string, error) {
+	t, err := decodeMacaroon(macaroon)
+	if err != nil {
+		return "", err
+	}
+	if err := t.attenuate(caveats); err != nil {
+		return "", err
+	}
+	return t.encode()
+}
+
+func (t *macaroonToken) attenuate(caveats []string) error {
+	for _, caveat := range caveats {
+		if _, _, ok := splitCaveat(caveat); !ok {
+			return fmt.Errorf("wskeyauth: caveat %q isn't of the form \"key op value\"", caveat)
+		}
+		t.Caveats = append(t.Caveats, caveat)
+		t.Signature = macaroonMAC(t.Signature, []byte(caveat))
+	}
+	return nil
+}
+
+func (t *macaroonToken) encode() (string, error) {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeMacaroon(macaroon string) (*macaroonToken, error) {
+	b, err := base64.RawURLEncoding.DecodeString(macaroon)
+	if err != nil {
+		return nil, ErrInvalidMacaroon
+	}
+	var t macaroonToken
+	if err := json.Unmarshal(b, &t); err != nil || len(t.ID) == 0 {
+		return nil, ErrInvalidMacaroon
+	}
+	return &t, nil
+}
+
+// splitCaveat splits a caveat into its key, and the rest of it.
+func splitCaveat(caveat string) (key, rest string, ok bool) {
+	key, rest, ok = strings.Cut(caveat, " ")
+	return key, rest, ok && key != "" && rest != ""
+}
+
+// Verify checks that macaroon was minted with m's secret, hasn't expired, and
+// is bound to a single fingerprint. check is called with every other caveat,
+// and returns an error unless it holds for the request at hand; unknown
+// caveats must fail it, or the restrictions of whoever added them would be
+// lost. A nil check fails every other caveat.
+func (m *Macaroons) Verify(macaroon string, check func(caveat string) error) (*Macaroon, error) {
+	key, err := m.rootKey()
+	if err != nil {
+		return nil, err
+	}
+	t, err := decodeMacaroon(macaroon)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := macaroonMAC(key, t.ID)
+	for _, caveat := range t.Caveats {
+		sig = macaroonMAC(sig, []byte(caveat))
+	}
+	if !hmac.Equal(sig, t.Signature) {
+		return nil, ErrInvalidMacaroon
+	}
+
+	now := clockOr(m.Clock).Now()
+	verified := &Macaroon{Caveats: t.Caveats}
+	for _, caveat := range t.Caveats {
+		key, rest, _ := splitCaveat(caveat)
+		switch key {
+		case "fingerprint":
+			fp, ok := strings.CutPrefix(rest, "= ")
+			if !ok || (verified.Fingerprint != "" && fp != verified.Fingerprint) {
+				return nil, fmt.Errorf("wskeyauth: macaroon caveat %q doesn't hold", caveat)
+			}
+			verified.Fingerprint = fp
+		case "expires":
+			at, ok := strings.CutPrefix(rest, "< ")
+			expires, err := time.Parse(time.RFC3339, at)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("wskeyauth: macaroon caveat %q doesn't hold", caveat)
+			}
+			if !now.Before(expires) {
+				return nil, ErrMacaroonExpired
+			}
+			if verified.Expires.IsZero() || expires.Before(verified.Expires) {
+				verified.Expires = expires
+			}
+		default:
+			if check == nil {
+				return nil, fmt.Errorf("wskeyauth: macaroon caveat %q doesn't hold", caveat)
+			}
+			if err := check(caveat); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if verified.Fingerprint == "" || verified.Expires.IsZero() {
+		return nil, ErrInvalidMacaroon
+	}
+	return verified, nil
+}
+
+// VerifyRequest verifies the macaroon of r, given as a bearer token in its
+// Authorization header, as Verify does.
+func (m *Macaroons) VerifyRequest(r *http.Request, check func(caveat string) error) (*Macaroon, error) {
+	macaroon, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, ErrInvalidMacaroon
+	}
+	return m.Verify(macaroon, check)
+}
@@ -0,0 +1,27 @@
+package wskeyauth_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+func TestMacaroonsExpireByClock(t *testing.T) {
+	clock := wskeyauthtest.NewClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	m := &wskeyauth.Macaroons{Secret: clusterSecret, TTL: time.Hour, Clock: clock}
+
+	macaroon, err := m.Mint("fingerprint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Verify(macaroon, nil); err != nil {
+		t.Fatalf("Verify() of a fresh macaroon = %v", err)
+	}
+	clock.Advance(time.Hour + time.Second)
+	if _, err := m.Verify(macaroon, nil); !errors.Is(err, wskeyauth.ErrMacaroonExpired) {
+		t.Fatalf("Verify() once expired = %v, want ErrMacaroonExpired", err)
+	}
+}
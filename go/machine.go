@@ -0,0 +1,216 @@
+package wskeyauth
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// State is where a ServerHandshake is.
+type State int
+
+const (
+	// StateAwaitingMessage means the handshake waits for the client's next
+	// message, to be given to Feed.
+	StateAwaitingMessage State = iota
+
+	// StateAuthenticated means the handshake is over, and the client was
+	// authenticated.
+	StateAuthenticated
+
+	// StateFailed means the handshake is over, and failed.
+	StateFailed
+)
+
+// ErrHandshakeOver is returned by ServerHandshake.Feed once the handshake is
+// over.
+var ErrHandshakeOver = errors.New("wskeyauth: handshake is over")
+
+var errHandshakeAbandoned = errors.New("wskeyauth: handshake was abandoned")
+
+// ServerHandshake is the server's end of a handshake, without a connection:
+// messages from the client are fed to it, and it returns the messages to send
+// back, for transports that aren't a Conn, or read loops that can't hand the
+// connection over to Handshake:
+//
+//	hs := wskeyauth.NewServerHandshake(opts...)
+//	out, state, err := hs.Start()
+//	for state == wskeyauth.StateAwaitingMessage {
+//		send(out)
+//		out, state, err = hs.Feed(<-messages)
+//	}
+//	send(out)
+//	if state == wskeyauth.StateAuthenticated {
+//		clientID := hs.Result().ClientID
+//		...
+//	}
+//
+// It runs the same handshake as Handshake, with the same options, save for
+// WithTimeout: it is for the caller to bound how long it waits for the
+// client, and to Close the handshake if it gives up, and the context of
+// WithContext shouldn't have a deadline. The client's address is taken from
+// WithRequest, if given.
+//
+// The handshake advances only as it is fed, never on its own, so that tests
+// can step through it a message at a time: each call runs the handshake's
+// steps, on the caller's goroutine, until it needs the client's next message
+// or ends. A ServerHandshake is safe for concurrent use, though messages fed
+// concurrently arrive in no particular order.
+type ServerHandshake struct {
+	opts []Option
+
+	mu sync.Mutex
+	h  *handshakeState
+
+	// pending is the message fed to the handshake, if fed, that it is yet
+	// to read, and out what it sent since it was last flushed.
+	pending json.RawMessage
+	fed     bool
+	closed  bool
+	out     []json.RawMessage
+
+	started bool
+	over    bool
+	result  *Result
+	err     error
+
+	// ended, if set, is called once the handshake is over, as by
+	// Authenticator.Start.
+	ended func(*Result)
+}
+
+// errSuspended is returned by the reads of a ServerHandshake that wasn't fed
+// the message they are for yet.
+var errSuspended = errors.New("wskeyauth: handshake awaits a message")
+
+// NewServerHandshake creates the server's end of a handshake with opts.
+func NewServerHandshake(opts ...Option) *ServerHandshake {
+	return &ServerHandshake{opts: append(append([]Option{}, opts...), WithTimeout(0))}
+}
+
+// Start starts the handshake, and returns what the server sends before the
+// client's first message, which is nothing unless WithChallengeFirst is used.
+func (s *ServerHandshake) Start() ([]json.RawMessage, State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return nil, s.state(), errors.New("wskeyauth: handshake was started already")
+	}
+	s.started = true
+
+	s.h = newHandshakeState(machineConn{s}, s.opts)
+	if result, err := s.h.begin(); result != nil {
+		s.end(result, err)
+		return s.flush(), s.state(), s.err
+	}
+	s.h.next = (*handshakeState).run
+	s.advance()
+	return s.flush(), s.state(), s.err
+}
+
+// Feed gives the handshake the client's next message, and returns what the
+// server sends back. Once the state is no longer StateAwaitingMessage, the
+// handshake is over, and the error is that of Handshake.
+func (s *ServerHandshake) Feed(msg json.RawMessage) ([]json.RawMessage, State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		return nil, StateFailed, errors.New("wskeyauth: handshake wasn't started")
+	}
+	if s.over {
+		return nil, s.state(), ErrHandshakeOver
+	}
+
+	s.pending, s.fed = msg, true
+	s.advance()
+	return s.flush(), s.state(), s.err
+}
+
+// Result returns the outcome of the handshake, once it is over, or nil.
+func (s *ServerHandshake) Result() *Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.over {
+		return nil
+	}
+	return s.result
+}
+
+// Close abandons the handshake, if it isn't over. It fails as if the
+// connection had been closed.
+func (s *ServerHandshake) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.started && !s.over {
+		s.advance()
+	}
+	return nil
+}
+
+// advance runs the handshake's step, and those it hands on to, until one
+// awaits a message that wasn't fed yet, or the handshake ends.
+func (s *ServerHandshake) advance() {
+	authenticated, clientID, reason, err := s.h.next(s.h)
+	if errors.Is(err, errSuspended) {
+		return
+	}
+	s.end(s.h.finish(authenticated, clientID, reason, err))
+}
+
+// end ends the handshake with its result.
+func (s *ServerHandshake) end(result *Result, err error) {
+	s.over, s.result, s.err = true, result, err
+	if s.ended != nil {
+		s.ended(result)
+	}
+}
+
+func (s *ServerHandshake) state() State {
+	switch {
+	case !s.over:
+		return StateAwaitingMessage
+	case s.result.Authenticated:
+		return StateAuthenticated
+	}
+	return StateFailed
+}
+
+// flush returns what the handshake sent since it was last flushed.
+func (s *ServerHandshake) flush() []json.RawMessage {
+	out := s.out
+	s.out = nil
+	return out
+}
+
+// machineConn is the connection a ServerHandshake runs the handshake over,
+// with the ServerHandshake locked. Reading takes the message it was fed, if
+// any, and suspends the handshake otherwise.
+type machineConn struct {
+	s *ServerHandshake
+}
+
+func (c machineConn) ReadJSON(v any) error {
+	if c.s.closed {
+		return errHandshakeAbandoned
+	}
+	if !c.s.fed {
+		return errSuspended
+	}
+	msg := c.s.pending
+	c.s.pending, c.s.fed = nil, false
+	return json.Unmarshal(msg, v)
+}
+
+func (c machineConn) WriteJSON(v any) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.s.out = append(c.s.out, msg)
+	return nil
+}
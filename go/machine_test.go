@@ -0,0 +1,123 @@
+package wskeyauth_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+func marshal(t *testing.T, msg any) json.RawMessage {
+	t.Helper()
+	b, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// only returns the one message in out, parsed.
+func only(t *testing.T, out []json.RawMessage) wskeyauth.TypeData {
+	t.Helper()
+	if len(out) != 1 {
+		t.Fatalf("got %d messages, want 1: %s", len(out), out)
+	}
+	var td wskeyauth.TypeData
+	if err := json.Unmarshal(out[0], &td); err != nil {
+		t.Fatal(err)
+	}
+	return td
+}
+
+// respond signs the challenge of a CHALLENGE with key.
+func respond(t *testing.T, key *wskeyauthtest.Key, challenge wskeyauth.TypeData) json.RawMessage {
+	t.Helper()
+	var data string
+	if err := json.Unmarshal(challenge.Data, &data); err != nil {
+		t.Fatalf("CHALLENGE data %s: %v", challenge.Data, err)
+	}
+	sig, err := key.SignChallengeFor(data, wskeyauth.SignedAudience("", key.ClientID()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return marshal(t, map[string]any{
+		"type": "CHALLENGE_RESPONSE",
+		"data": map[string]string{"signature": sig, "hash": "SHA-256"},
+	})
+}
+
+func TestServerHandshake(t *testing.T) {
+	key := wskeyauthtest.MustGenerateKey()
+	hs := wskeyauth.NewServerHandshake()
+
+	out, state, err := hs.Start()
+	if len(out) != 0 || state != wskeyauth.StateAwaitingMessage || err != nil {
+		t.Fatalf("Start() = %s, %v, %v", out, state, err)
+	}
+	if hs.Result() != nil {
+		t.Fatal("Result() isn't nil before the handshake is over")
+	}
+
+	out, state, err = hs.Feed(marshal(t, map[string]any{"type": "CLIENT_ID", "data": key.ClientID()}))
+	if state != wskeyauth.StateAwaitingMessage || err != nil {
+		t.Fatalf("Feed(CLIENT_ID) = %v, %v", state, err)
+	}
+	challenge := only(t, out)
+	if challenge.Type != "CHALLENGE" {
+		t.Fatalf("got %s, want a CHALLENGE", challenge.Type)
+	}
+
+	out, state, err = hs.Feed(respond(t, key, challenge))
+	if state != wskeyauth.StateAuthenticated || err != nil {
+		t.Fatalf("Feed(CHALLENGE_RESPONSE) = %v, %v", state, err)
+	}
+	if typ := only(t, out).Type; typ != "SIGNATURE_MATCHES" {
+		t.Fatalf("got %s, want SIGNATURE_MATCHES", typ)
+	}
+	if result := hs.Result(); !result.Authenticated || result.ClientID != key.ClientID() {
+		t.Fatalf("Result() = %+v", result)
+	}
+
+	if _, _, err := hs.Feed(marshal(t, map[string]any{"type": "CLIENT_ID"})); !errors.Is(err, wskeyauth.ErrHandshakeOver) {
+		t.Fatalf("Feed() once over = %v, want ErrHandshakeOver", err)
+	}
+}
+
+func TestServerHandshakeRetries(t *testing.T) {
+	key := wskeyauthtest.MustGenerateKey()
+	hs := wskeyauth.NewServerHandshake(wskeyauth.WithRetries(1))
+	hs.Start()
+
+	// the malformed message is answered, and the handshake waits for the
+	// client to resend it, from the same step
+	out, state, _ := hs.Feed(json.RawMessage(`{"type":"CHALLENGE_RESPONSE"}`))
+	if td := only(t, out); td.Type != "CLIENT_ERROR" || state != wskeyauth.StateAwaitingMessage {
+		t.Fatalf("Feed() = %s, %v, want a CLIENT_ERROR to retry", out, state)
+	}
+
+	out, _, _ = hs.Feed(marshal(t, map[string]any{"type": "CLIENT_ID", "data": key.ClientID()}))
+	out, state, err := hs.Feed(respond(t, key, only(t, out)))
+	if state != wskeyauth.StateAuthenticated || err != nil {
+		t.Fatalf("Feed(CHALLENGE_RESPONSE) = %s, %v, %v", out, state, err)
+	}
+}
+
+func TestServerHandshakeClose(t *testing.T) {
+	key := wskeyauthtest.MustGenerateKey()
+	hs := wskeyauth.NewServerHandshake()
+	hs.Start()
+	hs.Feed(marshal(t, map[string]any{"type": "CLIENT_ID", "data": key.ClientID()}))
+
+	if err := hs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	result := hs.Result()
+	if result == nil || result.Authenticated || result.Reason != wskeyauth.ReasonReadFailed {
+		t.Fatalf("Result() once closed = %+v", result)
+	}
+	if err := hs.Close(); err != nil {
+		t.Fatalf("second Close() = %v", err)
+	}
+}
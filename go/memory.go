@@ -0,0 +1,60 @@
+package wskeyauth
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// WithLockedMemory keeps the handshake's challenge, and the signature of it
+// the client sent back, in memory locked into RAM, so that they are never
+// written to swap, for deployments with strict memory hygiene requirements.
+// Locked memory is taken from the system a page at a time, and kept for
+// later handshakes; if it can't be, as when RLIMIT_MEMLOCK is too low, the
+// handshake fails with SERVER_ERROR. It is only supported on Linux and
+// macOS; elsewhere every handshake fails.
+//
+// The challenge and signature are zeroed once the handshake is over, whether
+// their memory is locked or not. The strings the challenge is sent as are
+// left to the garbage collector, as strings can't be zeroed.
+func WithLockedMemory() Option {
+	return func(cfg *config) {
+		cfg.lockedMemory = true
+	}
+}
+
+// lockedBuffers are the buffers carved out of locked memory, for handshakes
+// using WithLockedMemory, that no handshake is using.
+var lockedBuffers struct {
+	mu   sync.Mutex
+	free []*buffers
+}
+
+func getLockedBuffers() (*buffers, error) {
+	lockedBuffers.mu.Lock()
+	defer lockedBuffers.mu.Unlock()
+
+	if len(lockedBuffers.free) == 0 {
+		mem, err := lockMemory()
+		if err != nil {
+			return nil, err
+		}
+		// buffers holds nothing but bytes, so it may live outside of the
+		// Go heap
+		size := int(unsafe.Sizeof(buffers{}))
+		for off := 0; off+size <= len(mem); off += size {
+			b := (*buffers)(unsafe.Pointer(&mem[off]))
+			b.locked = true
+			lockedBuffers.free = append(lockedBuffers.free, b)
+		}
+	}
+
+	b := lockedBuffers.free[len(lockedBuffers.free)-1]
+	lockedBuffers.free = lockedBuffers.free[:len(lockedBuffers.free)-1]
+	return b, nil
+}
+
+func putLockedBuffers(b *buffers) {
+	lockedBuffers.mu.Lock()
+	defer lockedBuffers.mu.Unlock()
+	lockedBuffers.free = append(lockedBuffers.free, b)
+}
@@ -0,0 +1,21 @@
+//go:build linux || darwin
+
+package wskeyauth
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// lockMemory maps a page of memory, and locks it into RAM.
+func lockMemory() ([]byte, error) {
+	mem, err := syscall.Mmap(-1, 0, syscall.Getpagesize(), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, fmt.Errorf("wskeyauth: failed to map memory to lock: %w", err)
+	}
+	if err := syscall.Mlock(mem); err != nil {
+		syscall.Munmap(mem)
+		return nil, fmt.Errorf("wskeyauth: failed to lock memory: %w", err)
+	}
+	return mem, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package wskeyauth
+
+import (
+	"errors"
+	"runtime"
+)
+
+func lockMemory() ([]byte, error) {
+	return nil, errors.New("wskeyauth: locked memory is not supported on " + runtime.GOOS)
+}
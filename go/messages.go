@@ -0,0 +1,126 @@
+package wskeyauth
+
+import "sync"
+
+//go:generate go run ./internal/tsgen -o ../browser/src/protocol.gen.ts
+
+// The messages we send are typed, rather than built out of maps, so that
+// encoding them doesn't allocate a map and box every value on each handshake.
+
+type typeMessage struct {
+	Type  string `json:"type"`
+	Retry bool   `json:"retry,omitempty"`
+}
+
+type stringMessage struct {
+	Type  string `json:"type"`
+	Data  string `json:"data"`
+	Retry bool   `json:"retry,omitempty"`
+}
+
+type errorData struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+	Error   string `json:"error,omitempty"`
+}
+
+type errorMessage struct {
+	Type  string    `json:"type"`
+	Data  errorData `json:"data"`
+	Retry bool      `json:"retry,omitempty"`
+}
+
+// newErrorMessage returns an error of typ, with the code of reason, why the
+// handshake failed or the message was turned away.
+func newErrorMessage(typ string, reason FailureReason, message string, err error) *errorMessage {
+	m := &errorMessage{Type: typ, Data: errorData{Code: reason.Code(), Message: message}}
+	if err != nil {
+		m.Data.Error = err.Error()
+	}
+	return m
+}
+
+type challengeMessage struct {
+	Type         string        `json:"type"`
+	Data         string        `json:"data"`
+	Capabilities *Capabilities `json:"capabilities"`
+	Server       *serverProof  `json:"server,omitempty"`
+
+	ProofOfWork *proofOfWorkData `json:"proofOfWork,omitempty"`
+}
+
+type matchesMessage struct {
+	Type  string       `json:"type"`
+	Data  string       `json:"data,omitempty"`
+	Token *AccessToken `json:"token,omitempty"`
+
+	Macaroon string           `json:"macaroon,omitempty"`
+	TURN     *TURNCredentials `json:"turn,omitempty"`
+
+	Guest  bool     `json:"guest,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+type challengeResponse struct {
+	Signature         string `json:"signature"`
+	Hash              string `json:"hash"`
+	AuthenticatorData string `json:"authenticatorData"`
+	ClientDataJSON    string `json:"clientDataJSON"`
+	Ephemeral         string `json:"ephemeral"`
+	IDToken           string `json:"idToken"`
+	ProofOfWork       string `json:"proofOfWork"`
+	Challenge         string `json:"challenge"`
+}
+
+func (r *challengeResponse) copyTo(msg *ClientMessage) {
+	msg.Signature, msg.Hash = r.Signature, r.Hash
+	msg.AuthenticatorData, msg.ClientDataJSON = r.AuthenticatorData, r.ClientDataJSON
+	msg.Ephemeral, msg.IDToken = r.Ephemeral, r.IDToken
+	msg.ProofOfWork, msg.EchoedChallenge = r.ProofOfWork, r.Challenge
+}
+
+// buffers holds everything a handshake needs scratch space for. They are
+// pooled, since on busy servers the handshake is hot enough for its garbage
+// to show up in GC pauses.
+type buffers struct {
+	challenge [challengeByteLength]byte
+	encoded   [challengeEncodedLength]byte
+
+	// signature has room for the signatures we accept, plus slack so that
+	// slightly-too-long signatures can still be reported accurately.
+	signature [signatureBufferLength]byte
+
+	// locked is set for buffers in locked memory, as WithLockedMemory has
+	// them.
+	locked bool
+}
+
+const (
+	challengeEncodedLength = (challengeByteLength + 2) / 3 * 4
+	signatureBufferLength  = 96
+)
+
+var buffersPool = sync.Pool{
+	New: func() any { return new(buffers) },
+}
+
+func getBuffers(locked bool) (*buffers, error) {
+	if locked {
+		return getLockedBuffers()
+	}
+	return buffersPool.Get().(*buffers), nil
+}
+
+// putBuffers zeroes b, so that nothing of a handshake outlives it, and
+// returns it to where it came from.
+func putBuffers(b *buffers) {
+	clear(b.challenge[:])
+	clear(b.encoded[:])
+	clear(b.signature[:])
+
+	if b.locked {
+		putLockedBuffers(b)
+	} else {
+		buffersPool.Put(b)
+	}
+}
@@ -0,0 +1,129 @@
+package wskeyauth
+
+import (
+	"sort"
+	"time"
+)
+
+// MetadataKey attaches values of type T to sessions, such as the name of a
+// client's device, or the scopes it was granted:
+//
+//	var DeviceName = wskeyauth.NewMetadataKey[string]("device")
+//
+//	DeviceName.Set(session, "Alice's phone")
+//	name, ok := DeviceName.Get(session)
+//
+// Keys are told apart by identity, not by name, so packages can't clash by
+// picking the same name. The name is what the value appears as in snapshots.
+type MetadataKey[T any] struct {
+	name string
+}
+
+// NewMetadataKey creates a key for values of type T, which appear under name
+// in snapshots. Create keys once, as package variables, rather than per
+// session: a new key doesn't see the values attached with another.
+func NewMetadataKey[T any](name string) *MetadataKey[T] {
+	return &MetadataKey[T]{name: name}
+}
+
+// Name returns the name the key was created with.
+func (k *MetadataKey[T]) Name() string {
+	return k.name
+}
+
+// Set attaches v to s, replacing any value attached before.
+func (k *MetadataKey[T]) Set(s *Session, v T) {
+	s.metadataMu.Lock()
+	defer s.metadataMu.Unlock()
+
+	if s.metadata == nil {
+		s.metadata = map[any]metadataValue{}
+	}
+	s.metadata[k] = metadataValue{name: k.name, value: v}
+}
+
+// Get returns the value attached to s, if there is one.
+func (k *MetadataKey[T]) Get(s *Session) (T, bool) {
+	s.metadataMu.RLock()
+	defer s.metadataMu.RUnlock()
+
+	v, ok := s.metadata[k]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return v.value.(T), true
+}
+
+// Delete removes the value attached to s, if there is one.
+func (k *MetadataKey[T]) Delete(s *Session) {
+	s.metadataMu.Lock()
+	defer s.metadataMu.Unlock()
+	delete(s.metadata, k)
+}
+
+type metadataValue struct {
+	name  string
+	value any
+}
+
+// Metadata returns the values attached to s, by the names of their keys. If
+// keys share a name, one of their values is returned.
+func (s *Session) Metadata() map[string]any {
+	s.metadataMu.RLock()
+	defer s.metadataMu.RUnlock()
+
+	metadata := make(map[string]any, len(s.metadata))
+	for _, v := range s.metadata {
+		metadata[v.name] = v.value
+	}
+	return metadata
+}
+
+// SessionSnapshot describes a session at some point in time, for admin and
+// inspection APIs. It encodes to JSON as is.
+type SessionSnapshot struct {
+	ClientID    string         `json:"clientId"`
+	Fingerprint string         `json:"fingerprint"`
+	RemoteAddr  string         `json:"remoteAddr,omitempty"`
+	ConnectedAt time.Time      `json:"connectedAt"`
+	Metadata    map[string]any `json:"metadata,omitempty"`
+}
+
+// Snapshot describes s as it is now.
+func (s *Session) Snapshot() SessionSnapshot {
+	return SessionSnapshot{
+		ClientID:    s.ClientID(),
+		Fingerprint: s.Fingerprint(),
+		RemoteAddr:  s.RemoteAddr,
+		ConnectedAt: s.ConnectedAt,
+		Metadata:    s.Metadata(),
+	}
+}
+
+// Snapshot describes every registered session as it is now, oldest first.
+func (r *Registry) Snapshot() []SessionSnapshot {
+	var snapshots []SessionSnapshot
+	r.Range(func(s *Session) bool {
+		snapshots = append(snapshots, s.Snapshot())
+		return true
+	})
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ConnectedAt.Before(snapshots[j].ConnectedAt)
+	})
+	return snapshots
+}
+
+// SnapshotByFingerprint describes the sessions of the client with
+// fingerprint as they are now, oldest first.
+func (r *Registry) SnapshotByFingerprint(fingerprint string) []SessionSnapshot {
+	sessions := r.GetByFingerprint(fingerprint)
+	snapshots := make([]SessionSnapshot, len(sessions))
+	for i, s := range sessions {
+		snapshots[i] = s.Snapshot()
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ConnectedAt.Before(snapshots[j].ConnectedAt)
+	})
+	return snapshots
+}
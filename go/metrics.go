@@ -0,0 +1,123 @@
+package wskeyauth
+
+import (
+	"strings"
+	"time"
+)
+
+// FailureReason classifies why a handshake didn't authenticate the client.
+type FailureReason string
+
+const (
+	// ReasonReadFailed means a message couldn't be read off the connection,
+	// typically because the client went away.
+	ReasonReadFailed FailureReason = "read_failed"
+
+	// ReasonUnexpectedMessage means the client sent a message out of order.
+	ReasonUnexpectedMessage FailureReason = "unexpected_message"
+
+	// ReasonMalformedMessage means a message, or a field inside of it, could
+	// not be decoded.
+	ReasonMalformedMessage FailureReason = "malformed_message"
+
+	// ReasonInvalidClientID means the client ID wasn't in a supported format.
+	ReasonInvalidClientID FailureReason = "invalid_client_id"
+
+	// ReasonInvalidPublicKey means the client ID was well-formed, but its key
+	// isn't a point on the curve.
+	ReasonInvalidPublicKey FailureReason = "invalid_public_key"
+
+	// ReasonUntrustedCertificate means the client's X.509 certificate didn't
+	// verify, or was revoked.
+	ReasonUntrustedCertificate FailureReason = "untrusted_certificate"
+
+	// ReasonUntrustedDelegation means the client's delegated key wasn't
+	// signed by a trusted root, expired, or was revoked.
+	ReasonUntrustedDelegation FailureReason = "untrusted_delegation"
+
+	// ReasonUnsupportedAlgorithm means the client's key, or a certificate it
+	// came with, is of an algorithm the AlgorithmPolicy doesn't allow.
+	ReasonUnsupportedAlgorithm FailureReason = "unsupported_algorithm"
+
+	// ReasonUnknownKey means the client's key isn't in the KeyStore.
+	ReasonUnknownKey FailureReason = "unknown_key"
+
+	// ReasonRevoked means the client's key is on the RevocationList.
+	ReasonRevoked FailureReason = "revoked"
+
+	// ReasonRejected means the application rejected the client ID through
+	// Hooks.OnClientID.
+	ReasonRejected FailureReason = "rejected"
+
+	// ReasonUnsupportedHash means the client signed with a hash we don't
+	// support.
+	ReasonUnsupportedHash FailureReason = "unsupported_hash"
+
+	// ReasonSignatureMismatch means the signature didn't verify against the
+	// client's key.
+	ReasonSignatureMismatch FailureReason = "signature_mismatch"
+
+	// ReasonProofOfWorkMismatch means the client didn't do the work
+	// WithProofOfWork asked for.
+	ReasonProofOfWorkMismatch FailureReason = "proof_of_work_mismatch"
+
+	// ReasonSecondFactorMismatch means the client didn't give a valid TOTP
+	// code, or had no second factor to give one for.
+	ReasonSecondFactorMismatch FailureReason = "second_factor_mismatch"
+
+	// ReasonInvalidIDToken means the client's OIDC ID token didn't verify, or
+	// it presented none where one is required.
+	ReasonInvalidIDToken FailureReason = "invalid_id_token"
+
+	// ReasonPairingPending means the client's key isn't known yet, and is
+	// waiting to be approved through WithPairing.
+	ReasonPairingPending FailureReason = "pairing_pending"
+
+	// ReasonAddressDenied means WithIPFilter didn't let the client's address
+	// in.
+	ReasonAddressDenied FailureReason = "address_denied"
+
+	// ReasonRateLimited means the RateLimiter didn't allow the handshake.
+	ReasonRateLimited FailureReason = "rate_limited"
+
+	// ReasonBanned means the client was locked out by WithBans, after too many
+	// of its handshakes failed.
+	ReasonBanned FailureReason = "banned"
+
+	// ReasonVetoed means an Interceptor vetoed one of the handshake's
+	// messages.
+	ReasonVetoed FailureReason = "vetoed"
+
+	// ReasonTimeout means the client didn't complete the handshake in time.
+	ReasonTimeout FailureReason = "timeout"
+
+	// ReasonServerError means the handshake failed on our end.
+	ReasonServerError FailureReason = "server_error"
+)
+
+// Code returns the code of the errors clients are sent for r, which is r in
+// upper case, such as UNKNOWN_KEY for ReasonUnknownKey. Clients go by it,
+// rather than by the error's text, which may be translated.
+func (r FailureReason) Code() string {
+	return strings.ToUpper(string(r))
+}
+
+// Metrics receives counts and timings from handshakes. The
+// contrib/prometheus package provides an implementation backed by Prometheus
+// collectors.
+type Metrics interface {
+	HandshakeStarted()
+	HandshakeSucceeded()
+	HandshakeFailed(reason FailureReason)
+
+	// VerificationDuration is called with the time it took to verify the
+	// client's signature.
+	VerificationDuration(d time.Duration)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) HandshakeStarted()                  {}
+func (nopMetrics) HandshakeSucceeded()                {}
+func (nopMetrics) HandshakeFailed(FailureReason)      {}
+func (nopMetrics) VerificationDuration(time.Duration) {}
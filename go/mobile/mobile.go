@@ -0,0 +1,228 @@
+// Package wskeyauthmobile wraps the Go client for gomobile, so that iOS and
+// Android apps authenticate to ws-key-auth servers with the same handshake as
+// Go clients do:
+//
+//	gomobile bind -target=ios ./mobile
+//	gomobile bind -target=android ./mobile
+//
+// Its exported surface only has what gomobile binds: strings, numbers, byte
+// slices, errors, and pointers to the structs here, with no channels or
+// callbacks, and the one interface IDTokenProvider, for the app to implement. Keys are generated and held in memory by Key, and
+// exported as PKCS #8 for the app to keep in the Keychain or Keystore:
+//
+//	key, err := wskeyauthmobile.GenerateKey("P-256")
+//	pkcs8, err := key.Export()
+//	...
+//	key, err := wskeyauthmobile.ImportKey(pkcs8)
+//	conn, err := wskeyauthmobile.NewClient(key).Connect("wss://example.com/ws", 10000)
+//	err = conn.Send(`{"type":"HELLO"}`)
+//	message, err := conn.Receive()
+package wskeyauthmobile
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	wskeyauthclient "github.com/castcam-live/ws-key-auth/go/client"
+)
+
+// Key is a client's private key, an ECDSA P-256 or an Ed25519 key.
+type Key struct {
+	signer    crypto.Signer
+	clientID  string
+	destroyed bool
+}
+
+// GenerateKey generates a key for algorithm, which is "P-256" or "Ed25519".
+func GenerateKey(algorithm string) (*Key, error) {
+	var signer crypto.Signer
+	var err error
+	switch algorithm {
+	case "P-256":
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "Ed25519":
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, errors.New("wskeyauthmobile: unsupported algorithm " + algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newKey(signer)
+}
+
+// ImportKey imports a key exported with Export.
+func ImportKey(pkcs8 []byte) (*Key, error) {
+	key, err := x509.ParsePKCS8PrivateKey(pkcs8)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("wskeyauthmobile: unsupported key type")
+	}
+	return newKey(signer)
+}
+
+func newKey(signer crypto.Signer) (*Key, error) {
+	client, err := wskeyauthclient.New(signer)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{signer: signer, clientID: client.ClientID()}, nil
+}
+
+// Export returns the key in PKCS #8, for the app to store securely.
+func (k *Key) Export() ([]byte, error) {
+	if k.destroyed {
+		return nil, wskeyauthclient.ErrDestroyed
+	}
+	return x509.MarshalPKCS8PrivateKey(k.signer)
+}
+
+// Destroy zeroes the key in memory once the app has no more use for it, as
+// wskeyauthclient.Client.Destroy does. Exporting it, and connecting with it,
+// fail from then on.
+func (k *Key) Destroy() {
+	if client, err := wskeyauthclient.New(k.signer); err == nil {
+		client.Destroy()
+	}
+	k.destroyed = true
+}
+
+// ClientID returns the client ID of the key, which servers know the client
+// by.
+func (k *Key) ClientID() string {
+	return k.clientID
+}
+
+// Client connects to servers with a key. Its setters configure the
+// handshake, as the fields of wskeyauthclient.Client do.
+type Client struct {
+	key      *Key
+	audience string
+	serverID string
+	idToken  IDTokenProvider
+}
+
+// NewClient creates a client that authenticates with key.
+func NewClient(key *Key) *Client {
+	return &Client{key: key}
+}
+
+// SetAudience sets the server the client believes it is connected to, for
+// servers that bind signatures to an audience. It defaults to the scheme and
+// host of the URL connected to.
+func (c *Client) SetAudience(audience string) {
+	c.audience = audience
+}
+
+// SetServerID sets the server ID the server must prove it holds the key to.
+func (c *Client) SetServerID(serverID string) {
+	c.serverID = serverID
+}
+
+// IDTokenProvider gets OIDC ID tokens from the app's identity provider.
+type IDTokenProvider interface {
+	// IDToken returns an ID token whose "nonce" claim is nonce, which binds
+	// it to the handshake it is presented in.
+	IDToken(nonce string) (string, error)
+}
+
+// SetIDToken sets where the OIDC ID tokens to present with the client's key
+// come from.
+func (c *Client) SetIDToken(idToken IDTokenProvider) {
+	c.idToken = idToken
+}
+
+// Connect connects to the server at url, and authenticates, within
+// timeoutMillis milliseconds, or without a timeout if it isn't positive.
+func (c *Client) Connect(url string, timeoutMillis int64) (*Connection, error) {
+	if c.key.destroyed {
+		return nil, wskeyauthclient.ErrDestroyed
+	}
+	client, err := wskeyauthclient.New(c.key.signer)
+	if err != nil {
+		return nil, err
+	}
+	client.Audience, client.ServerID = c.audience, c.serverID
+	if c.idToken != nil {
+		client.IDToken = c.idToken.IDToken
+	}
+
+	conn := &Connection{clientID: client.ClientID()}
+	client.OnAccessToken = func(token *wskeyauth.AccessToken) { conn.accessToken = token.AccessToken }
+	client.OnMacaroon = func(macaroon string) { conn.macaroon = macaroon }
+
+	ctx := context.Background()
+	if timeoutMillis > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutMillis)*time.Millisecond)
+		defer cancel()
+	}
+	if conn.ws, err = client.Dial(ctx, url, nil); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Connection is an authenticated connection, over which the app exchanges
+// text messages with the server.
+type Connection struct {
+	ws       *websocket.Conn
+	clientID string
+
+	accessToken string
+	macaroon    string
+
+	readMu  sync.Mutex
+	writeMu sync.Mutex
+}
+
+// ClientID returns the client ID the connection authenticated as.
+func (c *Connection) ClientID() string {
+	return c.clientID
+}
+
+// AccessToken returns the access token the server handed out on
+// authenticating the client, if it did.
+func (c *Connection) AccessToken() string {
+	return c.accessToken
+}
+
+// Macaroon returns the macaroon the server handed out on authenticating the
+// client, if it did.
+func (c *Connection) Macaroon() string {
+	return c.macaroon
+}
+
+// Send sends message, as a text message.
+func (c *Connection) Send(message string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.ws.WriteMessage(websocket.TextMessage, []byte(message))
+}
+
+// Receive blocks until the next message from the server, and returns it.
+func (c *Connection) Receive() (string, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+	_, message, err := c.ws.ReadMessage()
+	return string(message), err
+}
+
+// Close closes the connection. Receive returns an error once it did.
+func (c *Connection) Close() error {
+	return c.ws.Close()
+}
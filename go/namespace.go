@@ -0,0 +1,109 @@
+package wskeyauth
+
+import (
+	"errors"
+	"strings"
+)
+
+// Client IDs of the formats that start with a name and a $ may carry a
+// namespace, after the name, such as the name of the application they were
+// minted for:
+//
+//	WebCrypto-raw.EC.P-256.castcam$<base64 encoded public key>
+//
+// Servers with WithNamespace only accept client IDs in their namespace, so
+// that keys minted for one application can't be used with another. The
+// namespace doesn't change who the client is: its fingerprint is that of the
+// client ID without it. did:keys and OpenSSH keys have no room for a
+// namespace, so servers with one reject them.
+//
+// Clients in a namespace sign it along with the challenge, after the
+// audience, if any, and a NUL, as SignedAudience returns, so that a
+// signature made for a server of one namespace is of no use with another.
+// Signed timestamps are signed for it as well. Noise handshakes need no more:
+// their signatures are of keys only the client holds, and SRP proofs are made
+// with a verifier that is the server's own.
+
+// namespacedPrefixes are the names of the client ID formats that may carry a
+// namespace.
+var namespacedPrefixes = []string{
+	"WebCrypto-raw.EC.P-256",
+	"WebAuthn-raw.EC.P-256",
+	strings.TrimSuffix(x509Prefix, "$"),
+	strings.TrimSuffix(delegationPrefix, "$"),
+	strings.TrimSuffix(jwkPrefix, "$"),
+	strings.TrimSuffix(spkiPrefix, "$"),
+	strings.TrimSuffix(hmacPrefix, "$"),
+	strings.TrimSuffix(srpPrefix, "$"),
+}
+
+// WithNamespace only accepts client IDs in namespace. Without it, client IDs
+// are accepted whatever their namespace, if any.
+func WithNamespace(namespace string) Option {
+	return func(cfg *config) {
+		cfg.namespace = namespace
+	}
+}
+
+// NamespaceClientID returns clientID in namespace.
+func NamespaceClientID(clientID, namespace string) (string, error) {
+	if err := checkNamespace(namespace); err != nil {
+		return "", err
+	}
+	stripped, current := splitNamespace(clientID)
+	if current != "" {
+		return "", errors.New("wskeyauth: client ID is in a namespace already")
+	}
+	for _, prefix := range namespacedPrefixes {
+		if rest, ok := strings.CutPrefix(stripped, prefix+"$"); ok {
+			return prefix + "." + namespace + "$" + rest, nil
+		}
+	}
+	return "", errors.New("wskeyauth: client IDs of this format can't be in a namespace")
+}
+
+// SignedAudience returns what a client with clientID signs after the
+// challenge of a server with audience, which may be "": audience, followed by
+// a NUL and the namespace if clientID is in one.
+func SignedAudience(audience, clientID string) string {
+	_, namespace := splitNamespace(clientID)
+	return signedAudience(audience, namespace)
+}
+
+func signedAudience(audience, namespace string) string {
+	if namespace == "" {
+		return audience
+	}
+	return audience + "\x00" + namespace
+}
+
+// splitNamespace returns clientID without its namespace, and the namespace,
+// or "" if it has none.
+func splitNamespace(clientID string) (string, string) {
+	for _, prefix := range namespacedPrefixes {
+		rest, ok := strings.CutPrefix(clientID, prefix+".")
+		if !ok {
+			continue
+		}
+		namespace, rest, ok := strings.Cut(rest, "$")
+		if !ok || checkNamespace(namespace) != nil {
+			break
+		}
+		return prefix + "$" + rest, namespace
+	}
+	return clientID, ""
+}
+
+// checkNamespace checks that namespace is of letters, digits, dashes and
+// underscores, as it can't be allowed to hold the . and $ that delimit it.
+func checkNamespace(namespace string) error {
+	if namespace == "" || len(namespace) > maxNameLength {
+		return errors.New("wskeyauth: expected namespace to be 1 to 128 characters long")
+	}
+	for _, c := range namespace {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return errors.New("wskeyauth: expected namespace to be letters, digits, - and _")
+		}
+	}
+	return nil
+}
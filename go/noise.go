@@ -0,0 +1,346 @@
+package wskeyauth
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/castcam-live/ws-key-auth/go/internal/noise"
+)
+
+// NoiseHandshake is an alternative to the challenge and response of Handshake,
+// for those who would rather rely on a formally analyzed pattern:
+// Noise_XX_25519_AESGCM_SHA256, of the Noise Protocol Framework. Each of its
+// three messages is sent as
+//
+//	{"type": "NOISE", "data": "<base64 Noise message>"}
+//
+// and the server ends the handshake as Handshake does, with
+// SIGNATURE_MATCHES, say.
+//
+// Noise authenticates X25519 static keys, which both sides generate afresh
+// for every handshake. Each ties its static key to its long-term key in the
+// payload of the message carrying it, by signing NoiseStaticKeyPrefix followed
+// by the static key, as challenges are signed:
+//
+//	{"id": "<client or server ID>", "signature": "<base64 signature>"}
+//
+// The client always does, with its client ID, which must have a P-256 or an
+// Ed25519 key. The server does with WithServerKey, and sends an empty payload
+// otherwise.
+//
+// Key stores, hooks, metrics, logging, rate limits and the timeout apply as
+// they do to Handshake. Passkeys, shared secrets, passwords, second factors
+// and ID tokens don't, as they are built on the challenge.
+//
+// Once authenticated, the client is given the returned connection to talk
+// over, whose messages are encrypted with the keys the handshake agreed on.
+func NoiseHandshake(conn Conn, opts ...Option) (bool, string, *EncryptedConn, error) {
+	h := newHandshakeState(conn, opts)
+	result, err := h.handshake((*handshakeState).runNoise)
+	if !result.Authenticated {
+		return false, result.ClientID, nil, err
+	}
+	return true, result.ClientID, h.encrypted, err
+}
+
+// NoiseStaticKeyPrefix starts everything signed to tie a Noise static key to
+// a long-term key. What is signed is of a fixed length, shorter than any
+// challenge, so it can't pass for a challenge response.
+const NoiseStaticKeyPrefix = "wskeyauth noise static key\x00"
+
+// noisePrologue binds Noise handshakes to this protocol.
+var noisePrologue = []byte("wskeyauth")
+
+type noiseIdentity struct {
+	ID        string `json:"id"`
+	Signature string `json:"signature"`
+}
+
+func noiseStaticInput(static []byte) []byte {
+	return append([]byte(NoiseStaticKeyPrefix), static...)
+}
+
+func (h *handshakeState) readNoise(step string) ([]byte, FailureReason, error) {
+	var msg stringMessage
+	if err := h.conn.ReadJSON(&msg); err != nil {
+		return nil, ReasonReadFailed, err
+	}
+	if msg.Type != "NOISE" {
+		h.conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonUnexpectedMessage, "Expected a NOISE event, but got "+msg.Type, nil))
+		return nil, ReasonUnexpectedMessage, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(msg.Data)
+	if err != nil {
+		h.conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Failed to parse "+step, err))
+		return nil, ReasonMalformedMessage, err
+	}
+	return b, "", nil
+}
+
+func (h *handshakeState) runNoise() (bool, string, FailureReason, error) {
+	conn, cfg, trace := h.conn, h.cfg, h.trace
+
+	if cfg.fips {
+		conn.WriteJSON(newErrorMessage("UNSUPPORTED_ALGORITHM", ReasonUnsupportedAlgorithm, "Noise handshakes are not approved in FIPS mode", nil))
+		return false, "", ReasonUnsupportedAlgorithm, notApproved("Noise handshakes")
+	}
+	if cfg.ipFilter != nil {
+		if ok, reason, err := h.checkAddr(); !ok {
+			return false, "", reason, err
+		}
+	}
+	if h.remoteAddr != "" {
+		if ok, reason, err := h.allow(addrKey(h.remoteAddr)); !ok {
+			return false, "", reason, err
+		}
+	}
+
+	trace.step("ReadNoiseMessage1")
+
+	msg, reason, err := h.readNoise("the first NOISE message")
+	if msg == nil {
+		return false, "", reason, err
+	}
+
+	static, err := x25519Key(cfg.random)
+	if err != nil {
+		conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to generate key", err))
+		return false, "", ReasonServerError, err
+	}
+	hs := noise.New(false, static, noisePrologue, cfg.random)
+	if _, err := hs.ReadMessage1(msg); err != nil {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Failed to parse the first NOISE message", err))
+		return false, "", ReasonMalformedMessage, err
+	}
+
+	trace.step("SendNoiseMessage2")
+
+	var payload []byte
+	if cfg.serverKey != nil {
+		sig, err := cfg.serverKey.sign(noiseStaticInput(static.PublicKey().Bytes()))
+		if err != nil {
+			conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to sign static key", err))
+			return false, "", ReasonServerError, err
+		}
+		payload, _ = json.Marshal(&noiseIdentity{ID: cfg.serverKey.id, Signature: base64.StdEncoding.EncodeToString(sig)})
+	}
+	if msg, err = hs.WriteMessage2(payload); err != nil {
+		conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to write NOISE message", err))
+		return false, "", ReasonServerError, err
+	}
+	conn.WriteJSON(&stringMessage{Type: "NOISE", Data: base64.StdEncoding.EncodeToString(msg)})
+
+	trace.step("ReadNoiseMessage3")
+
+	if msg, reason, err = h.readNoise("the last NOISE message"); msg == nil {
+		return false, "", reason, err
+	}
+	payload, err = hs.ReadMessage3(msg)
+	if errors.Is(err, noise.ErrDecrypt) {
+		conn.WriteJSON(&typeMessage{Type: "SIGNATURE_MISMATCH"})
+		return false, "", ReasonSignatureMismatch, err
+	}
+	if err != nil {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Failed to parse the last NOISE message", err))
+		return false, "", ReasonMalformedMessage, err
+	}
+
+	var identity noiseIdentity
+	if err := json.Unmarshal(payload, &identity); err != nil {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Failed to parse the last NOISE message", err))
+		return false, "", ReasonMalformedMessage, err
+	}
+	clientID := identity.ID
+
+	if format := clientIDFormat(clientID); !cfg.acceptsFormat(format) {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, string(format)+" client IDs are not accepted", nil))
+		return false, clientID, ReasonInvalidClientID, nil
+	}
+
+	pubKey, err := parseClientID(clientID, cfg.base64)
+	if errors.Is(err, ErrInvalidPublicKey) {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidPublicKey, "Client ID is not a valid public key", err))
+		return false, clientID, ReasonInvalidPublicKey, err
+	}
+	if err != nil {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, "Failed to parse client ID", err))
+		return false, clientID, ReasonInvalidClientID, err
+	}
+	if cfg.namespace != "" && pubKey.namespace != cfg.namespace {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, "Client ID is not in this server's namespace", nil))
+		return false, clientID, ReasonInvalidClientID, nil
+	}
+	if !signsMessages(pubKey) {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, "Client ID has no key to sign with", nil))
+		return false, clientID, ReasonInvalidClientID, nil
+	}
+	if err := cfg.algorithms.check(pubKey); err != nil {
+		conn.WriteJSON(newErrorMessage("UNSUPPORTED_ALGORITHM", ReasonUnsupportedAlgorithm, err.Error(), nil))
+		return false, clientID, ReasonUnsupportedAlgorithm, nil
+	}
+
+	if pubKey.certificates != nil {
+		if cfg.x509 == nil {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, "X.509 client IDs are not accepted", nil))
+			return false, clientID, ReasonInvalidClientID, nil
+		}
+		if err := cfg.x509.verify(pubKey, cfg.clock.Now()); err != nil {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonUntrustedCertificate, "Client certificate is not trusted", err))
+			return false, clientID, ReasonUntrustedCertificate, err
+		}
+	}
+
+	if pubKey.delegation != nil {
+		if cfg.delegation == nil {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonInvalidClientID, "Delegated client IDs are not accepted", nil))
+			return false, clientID, ReasonInvalidClientID, nil
+		}
+		if err := cfg.delegation.verify(pubKey, cfg.clock.Now(), cfg.fips); err != nil {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonUntrustedDelegation, "Delegated key is not trusted", err))
+			return false, clientID, ReasonUntrustedDelegation, err
+		}
+	}
+
+	fp := fingerprint(pubKey)
+	h.fingerprint = fp
+	h.log = h.log.With("fingerprint", fp)
+	h.log.Debug("wskeyauth: received client ID")
+
+	trace.setFingerprint(fp)
+
+	if ok, reason, err := h.allow("key:" + fp); !ok {
+		return false, clientID, reason, err
+	}
+	if ok, reason, err := h.checkRevoked(); !ok {
+		return false, clientID, reason, err
+	}
+	if pubKey.delegation == nil {
+		if ok, reason, err := h.lookup(); !ok {
+			return false, clientID, reason, err
+		}
+	}
+	if h.guest {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonUnknownKey, "Client key is not known", nil))
+		return false, clientID, ReasonUnknownKey, nil
+	}
+	if err := cfg.hooks.clientID(clientID); err != nil {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonRejected, "Client ID was rejected", err))
+		return false, clientID, ReasonRejected, err
+	}
+
+	trace.step("VerifySignature")
+
+	sig, err := cfg.base64.decode(nil, identity.Signature)
+	if err != nil {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Failed to parse the last NOISE message", err))
+		return false, clientID, ReasonMalformedMessage, err
+	}
+	start := time.Now()
+	verified := verifyWithKey(pubKey, noiseStaticInput(hs.PeerStatic()), sig)
+	cfg.metrics.VerificationDuration(time.Since(start))
+	if !verified {
+		conn.WriteJSON(&typeMessage{Type: "SIGNATURE_MISMATCH"})
+		return false, clientID, ReasonSignatureMismatch, nil
+	}
+
+	if h.pairing {
+		return h.pair(clientID)
+	}
+
+	send, receive := hs.Split()
+	h.encrypted = &EncryptedConn{conn: h.wire, seal: send.AEAD(), open: receive.AEAD()}
+
+	conn.WriteJSON(&typeMessage{Type: "SIGNATURE_MATCHES"})
+	return true, clientID, "", nil
+}
+
+// NoiseClientHandshake is the client's side of NoiseHandshake, up to the
+// message the server ends the handshake with, which the caller reads. It is
+// for clients such as wskeyauthclient's. sign signs what the key of clientID
+// signs. If serverID isn't empty, the server must prove it holds its key.
+func NoiseClientHandshake(conn Conn, clientID, serverID string, sign func(message []byte) ([]byte, error)) (*EncryptedConn, error) {
+	if FIPS() {
+		return nil, notApproved("Noise handshakes")
+	}
+
+	static, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	hs := noise.New(true, static, noisePrologue, rand.Reader)
+
+	msg, err := hs.WriteMessage1(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.WriteJSON(&stringMessage{Type: "NOISE", Data: base64.StdEncoding.EncodeToString(msg)}); err != nil {
+		return nil, err
+	}
+
+	var reply stringMessage
+	if err := conn.ReadJSON(&reply); err != nil {
+		return nil, err
+	}
+	if reply.Type != "NOISE" {
+		return nil, fmt.Errorf("wskeyauth: expected NOISE, but got %s", reply.Type)
+	}
+	if msg, err = base64.StdEncoding.DecodeString(reply.Data); err != nil {
+		return nil, fmt.Errorf("wskeyauth: failed to parse NOISE: %w", err)
+	}
+	payload, err := hs.ReadMessage2(msg)
+	if err != nil {
+		return nil, fmt.Errorf("wskeyauth: failed to parse NOISE: %w", err)
+	}
+
+	if serverID != "" {
+		if err := verifyNoiseServer(payload, serverID, hs.PeerStatic()); err != nil {
+			return nil, err
+		}
+	}
+
+	sig, err := sign(noiseStaticInput(static.PublicKey().Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	payload, _ = json.Marshal(&noiseIdentity{ID: clientID, Signature: base64.StdEncoding.EncodeToString(sig)})
+	if msg, err = hs.WriteMessage3(payload); err != nil {
+		return nil, err
+	}
+	if err := conn.WriteJSON(&stringMessage{Type: "NOISE", Data: base64.StdEncoding.EncodeToString(msg)}); err != nil {
+		return nil, err
+	}
+
+	send, receive := hs.Split()
+	return &EncryptedConn{conn: conn, seal: send.AEAD(), open: receive.AEAD()}, nil
+}
+
+func verifyNoiseServer(payload []byte, serverID string, static []byte) error {
+	var identity noiseIdentity
+	if len(payload) == 0 {
+		return errors.New("wskeyauth: server didn't prove its identity")
+	}
+	if err := json.Unmarshal(payload, &identity); err != nil {
+		return fmt.Errorf("wskeyauth: failed to parse server identity: %w", err)
+	}
+	if identity.ID != serverID {
+		return fmt.Errorf("wskeyauth: expected server %q, but got %q", serverID, identity.ID)
+	}
+
+	serverKey, err := parseClientID(serverID, Base64Std)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(identity.Signature)
+	if err != nil {
+		return fmt.Errorf("wskeyauth: failed to decode server signature: %w", err)
+	}
+	if !signsMessages(serverKey) || !verifyWithKey(serverKey, noiseStaticInput(static), sig) {
+		return errors.New("wskeyauth: server signature doesn't match its ID")
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package wskeyauth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// NonceStore remembers values that may only be used once, such as TOTP
+// codes, for as long as they would otherwise be accepted. A store shared
+// between servers, such as the Redis one in contrib/redis, stops a value used
+// on one from being replayed on another.
+type NonceStore interface {
+	// Use marks nonce as used for ttl, and reports whether it was unused
+	// until now. An error fails the handshake with ReasonServerError.
+	Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// WithNonceStore stops single-use values from being used twice, by
+// recording them in store.
+func WithNonceStore(store NonceStore) Option {
+	return func(cfg *config) {
+		cfg.nonces = store
+	}
+}
+
+// MemoryNonceStore is a NonceStore for a single process.
+type MemoryNonceStore struct {
+	// Clock tells when nonces expire. It defaults to SystemClock.
+	Clock Clock
+
+	mu      sync.Mutex
+	expires map[string]time.Time
+	swept   time.Time
+}
+
+// NewMemoryNonceStore creates an empty MemoryNonceStore.
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{expires: map[string]time.Time{}}
+}
+
+func (s *MemoryNonceStore) Use(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := clockOr(s.Clock).Now()
+	if s.expires == nil {
+		s.expires = map[string]time.Time{}
+	}
+
+	// forget expired nonces once in a while, rather than on every use
+	if now.Sub(s.swept) > time.Minute {
+		for n, expires := range s.expires {
+			if now.After(expires) {
+				delete(s.expires, n)
+			}
+		}
+		s.swept = now
+	}
+
+	if expires, ok := s.expires[nonce]; ok && !now.After(expires) {
+		return false, nil
+	}
+	s.expires[nonce] = now.Add(ttl)
+	return true, nil
+}
@@ -0,0 +1,328 @@
+package wskeyauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// With WithOIDC, clients may present an OpenID Connect ID token along with
+// their key, in the data of their CHALLENGE_RESPONSE:
+//
+//	{"signature": "<base64 signature>", "hash": "SHA-256", "idToken": "<JWT>"}
+//
+// Once the client proved it holds its key, the token is verified, and the
+// link between the key and the account is handed to OIDC.Link to record,
+// so that a user can sign in with their identity provider once, and bind
+// the device's key to their account. Tokens signed with RS256 and ES256 are
+// accepted, and only the RSA and P-256 keys of the issuer's JWKS are used:
+// none signed with Ed25519 ever verifies. In FIPS mode, neither do those
+// signed with RSA keys of fewer than 2048 bits.
+//
+// Tokens must be bound to the handshake they are presented in, by a "nonce"
+// claim of OIDCNonce of the challenge and the fingerprint of the client's
+// key, so that a leaked token can't link someone else's key to the account.
+// Clients ask their identity provider for a token with that nonce once they
+// got the CHALLENGE.
+
+// OIDC verifies ID tokens from one issuer. Its signing keys are fetched on
+// first use, and again whenever a token is signed with a key it doesn't know,
+// so an OIDC should be shared between handshakes.
+type OIDC struct {
+	// Issuer is the issuer tokens must come from, such as
+	// "https://accounts.google.com". Its keys are discovered through its
+	// /.well-known/openid-configuration.
+	Issuer string
+
+	// ClientIDs are the audiences tokens may be issued for; that is, the
+	// application's client IDs with the issuer.
+	ClientIDs []string
+
+	// Required rejects clients that don't present a token. Otherwise, tokens
+	// are only verified and linked when presented.
+	Required bool
+
+	// Link is called with the fingerprint of the client's key, and the
+	// verified token, to record the link between them. An error fails the
+	// handshake with ReasonServerError.
+	Link func(ctx context.Context, fingerprint string, token *IDToken) error
+
+	// HTTPClient fetches the issuer's keys. It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// IDToken is a verified ID token.
+type IDToken struct {
+	Issuer   string
+	Subject  string
+	Audience []string
+	Expiry   time.Time
+	IssuedAt time.Time
+
+	// Claims are all of the token's claims, as decoded from JSON.
+	Claims map[string]any
+}
+
+// WithOIDC verifies, and links, the ID tokens clients present with o.
+//
+// Tokens with many claims can outgrow DefaultReadLimit; raise it with
+// WithReadLimit if need be.
+func WithOIDC(o *OIDC) Option {
+	return func(cfg *config) {
+		cfg.oidc = o
+	}
+}
+
+// oidcLeeway is how far clocks may drift between us and the issuer.
+const oidcLeeway = time.Minute
+
+// oidcRefetchInterval is the least time between fetches of the issuer's keys,
+// so that tokens with unknown key IDs can't have us fetch them over and over.
+const oidcRefetchInterval = time.Minute
+
+// errFetchKeys is the issuer's fault, or ours, rather than the client's.
+var errFetchKeys = errors.New("failed to fetch the issuer's keys")
+
+// OIDCNonce returns the nonce ID tokens presented in a handshake must carry:
+// the unpadded URL-safe base64 of a SHA-256 hash of fingerprint, that of the
+// client's key, and challenge, what the client proves it holds its key with.
+// That is the challenge, followed by the audience, if any, for key clients,
+// and the server's ephemeral B for password ones.
+func OIDCNonce(challenge []byte, fingerprint string) string {
+	h := sha256.New()
+	h.Write([]byte("ws-key-auth OIDC nonce\x00"))
+	h.Write([]byte(fingerprint))
+	h.Write([]byte{0})
+	h.Write(challenge)
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// verify verifies token, as of now, and that it carries nonce, with only the
+// keys FIPS approves if fips is set.
+func (o *OIDC) verify(ctx context.Context, token, nonce string, now time.Time, fips bool) (*IDToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("expected ID token to be a JWT")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("failed to parse ID token header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ID token signature: %w", err)
+	}
+
+	key, err := o.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if fips && key.N.BitLen() < minApprovedRSABits {
+			return nil, notApproved(fmt.Sprintf("ID tokens signed with %d bit RSA keys", key.N.BitLen()))
+		}
+		if header.Alg != "RS256" || rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) != nil {
+			return nil, errors.New("ID token signature doesn't match")
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg != "ES256" || len(sig) != 64 ||
+			!ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+			return nil, errors.New("ID token signature doesn't match")
+		}
+	default:
+		return nil, fmt.Errorf("ID token is signed with an unsupported key of type %T", key)
+	}
+
+	var claims map[string]any
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse ID token claims: %w", err)
+	}
+	var registered struct {
+		Iss   string   `json:"iss"`
+		Sub   string   `json:"sub"`
+		Aud   audience `json:"aud"`
+		Exp   int64    `json:"exp"`
+		Iat   int64    `json:"iat"`
+		Nonce string   `json:"nonce"`
+	}
+	if err := decodeJWTPart(parts[1], &registered); err != nil {
+		return nil, fmt.Errorf("failed to parse ID token claims: %w", err)
+	}
+
+	t := &IDToken{
+		Issuer:   registered.Iss,
+		Subject:  registered.Sub,
+		Audience: registered.Aud,
+		Expiry:   time.Unix(registered.Exp, 0),
+		IssuedAt: time.Unix(registered.Iat, 0),
+		Claims:   claims,
+	}
+	switch {
+	case t.Issuer != o.Issuer:
+		return nil, fmt.Errorf("expected ID token to be issued by %s, but it was issued by %s", o.Issuer, t.Issuer)
+	case !slices.ContainsFunc(t.Audience, func(aud string) bool { return slices.Contains(o.ClientIDs, aud) }):
+		return nil, errors.New("ID token was issued for another audience")
+	case t.Subject == "":
+		return nil, errors.New("ID token has no subject")
+	case now.After(t.Expiry.Add(oidcLeeway)):
+		return nil, errors.New("ID token has expired")
+	case t.IssuedAt.After(now.Add(oidcLeeway)):
+		return nil, errors.New("ID token was issued in the future")
+	case subtle.ConstantTimeCompare([]byte(registered.Nonce), []byte(nonce)) != 1:
+		return nil, errors.New("ID token is not bound to this handshake")
+	}
+	return t, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// audience is an "aud" claim, which is either a string or an array of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if json.Unmarshal(b, &single) == nil {
+		*a = audience{single}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+// key returns the issuer's key with kid, fetching its keys if need be. The
+// lock isn't held while they are fetched, so a slow issuer doesn't hold up
+// tokens signed with keys we know; fetchedAt is set before the fetch, so that
+// only one handshake at a time fetches them.
+func (o *OIDC) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	o.mu.Lock()
+	if key, ok := o.keys[kid]; ok {
+		o.mu.Unlock()
+		return key, nil
+	}
+	if time.Since(o.fetchedAt) < oidcRefetchInterval {
+		o.mu.Unlock()
+		return nil, fmt.Errorf("ID token is signed with an unknown key %q", kid)
+	}
+	fetchedAt := o.fetchedAt
+	o.fetchedAt = time.Now()
+	o.mu.Unlock()
+
+	keys, err := o.fetchKeys(ctx)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err != nil {
+		// let the next handshake try again
+		o.fetchedAt = fetchedAt
+		return nil, fmt.Errorf("%w: %w", errFetchKeys, err)
+	}
+	o.keys = keys
+
+	if key, ok := o.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("ID token is signed with an unknown key %q", kid)
+}
+
+func (o *OIDC) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := o.getJSON(ctx, strings.TrimSuffix(o.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.JWKSURI == "" {
+		return nil, errors.New("issuer has no jwks_uri")
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := o.getJSON(ctx, discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range jwks.Keys {
+		switch {
+		case k.Kty == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) > 4 {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case k.Kty == "EC" && k.Crv == "P-256":
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+				continue
+			}
+			if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	return keys, nil
+}
+
+func (o *OIDC) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	client := o.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,140 @@
+package wskeyauth
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Option configures a handshake.
+type Option func(*config)
+
+type config struct {
+	ctx      context.Context
+	clock    Clock
+	random   io.Reader
+	fips     bool
+	metrics  Metrics
+	tracer   trace.Tracer
+	logger   *slog.Logger
+	hooks    Hooks
+	audit    AuditSink
+	events   *EventBus
+	recorder *Recorder
+	codec    Codec
+	encoding Encoding
+
+	interceptors   []Interceptor
+	errorVerbosity ErrorVerbosity
+	catalog        MessageCatalog
+
+	challengePool *ChallengePool
+	lockedMemory  bool
+
+	readLimit        int64
+	restoreReadLimit int64
+	restoresReads    bool
+	timeout          time.Duration
+	retries          int
+	challengeFirst   bool
+	base64           Base64
+	audience         string
+	namespace        string
+	formats          map[ClientIDFormat]bool
+	algorithms       *AlgorithmPolicy
+	extensions       []string
+	webauthn         *WebAuthn
+	keyStore         KeyStore
+	revocations      RevocationList
+	x509             *X509
+	delegation       *Delegation
+	serverKey        *ServerKey
+	passwords        PasswordStore
+	totp             *TOTP
+	tokenExchange    *TokenExchange
+	macaroons        *Macaroons
+	turn             *TURN
+	oidc             *OIDC
+	nonces           NonceStore
+	stateless        *StatelessChallenges
+	ipFilter         *IPFilter
+	proofOfWork      *ProofOfWork
+	request          *http.Request
+	trustedProxies   []netip.Prefix
+	rateLimiter      RateLimiter
+	bans             *Bans
+	tlsClientAuth    *TLSClientAuth
+	timestamps       *SignedTimestamps
+	pairing          *Pairing
+	guests           *Guests
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		ctx:     context.Background(),
+		clock:   SystemClock,
+		random:  rand.Reader,
+		fips:    FIPS(),
+		metrics: nopMetrics{},
+		tracer:  noop.NewTracerProvider().Tracer(""),
+		logger:  slog.New(discardHandler{}),
+		codec:   JSONCodec,
+
+		readLimit: DefaultReadLimit,
+		timeout:   DefaultTimeout,
+		base64:    Base64Std,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.fips {
+		cfg.algorithms = cfg.algorithms.withFIPS()
+	}
+	return cfg
+}
+
+// WithMetrics reports the outcome and timings of the handshake to m.
+func WithMetrics(m Metrics) Option {
+	return func(cfg *config) {
+		cfg.metrics = m
+	}
+}
+
+// WithContext sets the context the handshake runs in. Its values, such as the
+// active trace span, are inherited by the handshake.
+func WithContext(ctx context.Context) Option {
+	return func(cfg *config) {
+		cfg.ctx = ctx
+	}
+}
+
+// WithTracerProvider traces the handshake with OpenTelemetry: one span for the
+// whole handshake, and a child span for each protocol step.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(cfg *config) {
+		cfg.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// WithLogger emits a structured event for every stage of the handshake, and
+// for why it failed, if it did. Stages are logged at debug level, successes
+// at info and failures at warn.
+func WithLogger(logger *slog.Logger) Option {
+	return func(cfg *config) {
+		cfg.logger = logger
+	}
+}
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
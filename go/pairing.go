@@ -0,0 +1,346 @@
+package wskeyauth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Devices pair with an account by completing the handshake with a key the
+// server doesn't know yet. Rather than turning them away, a server with
+// WithPairing opens a pairing request for the key, once the device proved it
+// holds it, and ends the handshake with
+//
+//	{"type": "PAIRING_PENDING", "data": {"code": "7KQ4-M2XD", "expiresAt": "2006-01-02T15:04:05Z"}}
+//
+// The device shows the code, as text or as a QR code, to a client that is
+// already trusted, or to an administrator, who approves it with
+// Pairing.Approve. That records the device's key, so that its next handshake
+// succeeds. Devices handshake again until then, and are given the same code
+// while the request is pending.
+
+// DefaultPairingTTL is how long pairing requests stay open unless
+// Pairing.TTL says otherwise.
+const DefaultPairingTTL = 10 * time.Minute
+
+// PairingState is where a pairing request stands.
+type PairingState int
+
+const (
+	// PairingPending is a request waiting to be approved or rejected.
+	PairingPending PairingState = iota
+	// PairingApproved is a request whose key was recorded.
+	PairingApproved
+	// PairingRejected is a request that was turned down.
+	PairingRejected
+)
+
+func (s PairingState) String() string {
+	switch s {
+	case PairingPending:
+		return "pending"
+	case PairingApproved:
+		return "approved"
+	case PairingRejected:
+		return "rejected"
+	}
+	return "unknown"
+}
+
+// PairingRequest is a device asking to be paired.
+type PairingRequest struct {
+	// Code is what the device shows, for the approver to enter or scan.
+	Code string
+
+	ClientID    string
+	Fingerprint string
+	RemoteAddr  string
+
+	State     PairingState
+	CreatedAt time.Time
+	ExpiresAt time.Time
+
+	// DecidedBy is who approved or rejected the request, as given to
+	// Pairing.Approve or Pairing.Reject.
+	DecidedBy string
+}
+
+// Expired reports whether r can no longer be decided on.
+func (r *PairingRequest) Expired(now time.Time) bool {
+	return now.After(r.ExpiresAt)
+}
+
+// ErrPairingNotFound is returned for pairing codes with no pending request,
+// including those of requests that expired or were already decided on.
+var ErrPairingNotFound = errors.New("wskeyauth: no pending pairing request with that code")
+
+// PairingStore holds pairing requests. Share one between servers, so that a
+// request opened on one can be approved on another.
+type PairingStore interface {
+	// Put creates or replaces the request with r's code.
+	Put(ctx context.Context, r *PairingRequest) error
+
+	// ByCode returns the request with code, or nil if there is none.
+	ByCode(ctx context.Context, code string) (*PairingRequest, error)
+
+	// ByFingerprint returns the latest request for the key with
+	// fingerprint, or nil if there is none.
+	ByFingerprint(ctx context.Context, fingerprint string) (*PairingRequest, error)
+}
+
+// Pairing configures how devices pair.
+type Pairing struct {
+	// Store holds the pairing requests. It defaults to a
+	// MemoryPairingStore.
+	Store PairingStore
+
+	// OnApprove records the key of an approved request, such that the
+	// server's KeyStore knows it from then on, as with the Add method of the
+	// SQL store in keystore/sql. The request is only approved if it succeeds.
+	OnApprove func(ctx context.Context, r *PairingRequest) error
+
+	// TTL is how long requests stay open. It defaults to DefaultPairingTTL.
+	TTL time.Duration
+
+	// Clock tells when requests expire. It defaults to SystemClock.
+	Clock Clock
+
+	once sync.Once
+}
+
+// WithPairing opens pairing requests for clients whose key isn't in the
+// KeyStore, instead of rejecting them with ReasonUnknownKey. Such handshakes
+// fail with ReasonPairingPending. Without a KeyStore, every key is known, and
+// there is nothing to pair.
+func WithPairing(p *Pairing) Option {
+	return func(cfg *config) {
+		cfg.pairing = p
+	}
+}
+
+func (p *Pairing) store() PairingStore {
+	p.once.Do(func() {
+		if p.Store == nil {
+			p.Store = NewMemoryPairingStore()
+		}
+	})
+	return p.Store
+}
+
+func (p *Pairing) ttl() time.Duration {
+	if p.TTL <= 0 {
+		return DefaultPairingTTL
+	}
+	return p.TTL
+}
+
+// open returns the pending request for the client, opening one if there is
+// none.
+func (p *Pairing) open(ctx context.Context, random io.Reader, clientID, fingerprint, remoteAddr string) (*PairingRequest, error) {
+	now := clockOr(p.Clock).Now()
+
+	r, err := p.store().ByFingerprint(ctx, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil && r.State == PairingPending && !r.Expired(now) {
+		return r, nil
+	}
+
+	code, err := newPairingCode(random)
+	if err != nil {
+		return nil, err
+	}
+	r = &PairingRequest{
+		Code:        code,
+		ClientID:    clientID,
+		Fingerprint: fingerprint,
+		RemoteAddr:  remoteAddr,
+		State:       PairingPending,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(p.ttl()),
+	}
+	if err := p.store().Put(ctx, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Pending returns the pending request with code, for the approver to look
+// over before deciding on it.
+func (p *Pairing) Pending(ctx context.Context, code string) (*PairingRequest, error) {
+	r, err := p.store().ByCode(ctx, normalizePairingCode(code))
+	if err != nil {
+		return nil, err
+	}
+	if r == nil || r.State != PairingPending || r.Expired(clockOr(p.Clock).Now()) {
+		return nil, ErrPairingNotFound
+	}
+	return r, nil
+}
+
+// Approve approves the pending request with code, recording its key with
+// OnApprove. by says who approved it, such as the fingerprint of the trusted
+// client the code was entered on.
+func (p *Pairing) Approve(ctx context.Context, code, by string) (*PairingRequest, error) {
+	r, err := p.Pending(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if p.OnApprove != nil {
+		if err := p.OnApprove(ctx, r); err != nil {
+			return nil, err
+		}
+	}
+	return r, p.decide(ctx, r, PairingApproved, by)
+}
+
+// Reject turns down the pending request with code. The device may ask again,
+// and gets a new code if it does.
+func (p *Pairing) Reject(ctx context.Context, code, by string) (*PairingRequest, error) {
+	r, err := p.Pending(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	return r, p.decide(ctx, r, PairingRejected, by)
+}
+
+func (p *Pairing) decide(ctx context.Context, r *PairingRequest, state PairingState, by string) error {
+	decided := *r
+	decided.State, decided.DecidedBy = state, by
+	if err := p.store().Put(ctx, &decided); err != nil {
+		return err
+	}
+	*r = decided
+	return nil
+}
+
+// pairingAlphabet is Crockford's base32, which leaves out the letters most
+// easily mistaken for others.
+const pairingAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newPairingCode returns a code of 8 random characters, 40 bits, in two
+// groups of four.
+func newPairingCode(random io.Reader) (string, error) {
+	var b [8]byte
+	if err := readRandom(random, b[:]); err != nil {
+		return "", err
+	}
+	code := make([]byte, 0, 9)
+	for i, v := range b {
+		if i == 4 {
+			code = append(code, '-')
+		}
+		code = append(code, pairingAlphabet[v%32])
+	}
+	return string(code), nil
+}
+
+// normalizePairingCode undoes what people do to codes as they type them in:
+// lowercase letters, missing dashes, and letters for the digits they look
+// like.
+func normalizePairingCode(code string) string {
+	b := make([]byte, 0, 9)
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		if 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		switch c {
+		case 'O':
+			c = '0'
+		case 'I', 'L':
+			c = '1'
+		case '-', ' ':
+			continue
+		}
+		b = append(b, c)
+		if len(b) == 4 {
+			b = append(b, '-')
+		}
+	}
+	return string(b)
+}
+
+type pairingData struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type pairingMessage struct {
+	Type string      `json:"type"`
+	Data pairingData `json:"data"`
+}
+
+// pair opens a pairing request for a client that proved it holds its key.
+func (h *handshakeState) pair(clientID string) (bool, string, FailureReason, error) {
+	r, err := h.cfg.pairing.open(h.cfg.ctx, h.cfg.random, clientID, h.fingerprint, h.remoteAddr)
+	if err != nil {
+		h.conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to open pairing request", err))
+		return false, clientID, ReasonServerError, err
+	}
+	h.log.Info("wskeyauth: pairing request pending", "code", r.Code)
+	h.conn.WriteJSON(&pairingMessage{Type: "PAIRING_PENDING", Data: pairingData{Code: r.Code, ExpiresAt: r.ExpiresAt}})
+	return false, clientID, ReasonPairingPending, nil
+}
+
+// MemoryPairingStore is a PairingStore for a single process. It forgets
+// requests once they expire.
+type MemoryPairingStore struct {
+	// Clock tells when requests expire. It defaults to SystemClock.
+	Clock Clock
+
+	mu            sync.Mutex
+	byCode        map[string]*PairingRequest
+	byFingerprint map[string]*PairingRequest
+}
+
+// NewMemoryPairingStore creates an empty MemoryPairingStore.
+func NewMemoryPairingStore() *MemoryPairingStore {
+	return &MemoryPairingStore{
+		byCode:        map[string]*PairingRequest{},
+		byFingerprint: map[string]*PairingRequest{},
+	}
+}
+
+func (s *MemoryPairingStore) Put(_ context.Context, r *PairingRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := clockOr(s.Clock).Now()
+	for code, old := range s.byCode {
+		if old.Expired(now) {
+			delete(s.byCode, code)
+			if s.byFingerprint[old.Fingerprint] == old {
+				delete(s.byFingerprint, old.Fingerprint)
+			}
+		}
+	}
+
+	stored := *r
+	s.byCode[r.Code] = &stored
+	s.byFingerprint[r.Fingerprint] = &stored
+	return nil
+}
+
+func (s *MemoryPairingStore) ByCode(_ context.Context, code string) (*PairingRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyPairingRequest(s.byCode[code]), nil
+}
+
+func (s *MemoryPairingStore) ByFingerprint(_ context.Context, fingerprint string) (*PairingRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyPairingRequest(s.byFingerprint[fingerprint]), nil
+}
+
+func copyPairingRequest(r *PairingRequest) *PairingRequest {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	return &c
+}
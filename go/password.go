@@ -0,0 +1,182 @@
+package wskeyauth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/castcam-live/ws-key-auth/go/internal/srp"
+)
+
+// Clients that only have a password authenticate with SRP-6a, so that the
+// server never learns the password, and only stores a verifier of it. Their
+// client IDs name the user:
+//
+//	SRP-6a$<username>
+//
+// The CHALLENGE carries the user's salt, and the server's public value B:
+//
+//	{"type": "CHALLENGE", "data": {"salt": "<base64>", "ephemeral": "<base64 B>"}}
+//
+// and the client responds with its public value A, and its proof M1:
+//
+//	{
+//		"type": "CHALLENGE_RESPONSE",
+//		"data": {"ephemeral": "<base64 A>", "signature": "<base64 M1>", "hash": "SHA-256"}
+//	}
+//
+// If the proof matches, the data of SIGNATURE_MATCHES is the server's proof
+// M2, for the client to check that the server knew its verifier. Servers
+// that accept passwords advertise the "srp" extension, so that clients with
+// both a key and a password can pick. Their fingerprint is "SRP:<username>".
+
+const srpPrefix = "SRP-6a$"
+
+// PasswordVerifier is what the server stores for a password client, in place
+// of the password.
+type PasswordVerifier struct {
+	Salt     []byte
+	Verifier []byte
+}
+
+// NewPasswordVerifier returns the verifier of password for username, with a
+// new random salt, for enrolling a password client.
+func NewPasswordVerifier(username, password string) (*PasswordVerifier, error) {
+	salt, verifier, err := srp.NewVerifier(username, password)
+	if err != nil {
+		return nil, err
+	}
+	return &PasswordVerifier{Salt: salt, Verifier: verifier}, nil
+}
+
+// PasswordStore holds the verifiers of password clients.
+type PasswordStore interface {
+	// Verifier returns the verifier for username, or nil if there is none. An
+	// error fails the handshake with ReasonServerError.
+	Verifier(ctx context.Context, username string) (*PasswordVerifier, error)
+}
+
+// WithPasswords accepts SRP client IDs, authenticating them against the
+// verifiers in store. Without it, SRP client IDs are rejected. Key-based
+// clients are unaffected, so both can share an endpoint.
+func WithPasswords(store PasswordStore) Option {
+	return func(cfg *config) {
+		cfg.passwords = store
+	}
+}
+
+func parseSRPClientID(clientID string) (*publicKey, error) {
+	username := strings.TrimPrefix(clientID, srpPrefix)
+	if err := checkName(username, "username"); err != nil {
+		return nil, err
+	}
+	return &publicKey{username: username}, nil
+}
+
+type srpChallenge struct {
+	Salt      string `json:"salt"`
+	Ephemeral string `json:"ephemeral"`
+}
+
+type srpChallengeMessage struct {
+	Type         string        `json:"type"`
+	Data         srpChallenge  `json:"data"`
+	Capabilities *Capabilities `json:"capabilities"`
+}
+
+// runSRP runs the rest of the handshake with a password client, once it sent
+// its client ID.
+func (h *handshakeState) runSRP(username string) (bool, string, FailureReason, error) {
+	conn, cfg, trace, clientID := h.conn, h.cfg, h.trace, h.clientID
+
+	verifier, err := cfg.passwords.Verifier(cfg.ctx, username)
+	if err != nil {
+		conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to look up client key", err))
+		return false, clientID, ReasonServerError, err
+	}
+	if verifier == nil {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonUnknownKey, "Client key is not known", nil))
+		return false, clientID, ReasonUnknownKey, nil
+	}
+
+	trace.step("SendChallenge")
+
+	server, err := srp.NewServer(username, verifier.Salt, verifier.Verifier, cfg.random)
+	if err != nil {
+		conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to generate challenge", err))
+		return false, clientID, ReasonServerError, err
+	}
+
+	conn.WriteJSON(&srpChallengeMessage{
+		Type: "CHALLENGE",
+		Data: srpChallenge{
+			Salt:      base64.StdEncoding.EncodeToString(verifier.Salt),
+			Ephemeral: base64.StdEncoding.EncodeToString(server.B()),
+		},
+		Capabilities: cfg.capabilities(),
+	})
+	h.log.Debug("wskeyauth: sent challenge")
+	cfg.hooks.challengeSent(clientID)
+
+	h.srp = server
+
+	trace.step("ReadChallengeResponse")
+	return h.then((*handshakeState).readSRPResponse)
+}
+
+// readSRPResponse reads a password client's CHALLENGE_RESPONSE, and checks
+// its proof.
+func (h *handshakeState) readSRPResponse() (bool, string, FailureReason, error) {
+	conn, cfg, trace, clientID, server := h.conn, h.cfg, h.trace, h.clientID, h.srp
+
+	var msg ClientMessage
+	if reason, err := h.readMessage(&msg, "CHALLENGE_RESPONSE"); reason != "" {
+		return false, clientID, reason, err
+	}
+
+	if msg.Hash != "SHA-256" {
+		conn.WriteJSON(&stringMessage{
+			Type: "UNSUPPORTED_HASH",
+			Data: "Got hash of type " + msg.Hash + ", but the only supported hash currently is SHA-256 (more coming soon!)",
+		})
+		return false, clientID, ReasonUnsupportedHash, nil
+	}
+
+	if !cfg.algorithms.allowsHash(msg.Hash) {
+		conn.WriteJSON(&stringMessage{Type: "UNSUPPORTED_HASH", Data: msg.Hash + " is not accepted"})
+		return false, clientID, ReasonUnsupportedHash, nil
+	}
+
+	ephemeral, err := cfg.base64.decode(nil, msg.Ephemeral)
+	if err == nil && len(ephemeral) == 0 {
+		err = errors.New(`expected data to have an "ephemeral"`)
+	}
+	if err != nil {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Failed to parse CHALLENGE_RESPONSE", err))
+		return false, clientID, ReasonMalformedMessage, err
+	}
+	proof, err := cfg.base64.decode(nil, msg.Signature)
+	if err != nil {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Failed to parse CHALLENGE_RESPONSE", err))
+		return false, clientID, ReasonMalformedMessage, err
+	}
+
+	trace.step("VerifySignature")
+
+	start := time.Now()
+	serverProof, err := server.Verify(ephemeral, proof)
+	cfg.metrics.VerificationDuration(time.Since(start))
+
+	if errors.Is(err, srp.ErrMismatch) {
+		conn.WriteJSON(&typeMessage{Type: "SIGNATURE_MISMATCH"})
+		return false, clientID, ReasonSignatureMismatch, nil
+	}
+	if err != nil {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Failed to parse CHALLENGE_RESPONSE", err))
+		return false, clientID, ReasonMalformedMessage, err
+	}
+
+	return h.authenticate(base64.StdEncoding.EncodeToString(serverProof), msg.IDToken, server.B())
+}
@@ -0,0 +1,230 @@
+package wskeyauth
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Presence is whether a client is connected, and how.
+type Presence struct {
+	Fingerprint string
+
+	// ClientID is the client ID of the client's oldest session, or of its
+	// last one once it is offline.
+	ClientID string
+
+	// Since is when the client's oldest session connected. It is zero when
+	// the client isn't connected.
+	Since time.Time
+
+	// Connections is the number of sessions the client has.
+	Connections int
+}
+
+// Online reports whether the client has any sessions.
+func (p Presence) Online() bool {
+	return p.Connections > 0
+}
+
+type PresenceEventType int
+
+const (
+	// ClientOnline is emitted when a client's first session joins.
+	ClientOnline PresenceEventType = iota
+	// ClientOffline is emitted when a client's last session leaves.
+	ClientOffline
+)
+
+type PresenceEvent struct {
+	Type     PresenceEventType
+	Presence Presence
+}
+
+// PresenceBackend shares presence between servers, so that each can tell
+// whether a client is connected to any of them. Servers report the sessions
+// they hold themselves, and the backend adds them up.
+type PresenceBackend interface {
+	// Update records the client's presence on this server. Connections is
+	// zero once it has no sessions left here.
+	Update(ctx context.Context, p Presence) error
+
+	// Presence returns the client's presence across all servers.
+	Presence(ctx context.Context, fingerprint string) (Presence, error)
+
+	// Online returns the presence of every client connected to any server.
+	Online(ctx context.Context) ([]Presence, error)
+}
+
+// RegistryOption configures a Registry.
+type RegistryOption func(*Registry)
+
+// WithPresenceBackend has the registry report presence to b, and answer
+// Presence and Online with it.
+func WithPresenceBackend(b PresenceBackend) RegistryOption {
+	return func(r *Registry) {
+		r.presence = b
+	}
+}
+
+// Presence returns the presence of the client with the given client ID. With
+// a PresenceBackend, it is across all servers, rather than just this one.
+func (r *Registry) Presence(ctx context.Context, clientID string) (Presence, error) {
+	fingerprint, err := Fingerprint(clientID)
+	if err != nil {
+		return Presence{}, err
+	}
+	if r.presence != nil {
+		return r.presence.Presence(ctx, fingerprint)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return presenceOf(fingerprint, r.byFingerprint[fingerprint]), nil
+}
+
+// Online returns the presence of every connected client. With a
+// PresenceBackend, it is across all servers, rather than just this one.
+func (r *Registry) Online(ctx context.Context) ([]Presence, error) {
+	if r.presence != nil {
+		return r.presence.Online(ctx)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	online := make([]Presence, 0, len(r.byFingerprint))
+	for fingerprint, sessions := range r.byFingerprint {
+		online = append(online, presenceOf(fingerprint, sessions))
+	}
+	return online, nil
+}
+
+// SubscribePresence calls f whenever a client comes online or goes offline,
+// from the goroutine that caused it, until the returned function is called.
+// Only sessions of this registry are seen, even with a PresenceBackend.
+func (r *Registry) SubscribePresence(f func(PresenceEvent)) (unsubscribe func()) {
+	r.subscribersMu.Lock()
+	id := r.nextID
+	r.nextID++
+	r.presenceSubscribers[id] = f
+	r.subscribersMu.Unlock()
+
+	return func() {
+		r.subscribersMu.Lock()
+		delete(r.presenceSubscribers, id)
+		r.subscribersMu.Unlock()
+	}
+}
+
+func (r *Registry) emitPresence(e PresenceEvent) {
+	r.subscribersMu.RLock()
+	subscribers := make([]func(PresenceEvent), 0, len(r.presenceSubscribers))
+	for _, f := range r.presenceSubscribers {
+		subscribers = append(subscribers, f)
+	}
+	r.subscribersMu.RUnlock()
+
+	for _, f := range subscribers {
+		f(e)
+	}
+}
+
+// presenceOf returns the presence of the client with the given sessions.
+func presenceOf(fingerprint string, sessions map[*Session]struct{}) Presence {
+	p := Presence{Fingerprint: fingerprint, Connections: len(sessions)}
+	for s := range sessions {
+		if p.Since.IsZero() || s.ConnectedAt.Before(p.Since) {
+			p.ClientID, p.Since = s.ClientID(), s.ConnectedAt
+		}
+	}
+	return p
+}
+
+// updatePresence reports p to the presence backend, if there is one, giving
+// it DefaultTimeout to take it.
+func (r *Registry) updatePresence(p Presence) error {
+	if r.presence == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	return r.presence.Update(ctx, p)
+}
+
+// lockSessionPresence locks the presence of s's client, and of the clients
+// with the given fingerprints, returning s's fingerprint, which doesn't
+// change until the returned function unlocks them.
+func (r *Registry) lockSessionPresence(s *Session, fingerprints ...string) (string, func()) {
+	for {
+		fingerprint := s.Fingerprint()
+		unlock := r.presenceLocks.lock(append([]string{fingerprint}, fingerprints...)...)
+		// only reidentify changes it, with it locked
+		if s.Fingerprint() == fingerprint {
+			return fingerprint, unlock
+		}
+		unlock()
+	}
+}
+
+// presenceLocks serialize the updates of the presence backend for each
+// client, so that it sees them in order, without those of one client waiting
+// on those of another.
+type presenceLocks struct {
+	mu    sync.Mutex
+	locks map[string]*presenceLock
+}
+
+type presenceLock struct {
+	sync.Mutex
+
+	// refs is how many hold or wait for the lock, which is dropped once
+	// none do.
+	refs int
+}
+
+// lock locks the presence of the clients with the given fingerprints, in
+// order, so that those locking several at once don't deadlock, and returns
+// the function unlocking them.
+func (l *presenceLocks) lock(fingerprints ...string) func() {
+	sort.Strings(fingerprints)
+	n := 0
+	for i, fingerprint := range fingerprints {
+		if i == 0 || fingerprint != fingerprints[n-1] {
+			fingerprints[n] = fingerprint
+			n++
+		}
+	}
+	fingerprints = fingerprints[:n]
+
+	held := make([]*presenceLock, 0, n)
+	for _, fingerprint := range fingerprints {
+		l.mu.Lock()
+		if l.locks == nil {
+			l.locks = map[string]*presenceLock{}
+		}
+		k, ok := l.locks[fingerprint]
+		if !ok {
+			k = &presenceLock{}
+			l.locks[fingerprint] = k
+		}
+		k.refs++
+		l.mu.Unlock()
+
+		k.Lock()
+		held = append(held, k)
+	}
+
+	return func() {
+		l.mu.Lock()
+		for i, k := range held {
+			if k.refs--; k.refs == 0 {
+				delete(l.locks, fingerprints[i])
+			}
+		}
+		l.mu.Unlock()
+		for _, k := range held {
+			k.Unlock()
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package wskeyauth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+// blockingPresence blocks updates of the client with the given fingerprint
+// until release is closed.
+type blockingPresence struct {
+	fingerprint string
+	blocked     chan struct{}
+	release     chan struct{}
+}
+
+func (b *blockingPresence) Update(ctx context.Context, p wskeyauth.Presence) error {
+	if p.Fingerprint != b.fingerprint {
+		return nil
+	}
+	b.blocked <- struct{}{}
+	select {
+	case <-b.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *blockingPresence) Presence(context.Context, string) (wskeyauth.Presence, error) {
+	return wskeyauth.Presence{}, nil
+}
+
+func (b *blockingPresence) Online(context.Context) ([]wskeyauth.Presence, error) {
+	return nil, nil
+}
+
+func TestPresenceUpdatesDontBlockOthers(t *testing.T) {
+	slow, fast := wskeyauthtest.MustGenerateKey(), wskeyauthtest.MustGenerateKey()
+	fingerprint, err := wskeyauth.Fingerprint(slow.ClientID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &blockingPresence{fingerprint: fingerprint, blocked: make(chan struct{}), release: make(chan struct{})}
+	registry := wskeyauth.NewRegistry(wskeyauth.WithPresenceBackend(backend))
+
+	added := make(chan error, 1)
+	go func() {
+		_, err := registry.Add(slow.ClientID(), wskeyauthtest.NewMockConn())
+		added <- err
+	}()
+	<-backend.blocked
+
+	done := make(chan struct{})
+	go func() {
+		s, err := registry.Add(fast.ClientID(), wskeyauthtest.NewMockConn())
+		if err == nil {
+			registry.Remove(s)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("adding a session waited on the presence of another client")
+	}
+
+	close(backend.release)
+	if err := <-added; err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package wskeyauth
+
+import (
+	"crypto/sha256"
+	"math/bits"
+	"strconv"
+)
+
+// DefaultProofOfWorkDifficulty is the difficulty of proofs of work unless
+// ProofOfWork.Difficulty says otherwise. It takes a client some 65,000 hashes,
+// in the order of milliseconds.
+const DefaultProofOfWorkDifficulty = 16
+
+// ProofOfWorkPrefix starts everything hashed for a proof of work.
+const ProofOfWorkPrefix = "wskeyauth proof of work\x00"
+
+// maxProofOfWorkNonce bounds the nonces the server hashes.
+const maxProofOfWorkNonce = 64
+
+// ProofOfWork configures the server to have clients prove they did some
+// work in their CHALLENGE_RESPONSE, alongside their signature, so that
+// flooding the server with handshakes costs more than it costs the server to
+// turn them away. The CHALLENGE then says how much:
+//
+//	{"type": "CHALLENGE", "data": "<challenge>", "proofOfWork": {"difficulty": 16}}
+//
+// and the client answers with a nonce of its choosing:
+//
+//	{"type": "CHALLENGE_RESPONSE", "data": {"signature": "...", "hash": "SHA-256", "proofOfWork": "<nonce>"}}
+//
+// such that the SHA-256 of ProofOfWorkPrefix, followed by what the client
+// signs, which is the challenge and the server's audience, if it has one, and
+// the nonce, starts with difficulty zero bits. The work is checked before the
+// signature, and clients that didn't do it are sent PROOF_OF_WORK_MISMATCH.
+//
+// Clients that skip the challenge, with WithTLSClientAuth or a signed
+// timestamp, or that authenticate with a password, don't have to do the work.
+type ProofOfWork struct {
+	// Difficulty is how many zero bits the hash has to start with. It
+	// defaults to DefaultProofOfWorkDifficulty; every one more doubles the
+	// client's work.
+	Difficulty int
+
+	// Required, if not nil, decides for each handshake, of a client from
+	// remoteAddr, whether the client has to do the work, such as only while
+	// the server is under load:
+	//
+	//	Required: func(string) bool { return auth.InFlight() > 1000 }
+	//
+	// Without it, every client has to.
+	Required func(remoteAddr string) bool
+}
+
+// WithProofOfWork has clients prove they did some work before the server
+// checks their signature, as p says.
+func WithProofOfWork(p ProofOfWork) Option {
+	return func(cfg *config) {
+		cfg.proofOfWork = &p
+	}
+}
+
+// difficulty returns the difficulty of the work a client from remoteAddr has
+// to do, or 0 if it doesn't have to do any.
+func (p *ProofOfWork) difficulty(remoteAddr string) int {
+	if p == nil || p.Required != nil && !p.Required(remoteAddr) {
+		return 0
+	}
+	if p.Difficulty <= 0 {
+		return DefaultProofOfWorkDifficulty
+	}
+	return p.Difficulty
+}
+
+type proofOfWorkData struct {
+	Difficulty int `json:"difficulty"`
+}
+
+// CheckProofOfWork reports whether nonce proves difficulty's worth of work
+// for signed, which is what the client signs: the challenge, followed by the
+// server's audience, if it has one.
+func CheckProofOfWork(signed []byte, nonce string, difficulty int) bool {
+	h := sha256.New()
+	h.Write([]byte(ProofOfWorkPrefix))
+	h.Write(signed)
+	h.Write([]byte(nonce))
+
+	var sum [sha256.Size]byte
+	return leadingZeros(h.Sum(sum[:0])) >= difficulty
+}
+
+// SolveProofOfWork returns a nonce that proves difficulty's worth of work for
+// signed, as CheckProofOfWork checks it.
+func SolveProofOfWork(signed []byte, difficulty int) string {
+	h := sha256.New()
+	var sum [sha256.Size]byte
+	var nonce []byte
+	for i := uint64(0); ; i++ {
+		nonce = strconv.AppendUint(nonce[:0], i, 36)
+
+		h.Reset()
+		h.Write([]byte(ProofOfWorkPrefix))
+		h.Write(signed)
+		h.Write(nonce)
+		if leadingZeros(h.Sum(sum[:0])) >= difficulty {
+			return string(nonce)
+		}
+	}
+}
+
+func leadingZeros(b []byte) int {
+	n := 0
+	for _, c := range b {
+		if c != 0 {
+			return n + bits.LeadingZeros8(c)
+		}
+		n += 8
+	}
+	return n
+}
+
+// checkProofOfWork checks the work the client did for payload, if it had to
+// do any.
+func (h *handshakeState) checkProofOfWork(payload []byte, nonce string) (bool, FailureReason, error) {
+	if h.workDifficulty == 0 {
+		return true, "", nil
+	}
+
+	if nonce == "" || len(nonce) > maxProofOfWorkNonce {
+		h.conn.WriteJSON(&stringMessage{
+			Type: "PROOF_OF_WORK_MISMATCH",
+			Data: "Expected a proof of work of difficulty " + strconv.Itoa(h.workDifficulty),
+		})
+		return false, ReasonProofOfWorkMismatch, nil
+	}
+
+	signed := signedChallenge(payload, h.audience)
+	if !CheckProofOfWork(signed, nonce, h.workDifficulty) {
+		h.conn.WriteJSON(&typeMessage{Type: "PROOF_OF_WORK_MISMATCH"})
+		return false, ReasonProofOfWorkMismatch, nil
+	}
+	return true, "", nil
+}
@@ -0,0 +1,75 @@
+package wskeyauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Clients that share a secret with the server, rather than holding a key
+// pair, have client IDs that name the secret:
+//
+//	HMAC-SHA-256$<key ID>
+//
+// and send, in place of a signature, the base64 HMAC-SHA-256 of the challenge
+// followed by the server's audience, if any, keyed by the secret. They are
+// only accepted by servers whose KeyStore is a SecretStore. Their
+// fingerprint is "HMAC:<key ID>".
+
+const hmacPrefix = "HMAC-SHA-256$"
+
+const maxNameLength = 128
+
+// SecretStore is a KeyStore that holds pre-shared secrets too.
+type SecretStore interface {
+	KeyStore
+
+	// Secret returns the secret with keyID, or nil if there is none. An error
+	// fails the handshake with ReasonServerError.
+	Secret(ctx context.Context, keyID string) ([]byte, error)
+}
+
+// Secrets is a SecretStore of secrets by key ID, for servers whose only
+// clients are ones they share a secret with.
+type Secrets map[string][]byte
+
+// Lookup reports whether fingerprint is that of one of the secrets.
+func (s Secrets) Lookup(_ context.Context, fingerprint string) (bool, error) {
+	keyID, ok := strings.CutPrefix(fingerprint, "HMAC:")
+	return ok && s[keyID] != nil, nil
+}
+
+func (s Secrets) Secret(_ context.Context, keyID string) ([]byte, error) {
+	return s[keyID], nil
+}
+
+func parseKeyID(clientID string) (*publicKey, error) {
+	keyID := strings.TrimPrefix(clientID, hmacPrefix)
+	if err := checkName(keyID, "key ID"); err != nil {
+		return nil, err
+	}
+	return &publicKey{keyID: keyID}, nil
+}
+
+// checkName checks the name a client ID gives for a key or user.
+func checkName(name, what string) error {
+	if name == "" || len(name) > maxNameLength {
+		return fmt.Errorf("expected %s to be 1 to %d characters long", what, maxNameLength)
+	}
+	if strings.ContainsFunc(name, func(r rune) bool { return r == '$' || !unicode.IsGraphic(r) || unicode.IsSpace(r) }) {
+		return fmt.Errorf("expected %s to have no $, spaces or control characters", what)
+	}
+	return nil
+}
+
+// verifyHMAC reports whether mac is the HMAC of challenge and audience with
+// secret.
+func verifyHMAC(secret, challenge []byte, audience string, mac []byte) bool {
+	h := hmac.New(sha256.New, secret)
+	h.Write(challenge)
+	h.Write([]byte(audience))
+	return hmac.Equal(h.Sum(nil), mac)
+}
@@ -0,0 +1,58 @@
+package wskeyauth
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"io"
+)
+
+// WithRandom draws the handshake's random numbers, such as its challenge,
+// pairing codes, SRP ephemeral and Noise keys, from random, instead of
+// crypto/rand, for tests that need them reproducible, or that simulate the
+// source failing with wskeyauthtest.Random. A single Read must fill each
+// request; a short read fails the handshake with
+// ErrFailedToReadRandomNumbers, as it would with crypto/rand. Challenges taken
+// from a ChallengePool still come from crypto/rand, as do the keys of
+// EncryptServerSession and of the client's side of handshakes, which take no
+// options, and the randomness of server key signatures.
+//
+// Outside of tests, random must be a cryptographically secure source, or
+// challenges can be predicted, and signatures of them collected in advance.
+func WithRandom(random io.Reader) Option {
+	return func(cfg *config) {
+		cfg.random = random
+	}
+}
+
+// randomOr returns random, or crypto/rand's Reader if it is nil, for the
+// Random fields of types used outside of handshakes.
+func randomOr(random io.Reader) io.Reader {
+	if random == nil {
+		return rand.Reader
+	}
+	return random
+}
+
+// readRandom fills b from random with a single Read. It will be safe to
+// assume that any error coming from this function is a server error.
+func readRandom(random io.Reader, b []byte) error {
+	n, err := random.Read(b)
+	if err != nil {
+		return err
+	}
+	if n < len(b) {
+		return ErrFailedToReadRandomNumbers()
+	}
+	return nil
+}
+
+// x25519Key generates an X25519 key from random. The key is read off random
+// rather than left to GenerateKey, which ignores its reader under newer
+// GODEBUG defaults.
+func x25519Key(random io.Reader) (*ecdh.PrivateKey, error) {
+	b := make([]byte, 32)
+	if err := readRandom(random, b); err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPrivateKey(b)
+}
@@ -0,0 +1,72 @@
+package wskeyauth_test
+
+import (
+	"errors"
+	"testing"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+func TestRandomFailures(t *testing.T) {
+	broken := errors.New("entropy source is broken")
+	for _, test := range []struct {
+		name  string
+		fault func(*wskeyauthtest.Random)
+		is    func(error) bool
+	}{
+		{"ShortReads", func(r *wskeyauthtest.Random) { r.ShortReads(1) }, func(err error) bool {
+			return err != nil && err.Error() == wskeyauth.ErrFailedToReadRandomNumbers().Error()
+		}},
+		{"Fail", func(r *wskeyauthtest.Random) { r.Fail(broken) }, func(err error) bool {
+			return errors.Is(err, broken)
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			random := wskeyauthtest.NewRandom("seed")
+			test.fault(random)
+			key := wskeyauthtest.MustGenerateKey()
+
+			hs := wskeyauth.NewServerHandshake(wskeyauth.WithRandom(random))
+			hs.Start()
+			out, state, err := hs.Feed(marshal(t, map[string]any{"type": "CLIENT_ID", "data": key.ClientID()}))
+			if typ := only(t, out).Type; typ != "SERVER_ERROR" || state != wskeyauth.StateFailed || !test.is(err) {
+				t.Fatalf("Feed(CLIENT_ID) = %s, %v, %v", out, state, err)
+			}
+			if reason := hs.Result().Reason; reason != wskeyauth.ReasonServerError {
+				t.Fatalf("Reason = %s, want %s", reason, wskeyauth.ReasonServerError)
+			}
+		})
+	}
+}
+
+// noiseHandshake runs a Noise handshake over a pipe with opts, and returns
+// how it ended for the server.
+func noiseHandshake(t *testing.T, opts ...wskeyauth.Option) (bool, error) {
+	t.Helper()
+	key := wskeyauthtest.MustGenerateKey()
+	server, conn := wskeyauthtest.Pipe()
+	go func() {
+		wskeyauth.NoiseClientHandshake(conn, key.ClientID(), "", key.Sign)
+		conn.Close()
+	}()
+	authenticated, _, _, err := wskeyauth.NoiseHandshake(server, opts...)
+	return authenticated, err
+}
+
+func TestNoiseRandom(t *testing.T) {
+	random := wskeyauthtest.NewRandom("seed")
+	if authenticated, err := noiseHandshake(t, wskeyauth.WithRandom(random)); !authenticated {
+		t.Fatalf("NoiseHandshake() = %v", err)
+	}
+	// the server's static and ephemeral keys
+	if reads := random.Reads(); reads < 2 {
+		t.Fatalf("random was read %d times, want the server's keys drawn from it", reads)
+	}
+
+	broken := errors.New("entropy source is broken")
+	random.Fail(broken)
+	if authenticated, err := noiseHandshake(t, wskeyauth.WithRandom(random)); authenticated || !errors.Is(err, broken) {
+		t.Fatalf("NoiseHandshake() with a broken source = %v, %v", authenticated, err)
+	}
+}
@@ -0,0 +1,84 @@
+package wskeyauth
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// RateLimiter throttles handshakes. Handshakes are counted against the
+// client's address, as "addr:<host>", before anything is read, and against
+// its key, as "key:<fingerprint>", once it sent its client ID. A limiter
+// shared between servers, such as the Redis one in contrib/redis, throttles
+// clients across all of them.
+type RateLimiter interface {
+	// Allow counts a handshake against key, and reports whether it may go
+	// ahead. An error fails the handshake with ReasonServerError.
+	Allow(ctx context.Context, key string) (bool, error)
+}
+
+// WithRateLimiter rejects handshakes that limiter doesn't allow, with
+// RATE_LIMITED.
+func WithRateLimiter(limiter RateLimiter) Option {
+	return func(cfg *config) {
+		cfg.rateLimiter = limiter
+	}
+}
+
+// MemoryRateLimiter is a RateLimiter for a single process, allowing Limit
+// handshakes per key in each Window.
+type MemoryRateLimiter struct {
+	Limit  int
+	Window time.Duration
+
+	// Clock tells when windows start. It defaults to SystemClock.
+	Clock Clock
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+func (l *MemoryRateLimiter) Allow(_ context.Context, key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now := clockOr(l.Clock).Now(); l.counts == nil || now.Sub(l.start) >= l.Window {
+		l.start, l.counts = now, map[string]int{}
+	}
+	l.counts[key]++
+	return l.counts[key] <= l.Limit, nil
+}
+
+// allow checks the handshake against the bans and the rate limiter, if there
+// are any, and tells the client if it isn't allowed.
+func (h *handshakeState) allow(key string) (bool, FailureReason, error) {
+	if h.cfg.bans != nil && h.cfg.bans.applies(key) {
+		if ok, reason, err := h.checkBan(key); !ok {
+			return false, reason, err
+		}
+	}
+	if h.cfg.rateLimiter == nil {
+		return true, "", nil
+	}
+
+	ok, err := h.cfg.rateLimiter.Allow(h.cfg.ctx, key)
+	if err != nil {
+		h.conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to check rate limit", err))
+		return false, ReasonServerError, err
+	}
+	if !ok {
+		h.conn.WriteJSON(&stringMessage{Type: "RATE_LIMITED", Data: "Too many handshakes, try again later"})
+		return false, ReasonRateLimited, nil
+	}
+	return true, "", nil
+}
+
+// addrKey returns the rate limit key of a remote address.
+func addrKey(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return "addr:" + host
+	}
+	return "addr:" + remoteAddr
+}
@@ -0,0 +1,149 @@
+package wskeyauth
+
+import (
+	"errors"
+	"time"
+)
+
+// Clients rotate their keys without reconnecting, which would interrupt
+// whatever they are streaming, by sending
+//
+//	{"type": "RE_AUTH"}
+//
+// once authenticated, and then running the handshake again over the same
+// connection, from its CLIENT_ID on, with the new key. Servers handling it
+// with ReAuth answer as to any handshake, and, if it succeeds, the
+// connection takes on the new key's identity.
+
+// ReAuth runs fresh handshakes over connections that are authenticated
+// already.
+type ReAuth struct {
+	// Options are the options of the handshake, which are generally those
+	// the connection was first authenticated with.
+	Options []Option
+}
+
+// Handle handles msg, a message the client sent over conn once
+// authenticated, if it is a RE_AUTH, by running the handshake again. It
+// reports whether it was; the application should handle any other message
+// itself, as with Refresh.Handle.
+//
+// If the handshake succeeds, the application should treat the connection as
+// of the result's client ID from then on. If it fails, the client keeps its
+// identity, and it is for the application to decide whether to disconnect
+// it; the error is that of Handshake.
+//
+// Handle reads from conn for the handshake, and writes its messages to it, so
+// it must be called from the loop that reads conn, and not while anything
+// else writes to conn; use HandleSession for registered sessions.
+func (r *ReAuth) Handle(conn Conn, msg TypeData) (bool, *Result, error) {
+	if msg.Type != "RE_AUTH" {
+		return false, nil, nil
+	}
+	result, err := HandshakeResult(conn, r.Options...)
+	return true, result, err
+}
+
+// HandleSession handles msg as Handle does, for a registered session,
+// writing with Session.Send. If the handshake succeeds, the session takes on
+// the new client ID and fingerprint at once, as far as its registry is
+// concerned, with the presence of both the old and the new key updated;
+// lookups find it under one or the other, never both, nor neither. The
+// registry then emits a SessionReidentified event, on which Channels and
+// other subscribers check what the session may still do.
+func (r *ReAuth) HandleSession(s *Session, msg TypeData) (bool, *Result, error) {
+	ok, result, err := r.Handle(reAuthConn{s}, msg)
+	if !ok || !result.Authenticated {
+		return ok, result, err
+	}
+	return true, result, s.registry.reidentify(s, result.ClientID)
+}
+
+// reidentify gives s the identity of clientID. A session that left its
+// registry only has its fields changed.
+func (r *Registry) reidentify(s *Session, clientID string) error {
+	fingerprint, err := Fingerprint(clientID)
+	if err != nil {
+		return err
+	}
+
+	old, unlock := r.lockSessionPresence(s, fingerprint)
+	r.mu.Lock()
+	oldClientID, _ := s.Identity()
+	sessions, ok := r.byFingerprint[old]
+	if ok {
+		_, ok = sessions[s]
+	}
+	if !ok {
+		s.identity.Store(&sessionIdentity{clientID: clientID, fingerprint: fingerprint})
+		r.mu.Unlock()
+		unlock()
+		return nil
+	}
+
+	delete(sessions, s)
+	if len(sessions) == 0 {
+		delete(r.byFingerprint, old)
+	}
+	s.identity.Store(&sessionIdentity{clientID: clientID, fingerprint: fingerprint})
+	others, online := r.byFingerprint[fingerprint]
+	if !online {
+		others = map[*Session]struct{}{}
+		r.byFingerprint[fingerprint] = others
+	}
+	others[s] = struct{}{}
+
+	left := presenceOf(old, sessions)
+	if !left.Online() {
+		left.ClientID = oldClientID
+	}
+	joined := presenceOf(fingerprint, others)
+	r.mu.Unlock()
+
+	if old != fingerprint {
+		_ = r.updatePresence(left)
+		err = r.updatePresence(joined)
+	}
+	unlock()
+
+	if old != fingerprint {
+		if !left.Online() {
+			r.emitPresence(PresenceEvent{Type: ClientOffline, Presence: left})
+		}
+		if !online {
+			r.emitPresence(PresenceEvent{Type: ClientOnline, Presence: joined})
+		}
+	}
+	if clientID != oldClientID {
+		r.emit(RegistryEvent{Type: SessionReidentified, Session: s, PreviousClientID: oldClientID})
+	}
+	return err
+}
+
+// reAuthConn runs a handshake over a session, writing through Send. It
+// interrupts reads on connections with a SetReadDeadline method, so that a
+// handshake that times out ends as it would on a fresh connection.
+type reAuthConn struct {
+	s *Session
+}
+
+func (c reAuthConn) ReadJSON(v any) error  { return c.s.Conn.ReadJSON(v) }
+func (c reAuthConn) WriteJSON(v any) error { return c.s.Send(v) }
+
+func (c reAuthConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	w, ok := c.s.Conn.(controlWriter)
+	if !ok {
+		return errors.New("wskeyauth: connection can't write control frames")
+	}
+	c.s.writeMu.Lock()
+	defer c.s.writeMu.Unlock()
+	return w.WriteControl(messageType, data, deadline)
+}
+
+func (c reAuthConn) SetReadDeadline(t time.Time) error {
+	d, ok := c.s.Conn.(readDeadliner)
+	if !ok {
+		return errors.New("wskeyauth: connection can't be interrupted")
+	}
+	return d.SetReadDeadline(t)
+}
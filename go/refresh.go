@@ -0,0 +1,167 @@
+package wskeyauth
+
+import (
+	"context"
+	"errors"
+)
+
+// Access tokens, macaroons and TURN credentials handed out in
+// SIGNATURE_MATCHES expire, often long before the connection ends. Clients
+// renew them without reconnecting by sending
+//
+//	{"type": "REFRESH"}
+//
+// once authenticated, to which servers handling it with Refresh respond with
+// fresh credentials, in the members SIGNATURE_MATCHES has them in:
+//
+//	{"type": "REFRESHED", "token": {...}, "macaroon": "..."}
+//
+// or, if the client may no longer have them, with
+//
+//	{"type": "REFRESH_DENIED", "data": "..."}
+
+// ErrRefreshDenied is returned by Refresh.Handle when the client was denied
+// fresh credentials.
+var ErrRefreshDenied = errors.New("wskeyauth: refresh denied")
+
+// Refresh hands authenticated clients fresh credentials. It should be
+// configured as the handshake's WithTokenExchange and WithMacaroons were.
+type Refresh struct {
+	// TokenExchange, if set, issues fresh access tokens.
+	TokenExchange *TokenExchange
+
+	// Macaroons, if set, mints fresh macaroons.
+	Macaroons *Macaroons
+
+	// TURN, if set, issues fresh TURN credentials.
+	TURN *TURN
+
+	// KeyStore, if set, must still know the client's key, so that keys
+	// removed from it are denied.
+	KeyStore KeyStore
+
+	// Guests, if set, refreshes clients whose keys KeyStore doesn't know as
+	// guests, as WithGuests lets them in, rather than denying them: their
+	// access tokens are of the guests' scope, and their macaroons carry the
+	// guests' scope caveat. It needs KeyStore, to tell guests apart.
+	Guests *Guests
+
+	// Allow, if set, is called before every refresh, and denies it by
+	// returning an error, as for a key that was revoked.
+	Allow func(ctx context.Context, clientID, fingerprint string) error
+
+	// ErrorVerbosity is how much the errors sent to clients tell them, as
+	// WithErrorVerbosity sets for handshakes.
+	ErrorVerbosity ErrorVerbosity
+
+	// Messages, if set, replaces the text of the errors sent to clients, as
+	// WithMessageCatalog does for handshakes.
+	Messages MessageCatalog
+
+	// Clock tells when TURN credentials expire. It defaults to
+	// SystemClock.
+	Clock Clock
+}
+
+type refreshedMessage struct {
+	Type     string           `json:"type"`
+	Token    *AccessToken     `json:"token,omitempty"`
+	Macaroon string           `json:"macaroon,omitempty"`
+	TURN     *TURNCredentials `json:"turn,omitempty"`
+}
+
+// Handle handles msg, a message the client with clientID sent over conn once
+// authenticated, if it is a REFRESH. It reports whether it was; the
+// application should handle any other message itself:
+//
+//	for {
+//		var msg wskeyauth.TypeData
+//		if err := conn.ReadJSON(&msg); err != nil {
+//			return err
+//		}
+//		if ok, err := refresh.Handle(ctx, conn, clientID, msg); ok {
+//			if errors.Is(err, wskeyauth.ErrRefreshDenied) {
+//				return err
+//			}
+//			continue
+//		}
+//		...
+//	}
+//
+// Handle writes its response to conn, so it must not be called while
+// anything else writes to conn; use HandleSession for registered sessions.
+func (r *Refresh) Handle(ctx context.Context, conn Conn, clientID string, msg TypeData) (bool, error) {
+	if msg.Type != "REFRESH" {
+		return false, nil
+	}
+	conn = r.ErrorVerbosity.wrap(wrapCatalog(conn, r.Messages))
+
+	fp, err := Fingerprint(clientID)
+	if err != nil {
+		return true, err
+	}
+
+	var guest bool
+	if r.KeyStore != nil {
+		known, err := r.KeyStore.Lookup(ctx, fp)
+		if err != nil {
+			conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to look up client key", err))
+			return true, err
+		}
+		if !known && r.Guests == nil {
+			conn.WriteJSON(&stringMessage{Type: "REFRESH_DENIED", Data: "Client key is not known"})
+			return true, ErrRefreshDenied
+		}
+		guest = !known
+	} else if r.Guests != nil {
+		err := errors.New("wskeyauth: Refresh.Guests needs a KeyStore")
+		conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to tell whether the client is a guest", err))
+		return true, err
+	}
+	if r.Allow != nil {
+		if err := r.Allow(ctx, clientID, fp); err != nil {
+			conn.WriteJSON(&stringMessage{Type: "REFRESH_DENIED", Data: "Refresh was denied"})
+			return true, errors.Join(ErrRefreshDenied, err)
+		}
+	}
+
+	reply := &refreshedMessage{Type: "REFRESHED"}
+	if r.TokenExchange != nil {
+		scope := r.TokenExchange.Scope
+		if guest {
+			scope = r.Guests.scope()
+		}
+		if reply.Token, err = r.TokenExchange.exchange(ctx, clientID, fp, scope); err != nil {
+			conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to exchange token", err))
+			return true, err
+		}
+	}
+	if r.Macaroons != nil {
+		var caveats []string
+		if guest {
+			caveats = append(caveats, r.Guests.caveat())
+		}
+		if reply.Macaroon, err = r.Macaroons.mint(clientID, fp, caveats...); err != nil {
+			conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to mint macaroon", err))
+			return true, err
+		}
+	}
+	if r.TURN != nil {
+		reply.TURN = r.TURN.Credentials(fp, clockOr(r.Clock).Now())
+	}
+	return true, conn.WriteJSON(reply)
+}
+
+// HandleSession handles msg as Handle does, for a registered session, writing
+// its response with Session.Send.
+func (r *Refresh) HandleSession(ctx context.Context, s *Session, msg TypeData) (bool, error) {
+	return r.Handle(ctx, sessionConn{s}, s.ClientID(), msg)
+}
+
+// sessionConn writes to a session through Send.
+type sessionConn struct {
+	s *Session
+}
+
+func (c sessionConn) ReadJSON(v any) error  { return c.s.Conn.ReadJSON(v) }
+func (c sessionConn) WriteJSON(v any) error { return c.s.Send(v) }
@@ -0,0 +1,84 @@
+package wskeyauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+// noKeys is a KeyStore that knows no keys.
+type noKeys struct{}
+
+func (noKeys) Lookup(context.Context, string) (bool, error) { return false, nil }
+
+// recordConn records the messages written to it, whole.
+type recordConn struct {
+	written []json.RawMessage
+}
+
+func (c *recordConn) ReadJSON(any) error { return nil }
+
+func (c *recordConn) WriteJSON(v any) error {
+	b, err := json.Marshal(v)
+	c.written = append(c.written, b)
+	return err
+}
+
+func TestRefreshGuests(t *testing.T) {
+	var scopes []string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scopes = append(scopes, r.PostFormValue("scope"))
+		w.Write([]byte(`{"access_token": "token", "token_type": "Bearer"}`))
+	}))
+	defer endpoint.Close()
+
+	guests := &wskeyauth.Guests{Scopes: []string{"read", "list"}}
+	macaroons := &wskeyauth.Macaroons{Secret: clusterSecret}
+	refresh := &wskeyauth.Refresh{
+		TokenExchange: &wskeyauth.TokenExchange{Endpoint: endpoint.URL, Scope: "read write"},
+		Macaroons:     macaroons,
+		KeyStore:      noKeys{},
+		Guests:        guests,
+	}
+
+	key := wskeyauthtest.MustGenerateKey()
+	conn := &recordConn{}
+	ok, err := refresh.Handle(context.Background(), conn, key.ClientID(), wskeyauth.TypeData{Type: "REFRESH"})
+	if !ok || err != nil {
+		t.Fatalf("Handle() = %v, %v", ok, err)
+	}
+
+	var reply struct {
+		Type     string `json:"type"`
+		Macaroon string `json:"macaroon"`
+	}
+	if len(conn.written) != 1 || json.Unmarshal(conn.written[0], &reply) != nil || reply.Type != "REFRESHED" {
+		t.Fatalf("Handle() wrote %s, want a REFRESHED", conn.written)
+	}
+	if want := []string{"read list"}; !slices.Equal(scopes, want) {
+		t.Fatalf("token exchange got scopes %q, want %q", scopes, want)
+	}
+	var caveats []string
+	if _, err := macaroons.Verify(reply.Macaroon, func(caveat string) error {
+		caveats = append(caveats, caveat)
+		return nil
+	}); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+	if want := []string{"scope = read list"}; !slices.Equal(caveats, want) {
+		t.Fatalf("macaroon has caveats %q, want %q", caveats, want)
+	}
+
+	// without Guests, clients the KeyStore doesn't know are denied
+	refresh.Guests = nil
+	if _, err := refresh.Handle(context.Background(), &recordConn{}, key.ClientID(), wskeyauth.TypeData{Type: "REFRESH"}); !errors.Is(err, wskeyauth.ErrRefreshDenied) {
+		t.Fatalf("Handle() without Guests = %v, want ErrRefreshDenied", err)
+	}
+}
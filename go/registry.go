@@ -0,0 +1,494 @@
+package wskeyauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fingerprint returns a short, stable identifier for the key behind a client
+// ID, in the same "SHA256:<base64>" form that OpenSSH uses for its keys. The
+// key may be in any variant of base64, and the fingerprint is the same in
+// each. It is the same in every client ID format, too, so it differs from
+// ssh-keygen's fingerprint of an SSH key, which hashes the key's SSH
+// encoding. HMAC and SRP client IDs, which have no key, are fingerprinted by
+// the name they give, as "HMAC:<key ID>" and "SRP:<username>".
+func Fingerprint(clientID string) (string, error) {
+	pubKey, err := parseClientID(clientID, Base64Any)
+	if err != nil {
+		return "", err
+	}
+	return fingerprint(pubKey), nil
+}
+
+func fingerprint(pubKey *publicKey) string {
+	if pubKey.keyID != "" {
+		return "HMAC:" + pubKey.keyID
+	}
+	if pubKey.username != "" {
+		return "SRP:" + pubKey.username
+	}
+	if pubKey.ed25519 != nil {
+		sum := sha256.Sum256(pubKey.ed25519)
+		return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+	}
+
+	// the uncompressed point, as in the client ID
+	var raw [65]byte
+	raw[0] = 4
+	pubKey.ecdsa.X.FillBytes(raw[1:33])
+	pubKey.ecdsa.Y.FillBytes(raw[33:])
+
+	sum := sha256.Sum256(raw[:])
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// Session is an authenticated connection tracked by a Registry.
+type Session struct {
+	Conn        Conn
+	ConnectedAt time.Time
+
+	// RemoteAddr is the address of the client, if Conn has a RemoteAddr
+	// method, as gorilla/websocket's does.
+	RemoteAddr string
+
+	registry *Registry
+	writeMu  sync.Mutex
+
+	metadataMu sync.RWMutex
+	metadata   map[any]metadataValue
+
+	sessionExpiry
+
+	// identity is replaced whole when the session re-authenticates, so that
+	// it can be read without holding the registry's lock.
+	identity atomic.Pointer[sessionIdentity]
+}
+
+type sessionIdentity struct {
+	clientID    string
+	fingerprint string
+}
+
+// ClientID returns the client ID the session authenticated with, or, if it
+// re-authenticated since, the one it last did.
+func (s *Session) ClientID() string {
+	return s.identity.Load().clientID
+}
+
+// Fingerprint returns the fingerprint of the session's client ID.
+func (s *Session) Fingerprint() string {
+	return s.identity.Load().fingerprint
+}
+
+// Identity returns the session's client ID and its fingerprint together, which
+// separate calls to ClientID and Fingerprint might not if the session
+// re-authenticates between them.
+func (s *Session) Identity() (clientID, fingerprint string) {
+	id := s.identity.Load()
+	return id.clientID, id.fingerprint
+}
+
+// Send writes v to the session's connection. Connections, gorilla's among
+// them, generally don't support concurrent writes, so once a session is
+// registered all writes to it should go through Send, which serializes them.
+func (s *Session) Send(v any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.Conn.WriteJSON(v)
+}
+
+// Disconnect closes the session's connection, telling the client why with a
+// close frame with closeCode and reason if the connection has a WriteControl
+// method, as gorilla/websocket's does, and removes the session from its
+// registry. The connection must be an io.Closer.
+func (s *Session) Disconnect(closeCode int, reason string) error {
+	c, ok := s.Conn.(io.Closer)
+	if !ok {
+		return fmt.Errorf("wskeyauth: can't close a %T", s.Conn)
+	}
+
+	s.writeMu.Lock()
+	if w, ok := s.Conn.(controlWriter); ok {
+		w.WriteControl(closeMessage, closeFrame(closeCode, reason), time.Now().Add(time.Second))
+	}
+	err := c.Close()
+	s.writeMu.Unlock()
+
+	s.Leave()
+	return err
+}
+
+// Leave removes the session from its registry. It is safe to call more than
+// once.
+func (s *Session) Leave() {
+	s.registry.Remove(s)
+}
+
+type RegistryEventType int
+
+const (
+	// SessionJoined is emitted after a session was added to the registry.
+	SessionJoined RegistryEventType = iota
+	// SessionLeft is emitted after a session was removed from the registry.
+	SessionLeft
+	// ChannelJoined is emitted after a session joined one of the registry's
+	// Channels.
+	ChannelJoined
+	// ChannelLeft is emitted after a session left one of the registry's
+	// Channels, including when it left the registry.
+	ChannelLeft
+	// SessionReidentified is emitted after a session took on a new client ID
+	// by re-authenticating, with ReAuth.HandleSession.
+	SessionReidentified
+)
+
+type RegistryEvent struct {
+	Type    RegistryEventType
+	Session *Session
+
+	// Channel is the channel of ChannelJoined and ChannelLeft events.
+	Channel string
+
+	// PreviousClientID is the client ID the session had before a
+	// SessionReidentified event.
+	PreviousClientID string
+}
+
+// Registry tracks authenticated connections. A client may hold several
+// connections at once, so every lookup returns all of them. Sessions are keyed
+// by their key's fingerprint, meaning that different encodings of the same
+// key are treated as the same client.
+type Registry struct {
+	mu            sync.RWMutex
+	byFingerprint map[string]map[*Session]struct{}
+	count         int
+
+	// closed is set once an Authenticator shut down, after which sessions
+	// are refused
+	closed bool
+
+	presenceLocks presenceLocks
+	presence      PresenceBackend
+
+	expiry SessionExpiry
+	clock  Clock
+
+	cluster ClusterBackend
+	node    string
+
+	events *EventBus
+
+	subscribersMu       sync.RWMutex
+	subscribers         map[int]func(RegistryEvent)
+	presenceSubscribers map[int]func(PresenceEvent)
+	nextID              int
+}
+
+func NewRegistry(opts ...RegistryOption) *Registry {
+	r := &Registry{
+		byFingerprint:       map[string]map[*Session]struct{}{},
+		subscribers:         map[int]func(RegistryEvent){},
+		presenceSubscribers: map[int]func(PresenceEvent){},
+		clock:               SystemClock,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Add registers conn as authenticated with clientID, which should be the
+// client ID returned by Handshake. Call Leave on the returned session once the
+// connection is closed. With a PresenceBackend, Add fails if the backend
+// can't be told about the session. Once an Authenticator with the registry
+// shut down, Add fails with ErrShuttingDown, as the session would be left
+// connected; close the connection.
+func (r *Registry) Add(clientID string, conn Conn) (*Session, error) {
+	fingerprint, err := Fingerprint(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		Conn:        conn,
+		ConnectedAt: r.clock.Now(),
+		registry:    r,
+	}
+	s.identity.Store(&sessionIdentity{clientID: clientID, fingerprint: fingerprint})
+	if a, ok := conn.(interface{ RemoteAddr() net.Addr }); ok {
+		s.RemoteAddr = a.RemoteAddr().String()
+	}
+	r.startExpiry(s)
+
+	unlock := r.presenceLocks.lock(fingerprint)
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		unlock()
+		s.stopExpiry()
+		return nil, ErrShuttingDown
+	}
+	sessions, ok := r.byFingerprint[fingerprint]
+	if !ok {
+		sessions = map[*Session]struct{}{}
+		r.byFingerprint[fingerprint] = sessions
+	}
+	sessions[s] = struct{}{}
+	r.count++
+	p := presenceOf(fingerprint, sessions)
+	r.mu.Unlock()
+
+	if err := r.updatePresence(p); err != nil {
+		r.remove(s)
+		unlock()
+		return nil, err
+	}
+	unlock()
+
+	r.emit(RegistryEvent{Type: SessionJoined, Session: s})
+	if !ok {
+		r.emitPresence(PresenceEvent{Type: ClientOnline, Presence: p})
+	}
+
+	return s, nil
+}
+
+// Remove unregisters s. Removing a session that isn't registered is a no-op.
+// With a PresenceBackend, failing to report that s left is ignored, as there
+// is nothing the caller could do about it.
+func (r *Registry) Remove(s *Session) {
+	_, unlock := r.lockSessionPresence(s)
+	p, ok := r.remove(s)
+	if ok {
+		_ = r.updatePresence(p)
+	}
+	unlock()
+
+	if ok {
+		r.emit(RegistryEvent{Type: SessionLeft, Session: s})
+		if r.events != nil {
+			r.events.Publish(Event{Kind: EventDisconnected, ClientID: s.ClientID(), Fingerprint: s.Fingerprint(), RemoteAddr: s.RemoteAddr, Session: s})
+		}
+		if !p.Online() {
+			r.emitPresence(PresenceEvent{Type: ClientOffline, Presence: p})
+		}
+	}
+}
+
+// remove unregisters s, returning the presence of its client afterwards, and
+// whether it was registered.
+func (r *Registry) remove(s *Session) (Presence, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sessions, ok := r.byFingerprint[s.Fingerprint()]
+	if ok {
+		_, ok = sessions[s]
+	}
+	if !ok {
+		return Presence{}, false
+	}
+	s.stopExpiry()
+
+	delete(sessions, s)
+	if len(sessions) == 0 {
+		delete(r.byFingerprint, s.Fingerprint())
+	}
+	r.count--
+
+	p := presenceOf(s.Fingerprint(), sessions)
+	if !p.Online() {
+		p.ClientID = s.ClientID()
+	}
+	return p, true
+}
+
+// registered reports whether s is registered.
+func (r *Registry) registered(s *Session) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.byFingerprint[s.Fingerprint()][s]
+	return ok
+}
+
+// Get returns the sessions of the client with the given client ID.
+func (r *Registry) Get(clientID string) []*Session {
+	fingerprint, err := Fingerprint(clientID)
+	if err != nil {
+		return nil
+	}
+	return r.GetByFingerprint(fingerprint)
+}
+
+// GetByFingerprint returns the sessions of the client whose key has the given
+// fingerprint.
+func (r *Registry) GetByFingerprint(fingerprint string) []*Session {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := r.byFingerprint[fingerprint]
+	result := make([]*Session, 0, len(sessions))
+	for s := range sessions {
+		result = append(result, s)
+	}
+	return result
+}
+
+// Range calls f for every registered session, until f returns false. f works
+// on a snapshot, so it may add and remove sessions itself.
+func (r *Registry) Range(f func(s *Session) bool) {
+	r.mu.RLock()
+	snapshot := make([]*Session, 0, r.count)
+	for _, sessions := range r.byFingerprint {
+		for s := range sessions {
+			snapshot = append(snapshot, s)
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, s := range snapshot {
+		if !f(s) {
+			return
+		}
+	}
+}
+
+// Count returns the number of registered sessions.
+func (r *Registry) Count() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.count
+}
+
+// ErrNotConnected is returned by SendTo and Disconnect when the client has no sessions.
+var ErrNotConnected = errors.New("client is not connected")
+
+// SendTo sends message to every session of the client with the given client
+// ID, returning the errors of those it failed to send to. In a cluster, it is
+// sent to the sessions of the other servers too; see JoinCluster.
+func (r *Registry) SendTo(clientID string, message any) error {
+	fingerprint, err := Fingerprint(clientID)
+	if err != nil {
+		return ErrNotConnected
+	}
+	sessions := r.GetByFingerprint(fingerprint)
+
+	var published bool
+	if len(sessions) > 0 || r.connectedElsewhere(fingerprint) {
+		published, err = r.publish(ClusterMessage{Op: ClusterSendTo, Fingerprint: fingerprint}, message)
+	}
+	if len(sessions) == 0 && !published {
+		return ErrNotConnected
+	}
+	return errors.Join(err, send(sessions, message))
+}
+
+// Disconnect disconnects every session of the client with the given client
+// ID, as Session.Disconnect does, for when its key was revoked or its account
+// banned. It returns ErrNotConnected if the client has no sessions. In a
+// cluster, the sessions of the other servers are disconnected too; see
+// JoinCluster.
+func (r *Registry) Disconnect(clientID string, closeCode int, reason string) error {
+	fingerprint, err := Fingerprint(clientID)
+	if err != nil {
+		return ErrNotConnected
+	}
+	return r.DisconnectFingerprint(fingerprint, closeCode, reason)
+}
+
+// DisconnectFingerprint disconnects every session of the client with
+// fingerprint, as Disconnect does.
+func (r *Registry) DisconnectFingerprint(fingerprint string, closeCode int, reason string) error {
+	sessions := r.GetByFingerprint(fingerprint)
+
+	var published bool
+	var err error
+	var errs []error
+	if len(sessions) > 0 || r.connectedElsewhere(fingerprint) {
+		published, err = r.publish(ClusterMessage{Op: ClusterDisconnect, Fingerprint: fingerprint, CloseCode: closeCode, Reason: reason}, nil)
+		errs = append(errs, err)
+	}
+	if len(sessions) == 0 && !published {
+		return ErrNotConnected
+	}
+
+	for _, s := range sessions {
+		if err := s.Disconnect(closeCode, reason); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Fingerprint(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Broadcast sends message to every registered session, returning the errors
+// of those it failed to send to. Sessions are written to concurrently, so that
+// a slow connection doesn't hold up the others. In a cluster, it is sent to
+// the sessions of the other servers too; see JoinCluster.
+func (r *Registry) Broadcast(message any) error {
+	_, err := r.publish(ClusterMessage{Op: ClusterBroadcast}, message)
+	return errors.Join(err, r.broadcast(message))
+}
+
+// broadcast sends message to every session of this server.
+func (r *Registry) broadcast(message any) error {
+	var sessions []*Session
+	r.Range(func(s *Session) bool {
+		sessions = append(sessions, s)
+		return true
+	})
+	return send(sessions, message)
+}
+
+func send(sessions []*Session, message any) error {
+	errs := make([]error, len(sessions))
+	var wg sync.WaitGroup
+	for i, s := range sessions {
+		wg.Add(1)
+		go func(i int, s *Session) {
+			defer wg.Done()
+			if err := s.Send(message); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", s.Fingerprint(), err)
+			}
+		}(i, s)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Subscribe calls f with every join and leave event, of sessions and of
+// channels, and every re-authentication, from the goroutine that caused it,
+// until the returned function is called.
+func (r *Registry) Subscribe(f func(RegistryEvent)) (unsubscribe func()) {
+	r.subscribersMu.Lock()
+	id := r.nextID
+	r.nextID++
+	r.subscribers[id] = f
+	r.subscribersMu.Unlock()
+
+	return func() {
+		r.subscribersMu.Lock()
+		delete(r.subscribers, id)
+		r.subscribersMu.Unlock()
+	}
+}
+
+func (r *Registry) emit(e RegistryEvent) {
+	r.subscribersMu.RLock()
+	subscribers := make([]func(RegistryEvent), 0, len(r.subscribers))
+	for _, f := range r.subscribers {
+		subscribers = append(subscribers, f)
+	}
+	r.subscribersMu.RUnlock()
+
+	for _, f := range subscribers {
+		f(e)
+	}
+}
@@ -0,0 +1,67 @@
+package wskeyauth
+
+import "errors"
+
+// WithRetries lets clients resend a message that failed to parse, or wasn't of
+// the type expected, up to n times over the handshake, rather than having to
+// reconnect, which is slow on mobile networks. The CLIENT_ERROR for such a
+// message has "retry" set while retries remain:
+//
+//	{"type": "CLIENT_ERROR", "data": {"message": "Failed to parse CLIENT_ID", "error": "..."}, "retry": true}
+//
+// and the server waits for the message again, which must then arrive within
+// what remains of WithTimeout. Messages that parse, but don't hold up, such as
+// signatures that don't match, still fail the handshake.
+func WithRetries(n int) Option {
+	return func(cfg *config) {
+		cfg.retries = n
+	}
+}
+
+// readMessage reads the client's next message, of typ, into msg. A message
+// that fails to parse, or is of another type, is answered with a CLIENT_ERROR
+// and, while retries remain, read again. It returns the reason the handshake
+// fails, if it does.
+func (h *handshakeState) readMessage(msg *ClientMessage, typ string) (FailureReason, error) {
+	conn, cfg := h.conn, h.cfg
+
+	for {
+		var messageErr *MessageError
+		err := cfg.codec.ReadMessage(conn, msg)
+		if errors.Is(err, errSuspended) {
+			// the step is run again once the message arrives
+			return ReasonReadFailed, err
+		}
+		if err != nil && !errors.As(err, &messageErr) {
+			// a client that never got as far as its CLIENT_ID isn't told
+			if typ != "CLIENT_ID" {
+				conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonReadFailed, "Failed to read "+typ, err))
+			}
+			return ReasonReadFailed, err
+		}
+
+		retry := h.retries < cfg.retries
+		var reason FailureReason
+		switch {
+		case msg.Type != typ:
+			m := newErrorMessage("CLIENT_ERROR", ReasonUnexpectedMessage, "Expected a "+typ+" event, but got "+msg.Type, nil)
+			m.Retry = retry
+			conn.WriteJSON(m)
+			reason, err = ReasonUnexpectedMessage, nil
+		case messageErr != nil:
+			m := newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Failed to parse "+typ, messageErr.Err)
+			m.Retry = retry
+			conn.WriteJSON(m)
+			reason = ReasonMalformedMessage
+		default:
+			return "", nil
+		}
+
+		if !retry {
+			return reason, err
+		}
+		h.retries++
+		h.log.Debug("wskeyauth: waiting for the client to resend its message", "reason", reason, "error", err)
+		*msg = ClientMessage{}
+	}
+}
@@ -0,0 +1,105 @@
+package wskeyauth
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RevocationList holds the keys that may no longer authenticate, however
+// they would have otherwise: by a KeyStore, a certificate, a delegation, or as
+// guests. A list shared between servers, such as the Redis one in
+// contrib/redis, revokes keys on all of them at once.
+type RevocationList interface {
+	// Revoked reports whether the key with fingerprint was revoked. An
+	// error fails the handshake with ReasonServerError.
+	Revoked(ctx context.Context, fingerprint string) (bool, error)
+
+	// Revoke adds the key with fingerprint to the list, for reason.
+	// Revoking a key that was already revoked keeps its original
+	// revocation.
+	Revoke(ctx context.Context, fingerprint, reason string) error
+}
+
+// WithRevocationList rejects clients whose keys are on list, as soon as they
+// sent their client ID, failing the handshake with ReasonRevoked. Keys
+// revoked while their clients are connected aren't disconnected by the list;
+// call Registry.DisconnectFingerprint for that, as the admin package does.
+func WithRevocationList(list RevocationList) Option {
+	return func(cfg *config) {
+		cfg.revocations = list
+	}
+}
+
+// Revocation is a key on a MemoryRevocationList.
+type Revocation struct {
+	Fingerprint string    `json:"fingerprint"`
+	Reason      string    `json:"reason,omitempty"`
+	RevokedAt   time.Time `json:"revokedAt"`
+}
+
+// MemoryRevocationList is a RevocationList for a single process.
+type MemoryRevocationList struct {
+	// Clock tells when keys were revoked. It defaults to SystemClock.
+	Clock Clock
+
+	mu      sync.RWMutex
+	revoked map[string]Revocation
+}
+
+func (l *MemoryRevocationList) Revoked(_ context.Context, fingerprint string) (bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	_, ok := l.revoked[fingerprint]
+	return ok, nil
+}
+
+func (l *MemoryRevocationList) Revoke(_ context.Context, fingerprint, reason string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.revoked == nil {
+		l.revoked = map[string]Revocation{}
+	}
+	if _, ok := l.revoked[fingerprint]; !ok {
+		l.revoked[fingerprint] = Revocation{Fingerprint: fingerprint, Reason: reason, RevokedAt: clockOr(l.Clock).Now()}
+	}
+	return nil
+}
+
+// List returns the revoked keys, oldest revocation first.
+func (l *MemoryRevocationList) List() []Revocation {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	revocations := make([]Revocation, 0, len(l.revoked))
+	for _, r := range l.revoked {
+		revocations = append(revocations, r)
+	}
+	sort.Slice(revocations, func(i, j int) bool {
+		return revocations[i].RevokedAt.Before(revocations[j].RevokedAt)
+	})
+	return revocations
+}
+
+// checkRevoked checks that the client's key isn't on the revocation list, if
+// there is one.
+func (h *handshakeState) checkRevoked() (bool, FailureReason, error) {
+	if h.cfg.revocations == nil {
+		return true, "", nil
+	}
+
+	revoked, err := h.cfg.revocations.Revoked(h.cfg.ctx, h.fingerprint)
+	if err != nil {
+		h.conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to check client key", err))
+		return false, ReasonServerError, err
+	}
+	if revoked {
+		h.log.Debug("wskeyauth: client key was revoked")
+		h.conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonRevoked, "Client key was revoked", nil))
+		return false, ReasonRevoked, nil
+	}
+	return true, "", nil
+}
@@ -0,0 +1,168 @@
+package wskeyauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/castcam-live/ws-key-auth/go/internal/base58"
+)
+
+// Clients may ask the server to prove its identity too, by sending a
+// challenge of their own alongside their CLIENT_ID:
+//
+//	{"type": "CLIENT_ID", "data": "<client ID>", "challenge": "<base64>"}
+//
+// of 16 to 64 random bytes. A server with WithServerKey then signs the
+// client's challenge, its own, and its audience, if any, concatenated, and
+// sends the signature in a "server" member of its CHALLENGE:
+//
+//	{
+//		"type": "CHALLENGE",
+//		"data": "<base64 challenge>",
+//		"server": {"id": "<server ID>", "signature": "<base64 signature>"}
+//	}
+//
+// Server IDs are encoded as client IDs are: WebCrypto-raw.EC.P-256$ for P-256
+// keys, and did:key for Ed25519 keys. Signatures are made as clients make
+// them, too. Clients pin the server ID they expect, and abandon the handshake
+// if the server's doesn't match, or its signature doesn't verify.
+
+const (
+	minClientChallengeLength = 16
+	maxClientChallengeLength = 64
+)
+
+// ServerKey is the key a server proves its identity with.
+type ServerKey struct {
+	signer crypto.Signer
+	id     string
+}
+
+// NewServerKey creates a server key that signs with signer, which must hold
+// a P-256 ECDSA or an Ed25519 key. As only crypto.Signer is needed, the key
+// may live in a KMS or an HSM.
+func NewServerKey(signer crypto.Signer) (*ServerKey, error) {
+	id, err := ServerID(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &ServerKey{signer: signer, id: id}, nil
+}
+
+// ID returns the server ID of the key, for clients to pin.
+func (k *ServerKey) ID() string {
+	return k.id
+}
+
+// ServerID returns the server ID of pub, which must be a P-256 ECDSA or an
+// Ed25519 public key.
+func ServerID(pub crypto.PublicKey) (string, error) {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return "", errors.New("wskeyauth: ECDSA server keys must be on P-256")
+		}
+		raw := make([]byte, 65)
+		raw[0] = 4
+		pub.X.FillBytes(raw[1:33])
+		pub.Y.FillBytes(raw[33:])
+		return "WebCrypto-raw.EC.P-256$" + base64.StdEncoding.EncodeToString(raw), nil
+	case ed25519.PublicKey:
+		return didKeyPrefix + "z" + base58.Encode(append(append([]byte{}, multicodecEd25519...), pub...)), nil
+	}
+	return "", fmt.Errorf("wskeyauth: unsupported server key type %T", pub)
+}
+
+// ParseServerID returns the public key identified by a server ID, as an
+// *ecdsa.PublicKey or an ed25519.PublicKey.
+func ParseServerID(id string) (crypto.PublicKey, error) {
+	pub, err := parseClientID(id, Base64Std)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case pub == nil || pub.webauthn || pub.certificates != nil || pub.keyID != "" || pub.username != "":
+		return nil, errors.New("wskeyauth: not a server ID")
+	case pub.ed25519 != nil:
+		return pub.ed25519, nil
+	}
+	return pub.ecdsa, nil
+}
+
+// WithServerKey has the server prove its identity with key, to clients that
+// ask it to.
+func WithServerKey(key *ServerKey) Option {
+	return func(cfg *config) {
+		cfg.serverKey = key
+	}
+}
+
+// serverProof is the "server" member of a CHALLENGE.
+type serverProof struct {
+	ID        string `json:"id"`
+	Signature string `json:"signature"`
+}
+
+// prove signs the client's challenge for a handshake in which the server
+// sent challenge.
+func (k *ServerKey) prove(clientChallenge, challenge []byte, audience string) (*serverProof, error) {
+	message := make([]byte, 0, len(clientChallenge)+len(challenge)+len(audience))
+	message = append(append(append(message, clientChallenge...), challenge...), audience...)
+
+	sig, err := k.sign(message)
+	if err != nil {
+		return nil, err
+	}
+	return &serverProof{ID: k.id, Signature: base64.StdEncoding.EncodeToString(sig)}, nil
+}
+
+// ed25519 reports whether k is an Ed25519 key, which WithFIPS doesn't sign
+// with.
+func (k *ServerKey) ed25519() bool {
+	_, ok := k.signer.Public().(ed25519.PublicKey)
+	return ok
+}
+
+// sign signs message as clients sign challenges.
+func (k *ServerKey) sign(message []byte) ([]byte, error) {
+	if _, ok := k.signer.Public().(ed25519.PublicKey); ok {
+		return k.signer.Sign(rand.Reader, message, crypto.Hash(0))
+	}
+
+	digest := sha256.Sum256(message)
+	der, err := k.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	// as WebCrypto signs: r and s, concatenated
+	var rs struct{ R, S *big.Int }
+	if rest, err := asn1.Unmarshal(der, &rs); err != nil || len(rest) > 0 {
+		return nil, errors.New("signer returned a malformed ECDSA signature")
+	}
+	sig := make([]byte, 64)
+	rs.R.FillBytes(sig[:32])
+	rs.S.FillBytes(sig[32:])
+	return sig, nil
+}
+
+func decodeClientChallenge(s string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("expected challenge to be base64: " + err.Error())
+	}
+	if len(b) < minClientChallengeLength || len(b) > maxClientChallengeLength {
+		return nil, fmt.Errorf("expected challenge to be %d to %d bytes long, but it was %d",
+			minClientChallengeLength, maxClientChallengeLength, len(b))
+	}
+	return b, nil
+}
@@ -0,0 +1,241 @@
+// Package signaling is a reference WebRTC signaling server. Peers
+// authenticate with the key handshake, join rooms, and then exchange the SDP
+// offers and answers and ICE candidates of their peer connections with the
+// other peers in their rooms, addressed by client ID.
+//
+// Once authenticated, peers join and leave rooms as with wskeyauth.Channels:
+//
+//	{"type": "JOIN", "data": "room"}
+//	{"type": "LEAVE", "data": "room"}
+//
+// A peer that joined a room is told who else is in it:
+//
+//	{"type": "PEERS", "data": {"room": "room", "peers": ["<client ID>", ...]}}
+//
+// and the others are told of peers joining and leaving it:
+//
+//	{"type": "PEER_JOINED", "data": {"room": "room", "peer": "<client ID>"}}
+//	{"type": "PEER_LEFT", "data": {"room": "room", "peer": "<client ID>"}}
+//
+// Peers then send OFFER, ANSWER and CANDIDATE messages to one another,
+// carrying whatever their WebRTC stack gives them:
+//
+//	{"type": "OFFER", "data": {"to": "<client ID>", "payload": {"type": "offer", "sdp": "..."}}}
+//
+// which reach every connection of the peer addressed, as
+//
+//	{"type": "OFFER", "data": {"from": "<client ID>", "payload": {...}}}
+//
+// if it shares a room with the sender, or are answered with
+//
+//	{"type": "PEER_NOT_FOUND", "data": "<client ID>"}
+//
+// otherwise, so that peers can only signal those they meet in a room.
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// Server serves the signaling protocol over WebSockets. The zero value is
+// ready to use, and lets any authenticated peer join any room.
+type Server struct {
+	// Upgrader upgrades requests to WebSockets.
+	Upgrader websocket.Upgrader
+
+	// Options are the options of the handshake.
+	Options []wskeyauth.Option
+
+	// Authorize, if set, is called for every join, and denies it by
+	// returning an error.
+	Authorize func(ctx context.Context, clientID, room string) error
+
+	once     sync.Once
+	registry *wskeyauth.Registry
+	rooms    *wskeyauth.Channels
+}
+
+// Registry returns the registry of the server's peers, such as to watch them
+// come and go, or to disconnect them.
+func (s *Server) Registry() *wskeyauth.Registry {
+	s.init()
+	return s.registry
+}
+
+// Rooms returns the server's rooms.
+func (s *Server) Rooms() *wskeyauth.Channels {
+	s.init()
+	return s.rooms
+}
+
+func (s *Server) init() {
+	s.once.Do(func() {
+		s.registry = wskeyauth.NewRegistry()
+		s.rooms = wskeyauth.NewChannels(s.registry, func(ctx context.Context, session *wskeyauth.Session, room string) error {
+			if s.Authorize == nil {
+				return nil
+			}
+			return s.Authorize(ctx, session.ClientID(), room)
+		})
+		s.registry.Subscribe(s.announce)
+	})
+}
+
+type peerMessage struct {
+	Type string `json:"type"`
+	Data struct {
+		Room string `json:"room"`
+		Peer string `json:"peer"`
+	} `json:"data"`
+}
+
+type peersMessage struct {
+	Type string `json:"type"`
+	Data struct {
+		Room  string   `json:"room"`
+		Peers []string `json:"peers"`
+	} `json:"data"`
+}
+
+type signal struct {
+	To      string          `json:"to,omitempty"`
+	From    string          `json:"from,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type signalMessage struct {
+	Type string `json:"type"`
+	Data signal `json:"data"`
+}
+
+type stringMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+// announce tells the other peers in a room of peers joining and leaving it.
+func (s *Server) announce(e wskeyauth.RegistryEvent) {
+	var typ string
+	switch e.Type {
+	case wskeyauth.ChannelJoined:
+		typ = "PEER_JOINED"
+	case wskeyauth.ChannelLeft:
+		typ = "PEER_LEFT"
+	default:
+		return
+	}
+
+	msg := &peerMessage{Type: typ}
+	msg.Data.Room, msg.Data.Peer = e.Channel, e.Session.ClientID()
+	for _, peer := range s.rooms.Members(e.Channel) {
+		if peer != e.Session {
+			peer.Send(msg)
+		}
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.init()
+
+	conn, err := s.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	s.serve(r.Context(), conn, append([]wskeyauth.Option{wskeyauth.WithRequest(r)}, s.Options...))
+}
+
+// serve authenticates the peer on conn, and then handles its messages until
+// the connection fails.
+func (s *Server) serve(ctx context.Context, conn wskeyauth.Conn, opts []wskeyauth.Option) {
+	authenticated, clientID, err := wskeyauth.Handshake(conn, opts...)
+	if !authenticated || err != nil {
+		return
+	}
+
+	session, err := s.registry.Add(clientID, conn)
+	if err != nil {
+		return
+	}
+	defer session.Leave()
+
+	for {
+		var msg wskeyauth.TypeData
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		session.Touch()
+		s.handle(ctx, session, msg)
+	}
+}
+
+// handle handles a message from session.
+func (s *Server) handle(ctx context.Context, session *wskeyauth.Session, msg wskeyauth.TypeData) {
+	if ok, err := s.rooms.HandleSession(ctx, session, msg); ok {
+		if err == nil && msg.Type == "JOIN" {
+			var room string
+			json.Unmarshal(msg.Data, &room)
+			s.sendPeers(session, room)
+		}
+		return
+	}
+
+	switch msg.Type {
+	case "OFFER", "ANSWER", "CANDIDATE":
+		var sig signal
+		if err := json.Unmarshal(msg.Data, &sig); err != nil || sig.To == "" {
+			session.Send(&stringMessage{Type: "CLIENT_ERROR", Data: "Expected " + msg.Type + " to carry who it is to"})
+			return
+		}
+		s.forward(session, msg.Type, sig)
+	default:
+		session.Send(&stringMessage{Type: "CLIENT_ERROR", Data: "Unknown message type " + msg.Type})
+	}
+}
+
+// sendPeers tells session, which just joined room, who else is in it.
+func (s *Server) sendPeers(session *wskeyauth.Session, room string) {
+	peers := []string{}
+	seen := map[string]bool{}
+	for _, peer := range s.rooms.Members(room) {
+		if peer.Fingerprint() != session.Fingerprint() && !seen[peer.Fingerprint()] {
+			seen[peer.Fingerprint()] = true
+			peers = append(peers, peer.ClientID())
+		}
+	}
+	msg := &peersMessage{Type: "PEERS"}
+	msg.Data.Room, msg.Data.Peers = room, peers
+	session.Send(msg)
+}
+
+// forward sends sig, from session, to every connection of the peer it is
+// addressed to that shares a room with session.
+func (s *Server) forward(session *wskeyauth.Session, typ string, sig signal) {
+	rooms := map[string]bool{}
+	for _, room := range s.rooms.Joined(session) {
+		rooms[room] = true
+	}
+
+	msg := &signalMessage{Type: typ, Data: signal{From: session.ClientID(), Payload: sig.Payload}}
+	sent := false
+	for _, peer := range s.registry.Get(sig.To) {
+		for _, room := range s.rooms.Joined(peer) {
+			if rooms[room] {
+				peer.Send(msg)
+				sent = true
+				break
+			}
+		}
+	}
+	if !sent {
+		session.Send(&stringMessage{Type: "PEER_NOT_FOUND", Data: sig.To})
+	}
+}
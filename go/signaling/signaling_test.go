@@ -0,0 +1,129 @@
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+// connect authenticates a peer with a fresh key to s, over a pipe, and
+// returns the peer's end of it.
+func connect(t *testing.T, s *Server) (*wskeyauthtest.Conn, *wskeyauthtest.Key) {
+	t.Helper()
+	s.init()
+
+	key := wskeyauthtest.MustGenerateKey()
+	server, conn := wskeyauthtest.Pipe()
+	go s.serve(context.Background(), server, nil)
+	t.Cleanup(func() { conn.Close() })
+
+	result, err := (&wskeyauthtest.Client{Key: key}).Run(conn)
+	if err != nil || !result.Authenticated() {
+		t.Fatalf("handshake = %+v, %v", result, err)
+	}
+	return conn, key
+}
+
+// send sends a message of typ, with data, to the server.
+func send(t *testing.T, conn *wskeyauthtest.Conn, typ string, data any) {
+	t.Helper()
+	if err := conn.WriteJSON(map[string]any{"type": typ, "data": data}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// receive reads the next message, which must be of typ, into data.
+func receive(t *testing.T, conn *wskeyauthtest.Conn, typ string, data any) {
+	t.Helper()
+	var msg struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != typ {
+		t.Fatalf("got %s %s, want a %s", msg.Type, msg.Data, typ)
+	}
+	if err := json.Unmarshal(msg.Data, data); err != nil {
+		t.Fatalf("%s data %s: %v", typ, msg.Data, err)
+	}
+}
+
+// join has conn join room, and returns the peers it was told are in it.
+func join(t *testing.T, conn *wskeyauthtest.Conn, room string) []string {
+	t.Helper()
+	send(t, conn, "JOIN", room)
+
+	var joined string
+	receive(t, conn, "JOINED", &joined)
+	var peers peersMessage
+	receive(t, conn, "PEERS", &peers.Data)
+	if joined != room || peers.Data.Room != room {
+		t.Fatalf("joined %q, and got the peers of %q, want %q", joined, peers.Data.Room, room)
+	}
+	return peers.Data.Peers
+}
+
+func TestJoinAndLeave(t *testing.T) {
+	s := &Server{}
+	alice, aliceKey := connect(t, s)
+	bob, bobKey := connect(t, s)
+
+	if peers := join(t, alice, "room"); len(peers) != 0 {
+		t.Fatalf("PEERS of an empty room = %v", peers)
+	}
+	if peers := join(t, bob, "room"); !reflect.DeepEqual(peers, []string{aliceKey.ClientID()}) {
+		t.Fatalf("PEERS = %v, want alice", peers)
+	}
+
+	var peer peerMessage
+	receive(t, alice, "PEER_JOINED", &peer.Data)
+	if peer.Data.Room != "room" || peer.Data.Peer != bobKey.ClientID() {
+		t.Fatalf("PEER_JOINED = %+v, want bob in room", peer.Data)
+	}
+
+	send(t, bob, "LEAVE", "room")
+	var left string
+	receive(t, bob, "LEFT", &left)
+	receive(t, alice, "PEER_LEFT", &peer.Data)
+	if peer.Data.Room != "room" || peer.Data.Peer != bobKey.ClientID() {
+		t.Fatalf("PEER_LEFT = %+v, want bob in room", peer.Data)
+	}
+}
+
+func TestForward(t *testing.T) {
+	s := &Server{}
+	alice, aliceKey := connect(t, s)
+	bob, bobKey := connect(t, s)
+	carol, carolKey := connect(t, s)
+
+	join(t, alice, "room")
+	join(t, bob, "room")
+	var peer peerMessage
+	receive(t, alice, "PEER_JOINED", &peer.Data)
+
+	payload := json.RawMessage(`{"type":"offer","sdp":"v=0"}`)
+	send(t, alice, "OFFER", signal{To: bobKey.ClientID(), Payload: payload})
+	var sig signal
+	receive(t, bob, "OFFER", &sig)
+	if sig.From != aliceKey.ClientID() || string(sig.Payload) != string(payload) {
+		t.Fatalf("OFFER = %+v, want alice's payload", sig)
+	}
+
+	// carol shares no room with either of them
+	var notFound string
+	send(t, alice, "OFFER", signal{To: carolKey.ClientID(), Payload: payload})
+	receive(t, alice, "PEER_NOT_FOUND", &notFound)
+	if notFound != carolKey.ClientID() {
+		t.Fatalf("PEER_NOT_FOUND = %q, want carol", notFound)
+	}
+	send(t, carol, "ANSWER", signal{To: aliceKey.ClientID(), Payload: payload})
+	receive(t, carol, "PEER_NOT_FOUND", &notFound)
+	if notFound != aliceKey.ClientID() {
+		t.Fatalf("PEER_NOT_FOUND = %q, want alice", notFound)
+	}
+}
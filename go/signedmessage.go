@@ -0,0 +1,194 @@
+package wskeyauth
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// SignedMessage is an application message signed with the client's key, for
+// deployments that need to prove what a client sent, rather than just that
+// it was connected:
+//
+//	{"seq": 1, "data": {"action": "delete", "id": 42}, "signature": "<base64>"}
+//
+// The signature is over MessageSigningPrefix, followed by the connection's
+// MessageBinding, the sequence number as 8 big-endian bytes, and data exactly
+// as it was sent. It is made as the signature of a challenge is: for P-256
+// keys, the concatenation of r and s over the SHA-256 hash of it.
+//
+// Sequence numbers start at 1 on every connection, and go up by one with
+// every message, so that messages can't be dropped, reordered or replayed
+// without it showing; the binding keeps messages of one connection from
+// being replayed over another, where the sequence numbers start over.
+type SignedMessage struct {
+	Seq       uint64          `json:"seq"`
+	Data      json.RawMessage `json:"data"`
+	Signature string          `json:"signature"`
+}
+
+// MessageSigningPrefix starts everything signed for a SignedMessage, so that
+// a signed message can never pass for the response to a challenge, or the
+// other way around.
+const MessageSigningPrefix = "wskeyauth signed message\x00"
+
+// MessageBinding returns the binding of the messages signed over a connection
+// whose handshake signed challenge, which is the challenge followed by the
+// audience, if the server has one. Servers find it in Result.MessageBinding.
+func MessageBinding(challenge []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte("wskeyauth message binding\x00"))
+	h.Write(challenge)
+	return h.Sum(nil)
+}
+
+// MessageSigningInput returns what is signed for a message with seq and
+// data, over the connection of binding.
+func MessageSigningInput(binding []byte, seq uint64, data []byte) []byte {
+	b := make([]byte, 0, len(MessageSigningPrefix)+len(binding)+8+len(data))
+	b = append(b, MessageSigningPrefix...)
+	b = append(b, binding...)
+	b = binary.BigEndian.AppendUint64(b, seq)
+	return append(b, data...)
+}
+
+// VerifySignedMessage checks that m was signed with the key of clientID, over
+// the connection of binding. It doesn't check the sequence number, so that
+// archived messages can be checked long after the fact, as long as the
+// binding is archived with them; MessageVerifier does, for live connections.
+//
+// Only client IDs with a key that signs directly work: not passkeys, nor
+// HMAC or SRP client IDs.
+func VerifySignedMessage(clientID string, binding []byte, m *SignedMessage) error {
+	pubKey, err := parseClientID(clientID, Base64Any)
+	if err != nil {
+		return err
+	}
+	if len(binding) == 0 {
+		return errNoMessageBinding
+	}
+	return verifySignedMessage(pubKey, binding, m)
+}
+
+var (
+	errMessageSignatureMismatch = errors.New("wskeyauth: message signature doesn't match")
+	errNoMessageKey             = errors.New("wskeyauth: client ID has no key to sign messages with")
+	errNoMessageBinding         = errors.New("wskeyauth: no message binding; the handshake had no challenge")
+)
+
+// signsMessages reports whether pubKey can sign messages.
+func signsMessages(pubKey *publicKey) bool {
+	return !pubKey.webauthn && (pubKey.ecdsa != nil || pubKey.ed25519 != nil)
+}
+
+func verifySignedMessage(pubKey *publicKey, binding []byte, m *SignedMessage) error {
+	if !signsMessages(pubKey) {
+		return errNoMessageKey
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("wskeyauth: failed to decode message signature: %w", err)
+	}
+
+	if !verifyWithKey(pubKey, MessageSigningInput(binding, m.Seq, m.Data), sig) {
+		return errMessageSignatureMismatch
+	}
+	return nil
+}
+
+// verifyWithKey checks sig over message, as it is signed for pubKey, which
+// must be a P-256 or an Ed25519 key.
+func verifyWithKey(pubKey *publicKey, message, sig []byte) bool {
+	if pubKey.ed25519 != nil {
+		return ed25519.Verify(pubKey.ed25519, message, sig)
+	}
+	hash := sha256.Sum256(message)
+	return len(sig) == 64 && verifySignature(pubKey.ecdsa, hash[:], sig)
+}
+
+// SequenceError is returned by MessageVerifier for a message that isn't the
+// one that was expected next.
+type SequenceError struct {
+	Expected, Got uint64
+}
+
+func (e *SequenceError) Error() string {
+	return fmt.Sprintf("wskeyauth: expected message %d, but got %d", e.Expected, e.Got)
+}
+
+// MessageVerifier verifies the signed messages of one connection, once the
+// handshake is over:
+//
+//	verifier, err := wskeyauth.NewMessageVerifier(result.ClientID, result.MessageBinding)
+//	for {
+//		var action Action
+//		signed, err := verifier.ReadJSON(conn, &action)
+//		if err != nil {
+//			return err
+//		}
+//		archive(signed)
+//		...
+//	}
+type MessageVerifier struct {
+	pubKey  *publicKey
+	binding []byte
+
+	mu   sync.Mutex
+	next uint64
+}
+
+// NewMessageVerifier creates a verifier for the messages of the client
+// authenticated with clientID, over the connection of binding, which is the
+// result's MessageBinding. Clients that authenticated without a challenge,
+// with a signed timestamp or a TLS client certificate, have no binding.
+func NewMessageVerifier(clientID string, binding []byte) (*MessageVerifier, error) {
+	pubKey, err := parseClientID(clientID, Base64Any)
+	if err != nil {
+		return nil, err
+	}
+	if !signsMessages(pubKey) {
+		return nil, errNoMessageKey
+	}
+	if len(binding) == 0 {
+		return nil, errNoMessageBinding
+	}
+	return &MessageVerifier{pubKey: pubKey, binding: binding, next: 1}, nil
+}
+
+// Verify checks that m was signed by the client, and is the message that was
+// expected next.
+func (v *MessageVerifier) Verify(m *SignedMessage) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if m.Seq != v.next {
+		return &SequenceError{Expected: v.next, Got: m.Seq}
+	}
+	if err := verifySignedMessage(v.pubKey, v.binding, m); err != nil {
+		return err
+	}
+	v.next++
+	return nil
+}
+
+// ReadJSON reads a signed message from conn, verifies it, and decodes its data
+// into dst. It returns the message as it was signed, for keeping as proof.
+func (v *MessageVerifier) ReadJSON(conn Conn, dst any) (*SignedMessage, error) {
+	var m SignedMessage
+	if err := conn.ReadJSON(&m); err != nil {
+		return nil, err
+	}
+	if err := v.Verify(&m); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(m.Data, dst); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
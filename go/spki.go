@@ -0,0 +1,51 @@
+package wskeyauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"errors"
+	"strings"
+)
+
+// Keys may be given as DER encoded SubjectPublicKeyInfos too, as WebCrypto
+// exports them with exportKey("spki"), and as PEM public keys hold them:
+//
+//	SPKI$<base64 DER>
+//
+// for P-256 ECDSA and Ed25519 keys. The base64 variants accepted are those of
+// WithBase64. P-256 clients sign as WebCrypto clients do, and Ed25519 ones as
+// did:key ones do.
+
+const spkiPrefix = "SPKI$"
+
+// maxSPKILength bounds the keys we'll decode. Keys we accept are far
+// shorter.
+const maxSPKILength = 256
+
+func parseSPKIClientID(clientID string, accept Base64) (*publicKey, error) {
+	encoded := strings.TrimPrefix(clientID, spkiPrefix)
+	if len(encoded) > maxSPKILength {
+		return nil, errors.New("expected SPKI of ID to be at most 256 characters long")
+	}
+	der, err := accept.decode(nil, encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return nil, errors.New("expected SPKI of ID to hold a P-256 or Ed25519 key")
+		}
+		return &publicKey{ecdsa: key}, nil
+	case ed25519.PublicKey:
+		return &publicKey{ed25519: key}, nil
+	}
+	return nil, errors.New("expected SPKI of ID to hold a P-256 or Ed25519 key")
+}
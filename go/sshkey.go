@@ -0,0 +1,111 @@
+package wskeyauth
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// OpenSSH public keys, as found in authorized_keys and .pub files, are
+// accepted as client IDs too:
+//
+//	ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAA... [comment]
+//	ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAA... [comment]
+//
+// The comment, if any, doesn't change who the client is. ECDSA clients sign as
+// WebCrypto clients do, and Ed25519 clients as did:key ones do.
+
+const (
+	sshECDSAP256 = "ecdsa-sha2-nistp256"
+	sshEd25519   = "ssh-ed25519"
+)
+
+func isSSHKey(clientID string) bool {
+	return strings.HasPrefix(clientID, sshECDSAP256+" ") || strings.HasPrefix(clientID, sshEd25519+" ")
+}
+
+func parseSSHKey(line string) (*publicKey, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil, errors.New("expected SSH key to be a key type followed by a base64 key")
+	}
+	keyType := fields[0]
+
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, errors.New("expected SSH key to be valid base64: " + err.Error())
+	}
+
+	r := sshReader{b: blob}
+	if t := r.string(); r.err != nil {
+		return nil, r.err
+	} else if t != keyType {
+		return nil, errors.New("expected SSH key of type " + keyType + ", but it is encoded as " + t)
+	}
+
+	var pub *publicKey
+	switch keyType {
+	case sshECDSAP256:
+		if r.string() != "nistp256" {
+			return nil, errors.New("expected SSH key to be on nistp256")
+		}
+		point := []byte(r.string())
+		if r.err == nil {
+			if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+				return nil, ErrInvalidPublicKey
+			}
+			pub = &publicKey{ecdsa: &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(point[1:33]),
+				Y:     new(big.Int).SetBytes(point[33:]),
+			}}
+		}
+	case sshEd25519:
+		key := r.string()
+		if r.err == nil && len(key) != ed25519.PublicKeySize {
+			return nil, errors.New("expected SSH Ed25519 key to be 32 bytes long")
+		}
+		pub = &publicKey{ed25519: ed25519.PublicKey(key)}
+	default:
+		return nil, errors.New("unsupported SSH key type " + keyType)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.b) > 0 {
+		return nil, errors.New("unexpected trailing data in SSH key")
+	}
+	return pub, nil
+}
+
+// sshReader reads the length-prefixed strings of the SSH wire format. Once
+// it runs out of data, it sets err and returns empty strings.
+type sshReader struct {
+	b   []byte
+	err error
+}
+
+func (r *sshReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	if len(r.b) < 4 {
+		r.err = errors.New("SSH key is truncated")
+		return ""
+	}
+	n := binary.BigEndian.Uint32(r.b)
+	if uint64(n) > uint64(len(r.b)-4) {
+		r.err = errors.New("SSH key is truncated")
+		return ""
+	}
+	s := string(r.b[4 : 4+n])
+	r.b = r.b[4+n:]
+	return s
+}
@@ -0,0 +1,190 @@
+package wskeyauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+// Servers with WithStatelessChallenges issue challenges that any server
+// sharing the cluster's secret can verify, so that a client may respond to a
+// CHALLENGE on another server than the one that sent it, such as when a load
+// balancer spreads the requests of one handshake over several. The challenge
+// looks as any other to clients, which sign it as usual, and echo it back
+// with their response:
+//
+//	{
+//		"type": "CHALLENGE_RESPONSE",
+//		"data": {"signature": "...", "hash": "SHA-256", "challenge": "<the challenge>"}
+//	}
+//
+// A server that didn't issue the challenge verifies it against the secret,
+// and that it is recent, instead of remembering it. Unless told otherwise,
+// challenges are only accepted from the address they were issued to, and
+// only once, as the cluster's shared NonceStore records.
+
+// DefaultStatelessChallengeTTL is how long stateless challenges are accepted
+// for, unless StatelessChallenges.TTL says otherwise.
+const DefaultStatelessChallengeTTL = time.Minute
+
+var (
+	errStatelessSecret = errors.New("wskeyauth: StatelessChallenges.Secret must be at least 32 bytes")
+	errStatelessNonces = errors.New("wskeyauth: StatelessChallenges needs Nonces, unless AllowReplay is set")
+)
+
+// statelessChallengePrefix separates the MACs of challenges from any other
+// use of the secret.
+const statelessChallengePrefix = "wskeyauth challenge\x00"
+
+const (
+	statelessNonceLength = challengeByteLength - 8 - sha256.Size
+	statelessTimeOffset  = statelessNonceLength
+	statelessMACOffset   = statelessTimeOffset + 8
+)
+
+// StatelessChallenges configures challenges that carry their own proof of
+// having been issued by the cluster: a random nonce, the time it was issued,
+// and an HMAC of both.
+type StatelessChallenges struct {
+	// Secret is the secret the servers of the cluster share, of at least
+	// 32 random bytes; handshakes fail with a shorter one.
+	Secret []byte
+
+	// TTL is how long after it was issued a challenge is accepted. It
+	// defaults to DefaultStatelessChallengeTTL.
+	TTL time.Duration
+
+	// Challenges are bound to the address of the client they were issued
+	// to, so that they are only accepted from it. Only the host is bound,
+	// as the port generally differs between requests. AnyAddress accepts
+	// them from any address, for clients whose address changes between
+	// the requests of a handshake, at the cost of a response seen in
+	// transit being accepted from anywhere.
+	AnyAddress bool
+
+	// Nonces records which challenges were responded to with a valid
+	// signature, so that a response can't be replayed within the TTL. It
+	// must be shared by the servers of the cluster, such as the Redis one
+	// in contrib/redis, and handshakes fail without it, unless AllowReplay
+	// is set: servers then keep no state at all, and a response seen in
+	// transit can be replayed until the challenge expires.
+	Nonces      NonceStore
+	AllowReplay bool
+}
+
+// WithStatelessChallenges issues challenges that any server with the same
+// secret accepts responses to, as s says.
+func WithStatelessChallenges(s *StatelessChallenges) Option {
+	return func(cfg *config) {
+		cfg.stateless = s
+	}
+}
+
+func (s *StatelessChallenges) ttl() time.Duration {
+	if s.TTL <= 0 {
+		return DefaultStatelessChallengeTTL
+	}
+	return s.TTL
+}
+
+// seal turns payload, which is random, into a challenge issued at now, for a
+// server with audience and a client at remoteAddr. It fails if s isn't safe
+// to issue challenges with.
+func (s *StatelessChallenges) seal(payload []byte, audience, remoteAddr string, now time.Time) error {
+	if len(s.Secret) < 32 {
+		return errStatelessSecret
+	}
+	if s.Nonces == nil && !s.AllowReplay {
+		return errStatelessNonces
+	}
+	binary.BigEndian.PutUint64(payload[statelessTimeOffset:], uint64(now.UnixMilli()))
+	s.mac(payload[statelessMACOffset:statelessMACOffset], payload[:statelessMACOffset], audience, remoteAddr)
+	return nil
+}
+
+// mac appends the MAC of the nonce and time in issued to b.
+func (s *StatelessChallenges) mac(b, issued []byte, audience, remoteAddr string) []byte {
+	h := hmac.New(sha256.New, s.Secret)
+	h.Write([]byte(statelessChallengePrefix))
+	h.Write(issued)
+	h.Write([]byte(audience))
+	h.Write([]byte{0})
+	if !s.AnyAddress {
+		host, _, err := net.SplitHostPort(remoteAddr)
+		if err != nil {
+			host = remoteAddr
+		}
+		h.Write([]byte(host))
+	}
+	return h.Sum(b)
+}
+
+// open reports whether challenge was issued by the cluster, for a server with
+// audience and a client at remoteAddr, no longer than the TTL before now.
+func (s *StatelessChallenges) open(challenge []byte, audience, remoteAddr string, now time.Time) bool {
+	if len(challenge) != challengeByteLength || len(s.Secret) < 32 {
+		return false
+	}
+	var mac [sha256.Size]byte
+	if !hmac.Equal(s.mac(mac[:0], challenge[:statelessMACOffset], audience, remoteAddr), challenge[statelessMACOffset:]) {
+		return false
+	}
+	issued := time.UnixMilli(int64(binary.BigEndian.Uint64(challenge[statelessTimeOffset:])))
+	// allow for a little skew between the servers' clocks
+	return !issued.After(now.Add(5*time.Second)) && now.Sub(issued) <= s.ttl()
+}
+
+// checkChallenge replaces payload with the challenge the client says it
+// signed, if it isn't payload already, once it checked that the cluster
+// issued it.
+func (h *handshakeState) checkChallenge(payload []byte, echoed string) (bool, FailureReason, error) {
+	conn, cfg := h.conn, h.cfg
+	s := cfg.stateless
+
+	if echoed != "" {
+		challenge, err := base64.StdEncoding.DecodeString(echoed)
+		if err != nil {
+			conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Failed to parse CHALLENGE_RESPONSE", err))
+			return false, ReasonMalformedMessage, err
+		}
+		if !hmac.Equal(challenge, payload) {
+			if !s.open(challenge, cfg.audience, h.remoteAddr, cfg.clock.Now()) {
+				h.log.Debug("wskeyauth: echoed challenge wasn't issued by the cluster, or expired")
+				conn.WriteJSON(&stringMessage{Type: "SIGNATURE_MISMATCH", Data: "Challenge was not issued by this server, or expired"})
+				return false, ReasonSignatureMismatch, nil
+			}
+			h.log.Debug("wskeyauth: adopted a challenge issued by another server")
+			copy(payload, challenge)
+		}
+	}
+	return true, "", nil
+}
+
+// useChallenge records that payload was responded to, if s.Nonces is set,
+// once the response was verified, so that responses to challenges seen by
+// others can't be sent, with a bogus signature, to use them up before their
+// clients do. Challenges the server issued itself are recorded too, or
+// responses to them could be replayed on other servers.
+func (h *handshakeState) useChallenge(payload []byte) (bool, FailureReason, error) {
+	conn, cfg := h.conn, h.cfg
+	s := cfg.stateless
+
+	if s.Nonces == nil {
+		return true, "", nil
+	}
+	nonce := "challenge:" + base64.RawStdEncoding.EncodeToString(payload[:statelessNonceLength])
+	unused, err := s.Nonces.Use(cfg.ctx, nonce, s.ttl()+5*time.Second)
+	if err != nil {
+		conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to check challenge", err))
+		return false, ReasonServerError, err
+	}
+	if !unused {
+		conn.WriteJSON(&stringMessage{Type: "SIGNATURE_MISMATCH", Data: "Challenge was responded to already"})
+		return false, ReasonSignatureMismatch, nil
+	}
+	return true, "", nil
+}
@@ -0,0 +1,88 @@
+package wskeyauth_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+var clusterSecret = []byte("a secret of at least 32 bytes...")
+
+func TestStatelessChallengesRefuseUnsafeConfig(t *testing.T) {
+	for name, s := range map[string]*wskeyauth.StatelessChallenges{
+		"short secret": {Secret: []byte("short"), AllowReplay: true},
+		"no nonces":    {Secret: clusterSecret},
+	} {
+		t.Run(name, func(t *testing.T) {
+			hs := wskeyauth.NewServerHandshake(wskeyauth.WithStatelessChallenges(s))
+			hs.Start()
+			key := wskeyauthtest.MustGenerateKey()
+			out, state, err := hs.Feed(marshal(t, map[string]any{"type": "CLIENT_ID", "data": key.ClientID()}))
+			if typ := only(t, out).Type; typ != "SERVER_ERROR" || state != wskeyauth.StateFailed || err == nil {
+				t.Fatalf("Feed(CLIENT_ID) = %s, %v, %v, want a SERVER_ERROR", out, state, err)
+			}
+		})
+	}
+}
+
+func TestStatelessChallengesAcrossServers(t *testing.T) {
+	s := &wskeyauth.StatelessChallenges{Secret: clusterSecret, Nonces: wskeyauth.NewMemoryNonceStore()}
+	key := wskeyauthtest.MustGenerateKey()
+
+	a := wskeyauth.NewServerHandshake(wskeyauth.WithStatelessChallenges(s))
+	a.Start()
+	out, _, _ := a.Feed(marshal(t, map[string]any{"type": "CLIENT_ID", "data": key.ClientID()}))
+	challenge := only(t, out)
+	var issued string
+	json.Unmarshal(challenge.Data, &issued)
+
+	// the response, with the challenge echoed, goes to another server
+	var response map[string]any
+	json.Unmarshal(respond(t, key, challenge), &response)
+	response["data"].(map[string]any)["challenge"] = issued
+
+	for i, want := range []wskeyauth.State{wskeyauth.StateAuthenticated, wskeyauth.StateFailed} {
+		b := wskeyauth.NewServerHandshake(wskeyauth.WithStatelessChallenges(s))
+		b.Start()
+		b.Feed(marshal(t, map[string]any{"type": "CLIENT_ID", "data": key.ClientID()}))
+		if out, state, _ := b.Feed(marshal(t, response)); state != want {
+			t.Fatalf("response %d = %s, %v, want %v", i, out, state, want)
+		}
+	}
+}
+
+func TestStatelessChallengesSurviveBogusResponses(t *testing.T) {
+	s := &wskeyauth.StatelessChallenges{Secret: clusterSecret, Nonces: wskeyauth.NewMemoryNonceStore()}
+	key := wskeyauthtest.MustGenerateKey()
+
+	a := wskeyauth.NewServerHandshake(wskeyauth.WithStatelessChallenges(s))
+	a.Start()
+	out, _, _ := a.Feed(marshal(t, map[string]any{"type": "CLIENT_ID", "data": key.ClientID()}))
+	challenge := only(t, out)
+	var issued string
+	json.Unmarshal(challenge.Data, &issued)
+
+	var response map[string]any
+	json.Unmarshal(respond(t, key, challenge), &response)
+	response["data"].(map[string]any)["challenge"] = issued
+
+	// someone who saw the challenge sends it first, with a bogus signature
+	bogus := map[string]any{"type": "CHALLENGE_RESPONSE", "data": map[string]any{}}
+	for k, v := range response["data"].(map[string]any) {
+		bogus["data"].(map[string]any)[k] = v
+	}
+	bogus["data"].(map[string]any)["signature"] = base64.StdEncoding.EncodeToString(make([]byte, 64))
+
+	for i, msg := range []map[string]any{bogus, response} {
+		want := []wskeyauth.State{wskeyauth.StateFailed, wskeyauth.StateAuthenticated}[i]
+		b := wskeyauth.NewServerHandshake(wskeyauth.WithStatelessChallenges(s))
+		b.Start()
+		b.Feed(marshal(t, map[string]any{"type": "CLIENT_ID", "data": key.ClientID()}))
+		if out, state, _ := b.Feed(marshal(t, msg)); state != want {
+			t.Fatalf("response %d = %s, %v, want %v", i, out, state, want)
+		}
+	}
+}
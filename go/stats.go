@@ -0,0 +1,136 @@
+package wskeyauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Stats is a snapshot of an Authenticator's handshakes since it was created.
+type Stats struct {
+	// Since is when the authenticator was created.
+	Since time.Time `json:"since"`
+
+	// Started counts the handshakes the authenticator ran, and InFlight
+	// those of them still running. Refused counts those it didn't run, as it
+	// was shutting down.
+	Started  int64 `json:"started"`
+	InFlight int   `json:"inFlight"`
+	Refused  int64 `json:"refused"`
+
+	// Succeeded and Failed count the handshakes that finished, and Failures
+	// breaks Failed down by reason.
+	Succeeded int64                   `json:"succeeded"`
+	Failed    int64                   `json:"failed"`
+	Failures  map[FailureReason]int64 `json:"failures"`
+
+	// SuccessRate is the share of finished handshakes that succeeded, or 0 if
+	// none finished yet.
+	SuccessRate float64 `json:"successRate"`
+
+	// Sessions is how many sessions the authenticator's registry holds, if
+	// it has one.
+	Sessions int `json:"sessions"`
+
+	// RateLimiter is the state of the authenticator's rate limiter, if it
+	// has one that reports it, as MemoryRateLimiter does.
+	RateLimiter *RateLimiterStats `json:"rateLimiter,omitempty"`
+
+	// ShuttingDown is set once Shutdown was called.
+	ShuttingDown bool `json:"shuttingDown"`
+}
+
+// RateLimiterStats is the state of a rate limiter's current window.
+type RateLimiterStats struct {
+	// Keys is how many keys were counted in the window, and Limited how many
+	// of them went over the limit.
+	Keys    int `json:"keys"`
+	Limited int `json:"limited"`
+
+	// WindowEnds is when the window ends, and the counts are reset.
+	WindowEnds time.Time `json:"windowEnds"`
+}
+
+// rateLimiterStatser is implemented by rate limiters that report their state.
+type rateLimiterStatser interface {
+	Stats() RateLimiterStats
+}
+
+// Stats returns the state of the limiter's current window.
+func (l *MemoryRateLimiter) Stats() RateLimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.counts == nil || time.Since(l.start) >= l.Window {
+		return RateLimiterStats{}
+	}
+	stats := RateLimiterStats{Keys: len(l.counts), WindowEnds: l.start.Add(l.Window)}
+	for _, n := range l.counts {
+		if n > l.Limit {
+			stats.Limited++
+		}
+	}
+	return stats
+}
+
+// count records the outcome of a handshake that finished, with a.mu held.
+func (a *Authenticator) count(result *Result) {
+	if result == nil {
+		return
+	}
+	if result.Authenticated {
+		a.succeeded++
+		return
+	}
+	a.failed++
+	a.failures[result.Reason]++
+}
+
+// Stats returns a snapshot of the authenticator's handshakes.
+func (a *Authenticator) Stats() Stats {
+	a.mu.Lock()
+	stats := Stats{
+		Since:        a.since,
+		InFlight:     len(a.inFlight),
+		Refused:      a.refused,
+		Succeeded:    a.succeeded,
+		Failed:       a.failed,
+		Failures:     make(map[FailureReason]int64, len(a.failures)),
+		ShuttingDown: a.closing,
+	}
+	for reason, n := range a.failures {
+		stats.Failures[reason] = n
+	}
+	a.mu.Unlock()
+
+	stats.Started = stats.Succeeded + stats.Failed + int64(stats.InFlight)
+	if finished := stats.Succeeded + stats.Failed; finished > 0 {
+		stats.SuccessRate = float64(stats.Succeeded) / float64(finished)
+	}
+	if a.registry != nil {
+		stats.Sessions = a.registry.Count()
+	}
+	if l, ok := a.rateLimiter.(rateLimiterStatser); ok {
+		s := l.Stats()
+		stats.RateLimiter = &s
+	}
+	return stats
+}
+
+// StatsHandler returns an http.Handler serving the authenticator's Stats as
+// JSON, for load balancer health checks and dashboards. It responds with 503
+// Service Unavailable once Shutdown was called, so that load balancers stop
+// sending clients to the server, and 200 OK otherwise:
+//
+//	http.Handle("/healthz", auth.StatsHandler())
+func (a *Authenticator) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := a.Stats()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if stats.ShuttingDown {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(stats)
+	})
+}
@@ -0,0 +1,163 @@
+package wskeyauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTicketTTL is how long tickets are valid for unless Tickets.TTL says
+// otherwise.
+const DefaultTicketTTL = 30 * time.Second
+
+// Errors returned by Tickets.Redeem.
+var (
+	ErrInvalidTicket = errors.New("wskeyauth: invalid ticket")
+	ErrTicketExpired = errors.New("wskeyauth: ticket expired")
+	ErrTicketUsed    = errors.New("wskeyauth: ticket was already used")
+)
+
+var errTicketSecret = errors.New("wskeyauth: ticket secret must be at least 32 bytes")
+
+// Tickets mints and redeems tickets: short-lived, single-use credentials for
+// clients that can't run the handshake themselves, such as native video
+// players. A client that did authenticate asks the server for a ticket, and
+// hands it to the player to put in the URL it connects to:
+//
+//	wss://example.com/stream?ticket=<ticket>
+//
+// The server redeems the ticket as it upgrades the connection, which is then
+// authenticated as the client the ticket was minted for.
+//
+// Tickets are the client ID, an expiry and a random nonce, authenticated with
+// an HMAC under Secret, all in unpadded URL-safe base64. They can't be
+// revoked, so keep TTL short.
+type Tickets struct {
+	// Secret is the key tickets are authenticated with, of at least 32
+	// random bytes; Mint and Redeem fail with a shorter one. Every server
+	// redeeming tickets needs the same one.
+	Secret []byte
+
+	// TTL is how long tickets are valid for. It defaults to
+	// DefaultTicketTTL.
+	TTL time.Duration
+
+	// Nonces records which tickets were redeemed. It defaults to a
+	// MemoryNonceStore; share one between servers, such as the Redis one in
+	// contrib/redis, so that a ticket can't be redeemed on each of them.
+	Nonces NonceStore
+
+	// Clock tells when tickets expire. It defaults to SystemClock.
+	Clock Clock
+
+	// Random is where nonces are drawn from. It defaults to crypto/rand.
+	Random io.Reader
+
+	once sync.Once
+}
+
+const (
+	ticketVersion     = 1
+	ticketNonceLength = 16
+	ticketHeader      = 1 + 8 + ticketNonceLength
+)
+
+func (t *Tickets) ttl() time.Duration {
+	if t.TTL <= 0 {
+		return DefaultTicketTTL
+	}
+	return t.TTL
+}
+
+func (t *Tickets) nonces() NonceStore {
+	t.once.Do(func() {
+		if t.Nonces == nil {
+			t.Nonces = &MemoryNonceStore{Clock: t.Clock}
+		}
+	})
+	return t.Nonces
+}
+
+func (t *Tickets) mac(payload []byte) []byte {
+	mac := hmac.New(sha256.New, t.Secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// Mint returns a ticket for the client with clientID, which should be one it
+// authenticated with.
+func (t *Tickets) Mint(clientID string) (string, error) {
+	if len(t.Secret) < 32 {
+		return "", errTicketSecret
+	}
+
+	payload := make([]byte, ticketHeader, ticketHeader+len(clientID))
+	payload[0] = ticketVersion
+	binary.BigEndian.PutUint64(payload[1:], uint64(clockOr(t.Clock).Now().Add(t.ttl()).UnixMilli()))
+	if err := readRandom(randomOr(t.Random), payload[9:ticketHeader]); err != nil {
+		return "", err
+	}
+	payload = append(payload, clientID...)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(t.mac(payload)), nil
+}
+
+// Redeem checks ticket, and uses it up, returning the client ID it was
+// minted for.
+func (t *Tickets) Redeem(ctx context.Context, ticket string) (string, error) {
+	// with a short secret, or none, anyone could compute the MAC
+	if len(t.Secret) < 32 {
+		return "", errTicketSecret
+	}
+
+	encoded, encodedMAC, ok := strings.Cut(ticket, ".")
+	if !ok {
+		return "", ErrInvalidTicket
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(payload) <= ticketHeader || payload[0] != ticketVersion {
+		return "", ErrInvalidTicket
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, t.mac(payload)) {
+		return "", ErrInvalidTicket
+	}
+
+	expires := time.UnixMilli(int64(binary.BigEndian.Uint64(payload[1:])))
+	now := clockOr(t.Clock).Now()
+	if now.After(expires) {
+		return "", ErrTicketExpired
+	}
+
+	// the ticket can't be redeemed after it expires, so the nonce only has to
+	// be remembered until then
+	nonce := "ticket:" + base64.RawURLEncoding.EncodeToString(payload[9:ticketHeader])
+	unused, err := t.nonces().Use(ctx, nonce, expires.Sub(now)+time.Second)
+	if err != nil {
+		return "", err
+	}
+	if !unused {
+		return "", ErrTicketUsed
+	}
+
+	return string(payload[ticketHeader:]), nil
+}
+
+// RedeemRequest redeems the ticket in the "ticket" query parameter of r, for
+// use before upgrading it to a WebSocket.
+func (t *Tickets) RedeemRequest(r *http.Request) (string, error) {
+	ticket := r.URL.Query().Get("ticket")
+	if ticket == "" {
+		return "", ErrInvalidTicket
+	}
+	return t.Redeem(r.Context(), ticket)
+}
@@ -0,0 +1,151 @@
+package wskeyauth
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds the whole handshake unless WithTimeout says otherwise.
+const DefaultTimeout = 10 * time.Second
+
+// TimeoutCloseCode is the WebSocket close code sent to clients that didn't
+// complete the handshake in time. It is in the range reserved for
+// applications, after HTTP's 408 Request Timeout.
+const TimeoutCloseCode = 4408
+
+// TimeoutError is returned by Handshake when the client didn't complete the
+// handshake in time.
+type TimeoutError struct {
+	// Step is the protocol step the handshake was stuck in, such as
+	// "ReadChallengeResponse".
+	Step string
+
+	// After is how long the handshake ran for.
+	After time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return "wskeyauth: handshake timed out after " + e.After.Round(time.Millisecond).String() + " in " + e.Step
+}
+
+// Timeout reports true, so that a TimeoutError is recognized by code that
+// checks for net.Error timeouts.
+func (e *TimeoutError) Timeout() bool { return true }
+
+// WithTimeout bounds the whole handshake at d, instead of DefaultTimeout. A
+// deadline on the context given to WithContext applies too, if it's earlier.
+// A d of 0 or less leaves the handshake unbounded.
+//
+// When time is up, the client is sent a TIMEOUT message, and a close frame
+// with TimeoutCloseCode if the connection has a WriteControl method, as
+// gorilla/websocket's does. The read in progress is then interrupted with
+// SetReadDeadline, or failing that, by closing the connection.
+func WithTimeout(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.timeout = d
+	}
+}
+
+// controlWriter is implemented by WebSocket connections that can send
+// control frames, such as gorilla/websocket's.
+type controlWriter interface {
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+}
+
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+const closeMessage = 8
+
+// closeFrame returns the payload of a close frame with code and reason.
+func closeFrame(code int, reason string) []byte {
+	frame := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(frame, uint16(code))
+	return append(frame, reason...)
+}
+
+var errTimedOut = errors.New("wskeyauth: handshake timed out")
+
+// deadline enforces the handshake timeout. The handshake writes through it,
+// so that the TIMEOUT message can't interleave with the handshake's own.
+type deadline struct {
+	Conn
+
+	// raw is the connection as it was given to Handshake, before any
+	// wrapping hid the methods used to interrupt it.
+	raw     Conn
+	timeout time.Duration
+	timer   Timer
+
+	mu      sync.Mutex
+	expired bool
+	stopped bool
+}
+
+// startDeadline starts the clock on the handshake over conn. It returns nil if
+// the handshake isn't bounded.
+func startDeadline(conn, raw Conn, cfg *config) *deadline {
+	timeout, bounded := cfg.timeout, cfg.timeout > 0
+	if at, ok := cfg.ctx.Deadline(); ok && (!bounded || time.Until(at) < timeout) {
+		// the context's deadline is in real time
+		timeout, bounded = time.Until(at), true
+	}
+	if !bounded {
+		return nil
+	}
+
+	d := &deadline{Conn: conn, raw: raw, timeout: timeout}
+	d.timer = cfg.clock.AfterFunc(timeout, d.expire)
+	return d
+}
+
+func (d *deadline) WriteJSON(v any) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.expired {
+		return errTimedOut
+	}
+	return d.Conn.WriteJSON(v)
+}
+
+func (d *deadline) expire() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return
+	}
+	d.expired = true
+
+	d.Conn.WriteJSON(&stringMessage{
+		Type: "TIMEOUT",
+		Data: "The handshake did not complete within " + d.timeout.Round(time.Millisecond).String(),
+	})
+
+	if c, ok := d.raw.(controlWriter); ok {
+		c.WriteControl(closeMessage, closeFrame(TimeoutCloseCode, "handshake timed out"), time.Now().Add(time.Second))
+	}
+
+	switch c := d.raw.(type) {
+	case readDeadliner:
+		c.SetReadDeadline(time.Now())
+	case io.Closer:
+		c.Close()
+	}
+}
+
+// stop stops the clock, and reports whether time had already run out.
+func (d *deadline) stop() bool {
+	d.timer.Stop()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stopped = true
+	return d.expired
+}
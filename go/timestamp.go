@@ -0,0 +1,149 @@
+package wskeyauth
+
+import (
+	"encoding/binary"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Clients reconnecting to servers with WithSignedTimestamps may skip the
+// challenge, by signing the current time along with their CLIENT_ID:
+//
+//	{
+//		"type": "CLIENT_ID",
+//		"data": "<client ID>",
+//		"timestamp": {"time": <unix milliseconds>, "signature": "<base64>"}
+//	}
+//
+// The signature is over TimestampSigningPrefix, followed by the time as 8
+// big-endian bytes, and the server's audience, if it has one. It is made as
+// the signature of a challenge is. If it matches, and the time is within the
+// server's window and wasn't signed for with the key before, the server
+// responds with SIGNATURE_MATCHES straight away, in a single round trip. If
+// the time is off, as it is for clients whose clocks are, or was used
+// already, the server sends a CHALLENGE as it would have otherwise, so
+// clients must be ready for either. A key can skip the challenge once every
+// millisecond.
+//
+// Only client IDs with a key that signs directly can skip the challenge: not
+// passkeys, nor HMAC or SRP client IDs, whose timestamps are ignored.
+
+// DefaultTimestampWindow is how far from the server's clock signed
+// timestamps may be unless SignedTimestamps.Window says otherwise.
+const DefaultTimestampWindow = 30 * time.Second
+
+// TimestampSigningPrefix starts everything signed for a signed timestamp, so
+// that it can never pass for the response to a challenge, or the other way
+// around.
+const TimestampSigningPrefix = "wskeyauth timestamp\x00"
+
+// SignedTimestamp is a timestamp signed by the client, sent alongside its
+// CLIENT_ID.
+type SignedTimestamp struct {
+	// Time is when the client signed it, in milliseconds since the Unix
+	// epoch.
+	Time      int64  `json:"time"`
+	Signature string `json:"signature"`
+}
+
+// TimestampSigningInput returns what is signed for a timestamp of ms, for a
+// server with audience.
+func TimestampSigningInput(ms int64, audience string) []byte {
+	b := make([]byte, 0, len(TimestampSigningPrefix)+8+len(audience))
+	b = append(b, TimestampSigningPrefix...)
+	b = binary.BigEndian.AppendUint64(b, uint64(ms))
+	return append(b, audience...)
+}
+
+// SignedTimestamps configures the server to accept signed timestamps in place
+// of challenge responses.
+type SignedTimestamps struct {
+	// Window is how far from the server's clock, either way, timestamps may
+	// be. It defaults to DefaultTimestampWindow.
+	Window time.Duration
+
+	// Nonces records which timestamps were signed for. It defaults to a
+	// MemoryNonceStore; share one between servers, such as the Redis one in
+	// contrib/redis, so that a timestamp can't be replayed on each of them.
+	Nonces NonceStore
+
+	once sync.Once
+}
+
+// WithSignedTimestamps lets clients authenticate with a signed timestamp,
+// without a challenge, as t says. Without it, timestamps are ignored, and
+// every client is challenged.
+func WithSignedTimestamps(t *SignedTimestamps) Option {
+	return func(cfg *config) {
+		cfg.timestamps = t
+	}
+}
+
+func (t *SignedTimestamps) window() time.Duration {
+	if t.Window <= 0 {
+		return DefaultTimestampWindow
+	}
+	return t.Window
+}
+
+func (t *SignedTimestamps) nonces(clock Clock) NonceStore {
+	t.once.Do(func() {
+		if t.Nonces == nil {
+			t.Nonces = &MemoryNonceStore{Clock: clock}
+		}
+	})
+	return t.Nonces
+}
+
+// checkTimestamp checks the client's signed timestamp. It reports whether
+// the timestamp authenticates the client; if it doesn't, and the reason is
+// empty, the client is to be challenged instead.
+func (h *handshakeState) checkTimestamp(pubKey *publicKey, timestamp *SignedTimestamp) (bool, FailureReason, error) {
+	conn, cfg := h.conn, h.cfg
+	t := cfg.timestamps
+
+	if !signsMessages(pubKey) {
+		h.log.Debug("wskeyauth: ignoring timestamp of a client ID that can't sign it")
+		return false, "", nil
+	}
+
+	signed := time.UnixMilli(timestamp.Time)
+	now := cfg.clock.Now()
+	if signed.Before(now.Add(-t.window())) || signed.After(now.Add(t.window())) {
+		h.log.Debug("wskeyauth: timestamp is outside the window", "timestamp", signed)
+		return false, "", nil
+	}
+
+	h.trace.step("VerifyTimestamp")
+
+	sig, err := cfg.base64.decode(nil, timestamp.Signature)
+	if err != nil {
+		conn.WriteJSON(newErrorMessage("CLIENT_ERROR", ReasonMalformedMessage, "Failed to parse CLIENT_ID", err))
+		return false, ReasonMalformedMessage, err
+	}
+
+	start := time.Now()
+	verified := verifyWithKey(pubKey, TimestampSigningInput(timestamp.Time, signedAudience(cfg.audience, pubKey.namespace)), sig)
+	cfg.metrics.VerificationDuration(time.Since(start))
+	if !verified {
+		conn.WriteJSON(&typeMessage{Type: "SIGNATURE_MISMATCH"})
+		return false, ReasonSignatureMismatch, nil
+	}
+
+	// timestamps outside the window are never accepted, so they only have to
+	// be remembered until they leave it
+	nonce := "timestamp:" + h.fingerprint + ":" + strconv.FormatInt(timestamp.Time, 10)
+	unused, err := t.nonces(cfg.clock).Use(cfg.ctx, nonce, signed.Add(t.window()).Sub(now)+time.Second)
+	if err != nil {
+		conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to check timestamp", err))
+		return false, ReasonServerError, err
+	}
+	if !unused {
+		h.log.Debug("wskeyauth: timestamp was signed for already", "timestamp", signed)
+		return false, "", nil
+	}
+
+	h.log.Debug("wskeyauth: signed timestamp matches")
+	return true, "", nil
+}
@@ -0,0 +1,35 @@
+package wskeyauth_test
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+func TestSignedTimestampBase64(t *testing.T) {
+	clock := wskeyauthtest.NewClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	key := wskeyauthtest.MustGenerateKey()
+	ms := clock.Now().UnixMilli()
+	sig, err := key.Sign(wskeyauth.TimestampSigningInput(ms, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs := wskeyauth.NewServerHandshake(
+		wskeyauth.WithClock(clock),
+		wskeyauth.WithSignedTimestamps(&wskeyauth.SignedTimestamps{}),
+		wskeyauth.WithBase64(wskeyauth.Base64Std|wskeyauth.Base64RawURL),
+	)
+	hs.Start()
+	out, state, err := hs.Feed(marshal(t, map[string]any{
+		"type":      "CLIENT_ID",
+		"data":      key.ClientID(),
+		"timestamp": map[string]any{"time": ms, "signature": base64.RawURLEncoding.EncodeToString(sig)},
+	}))
+	if state != wskeyauth.StateAuthenticated || err != nil {
+		t.Fatalf("Feed(CLIENT_ID) with an unpadded URL-safe timestamp signature = %s, %v, %v", out, state, err)
+	}
+}
@@ -0,0 +1,48 @@
+package wskeyauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/tls"
+)
+
+// TLSClientAuth skips the challenge for clients that already proved they
+// hold their key with a TLS client certificate, verified by the server's
+// tls.Config, such as with tls.RequireAndVerifyClientCert. If the public key
+// of the certificate is the key of the client's client ID, the server sends
+// SIGNATURE_MATCHES, or whatever comes before it, such as
+// SECOND_FACTOR_REQUIRED, in place of the CHALLENGE. Key stores, hooks and
+// rate limits still apply.
+type TLSClientAuth struct {
+	// State is the state of the TLS connection the WebSocket was upgraded
+	// from, as found in http.Request.TLS.
+	State *tls.ConnectionState
+
+	// RequireHandshake has every client sign the challenge, certificate or
+	// not, for deployments that want to turn the shortcut off without
+	// changing how they are set up.
+	RequireHandshake bool
+}
+
+// WithTLSClientAuth skips the challenge for clients whose verified TLS client
+// certificate holds their key, as TLSClientAuth describes.
+func WithTLSClientAuth(a TLSClientAuth) Option {
+	return func(cfg *config) {
+		cfg.tlsClientAuth = &a
+	}
+}
+
+// matches reports whether the verified client certificate holds pubKey.
+func (a *TLSClientAuth) matches(pubKey *publicKey) bool {
+	if a.RequireHandshake || a.State == nil || len(a.State.VerifiedChains) == 0 || pubKey.webauthn {
+		return false
+	}
+
+	switch key := a.State.VerifiedChains[0][0].PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		return pubKey.ecdsa != nil && pubKey.ecdsa.Equal(key)
+	case ed25519.PublicKey:
+		return pubKey.ed25519 != nil && pubKey.ed25519.Equal(key)
+	}
+	return false
+}
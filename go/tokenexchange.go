@@ -0,0 +1,146 @@
+package wskeyauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// With WithTokenExchange, the server exchanges the identity of every client
+// it authenticates for an access token, as in RFC 8693, and hands the token
+// to the client in a "token" member of SIGNATURE_MATCHES:
+//
+//	{
+//		"type": "SIGNATURE_MATCHES",
+//		"token": {"access_token": "...", "token_type": "Bearer", "expires_in": 3600}
+//	}
+//
+// so that clients authenticated over the WebSocket can call REST APIs that
+// take tokens from the same authorization server. If the exchange fails, the
+// handshake fails with SERVER_ERROR.
+
+// TokenExchangeGrantType is the grant type of an RFC 8693 token exchange.
+const TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+// FingerprintTokenType is the subject token type the server exchanges
+// clients' fingerprints as, unless TokenExchange.SubjectToken says otherwise.
+const FingerprintTokenType = "urn:castcam-live:ws-key-auth:token-type:fingerprint"
+
+// TokenExchange configures the exchange with the authorization server.
+type TokenExchange struct {
+	// Endpoint is the authorization server's token endpoint.
+	Endpoint string
+
+	// ClientID and ClientSecret, if set, authenticate the server to the
+	// authorization server, which must trust it to vouch for the clients it
+	// authenticated.
+	ClientID     string
+	ClientSecret string
+
+	// Audience and Scope, if set, are requested for the token.
+	Audience string
+	Scope    string
+
+	// SubjectToken returns the token to exchange for the client with
+	// clientID, and its type. By default, the client's fingerprint is
+	// exchanged, as a FingerprintTokenType.
+	SubjectToken func(clientID, fingerprint string) (token, tokenType string, err error)
+
+	// HTTPClient makes the request. It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// AccessToken is the token the authorization server issued for a client.
+type AccessToken struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int    `json:"expires_in,omitempty"`
+	Scope           string `json:"scope,omitempty"`
+}
+
+// WithTokenExchange exchanges every authenticated client's identity for an
+// access token through t, which is handed to the client.
+func WithTokenExchange(t TokenExchange) Option {
+	return func(cfg *config) {
+		cfg.tokenExchange = &t
+	}
+}
+
+// exchange requests a token for the client with clientID, of scope, if it
+// isn't empty.
+func (t *TokenExchange) exchange(ctx context.Context, clientID, fingerprint, scope string) (*AccessToken, error) {
+	subject, subjectType := fingerprint, FingerprintTokenType
+	if t.SubjectToken != nil {
+		var err error
+		if subject, subjectType, err = t.SubjectToken(clientID, fingerprint); err != nil {
+			return nil, err
+		}
+	}
+
+	form := url.Values{
+		"grant_type":           {TokenExchangeGrantType},
+		"subject_token":        {subject},
+		"subject_token_type":   {subjectType},
+		"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+	}
+	if t.Audience != "" {
+		form.Set("audience", t.Audience)
+	}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if t.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(t.ClientID), url.QueryEscape(t.ClientSecret))
+	}
+
+	client := t.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error != "" {
+			if oauthErr.Description != "" {
+				return nil, fmt.Errorf("token exchange failed: %s: %s", oauthErr.Error, oauthErr.Description)
+			}
+			return nil, fmt.Errorf("token exchange failed: %s", oauthErr.Error)
+		}
+		return nil, fmt.Errorf("token exchange failed with status %d", resp.StatusCode)
+	}
+
+	var token AccessToken
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("token exchange returned a malformed response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("token exchange returned no access token")
+	}
+	return &token, nil
+}
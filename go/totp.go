@@ -0,0 +1,169 @@
+package wskeyauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// With WithTOTP, a client whose signature matched is asked for a one-time
+// code before it is authenticated:
+//
+//	-> SECOND_FACTOR_REQUIRED, with data "TOTP"
+//	<- SECOND_FACTOR, with the code as its data
+//
+// and then SIGNATURE_MATCHES, or SECOND_FACTOR_MISMATCH. With
+// WithNonceStore, each code can only be used once; otherwise, as the server
+// keeps no state between handshakes, a code may be used more than once
+// within its period.
+
+// TOTPStore holds the TOTP secrets of clients.
+type TOTPStore interface {
+	// TOTPSecret returns the secret of the client whose key has fingerprint,
+	// or nil if it has none. An error fails the handshake with
+	// ReasonServerError.
+	TOTPSecret(ctx context.Context, fingerprint string) ([]byte, error)
+}
+
+// TOTP configures the second factor, as in RFC 6238 with HMAC-SHA-1.
+type TOTP struct {
+	Secrets TOTPStore
+
+	// Digits is the length of the codes. It defaults to 6.
+	Digits int
+
+	// Period is how long each code is valid for, in whole seconds. It
+	// defaults to 30 seconds.
+	Period time.Duration
+
+	// Skew is how many periods either side of the current one codes are
+	// accepted from, for clients whose clocks drift. It defaults to 1; set it
+	// to -1 to only accept the current code.
+	Skew int
+
+	// Optional lets clients without a secret through without a second
+	// factor. Otherwise, they are rejected.
+	Optional bool
+}
+
+// WithTOTP requires a TOTP code from clients after their signature matches,
+// checked against the secrets in t.Secrets.
+func WithTOTP(t TOTP) Option {
+	if t.Digits == 0 {
+		t.Digits = 6
+	}
+	if t.Period == 0 {
+		t.Period = 30 * time.Second
+	}
+	if t.Skew == 0 {
+		t.Skew = 1
+	} else if t.Skew < 0 {
+		t.Skew = 0
+	}
+	return func(cfg *config) {
+		cfg.totp = &t
+	}
+}
+
+// code returns the code for the period counter falls in.
+func (t *TOTP) code(secret []byte, counter uint64) string {
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(binary.BigEndian.AppendUint64(nil, counter))
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	truncated := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < t.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", t.Digits, truncated%mod)
+}
+
+// verify reports whether code is valid at now, and the counter of the period
+// it is for.
+func (t *TOTP) verify(secret []byte, code string, now time.Time) (uint64, bool) {
+	if len(code) != t.Digits {
+		return 0, false
+	}
+	period := uint64(t.Period / time.Second)
+	if period == 0 {
+		period = 1
+	}
+	counter := uint64(now.Unix()) / period
+
+	var matched uint64
+	valid := 0
+	for skew := -t.Skew; skew <= t.Skew; skew++ {
+		c := counter + uint64(skew)
+		if subtle.ConstantTimeCompare([]byte(t.code(secret, c)), []byte(code)) == 1 {
+			matched, valid = c, 1
+		}
+	}
+	return matched, valid == 1
+}
+
+// secondFactor asks the client of the handshake for a TOTP code, if it must
+// give one.
+func (h *handshakeState) secondFactor() (bool, string, FailureReason, error) {
+	conn, cfg, trace, clientID := h.conn, h.cfg, h.trace, h.clientID
+
+	secret, err := cfg.totp.Secrets.TOTPSecret(cfg.ctx, h.fingerprint)
+	if err != nil {
+		conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to look up second factor", err))
+		return false, clientID, ReasonServerError, err
+	}
+	if secret == nil && cfg.totp.Optional {
+		return h.authenticated()
+	}
+	if secret == nil {
+		conn.WriteJSON(&stringMessage{Type: "SECOND_FACTOR_MISMATCH", Data: "Client has no second factor"})
+		return false, clientID, ReasonSecondFactorMismatch, nil
+	}
+	h.totpSecret = secret
+
+	trace.step("ReadSecondFactor")
+
+	conn.WriteJSON(&stringMessage{Type: "SECOND_FACTOR_REQUIRED", Data: "TOTP"})
+	h.log.Debug("wskeyauth: sent second factor request")
+
+	return h.then((*handshakeState).readSecondFactor)
+}
+
+// readSecondFactor reads the client's TOTP code, and checks it is the right
+// one.
+func (h *handshakeState) readSecondFactor() (bool, string, FailureReason, error) {
+	conn, cfg, clientID := h.conn, h.cfg, h.clientID
+
+	var msg ClientMessage
+	if reason, err := h.readMessage(&msg, "SECOND_FACTOR"); reason != "" {
+		return false, clientID, reason, err
+	}
+
+	counter, ok := cfg.totp.verify(h.totpSecret, msg.SecondFactor, cfg.clock.Now())
+	if !ok {
+		conn.WriteJSON(&typeMessage{Type: "SECOND_FACTOR_MISMATCH"})
+		return false, clientID, ReasonSecondFactorMismatch, nil
+	}
+
+	if cfg.nonces != nil {
+		// codes stay valid for as long as the skew lets them
+		ttl := cfg.totp.Period * time.Duration(2*cfg.totp.Skew+1)
+		unused, err := cfg.nonces.Use(cfg.ctx, "totp:"+h.fingerprint+":"+strconv.FormatUint(counter, 10), ttl)
+		if err != nil {
+			conn.WriteJSON(newErrorMessage("SERVER_ERROR", ReasonServerError, "Failed to record second factor", err))
+			return false, clientID, ReasonServerError, err
+		}
+		if !unused {
+			conn.WriteJSON(&stringMessage{Type: "SECOND_FACTOR_MISMATCH", Data: "Code was already used"})
+			return false, clientID, ReasonSecondFactorMismatch, nil
+		}
+	}
+	return h.authenticated()
+}
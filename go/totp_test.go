@@ -0,0 +1,41 @@
+package wskeyauth
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTOTPVectors checks codes against the SHA-1 test vectors of RFC 6238,
+// Appendix B.
+func TestTOTPVectors(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	totp := &TOTP{Digits: 8, Period: 30 * time.Second}
+
+	for _, v := range []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	} {
+		if got := totp.code(secret, uint64(v.unix/30)); got != v.code {
+			t.Errorf("code at %d = %s, want %s", v.unix, got, v.code)
+		}
+		if _, ok := totp.verify(secret, v.code, time.Unix(v.unix, 0)); !ok {
+			t.Errorf("verify(%s) at %d failed", v.code, v.unix)
+		}
+	}
+
+	// the code of the period before is only accepted with skew
+	if _, ok := totp.verify(secret, "07081804", time.Unix(1111111111, 0)); ok {
+		t.Error("verify() accepted the code of the period before without skew")
+	}
+	totp.Skew = 1
+	if counter, ok := totp.verify(secret, "07081804", time.Unix(1111111111, 0)); !ok || counter != 1111111109/30 {
+		t.Errorf("verify() with skew = %d, %v", counter, ok)
+	}
+}
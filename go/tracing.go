@@ -0,0 +1,65 @@
+package wskeyauth
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/castcam-live/ws-key-auth/go"
+
+// tracing holds the span of a handshake, and of the protocol step currently in
+// progress.
+type tracing struct {
+	tracer trace.Tracer
+	ctx    context.Context
+	root   trace.Span
+	span   trace.Span
+
+	// name is the name of the current step.
+	name string
+}
+
+func startTracing(cfg *config) *tracing {
+	ctx, root := cfg.tracer.Start(cfg.ctx, "wskeyauth.Handshake")
+	return &tracing{tracer: cfg.tracer, ctx: ctx, root: root}
+}
+
+// step ends the span of the previous step, if any, and starts one for the
+// next.
+func (t *tracing) step(name string) {
+	t.endStep()
+	t.name = name
+	_, t.span = t.tracer.Start(t.ctx, "wskeyauth."+name)
+}
+
+func (t *tracing) endStep() {
+	if t.span != nil {
+		t.span.End()
+		t.span = nil
+	}
+}
+
+func (t *tracing) setFingerprint(fingerprint string) {
+	t.root.SetAttributes(attribute.String("wskeyauth.client.fingerprint", fingerprint))
+}
+
+func (t *tracing) fail(reason FailureReason, err error) {
+	for _, span := range []trace.Span{t.span, t.root} {
+		if span == nil {
+			continue
+		}
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.SetStatus(codes.Error, string(reason))
+	}
+	t.root.SetAttributes(attribute.String("wskeyauth.failure_reason", string(reason)))
+}
+
+func (t *tracing) end() {
+	t.endStep()
+	t.root.End()
+}
@@ -0,0 +1,137 @@
+package wskeyauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Recorder writes a transcript of every message exchanged during handshakes,
+// one line per message:
+//
+//	2023-04-01T12:00:00.123456789Z 7 <- {"type":"CLIENT_ID","data":"WebCrypto-raw.EC.P-256$BJ..."}
+//	2023-04-01T12:00:00.124001211Z 7 -> {"type":"CHALLENGE","data":"mJ4..."}
+//
+// The number identifies the handshake, so that transcripts of concurrent
+// handshakes can be told apart. "<-" marks messages from the client, and "->"
+// messages to it.
+//
+// Messages are recorded exactly as they went over the wire, unless a redactor
+// is configured.
+type Recorder struct {
+	mu     sync.Mutex
+	w      io.Writer
+	redact Redactor
+	next   uint64
+}
+
+// Redactor rewrites a message before it is written to a transcript.
+// fromClient tells which way the message was going.
+type Redactor func(msg []byte, fromClient bool) []byte
+
+// NewRecorder records transcripts to w. redact may be nil, to record messages
+// verbatim.
+func NewRecorder(w io.Writer, redact Redactor) *Recorder {
+	return &Recorder{w: w, redact: redact}
+}
+
+// RedactFields returns a Redactor that replaces the value of every object
+// member with one of the given names, at any depth, with "[REDACTED]". For
+// instance, RedactFields("signature") hides challenge signatures while keeping
+// everything else intact.
+func RedactFields(names ...string) Redactor {
+	redacted := map[string]bool{}
+	for _, name := range names {
+		redacted[name] = true
+	}
+
+	var walk func(v any) any
+	walk = func(v any) any {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				if redacted[k] {
+					v[k] = "[REDACTED]"
+				} else {
+					v[k] = walk(child)
+				}
+			}
+		case []any:
+			for i, child := range v {
+				v[i] = walk(child)
+			}
+		}
+		return v
+	}
+
+	return func(msg []byte, _ bool) []byte {
+		var v any
+		if err := json.Unmarshal(msg, &v); err != nil {
+			return []byte(`"[UNPARSEABLE MESSAGE REDACTED]"`)
+		}
+		b, err := json.Marshal(walk(v))
+		if err != nil {
+			return []byte(`"[UNPARSEABLE MESSAGE REDACTED]"`)
+		}
+		return b
+	}
+}
+
+// WithTranscript records the handshake's messages to rec.
+func WithTranscript(rec *Recorder) Option {
+	return func(cfg *config) {
+		cfg.recorder = rec
+	}
+}
+
+func (r *Recorder) wrap(conn Conn) Conn {
+	r.mu.Lock()
+	r.next++
+	id := r.next
+	r.mu.Unlock()
+
+	return &recordingConn{Conn: conn, recorder: r, id: id}
+}
+
+func (r *Recorder) record(id uint64, fromClient bool, msg []byte) {
+	if r.redact != nil {
+		msg = r.redact(msg, fromClient)
+	}
+
+	direction := "->"
+	if fromClient {
+		direction = "<-"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.w, "%s %d %s %s\n", time.Now().UTC().Format(time.RFC3339Nano), id, direction, msg)
+}
+
+// recordingConn passes messages through as raw JSON, so that the transcript
+// holds the bytes that went over the wire rather than a re-encoding of them.
+type recordingConn struct {
+	Conn
+	recorder *Recorder
+	id       uint64
+}
+
+func (c *recordingConn) ReadJSON(v any) error {
+	var msg json.RawMessage
+	if err := c.Conn.ReadJSON(&msg); err != nil {
+		return err
+	}
+	c.recorder.record(c.id, true, msg)
+	return json.Unmarshal(msg, v)
+}
+
+func (c *recordingConn) WriteJSON(v any) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.recorder.record(c.id, false, msg)
+	return c.Conn.WriteJSON(json.RawMessage(msg))
+}
@@ -0,0 +1,75 @@
+package wskeyauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+// With WithTURN, the server hands every client it authenticates time-limited
+// credentials for its TURN servers, in a "turn" member of SIGNATURE_MATCHES,
+// so that only authenticated clients may relay through them:
+//
+//	{"type": "SIGNATURE_MATCHES", "turn": {"username": "1700086400:SHA256:3q2+7w...", "password": "...", "ttl": 86400, "uris": ["turn:turn.example.com:3478"]}}
+//
+// The credentials are those of the TURN REST API, as coturn's
+// use-auth-secret and most other TURN servers accept: the username is when
+// they expire, in seconds since the Unix epoch, and the client's fingerprint,
+// and the password is the base64 of the HMAC-SHA1 of the username, keyed with
+// the secret the TURN servers share with this one.
+
+// DefaultTURNTTL is how long TURN credentials are valid unless TURN.TTL says
+// otherwise, as the TURN REST API suggests.
+const DefaultTURNTTL = 24 * time.Hour
+
+// TURN issues credentials for TURN servers.
+type TURN struct {
+	// Secret is the secret shared with the TURN servers, as coturn's
+	// static-auth-secret.
+	Secret []byte
+
+	// TTL is how long credentials are valid. It defaults to DefaultTURNTTL.
+	TTL time.Duration
+
+	// URIs are the TURN servers' URIs, such as
+	// "turn:turn.example.com:3478?transport=udp", for clients to configure
+	// their ICE servers with.
+	URIs []string
+}
+
+// WithTURN hands every authenticated client credentials for the TURN servers
+// of t.
+func WithTURN(t *TURN) Option {
+	return func(cfg *config) {
+		cfg.turn = t
+	}
+}
+
+// TURNCredentials are credentials for TURN servers.
+type TURNCredentials struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	TTL      int64    `json:"ttl"`
+	URIs     []string `json:"uris,omitempty"`
+}
+
+// Credentials returns credentials for the client with fingerprint, valid from
+// now for t.TTL.
+func (t *TURN) Credentials(fingerprint string, now time.Time) *TURNCredentials {
+	ttl := t.TTL
+	if ttl <= 0 {
+		ttl = DefaultTURNTTL
+	}
+
+	username := strconv.FormatInt(now.Add(ttl).Unix(), 10) + ":" + fingerprint
+	mac := hmac.New(sha1.New, t.Secret)
+	mac.Write([]byte(username))
+	return &TURNCredentials{
+		Username: username,
+		Password: base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+		TTL:      int64(ttl / time.Second),
+		URIs:     t.URIs,
+	}
+}
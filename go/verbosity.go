@@ -0,0 +1,90 @@
+package wskeyauth
+
+// ErrorVerbosity says how much the errors sent to clients tell them. Either
+// way, the error Handshake returns, and what it logs, tell everything.
+type ErrorVerbosity int
+
+const (
+	// VerboseErrors tell clients what went wrong, and why, such as the
+	// error a message failed to parse with:
+	//
+	//	{"type": "CLIENT_ERROR", "data": {"code": "MALFORMED_MESSAGE", "message": "Failed to parse CLIENT_ID", "error": "invalid character 'x' looking for beginning of value"}}
+	//
+	// The code of CLIENT_ERROR, SERVER_ERROR and UNSUPPORTED_ALGORITHM is
+	// the FailureReason.Code of why the handshake failed, for clients to go
+	// by, as the message may be translated by a MessageCatalog.
+	//
+	// They are the default, as they help while developing clients, but they
+	// tell anyone probing the server about its implementation, down to
+	// whether a key is known.
+	VerboseErrors ErrorVerbosity = iota
+
+	// TerseErrors tell clients only the type of error, such as CLIENT_ERROR
+	// or SIGNATURE_MISMATCH, and its code, if it has one:
+	//
+	//	{"type": "CLIENT_ERROR", "data": {"code": "UNKNOWN_KEY"}}
+	//
+	// for servers in production. Messages that carry data clients act on,
+	// such as RETRY_AFTER and PAIRING_PENDING, are sent as they are.
+	TerseErrors
+)
+
+// WithErrorVerbosity sets how much the errors sent to clients tell them. It
+// defaults to VerboseErrors.
+func WithErrorVerbosity(v ErrorVerbosity) Option {
+	return func(cfg *config) {
+		cfg.errorVerbosity = v
+	}
+}
+
+// errorTypes are the messages that TerseErrors strips of their data.
+var errorTypes = map[string]bool{
+	"CLIENT_ERROR":           true,
+	"SERVER_ERROR":           true,
+	"UNSUPPORTED_ALGORITHM":  true,
+	"UNSUPPORTED_HASH":       true,
+	"SIGNATURE_MISMATCH":     true,
+	"SECOND_FACTOR_MISMATCH": true,
+	"PROOF_OF_WORK_MISMATCH": true,
+	"REFRESH_DENIED":         true,
+}
+
+// wrap returns conn, stripping the errors written to it as v says.
+func (v ErrorVerbosity) wrap(conn Conn) Conn {
+	if v != TerseErrors {
+		return conn
+	}
+	return terseConn{conn}
+}
+
+// terseConn strips the errors written to it of their data.
+type terseConn struct {
+	Conn
+}
+
+// terseErrorMessage is an error stripped of all but its code.
+type terseErrorMessage struct {
+	Type string `json:"type"`
+	Data struct {
+		Code string `json:"code"`
+	} `json:"data"`
+	Retry bool `json:"retry,omitempty"`
+}
+
+func (c terseConn) WriteJSON(v any) error {
+	switch m := v.(type) {
+	case *errorMessage:
+		if errorTypes[m.Type] && m.Data.Code != "" {
+			terse := &terseErrorMessage{Type: m.Type, Retry: m.Retry}
+			terse.Data.Code = m.Data.Code
+			v = terse
+		} else if errorTypes[m.Type] {
+			v = &typeMessage{Type: m.Type, Retry: m.Retry}
+		}
+	case *stringMessage:
+		if errorTypes[m.Type] {
+			v = &typeMessage{Type: m.Type, Retry: m.Retry}
+		}
+	}
+	return c.Conn.WriteJSON(v)
+}
@@ -0,0 +1,33 @@
+package wskeyauth_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+func TestErrorsCarryCodes(t *testing.T) {
+	for _, v := range []wskeyauth.ErrorVerbosity{wskeyauth.VerboseErrors, wskeyauth.TerseErrors} {
+		hs := wskeyauth.NewServerHandshake(wskeyauth.WithErrorVerbosity(v))
+		hs.Start()
+		out, _, _ := hs.Feed(json.RawMessage(`{"type":"CLIENT_ID","data":"not a client ID"}`))
+
+		var msg struct {
+			Type string `json:"type"`
+			Data struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"data"`
+		}
+		if len(out) != 1 || json.Unmarshal(out[0], &msg) != nil {
+			t.Fatalf("verbosity %d: got %s, want one error", v, out)
+		}
+		if msg.Type != "CLIENT_ERROR" || msg.Data.Code != wskeyauth.ReasonInvalidClientID.Code() {
+			t.Fatalf("verbosity %d: got %s, want a CLIENT_ERROR with code %s", v, out[0], wskeyauth.ReasonInvalidClientID.Code())
+		}
+		if terse := v == wskeyauth.TerseErrors; terse != (msg.Data.Message == "") {
+			t.Fatalf("verbosity %d: got %s", v, out[0])
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package wskeyauth
+
+import "crypto/ecdsa"
+
+// verifySignature verifies a WebCrypto ECDSA signature, which is the
+// fixed-size concatenation of r and s, against hash.
+//
+// ecdsa.Verify takes r and s as big.Ints only to re-encode them as ASN.1
+// internally, so we encode the signature as ASN.1 ourselves, on the stack,
+// and skip the round-trip.
+func verifySignature(pubKey *ecdsa.PublicKey, hash, sig []byte) bool {
+	var der [2 + 2*(2+33)]byte
+	n := len(sig) / 2
+
+	i := 2
+	i += putASN1Integer(der[i:], sig[:n])
+	i += putASN1Integer(der[i:], sig[n:])
+
+	der[0] = 0x30 // SEQUENCE
+	der[1] = byte(i - 2)
+
+	return ecdsa.VerifyASN1(pubKey, hash, der[:i])
+}
+
+// putASN1Integer writes the big-endian unsigned integer v to dst as a DER
+// INTEGER, and returns the number of bytes written. v must be at most 32 bytes
+// long.
+func putASN1Integer(dst, v []byte) int {
+	for len(v) > 1 && v[0] == 0 {
+		v = v[1:]
+	}
+
+	dst[0] = 0x02 // INTEGER
+	i := 2
+	if len(v) > 0 && v[0]&0x80 != 0 {
+		// without a leading zero, the integer would read as negative
+		dst[i] = 0
+		i++
+	}
+	i += copy(dst[i:], v)
+	dst[1] = byte(i - 2)
+
+	return i
+}
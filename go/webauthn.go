@@ -0,0 +1,126 @@
+package wskeyauth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+)
+
+// WebAuthn configures the server to accept passkeys, and other WebAuthn
+// credentials, as clients. Their client IDs are of the form
+//
+//	WebAuthn-raw.EC.P-256$<base64 encoded public key>
+//
+// with the credential's public key in the same raw, uncompressed form as a
+// WebCrypto key's. The client has its authenticator sign the bytes it would
+// otherwise sign itself, as the WebAuthn challenge, and sends the resulting
+// assertion in its CHALLENGE_RESPONSE:
+//
+//	{
+//		"signature": "<base64 ASN.1 signature>",
+//		"hash": "SHA-256",
+//		"authenticatorData": "<base64 authenticator data>",
+//		"clientDataJSON": "<base64 client data>"
+//	}
+//
+// Signature counters aren't checked, since the server keeps no state between
+// handshakes.
+type WebAuthn struct {
+	// RPID is the relying party ID the credentials were created for, usually
+	// the site's domain, such as "example.com".
+	RPID string
+
+	// Origins are the origins assertions may be made on, such as
+	// "https://example.com".
+	Origins []string
+
+	// RequireUserVerification rejects assertions where the authenticator
+	// didn't verify the user, with a PIN or biometrics, only that they were
+	// present.
+	RequireUserVerification bool
+}
+
+// WithWebAuthn accepts WebAuthn client IDs, with assertions verified against
+// w. Without it, WebAuthn client IDs are rejected.
+func WithWebAuthn(w WebAuthn) Option {
+	return func(cfg *config) {
+		cfg.webauthn = &w
+	}
+}
+
+// assertion is the decoded WebAuthn assertion from a CHALLENGE_RESPONSE.
+type assertion struct {
+	authenticatorData []byte
+	clientDataJSON    []byte
+}
+
+func parseAssertion(msg *ClientMessage, accept Base64) (*assertion, error) {
+	if msg.AuthenticatorData == "" || msg.ClientDataJSON == "" {
+		return nil, errors.New("expected a WebAuthn assertion, with authenticatorData and clientDataJSON")
+	}
+
+	authenticatorData, err := accept.decode(nil, msg.AuthenticatorData)
+	if err != nil {
+		return nil, errors.New("authenticatorData: " + err.Error())
+	}
+	clientDataJSON, err := accept.decode(nil, msg.ClientDataJSON)
+	if err != nil {
+		return nil, errors.New("clientDataJSON: " + err.Error())
+	}
+
+	return &assertion{authenticatorData: authenticatorData, clientDataJSON: clientDataJSON}, nil
+}
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+)
+
+// verify checks that a is an assertion of challenge, made on one of our
+// origins, and reports whether sig is pub's signature of it.
+func (w *WebAuthn) verify(pub *ecdsa.PublicKey, challenge []byte, a *assertion, sig []byte) (bool, error) {
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	if err := json.Unmarshal(a.clientDataJSON, &clientData); err != nil {
+		return false, errors.New("clientDataJSON is malformed: " + err.Error())
+	}
+	if clientData.Type != "webauthn.get" {
+		return false, errors.New("expected an assertion of type webauthn.get, but got " + clientData.Type)
+	}
+	if clientData.Challenge != base64.RawURLEncoding.EncodeToString(challenge) {
+		return false, errors.New("the assertion is for another challenge")
+	}
+	if !slices.Contains(w.Origins, clientData.Origin) {
+		return false, errors.New("the assertion was made on an unexpected origin, " + clientData.Origin)
+	}
+
+	authData := a.authenticatorData
+	if len(authData) < 37 {
+		return false, errors.New("authenticatorData is too short")
+	}
+	rpIDHash := sha256.Sum256([]byte(w.RPID))
+	if !bytes.Equal(authData[:32], rpIDHash[:]) {
+		return false, errors.New("the assertion is for another relying party")
+	}
+	flags := authData[32]
+	if flags&flagUserPresent == 0 {
+		return false, errors.New("the authenticator didn't check for the user's presence")
+	}
+	if w.RequireUserVerification && flags&flagUserVerified == 0 {
+		return false, errors.New("the authenticator didn't verify the user")
+	}
+
+	clientDataHash := sha256.Sum256(a.clientDataJSON)
+	signed := sha256.New()
+	signed.Write(authData)
+	signed.Write(clientDataHash[:])
+
+	return ecdsa.VerifyASN1(pub, signed.Sum(nil), sig), nil
+}
@@ -0,0 +1,425 @@
+// Package webhook notifies external systems, such as fraud detection or
+// billing, of authentication events, by POSTing them to a URL as they
+// happen:
+//
+//	hooks := webhook.New(webhook.Config{URL: "https://example.com/hooks", Secret: secret})
+//	defer hooks.Close(ctx)
+//	defer hooks.Watch(registry)()
+//	wskeyauth.Handshake(conn, wskeyauth.WithAuditSink(hooks))
+//
+// The Dispatcher is a wskeyauth.AuditSink, which gets an event for every
+// handshake, successful or not, and watches registries for sessions leaving.
+// It may instead Listen to a wskeyauth.EventBus that handshakes and
+// registries publish to. Each event is POSTed as JSON:
+//
+//	{
+//		"id": "5f0c...",
+//		"type": "authenticated",
+//		"time": "2024-01-01T00:00:00Z",
+//		"clientId": "...",
+//		"fingerprint": "SHA256:...",
+//		"remoteAddr": "203.0.113.7:51234"
+//	}
+//
+// with the headers
+//
+//	Webhook-Id: 5f0c...
+//	Webhook-Timestamp: 1704067200
+//	Webhook-Signature: v1=<hex HMAC-SHA256 of the timestamp, ".", and the body>
+//
+// which receivers check with Verify. Deliveries that fail, or are answered
+// with a 5xx or 429, are retried with exponential backoff, with the same ID,
+// so that receivers can tell a retry from a new event.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// Defaults for the fields of Config left zero.
+const (
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = time.Second
+	DefaultMaxBackoff  = time.Minute
+	DefaultQueueSize   = 1024
+	DefaultWorkers     = 4
+
+	// DefaultTolerance is how old a delivery may be for Verify to accept it.
+	DefaultTolerance = 5 * time.Minute
+)
+
+// ErrQueueFull is returned by Send and Record when events come in faster
+// than they are delivered, and the event is dropped.
+var ErrQueueFull = errors.New("webhook: queue is full")
+
+// ErrInvalidSignature is returned by Verify for deliveries that aren't signed
+// with the secret, or are too old.
+var ErrInvalidSignature = errors.New("webhook: invalid signature")
+
+// EventType is what happened.
+type EventType string
+
+const (
+	// Authenticated is sent when a handshake authenticated a client.
+	Authenticated EventType = "authenticated"
+	// Failed is sent when a handshake failed, with the reason why.
+	Failed EventType = "failed"
+	// Disconnected is sent when a session left a watched registry.
+	Disconnected EventType = "disconnected"
+)
+
+// Event is the body of a delivery.
+type Event struct {
+	ID   string    `json:"id"`
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+
+	ClientID    string `json:"clientId,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	RemoteAddr  string `json:"remoteAddr,omitempty"`
+
+	// Reason is why a handshake failed.
+	Reason wskeyauth.FailureReason `json:"reason,omitempty"`
+
+	// ConnectedAt is when a session that disconnected had connected.
+	ConnectedAt *time.Time `json:"connectedAt,omitempty"`
+}
+
+// Config configures a Dispatcher.
+type Config struct {
+	// URL is where events are POSTed.
+	URL string
+
+	// Secret signs the deliveries.
+	Secret []byte
+
+	// Events are the types of events to deliver. They default to all of
+	// them.
+	Events []EventType
+
+	// Client sends the deliveries. It defaults to a client with a 10 second
+	// timeout.
+	Client *http.Client
+
+	// MaxAttempts is how often a delivery is tried before the event is
+	// given up on. Backoff is how long to wait after the first attempt,
+	// doubling after each one after that, up to MaxBackoff.
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+
+	// QueueSize is how many events may wait to be delivered, and Workers how
+	// many are delivered at once.
+	QueueSize int
+	Workers   int
+
+	// Logger logs failed deliveries. It defaults to discarding them.
+	Logger *slog.Logger
+
+	// Clock stamps events and deliveries. It defaults to
+	// wskeyauth.SystemClock.
+	Clock wskeyauth.Clock
+}
+
+// Dispatcher delivers events to a webhook, in the background.
+type Dispatcher struct {
+	cfg    Config
+	events map[EventType]bool
+
+	queue chan Event
+	ctx   context.Context
+	stop  context.CancelFunc
+
+	mu      sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+}
+
+var _ wskeyauth.AuditSink = (*Dispatcher)(nil)
+
+// New starts a dispatcher delivering events as cfg says. Call Close to stop
+// it.
+func New(cfg Config) *Dispatcher {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultWorkers
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = wskeyauth.SystemClock
+	}
+
+	d := &Dispatcher{cfg: cfg, queue: make(chan Event, cfg.QueueSize)}
+	if len(cfg.Events) > 0 {
+		d.events = map[EventType]bool{}
+		for _, t := range cfg.Events {
+			d.events[t] = true
+		}
+	}
+	d.ctx, d.stop = context.WithCancel(context.Background())
+	for i := 0; i < cfg.Workers; i++ {
+		d.workers.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+// Record queues an Authenticated or Failed event for the handshake record
+// describes.
+func (d *Dispatcher) Record(record wskeyauth.AuditRecord) error {
+	e := Event{
+		Type:        Failed,
+		Time:        record.Time,
+		ClientID:    record.ClientID,
+		Fingerprint: record.Fingerprint,
+		RemoteAddr:  record.RemoteAddr,
+		Reason:      record.Reason,
+	}
+	if record.Authenticated {
+		e.Type = Authenticated
+	}
+	return d.Send(e)
+}
+
+// Watch queues a Disconnected event for every session that leaves registry,
+// until unwatch is called.
+func (d *Dispatcher) Watch(registry *wskeyauth.Registry) (unwatch func()) {
+	return registry.Subscribe(func(e wskeyauth.RegistryEvent) {
+		if e.Type != wskeyauth.SessionLeft {
+			return
+		}
+		connectedAt := e.Session.ConnectedAt
+		d.Send(Event{
+			Type:        Disconnected,
+			Time:        d.cfg.Clock.Now(),
+			ClientID:    e.Session.ClientID(),
+			Fingerprint: e.Session.Fingerprint(),
+			RemoteAddr:  e.Session.RemoteAddr,
+			ConnectedAt: &connectedAt,
+		})
+	})
+}
+
+// Listen queues an event for every lifecycle event published to bus, until
+// unsubscribe is called, in place of using the dispatcher as an AuditSink and
+// watching registries.
+func (d *Dispatcher) Listen(bus *wskeyauth.EventBus) (unsubscribe func()) {
+	return bus.Subscribe(wskeyauth.EventAll, func(e wskeyauth.Event) {
+		event := Event{
+			Time:        e.Time,
+			ClientID:    e.ClientID,
+			Fingerprint: e.Fingerprint,
+			RemoteAddr:  e.RemoteAddr,
+		}
+		switch e.Kind {
+		case wskeyauth.EventAuthenticated:
+			event.Type = Authenticated
+		case wskeyauth.EventFailed:
+			event.Type, event.Reason = Failed, e.Result.Reason
+		case wskeyauth.EventDisconnected:
+			connectedAt := e.Session.ConnectedAt
+			event.Type, event.ConnectedAt = Disconnected, &connectedAt
+		}
+		d.Send(event)
+	})
+}
+
+// Send queues e, giving it an ID if it has none, unless its type isn't among
+// Config.Events. It doesn't wait for e to be delivered, and fails with
+// ErrQueueFull rather than wait for room in the queue.
+func (d *Dispatcher) Send(e Event) error {
+	if d.events != nil && !d.events[e.Type] {
+		return nil
+	}
+	if e.ID == "" {
+		var id [16]byte
+		rand.Read(id[:])
+		e.ID = hex.EncodeToString(id[:])
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return errors.New("webhook: dispatcher is closed")
+	}
+	select {
+	case d.queue <- e:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops taking events, and waits for those queued to be delivered,
+// until ctx is done, when the deliveries in progress are abandoned.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		d.stop()
+		return nil
+	case <-ctx.Done():
+		d.stop()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.workers.Done()
+	for e := range d.queue {
+		d.deliver(e)
+	}
+}
+
+// deliver POSTs e until it is accepted, or attempts run out.
+func (d *Dispatcher) deliver(e Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		d.cfg.Logger.Error("webhook: failed to encode event", "id", e.ID, "error", err)
+		return
+	}
+
+	backoff := d.cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(body, e.ID)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == d.cfg.MaxAttempts {
+			d.cfg.Logger.Warn("webhook: failed to deliver event", "id", e.ID, "type", e.Type, "attempts", attempt, "error", err)
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-d.ctx.Done():
+			d.cfg.Logger.Warn("webhook: abandoned event", "id", e.ID, "type", e.Type, "attempts", attempt, "error", err)
+			return
+		}
+		backoff = min(2*backoff, d.cfg.MaxBackoff)
+	}
+}
+
+// post makes one attempt at delivering body, and reports whether it is worth
+// trying again if it failed.
+func (d *Dispatcher) post(body []byte, id string) (bool, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, d.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(d.cfg.Clock.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Webhook-Id", id)
+	req.Header.Set("Webhook-Timestamp", timestamp)
+	req.Header.Set("Webhook-Signature", "v1="+hex.EncodeToString(sign(d.cfg.Secret, timestamp, body)))
+
+	resp, err := d.cfg.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("webhook: %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook: %s", resp.Status)
+	}
+}
+
+func sign(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// Verify checks that r is a delivery signed with secret, made no longer than
+// tolerance ago, or DefaultTolerance if it isn't positive, and returns its
+// event.
+func Verify(secret []byte, r *http.Request, tolerance time.Duration) (*Event, error) {
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := r.Header.Get("Webhook-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return nil, ErrInvalidSignature
+	}
+
+	want := sign(secret, timestamp, body)
+	// several signatures may be given, such as while the secret is rotated
+	valid := false
+	for _, s := range strings.Fields(r.Header.Get("Webhook-Signature")) {
+		if hexSig, ok := strings.CutPrefix(s, "v1="); ok {
+			got, err := hex.DecodeString(hexSig)
+			valid = valid || err == nil && hmac.Equal(got, want)
+		}
+	}
+	if !valid {
+		return nil, ErrInvalidSignature
+	}
+
+	var e Event
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
@@ -0,0 +1,226 @@
+// Package wskeyauthpb holds the protobuf definitions of the handshake's
+// messages, in handshake.proto, for teams that generate their clients from
+// them rather than write JSON by hand, and an Encoding that sends them as
+// protobuf:
+//
+//	wskeyauth.Handshake(conn, wskeyauth.WithEncoding(wskeyauthpb.Encoding))
+//
+// The Go client uses it by setting its Encoding to wskeyauthpb.Encoding.
+// Every message is a Message, sent as a binary WebSocket message.
+//
+// The data of messages other than the handshake's, such as those of channels
+// and signaling, which have no definitions here, is carried as a
+// google.protobuf.Struct.
+//
+// As in proto3, fields with zero values aren't distinguished from missing
+// ones, so that, say, an empty list of hashes in the capabilities reads the
+// same as none at all.
+package wskeyauthpb
+
+//go:generate protoc -I.. --go_out=.. --go_opt=paths=source_relative ../wskeyauthpb/handshake.proto
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// Encoding encodes messages as Messages.
+var Encoding wskeyauth.Encoding = encoding{}
+
+type encoding struct{}
+
+func (encoding) Binary() bool { return true }
+
+func (encoding) Encode(v any) ([]byte, error) {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("wskeyauthpb: message is not an object")
+	}
+
+	msg := &Message{}
+	m := msg.ProtoReflect()
+	rest := make(map[string]any, len(obj))
+	for k, value := range obj {
+		if k != "data" {
+			rest[k] = value
+		}
+	}
+	if err := setFields(m, rest); err != nil {
+		return nil, err
+	}
+
+	switch data := obj["data"].(type) {
+	case nil:
+	case string:
+		msg.Data = &Message_Text{Text: data}
+	case map[string]any:
+		name := dataField(msg.Type, data)
+		if name == "" {
+			object, err := structpb.NewStruct(data)
+			if err != nil {
+				return nil, fmt.Errorf("wskeyauthpb: data of %s: %w", msg.Type, err)
+			}
+			msg.Data = &Message_Object{Object: object}
+			break
+		}
+		fd := m.Descriptor().Fields().ByName(name)
+		sub := m.NewField(fd).Message()
+		if err := setFields(sub, data); err != nil {
+			return nil, err
+		}
+		m.Set(fd, protoreflect.ValueOfMessage(sub))
+	default:
+		return nil, fmt.Errorf("wskeyauthpb: data of %s is neither a string nor an object", msg.Type)
+	}
+
+	return proto.Marshal(msg)
+}
+
+func (encoding) Decode(data []byte, v *any) error {
+	var msg Message
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	*v = fields(msg.ProtoReflect())
+	return nil
+}
+
+// dataField returns the field of Message that holds data, the data of a
+// message of typ, or "" for data that goes in object, as that of messages
+// other than the handshake's.
+func dataField(typ string, data map[string]any) protoreflect.Name {
+	switch typ {
+	case "CHALLENGE":
+		if _, ok := data["salt"]; ok {
+			return "password_challenge"
+		}
+		return "audience_challenge"
+	case "CHALLENGE_RESPONSE":
+		return "challenge_response"
+	case "RETRY_AFTER":
+		return "retry_after"
+	case "PAIRING_PENDING":
+		return "pairing_pending"
+	case "KEY_EXCHANGE":
+		return "key_exchange"
+	case "CLIENT_ERROR", "SERVER_ERROR", "UNSUPPORTED_ALGORITHM":
+		return "error"
+	}
+	return ""
+}
+
+// setFields sets the fields of m from obj, by their JSON names.
+func setFields(m protoreflect.Message, obj map[string]any) error {
+	desc := m.Descriptor()
+	for k, v := range obj {
+		if v == nil {
+			continue
+		}
+		fd := desc.Fields().ByJSONName(k)
+		if fd == nil || fd.ContainingOneof() != nil {
+			return fmt.Errorf("wskeyauthpb: %s has no field %q", desc.Name(), k)
+		}
+
+		switch {
+		case fd.IsList():
+			elems, ok := v.([]any)
+			if !ok {
+				return fmt.Errorf("wskeyauthpb: %s.%s is not a list", desc.Name(), k)
+			}
+			list := m.Mutable(fd).List()
+			for _, elem := range elems {
+				value, err := scalar(fd, elem)
+				if err != nil {
+					return err
+				}
+				list.Append(value)
+			}
+		case fd.Message() != nil:
+			child, ok := v.(map[string]any)
+			if !ok {
+				return fmt.Errorf("wskeyauthpb: %s.%s is not an object", desc.Name(), k)
+			}
+			sub := m.NewField(fd).Message()
+			if err := setFields(sub, child); err != nil {
+				return err
+			}
+			m.Set(fd, protoreflect.ValueOfMessage(sub))
+		default:
+			value, err := scalar(fd, v)
+			if err != nil {
+				return err
+			}
+			m.Set(fd, value)
+		}
+	}
+	return nil
+}
+
+// scalar converts v, a JSON value, to the value of fd.
+func scalar(fd protoreflect.FieldDescriptor, v any) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		if s, ok := v.(string); ok {
+			return protoreflect.ValueOfString(s), nil
+		}
+	case protoreflect.BoolKind:
+		if b, ok := v.(bool); ok {
+			return protoreflect.ValueOfBool(b), nil
+		}
+	case protoreflect.Int64Kind:
+		if n, ok := v.(float64); ok && n == math.Trunc(n) && math.Abs(n) <= math.MaxInt64 {
+			return protoreflect.ValueOfInt64(int64(n)), nil
+		}
+	case protoreflect.Int32Kind:
+		if n, ok := v.(float64); ok && n == math.Trunc(n) && n >= math.MinInt32 && n <= math.MaxInt32 {
+			return protoreflect.ValueOfInt32(int32(n)), nil
+		}
+	}
+	return protoreflect.Value{}, fmt.Errorf("wskeyauthpb: %v is not a valid %s", v, fd.FullName())
+}
+
+// fields returns the fields of m that are set, as a JSON object. The member
+// of the data oneof that is set goes by "data".
+func fields(m protoreflect.Message) map[string]any {
+	obj := map[string]any{}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		name := fd.JSONName()
+		if fd.ContainingOneof() != nil {
+			name = "data"
+		}
+
+		if fd.IsList() {
+			list := v.List()
+			elems := make([]any, list.Len())
+			for i := range elems {
+				elems[i] = generic(fd, list.Get(i))
+			}
+			obj[name] = elems
+		} else {
+			obj[name] = generic(fd, v)
+		}
+		return true
+	})
+	return obj
+}
+
+// generic converts v, a value of fd, to a JSON value.
+func generic(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch fd.Kind() {
+	case protoreflect.MessageKind:
+		if object, ok := v.Message().Interface().(*structpb.Struct); ok {
+			return object.AsMap()
+		}
+		return fields(v.Message())
+	case protoreflect.Int64Kind, protoreflect.Int32Kind:
+		return float64(v.Int())
+	}
+	return v.Interface()
+}
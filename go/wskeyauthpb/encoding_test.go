@@ -0,0 +1,36 @@
+package wskeyauthpb_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthpb"
+)
+
+func TestEncodingRoundTrip(t *testing.T) {
+	for _, msg := range []string{
+		`{"type":"CLIENT_ID","data":"WebCrypto-raw.EC.P-256$BAE"}`,
+		`{"type":"CHALLENGE","data":{"challenge":"abc","audience":"wss://example.com"}}`,
+		`{"type":"CLIENT_ERROR","data":{"code":"UNKNOWN_KEY","message":"Client key is not known"}}`,
+		`{"type":"RETRY_AFTER","data":{"message":"Too many failed handshakes","retryAfter":30}}`,
+		`{"type":"JOIN","data":{"channel":"x"}}`,
+		`{"type":"OFFER","data":{"to":"did:key:z6Mk","sdp":{"type":"offer","lines":[1,2]}}}`,
+	} {
+		var want any
+		if err := json.Unmarshal([]byte(msg), &want); err != nil {
+			t.Fatal(err)
+		}
+		b, err := wskeyauthpb.Encoding.Encode(want)
+		if err != nil {
+			t.Fatalf("Encode(%s) = %v", msg, err)
+		}
+		var got any
+		if err := wskeyauthpb.Encoding.Decode(b, &got); err != nil {
+			t.Fatalf("Decode(%s) = %v", msg, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s came back as %v", msg, got)
+		}
+	}
+}
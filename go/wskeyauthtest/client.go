@@ -0,0 +1,198 @@
+// Package wskeyauthtest provides utilities for testing code that calls
+// wskeyauth.Handshake, without standing up real WebSocket servers: an
+// in-memory connection pair, key helpers, and a fake client that can be
+// scripted to play valid and invalid handshakes.
+package wskeyauthtest
+
+import (
+	"encoding/json"
+	"fmt"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// Client plays the client side of a handshake. With only Key set, it plays a
+// valid handshake; the other fields script deviations from it.
+type Client struct {
+	Key *Key
+
+	// ClientID, if set, is sent instead of Key's client ID.
+	ClientID string
+
+	// FirstMessage, if set, is sent instead of the CLIENT_ID message, after
+	// which the client only waits for the server's reply.
+	FirstMessage any
+
+	// Hash, if set, is reported instead of SHA-256 in the challenge response.
+	Hash string
+
+	// SignWith, if set, signs the challenge instead of Key, as an attacker
+	// without the client's key would.
+	SignWith *Key
+
+	// Audience, if set, is the audience the client signs for, as it would if
+	// it were connected to that server. Otherwise the client signs for
+	// whatever audience the server asks for, if any.
+	Audience string
+
+	// Passkey, if set, has the client play a WebAuthn credential rather than
+	// a WebCrypto key.
+	Passkey *Passkey
+
+	// TamperSignature, if set, is called with the base64 signature before it is
+	// sent, and returns what to send instead.
+	TamperSignature func(signature string) string
+}
+
+// Result is everything the server sent to the client, in order.
+type Result struct {
+	Messages []wskeyauth.TypeData
+}
+
+// Last returns the type of the last message the server sent, which decides
+// the outcome of the handshake.
+func (r Result) Last() string {
+	if len(r.Messages) == 0 {
+		return ""
+	}
+	return r.Messages[len(r.Messages)-1].Type
+}
+
+// Authenticated reports whether the server accepted the client.
+func (r Result) Authenticated() bool {
+	return r.Last() == "SIGNATURE_MATCHES"
+}
+
+// Run plays the handshake over conn, until the server has had its final
+// say.
+func (c *Client) Run(conn wskeyauth.Conn) (Result, error) {
+	var result Result
+
+	read := func() (wskeyauth.TypeData, error) {
+		var td wskeyauth.TypeData
+		err := conn.ReadJSON(&td)
+		if err == nil {
+			result.Messages = append(result.Messages, td)
+		}
+		return td, err
+	}
+
+	if c.FirstMessage != nil {
+		if err := conn.WriteJSON(c.FirstMessage); err != nil {
+			return result, err
+		}
+		_, err := read()
+		return result, err
+	}
+
+	clientID := c.ClientID
+	if clientID == "" && c.Passkey != nil {
+		clientID = c.Key.WebAuthnClientID()
+	} else if clientID == "" {
+		clientID = c.Key.ClientID()
+	}
+	if err := conn.WriteJSON(map[string]any{"type": "CLIENT_ID", "data": clientID}); err != nil {
+		return result, err
+	}
+
+	td, err := read()
+	if err != nil || td.Type != "CHALLENGE" {
+		return result, err
+	}
+
+	challenge, audience, err := parseChallenge(td.Data)
+	if err != nil {
+		return result, err
+	}
+	if c.Audience != "" {
+		audience = c.Audience
+	}
+	audience = wskeyauth.SignedAudience(audience, clientID)
+
+	signer := c.Key
+	if c.SignWith != nil {
+		signer = c.SignWith
+	}
+	data := map[string]string{}
+	if c.Passkey != nil {
+		data, err = signer.SignAssertion(challenge, audience, *c.Passkey)
+	} else {
+		data["signature"], err = signer.SignChallengeFor(challenge, audience)
+	}
+	if err != nil {
+		return result, err
+	}
+	if c.TamperSignature != nil {
+		data["signature"] = c.TamperSignature(data["signature"])
+	}
+
+	data["hash"] = c.Hash
+	if c.Hash == "" {
+		data["hash"] = "SHA-256"
+	}
+
+	err = conn.WriteJSON(map[string]any{"type": "CHALLENGE_RESPONSE", "data": data})
+	if err != nil {
+		return result, err
+	}
+
+	_, err = read()
+	return result, err
+}
+
+// Outcome is the result of a handshake run by Handshake, from both sides.
+type Outcome struct {
+	// Authenticated, ClientID and Err are what wskeyauth.Handshake returned.
+	Authenticated bool
+	ClientID      string
+	Err           error
+
+	// Client is what the client saw, and ClientErr the error it ran into, if
+	// any.
+	Client    Result
+	ClientErr error
+}
+
+// Handshake runs wskeyauth.Handshake with opts against client over a Pipe, and
+// reports how it went.
+func Handshake(client *Client, opts ...wskeyauth.Option) Outcome {
+	server, conn := Pipe()
+
+	type clientResult struct {
+		result Result
+		err    error
+	}
+	done := make(chan clientResult, 1)
+	go func() {
+		result, err := client.Run(conn)
+		done <- clientResult{result, err}
+	}()
+
+	var o Outcome
+	o.Authenticated, o.ClientID, o.Err = wskeyauth.Handshake(server, opts...)
+
+	// the server is done talking, so anything the client is still waiting on
+	// will never come
+	server.Close()
+
+	c := <-done
+	o.Client, o.ClientErr = c.result, c.err
+	return o
+}
+
+// parseChallenge parses the data of a CHALLENGE message, which is either the
+// challenge, or an object with the challenge and the server's audience.
+func parseChallenge(data json.RawMessage) (challenge, audience string, err error) {
+	if err := json.Unmarshal(data, &challenge); err == nil {
+		return challenge, "", nil
+	}
+
+	var structured struct {
+		Challenge string `json:"challenge"`
+		Audience  string `json:"audience"`
+	}
+	if err := json.Unmarshal(data, &structured); err != nil {
+		return "", "", fmt.Errorf("wskeyauthtest: failed to parse CHALLENGE: %w", err)
+	}
+	return structured.Challenge, structured.Audience, nil
+}
@@ -0,0 +1,100 @@
+package wskeyauthtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// Clock is a wskeyauth.Clock that only moves when told to, for testing
+// timeouts and expiry without waiting for them:
+//
+//	clock := wskeyauthtest.NewClock(time.Unix(0, 0))
+//	go wskeyauth.Handshake(server, wskeyauth.WithClock(clock), wskeyauth.WithTimeout(time.Second))
+//	clock.Advance(2 * time.Second) // the handshake times out
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*timer
+}
+
+var _ wskeyauth.Clock = (*Clock)(nil)
+
+// NewClock creates a clock reading now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) AfterFunc(d time.Duration, f func()) wskeyauth.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &timer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock d forward, running the functions of the timers
+// that came due, in the order they did, each on a goroutine of its own, as
+// time.AfterFunc does.
+func (c *Clock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to now, running the functions of the timers that came
+// due, as Advance does. Setting the clock back runs none.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	c.now = now
+	var due, pending []*timer
+	for _, t := range c.timers {
+		if t.at.After(now) {
+			pending = append(pending, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+	for _, t := range due {
+		go t.f()
+	}
+}
+
+// Timers returns how many timers are waiting to come due, for tests to wait
+// until the code under test set the one they are about to advance past.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+type timer struct {
+	clock *Clock
+	at    time.Time
+	f     func()
+}
+
+func (t *timer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
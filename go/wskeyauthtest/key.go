@@ -0,0 +1,118 @@
+package wskeyauthtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/castcam-live/ws-key-auth/go/internal/base58"
+)
+
+// Key is a client key pair.
+type Key struct {
+	Private *ecdsa.PrivateKey
+
+	// deterministic is set for keys from KeyFromSeed.
+	deterministic bool
+}
+
+// GenerateKey generates a new P-256 key pair.
+func GenerateKey() (*Key, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{Private: priv}, nil
+}
+
+// MustGenerateKey is like GenerateKey, but panics on failure.
+func MustGenerateKey() *Key {
+	k, err := GenerateKey()
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+// ClientID returns the client ID of the key, in the format a browser would
+// produce it.
+func (k *Key) ClientID() string {
+	return "WebCrypto-raw.EC.P-256$" + base64.StdEncoding.EncodeToString(k.rawPublicKey())
+}
+
+// rawPublicKey returns the public key as an uncompressed point.
+func (k *Key) rawPublicKey() []byte {
+	pub := k.Private.PublicKey
+
+	raw := make([]byte, 65)
+	raw[0] = 4
+	pub.X.FillBytes(raw[1:33])
+	pub.Y.FillBytes(raw[33:])
+	return raw
+}
+
+// Sign signs the SHA-256 hash of payload, returning the signature as
+// WebCrypto does: r and s, concatenated. Keys from KeyFromSeed return the
+// same signature for the same payload every time.
+func (k *Key) Sign(payload []byte) ([]byte, error) {
+	hash := sha256.Sum256(payload)
+	var r, s *big.Int
+	if k.deterministic {
+		r, s = signDeterministic(k.Private, hash[:])
+	} else {
+		var err error
+		if r, s, err = ecdsa.Sign(rand.Reader, k.Private, hash[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return sig, nil
+}
+
+// SignChallenge signs the base64 challenge sent in a CHALLENGE message, and
+// returns the base64 signature to send back in CHALLENGE_RESPONSE.
+func (k *Key) SignChallenge(challenge string) (string, error) {
+	return k.SignChallengeFor(challenge, "")
+}
+
+// SignChallengeFor is SignChallenge for servers that use
+// wskeyauth.WithAudience, signing the challenge for audience.
+func (k *Key) SignChallengeFor(challenge, audience string) (string, error) {
+	payload, err := base64.StdEncoding.DecodeString(challenge)
+	if err != nil {
+		return "", err
+	}
+
+	sig, err := k.Sign(append(payload, audience...))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// DIDKey returns the key as a did:key, which servers accept as a client ID
+// too.
+func (k *Key) DIDKey() string {
+	pub := k.Private.PublicKey
+	// multicodec p256-pub, followed by the compressed point
+	b := append([]byte{0x80, 0x24}, elliptic.MarshalCompressed(pub.Curve, pub.X, pub.Y)...)
+	return "did:key:z" + base58.Encode(b)
+}
+
+// AuthorizedKey returns the key in OpenSSH format, as a line of an
+// authorized_keys file, which servers accept as a client ID too.
+func (k *Key) AuthorizedKey() string {
+	var blob []byte
+	for _, s := range [][]byte{[]byte("ecdsa-sha2-nistp256"), []byte("nistp256"), k.rawPublicKey()} {
+		blob = binary.BigEndian.AppendUint32(blob, uint32(len(s)))
+		blob = append(blob, s...)
+	}
+	return "ecdsa-sha2-nistp256 " + base64.StdEncoding.EncodeToString(blob)
+}
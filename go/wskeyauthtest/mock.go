@@ -0,0 +1,208 @@
+package wskeyauthtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// MockConn is a wskeyauth.Conn for unit tests that plays a script of client
+// messages and read failures, and checks what the server sends against
+// expectations. Unlike Client, it runs on the calling goroutine, so a
+// handshake over a MockConn is fully deterministic.
+//
+//	conn := wskeyauthtest.NewMockConn().
+//		Send(map[string]any{"type": "CLIENT_ID", "data": key.ClientID()}).
+//		SignChallenges(key).
+//		ExpectMessage("CHALLENGE").
+//		ExpectMessage("SIGNATURE_MATCHES")
+//	wskeyauth.Handshake(conn)
+//	conn.AssertExpectations(t)
+//
+// Once the script runs out, reads fail with io.EOF.
+type MockConn struct {
+	mu sync.Mutex
+
+	script    []scripted
+	responses map[string]func(wskeyauth.TypeData) any
+	writeErr  error
+
+	expected []string
+	written  []wskeyauth.TypeData
+}
+
+// scripted is either a message to read, or an error to fail the read with.
+type scripted struct {
+	msg []byte
+	err error
+}
+
+func NewMockConn() *MockConn {
+	return &MockConn{responses: map[string]func(wskeyauth.TypeData) any{}}
+}
+
+// Send queues a message for the server to read. It is encoded as JSON; a
+// string or json.RawMessage is sent as is.
+func (m *MockConn) Send(msg any) *MockConn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.script = append(m.script, scripted{msg: encode(msg)})
+	return m
+}
+
+// FailRead makes the server's next read, after the messages queued so far,
+// fail with err.
+func (m *MockConn) FailRead(err error) *MockConn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.script = append(m.script, scripted{err: err})
+	return m
+}
+
+// FailWrites makes every write from the server fail with err.
+func (m *MockConn) FailWrites(err error) *MockConn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.writeErr = err
+	return m
+}
+
+// OnMessage calls respond whenever the server sends a message of the given
+// type, and queues whatever it returns (unless nil) as if passed to Send.
+func (m *MockConn) OnMessage(typ string, respond func(wskeyauth.TypeData) any) *MockConn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses[typ] = respond
+	return m
+}
+
+// SignChallenges answers every CHALLENGE with a valid CHALLENGE_RESPONSE
+// signed by key, for the audience the server asks for, if any.
+func (m *MockConn) SignChallenges(key *Key) *MockConn {
+	return m.OnMessage("CHALLENGE", func(td wskeyauth.TypeData) any {
+		challenge, audience, err := parseChallenge(td.Data)
+		if err != nil {
+			return nil
+		}
+		signature, err := key.SignChallengeFor(challenge, audience)
+		if err != nil {
+			return nil
+		}
+		return map[string]any{
+			"type": "CHALLENGE_RESPONSE",
+			"data": map[string]string{"signature": signature, "hash": "SHA-256"},
+		}
+	})
+}
+
+// ExpectMessage expects the server's next message, after those already
+// expected, to be of the given type.
+func (m *MockConn) ExpectMessage(typ string) *MockConn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expected = append(m.expected, typ)
+	return m
+}
+
+func (m *MockConn) ReadJSON(v any) error {
+	m.mu.Lock()
+	if len(m.script) == 0 {
+		m.mu.Unlock()
+		return io.EOF
+	}
+	next := m.script[0]
+	m.script = m.script[1:]
+	m.mu.Unlock()
+
+	if next.err != nil {
+		return next.err
+	}
+	return json.Unmarshal(next.msg, v)
+}
+
+func (m *MockConn) WriteJSON(v any) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var td wskeyauth.TypeData
+	if err := json.Unmarshal(msg, &td); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if m.writeErr != nil {
+		defer m.mu.Unlock()
+		return m.writeErr
+	}
+	m.written = append(m.written, td)
+	respond := m.responses[td.Type]
+	m.mu.Unlock()
+
+	if respond != nil {
+		if reply := respond(td); reply != nil {
+			m.Send(reply)
+		}
+	}
+	return nil
+}
+
+// Written returns every message the server sent so far.
+func (m *MockConn) Written() []wskeyauth.TypeData {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]wskeyauth.TypeData(nil), m.written...)
+}
+
+// Verify returns an error describing how the messages the server sent
+// differ from the expected ones, if they do.
+func (m *MockConn) Verify() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	got := make([]string, len(m.written))
+	for i, td := range m.written {
+		got[i] = td.Type
+	}
+
+	if strings.Join(got, ",") != strings.Join(m.expected, ",") {
+		return fmt.Errorf("wskeyauthtest: expected the server to send [%s], but it sent [%s]",
+			strings.Join(m.expected, " "), strings.Join(got, " "))
+	}
+	return nil
+}
+
+// TestingT is the subset of testing.TB that AssertExpectations needs.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// AssertExpectations fails t if Verify reports a difference.
+func (m *MockConn) AssertExpectations(t TestingT) {
+	t.Helper()
+	if err := m.Verify(); err != nil {
+		t.Errorf("%v", err)
+	}
+}
+
+func encode(msg any) []byte {
+	switch msg := msg.(type) {
+	case string:
+		return []byte(msg)
+	case json.RawMessage:
+		return msg
+	case []byte:
+		return msg
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		panic("wskeyauthtest: message cannot be encoded as JSON: " + err.Error())
+	}
+	return b
+}
@@ -0,0 +1,100 @@
+package wskeyauthtest
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClosed is returned by a Conn once either end of the pipe was closed.
+var ErrClosed = errors.New("wskeyauthtest: connection closed")
+
+// ErrReadLimit is returned by Conn.ReadJSON for a message larger than the
+// limit set with SetReadLimit. Like a WebSocket connection, the pipe is closed
+// when that happens.
+var ErrReadLimit = errors.New("wskeyauthtest: read limit exceeded")
+
+// Conn is one end of an in-memory connection created by Pipe. Messages
+// written to one end are read from the other, encoded as JSON in between so
+// that their wire form is exercised as it would be on a real WebSocket.
+type Conn struct {
+	in  <-chan []byte
+	out chan<- []byte
+
+	readLimit atomic.Int64
+
+	pipe *pipe
+}
+
+type pipe struct {
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Pipe creates a connected pair of connections. Writes are buffered, so a
+// side may write a few messages before the other reads them, as the
+// handshake does.
+func Pipe() (server, client *Conn) {
+	toServer := make(chan []byte, 16)
+	toClient := make(chan []byte, 16)
+	p := &pipe{closed: make(chan struct{})}
+
+	return &Conn{in: toServer, out: toClient, pipe: p},
+		&Conn{in: toClient, out: toServer, pipe: p}
+}
+
+func (c *Conn) ReadJSON(v any) error {
+	select {
+	case msg := <-c.in:
+		return c.unmarshal(msg, v)
+	case <-c.pipe.closed:
+		// deliver whatever was written before the close
+		select {
+		case msg := <-c.in:
+			return c.unmarshal(msg, v)
+		default:
+			return ErrClosed
+		}
+	}
+}
+
+func (c *Conn) unmarshal(msg []byte, v any) error {
+	if limit := c.readLimit.Load(); limit > 0 && int64(len(msg)) > limit {
+		c.Close()
+		return ErrReadLimit
+	}
+	return json.Unmarshal(msg, v)
+}
+
+// SetReadLimit sets the largest message, in bytes, this end will read. 0, the
+// default, means no limit.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit.Store(limit)
+}
+
+func (c *Conn) WriteJSON(v any) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-c.pipe.closed:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case c.out <- msg:
+		return nil
+	case <-c.pipe.closed:
+		return ErrClosed
+	}
+}
+
+// Close closes both ends of the pipe.
+func (c *Conn) Close() error {
+	c.pipe.closeOnce.Do(func() { close(c.pipe.closed) })
+	return nil
+}
@@ -0,0 +1,82 @@
+package wskeyauthtest
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+)
+
+// Random is a source of random numbers derived from a seed, the same ones
+// every time, for wskeyauth.WithRandom and the Random fields of the types
+// that draw them. It can be made to fail, to cover the paths taken when the
+// system's source does:
+//
+//	random := wskeyauthtest.NewRandom("seed")
+//	random.ShortReads(1)
+//	wskeyauth.HandshakeResult(server, wskeyauth.WithRandom(random)) // fails with ErrFailedToReadRandomNumbers
+//
+// Its numbers are anything but secret; it is for tests only.
+type Random struct {
+	mu      sync.Mutex
+	seed    string
+	counter uint32
+	buf     []byte
+
+	short int
+	err   error
+	reads int
+}
+
+// NewRandom creates a source whose numbers are derived from seed.
+func NewRandom(seed string) *Random {
+	return &Random{seed: seed}
+}
+
+// ShortReads makes the next n reads fill one byte less than they are asked
+// for, without an error, as a broken source might.
+func (r *Random) ShortReads(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.short = n
+}
+
+// Fail makes every read fail with err, until Fail is called with nil.
+func (r *Random) Fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+// Reads returns how many times the source was read.
+func (r *Random) Reads() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reads
+}
+
+func (r *Random) Read(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reads++
+	if r.err != nil {
+		return 0, r.err
+	}
+	n := len(b)
+	if r.short > 0 && n > 0 {
+		r.short--
+		n--
+	}
+
+	for len(r.buf) < n {
+		h := sha256.New()
+		h.Write([]byte("wskeyauthtest random\x00"))
+		h.Write([]byte(r.seed))
+		h.Write(binary.BigEndian.AppendUint32(nil, r.counter))
+		r.counter++
+		r.buf = h.Sum(r.buf)
+	}
+	copy(b, r.buf[:n])
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,305 @@
+package wskeyauthtest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+)
+
+// RecordingVersion is the version of the capture format written by
+// Recording.WriteTo. ReadRecording refuses recordings of later versions.
+const RecordingVersion = 1
+
+// Recording is a handshake captured by Record: every message exchanged, byte
+// for byte, along with the random numbers the server drew and the times it
+// read, which is what Replay needs to re-run the handshake exactly as it went.
+// Recordings of handshakes with older clients, checked in as golden files,
+// catch changes that break wire compatibility with them:
+//
+//	f, _ := os.Open("testdata/v1.2-client.json")
+//	rec, _ := wskeyauthtest.ReadRecording(f)
+//	if err := wskeyauthtest.Replay(rec, opts...); err != nil {
+//		t.Fatal(err)
+//	}
+//
+// Only the random numbers drawn through wskeyauth.WithRandom, and the times
+// told by wskeyauth.WithClock, are captured. Handshakes whose messages carry
+// anything else that changes from run to run can't be replayed byte for
+// byte. That covers macaroons, which draw their IDs from Macaroons.Random
+// and their expiry from Macaroons.Clock. It also covers tickets minted
+// during the handshake, from the Random and Clock of Tickets, access tokens
+// from a token exchange, and ECDSA signatures by a server key, which draw
+// from crypto/rand. Record golden files without those options, or give
+// Macaroons and Tickets a Random and Clock that start from the same seed and
+// time when recording and when replaying, such as those of NewRandom and
+// NewClock.
+type Recording struct {
+	Version int `json:"version"`
+
+	Messages []RecordedMessage `json:"messages"`
+	Random   []byte            `json:"random,omitempty"`
+	Times    []time.Time       `json:"times,omitempty"`
+
+	// Authenticated and ClientID are what the handshake returned.
+	Authenticated bool   `json:"authenticated"`
+	ClientID      string `json:"clientId,omitempty"`
+}
+
+// RecordedMessage is a message of a Recording. Message holds its bytes as a
+// string, so that they survive re-encoding of the recording unchanged.
+type RecordedMessage struct {
+	FromClient bool   `json:"fromClient"`
+	Message    string `json:"message"`
+}
+
+// ReadRecording reads a recording written by Recording.WriteTo.
+func ReadRecording(r io.Reader) (*Recording, error) {
+	var rec Recording
+	if err := json.NewDecoder(r).Decode(&rec); err != nil {
+		return nil, fmt.Errorf("wskeyauthtest: reading recording: %w", err)
+	}
+	if rec.Version < 1 || rec.Version > RecordingVersion {
+		return nil, fmt.Errorf("wskeyauthtest: unsupported recording version %d", rec.Version)
+	}
+	return &rec, nil
+}
+
+// WriteTo writes the recording to w as indented JSON, for it to be checked in
+// and read back with ReadRecording.
+func (rec *Recording) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(b, '\n'))
+	return int64(n), err
+}
+
+// Record runs the server side of a handshake over conn with opts, as
+// wskeyauth.HandshakeResult does, and captures it. The handshake draws its
+// random numbers from crypto/rand and tells the time by the system's clock,
+// whatever opts say.
+func Record(conn wskeyauth.Conn, opts ...wskeyauth.Option) (*Recording, *wskeyauth.Result, error) {
+	rec := &Recording{Version: RecordingVersion}
+	random := &recordingRandom{rec: rec}
+	clock := &recordingClock{rec: rec}
+	wrapped := &recordingConn{conn: conn, rec: rec, mu: &clock.mu}
+	random.mu = &clock.mu
+
+	opts = append(opts[:len(opts):len(opts)], wskeyauth.WithRandom(random), wskeyauth.WithClock(clock))
+	result, err := wskeyauth.HandshakeResult(wrapped, opts...)
+
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	rec.Authenticated = result.Authenticated
+	rec.ClientID = result.ClientID
+	return rec, result, err
+}
+
+// Replay re-runs the handshake captured in rec against the server code as it
+// is now, with opts, which must be those it was recorded with. It plays the
+// client's messages as recorded, and returns a *ReplayError for the first
+// message the server sent that differs from the recording, or the first
+// difference in the outcome of the handshake.
+func Replay(rec *Recording, opts ...wskeyauth.Option) error {
+	conn := &replayConn{rec: rec}
+	opts = append(opts[:len(opts):len(opts)],
+		wskeyauth.WithRandom(&replayRandom{r: bytes.NewReader(rec.Random)}),
+		wskeyauth.WithClock(&replayClock{times: rec.Times}))
+
+	result, _ := wskeyauth.HandshakeResult(conn, opts...)
+	if conn.diff != nil {
+		return conn.diff
+	}
+	for i := conn.next; i < len(rec.Messages); i++ {
+		if !rec.Messages[i].FromClient {
+			return &ReplayError{Index: i, Want: rec.Messages[i].Message}
+		}
+	}
+	if result.Authenticated != rec.Authenticated || result.ClientID != rec.ClientID {
+		return &ReplayError{
+			Index: len(rec.Messages),
+			Want:  fmt.Sprintf("authenticated=%t clientId=%q", rec.Authenticated, rec.ClientID),
+			Got:   fmt.Sprintf("authenticated=%t clientId=%q", result.Authenticated, result.ClientID),
+		}
+	}
+	return nil
+}
+
+// ReplayError is where a replayed handshake parted from its recording.
+type ReplayError struct {
+	// Index is the index of the recorded message the server's differs from,
+	// or the number of messages if it is the outcome that differs.
+	Index int
+
+	// Want is what was recorded, and Got what the server did instead; Got is
+	// empty if the server sent nothing.
+	Want string
+	Got  string
+}
+
+func (e *ReplayError) Error() string {
+	if e.Got == "" {
+		return fmt.Sprintf("wskeyauthtest: replay: message %d: server sent nothing, want %s", e.Index, e.Want)
+	}
+	return fmt.Sprintf("wskeyauthtest: replay: message %d: server sent %s, want %s", e.Index, e.Got, e.Want)
+}
+
+// recordingConn passes messages through as raw JSON, as the wskeyauth
+// transcript recorder does, so that the recording holds the bytes that went
+// over the wire.
+type recordingConn struct {
+	conn wskeyauth.Conn
+	rec  *Recording
+	mu   *sync.Mutex
+}
+
+func (c *recordingConn) ReadJSON(v any) error {
+	var msg json.RawMessage
+	if err := c.conn.ReadJSON(&msg); err != nil {
+		return err
+	}
+	c.record(true, msg)
+	return json.Unmarshal(msg, v)
+}
+
+func (c *recordingConn) WriteJSON(v any) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.record(false, msg)
+	return c.conn.WriteJSON(json.RawMessage(msg))
+}
+
+func (c *recordingConn) record(fromClient bool, msg []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rec.Messages = append(c.rec.Messages, RecordedMessage{FromClient: fromClient, Message: string(msg)})
+}
+
+type recordingRandom struct {
+	rec *Recording
+	mu  *sync.Mutex
+}
+
+func (r *recordingRandom) Read(b []byte) (int, error) {
+	n, err := rand.Read(b)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rec.Random = append(r.rec.Random, b[:n]...)
+	return n, err
+}
+
+type recordingClock struct {
+	rec *Recording
+	mu  sync.Mutex
+}
+
+func (c *recordingClock) Now() time.Time {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rec.Times = append(c.rec.Times, now)
+	return now
+}
+
+func (c *recordingClock) AfterFunc(d time.Duration, f func()) wskeyauth.Timer {
+	return wskeyauth.SystemClock.AfterFunc(d, f)
+}
+
+// replayConn plays the client's recorded messages, and checks the server's
+// against the recording, on the goroutine running the handshake.
+type replayConn struct {
+	rec  *Recording
+	next int
+	diff *ReplayError
+}
+
+func (c *replayConn) ReadJSON(v any) error {
+	if c.diff != nil || c.next >= len(c.rec.Messages) {
+		return io.EOF
+	}
+	msg := c.rec.Messages[c.next]
+	if !msg.FromClient {
+		// the server reads where it was recorded writing
+		c.diff = &ReplayError{Index: c.next, Want: msg.Message}
+		return io.EOF
+	}
+	c.next++
+	return json.Unmarshal([]byte(msg.Message), v)
+}
+
+func (c *replayConn) WriteJSON(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if c.diff != nil {
+		return io.ErrClosedPipe
+	}
+	if c.next >= len(c.rec.Messages) {
+		c.diff = &ReplayError{Index: c.next, Want: "nothing", Got: string(b)}
+		return io.ErrClosedPipe
+	}
+	msg := c.rec.Messages[c.next]
+	if msg.FromClient || msg.Message != string(b) {
+		want := msg.Message
+		if msg.FromClient {
+			want = "to read " + want
+		}
+		c.diff = &ReplayError{Index: c.next, Want: want, Got: string(b)}
+		return io.ErrClosedPipe
+	}
+	c.next++
+	return nil
+}
+
+// errRandomExhausted is returned once a replayed handshake draws more random
+// numbers than its recording holds.
+var errRandomExhausted = errors.New("wskeyauthtest: replay: recording ran out of random numbers")
+
+type replayRandom struct {
+	r *bytes.Reader
+}
+
+func (r *replayRandom) Read(b []byte) (int, error) {
+	if r.r.Len() < len(b) {
+		return 0, errRandomExhausted
+	}
+	return r.r.Read(b)
+}
+
+// replayClock reads the recorded times in turn, and then the last one again.
+// Its timers never fire: the replay is over as soon as the messages run out.
+type replayClock struct {
+	mu    sync.Mutex
+	times []time.Time
+	next  int
+}
+
+func (c *replayClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.times) == 0 {
+		return time.Time{}
+	}
+	now := c.times[min(c.next, len(c.times)-1)]
+	c.next++
+	return now
+}
+
+func (c *replayClock) AfterFunc(time.Duration, func()) wskeyauth.Timer {
+	return stoppedTimer{}
+}
+
+type stoppedTimer struct{}
+
+func (stoppedTimer) Stop() bool { return true }
@@ -0,0 +1,69 @@
+package wskeyauthtest_test
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+var update = flag.Bool("update", false, "record the golden handshakes again")
+
+// goldenOptions are the options the golden handshake is recorded and
+// replayed with. Its macaroon is minted from a seeded source and a fixed
+// clock, so that it comes out the same on every run.
+func goldenOptions() []wskeyauth.Option {
+	return []wskeyauth.Option{
+		wskeyauth.WithAudience("wss://example.com"),
+		wskeyauth.WithMacaroons(&wskeyauth.Macaroons{
+			Secret: []byte("golden macaroon secret, 32 bytes"),
+			Random: wskeyauthtest.NewRandom("macaroons"),
+			Clock:  wskeyauthtest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+		}),
+	}
+}
+
+func TestReplayGolden(t *testing.T) {
+	const golden = "testdata/handshake.json"
+
+	if *update {
+		server, conn := wskeyauthtest.Pipe()
+		go (&wskeyauthtest.Client{Key: wskeyauthtest.KeyFromSeed("golden")}).Run(conn)
+		rec, _, err := wskeyauthtest.Record(server, goldenOptions()...)
+		if err != nil || !rec.Authenticated {
+			t.Fatalf("Record() = %+v, %v", rec, err)
+		}
+		f, err := os.Create(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := rec.WriteTo(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rec, err := wskeyauthtest.ReadRecording(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wskeyauthtest.Replay(rec, goldenOptions()...); err != nil {
+		t.Fatalf("Replay() = %v; if the change to the wire format is intended, run go test -update", err)
+	}
+
+	// a server that asks for another audience sends another CHALLENGE
+	var replayErr *wskeyauthtest.ReplayError
+	if err := wskeyauthtest.Replay(rec, wskeyauth.WithAudience("wss://example.org")); !errors.As(err, &replayErr) {
+		t.Fatalf("Replay() with another audience = %v, want a *ReplayError", err)
+	}
+}
@@ -0,0 +1,113 @@
+package wskeyauthtest
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+)
+
+// KeyFromSeed derives a P-256 key pair from seed, the same one every time, so
+// that golden files and vectors shared with other implementations stay
+// stable. Keys derived from seeds sign deterministically, as RFC 6979 has
+// it, so their signatures are stable too.
+//
+// Anyone who knows the seed knows the private key; these are for tests only.
+func KeyFromSeed(seed string) *Key {
+	curve := elliptic.P256()
+	n := curve.Params().N
+
+	for i := uint32(0); ; i++ {
+		h := sha256.New()
+		h.Write([]byte("wskeyauthtest key\x00"))
+		h.Write([]byte(seed))
+		h.Write(binary.BigEndian.AppendUint32(nil, i))
+
+		d := new(big.Int).SetBytes(h.Sum(nil))
+		if d.Sign() == 0 || d.Cmp(n) >= 0 {
+			continue
+		}
+
+		priv := &ecdsa.PrivateKey{D: d}
+		priv.Curve = curve
+		priv.X, priv.Y = scalarBaseMult(d.FillBytes(make([]byte, 32)))
+		return &Key{Private: priv, deterministic: true}
+	}
+}
+
+// ChallengeFromSeed derives a base64 challenge from seed, of the length
+// servers send, the same one every time.
+func ChallengeFromSeed(seed string) string {
+	challenge := make([]byte, 0, 128)
+	for i := uint32(0); len(challenge) < 128; i++ {
+		h := sha256.New()
+		h.Write([]byte("wskeyauthtest challenge\x00"))
+		h.Write([]byte(seed))
+		h.Write(binary.BigEndian.AppendUint32(nil, i))
+		challenge = h.Sum(challenge)
+	}
+	return base64.StdEncoding.EncodeToString(challenge[:128])
+}
+
+// scalarBaseMult returns the P-256 point of the scalar d, which must be
+// between 1 and N-1.
+func scalarBaseMult(d []byte) (x, y *big.Int) {
+	priv, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		panic(err) // callers check the scalar is in range
+	}
+	point := priv.PublicKey().Bytes()
+	return new(big.Int).SetBytes(point[1:33]), new(big.Int).SetBytes(point[33:])
+}
+
+// signDeterministic signs hash with P-256 and RFC 6979's deterministic ECDSA,
+// with SHA-256, returning r and s.
+func signDeterministic(priv *ecdsa.PrivateKey, hash []byte) (r, s *big.Int) {
+	n := priv.Curve.Params().N
+
+	x := priv.D.FillBytes(make([]byte, 32))
+	e := new(big.Int).SetBytes(hash)
+	m := new(big.Int).Mod(e, n).FillBytes(make([]byte, 32))
+
+	mac := func(key []byte, parts ...[]byte) []byte {
+		h := hmac.New(sha256.New, key)
+		for _, p := range parts {
+			h.Write(p)
+		}
+		return h.Sum(nil)
+	}
+
+	v := make([]byte, 32)
+	for i := range v {
+		v[i] = 1
+	}
+	k := make([]byte, 32)
+	k = mac(k, v, []byte{0}, x, m)
+	v = mac(k, v)
+	k = mac(k, v, []byte{1}, x, m)
+	v = mac(k, v)
+
+	for {
+		v = mac(k, v)
+		nonce := new(big.Int).SetBytes(v)
+		if nonce.Sign() > 0 && nonce.Cmp(n) < 0 {
+			rx, _ := scalarBaseMult(v)
+			r = rx.Mod(rx, n)
+			if r.Sign() != 0 {
+				s = new(big.Int).Mul(r, priv.D)
+				s.Add(s, e)
+				s.Mul(s, new(big.Int).ModInverse(nonce, n))
+				s.Mod(s, n)
+				if s.Sign() != 0 {
+					return r, s
+				}
+			}
+		}
+		k = mac(k, v, []byte{0})
+		v = mac(k, v)
+	}
+}
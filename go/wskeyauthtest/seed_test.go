@@ -0,0 +1,69 @@
+package wskeyauthtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) *big.Int {
+	t.Helper()
+	i, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		t.Fatalf("invalid hex %q", s)
+	}
+	return i
+}
+
+// TestSignDeterministic checks signDeterministic against the P-256, SHA-256
+// vectors of RFC 6979, Appendix A.2.5.
+func TestSignDeterministic(t *testing.T) {
+	priv := &ecdsa.PrivateKey{D: mustHex(t, "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721")}
+	priv.Curve = elliptic.P256()
+	priv.X, priv.Y = scalarBaseMult(priv.D.FillBytes(make([]byte, 32)))
+
+	if priv.X.Cmp(mustHex(t, "60FED4BA255A9D31C961EB74C6356D68C049B8923B61FA6CE669622E60F29FB6")) != 0 ||
+		priv.Y.Cmp(mustHex(t, "7903FE1008B8BC99A41AE9E95628BC64F2F1B20C2D7E9F5177A3C294D4462299")) != 0 {
+		t.Fatalf("public key = %X, %X", priv.X, priv.Y)
+	}
+
+	for _, v := range []struct {
+		message, r, s string
+	}{
+		{"sample",
+			"EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+			"F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8"},
+		{"test",
+			"F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+			"019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083"},
+	} {
+		hash := sha256.Sum256([]byte(v.message))
+		r, s := signDeterministic(priv, hash[:])
+		if r.Cmp(mustHex(t, v.r)) != 0 || s.Cmp(mustHex(t, v.s)) != 0 {
+			t.Errorf("signature of %q = %X, %X, want %s, %s", v.message, r, s, v.r, v.s)
+		}
+		if !ecdsa.Verify(&priv.PublicKey, hash[:], r, s) {
+			t.Errorf("signature of %q doesn't verify", v.message)
+		}
+	}
+}
+
+func TestKeyFromSeed(t *testing.T) {
+	k := KeyFromSeed("test")
+	if k.ClientID() != KeyFromSeed("test").ClientID() {
+		t.Fatal("KeyFromSeed() isn't stable")
+	}
+
+	sig, err := k.Sign([]byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := k.Sign([]byte("payload"))
+	hash := sha256.Sum256([]byte("payload"))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if string(sig) != string(again) || !ecdsa.Verify(&k.Private.PublicKey, hash[:], r, s) {
+		t.Fatal("signatures of seeded keys aren't stable, or don't verify")
+	}
+}
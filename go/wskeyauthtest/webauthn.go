@@ -0,0 +1,70 @@
+package wskeyauthtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Passkey has a Client play a WebAuthn credential, answering challenges
+// with assertions as an authenticator would, for servers that use
+// wskeyauth.WithWebAuthn.
+type Passkey struct {
+	// RPID and Origin are the relying party ID and origin that assertions
+	// are made for.
+	RPID   string
+	Origin string
+
+	// UserVerified sets the user verified flag in assertions, as if the user
+	// had entered a PIN or used biometrics.
+	UserVerified bool
+}
+
+// WebAuthnClientID returns the client ID of the key as a WebAuthn credential.
+func (k *Key) WebAuthnClientID() string {
+	return "WebAuthn-raw.EC.P-256$" + base64.StdEncoding.EncodeToString(k.rawPublicKey())
+}
+
+// SignAssertion signs the base64 challenge sent in a CHALLENGE message, for
+// audience, as passkey would, and returns the data of the CHALLENGE_RESPONSE
+// to send back.
+func (k *Key) SignAssertion(challenge, audience string, passkey Passkey) (map[string]string, error) {
+	payload, err := base64.StdEncoding.DecodeString(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataJSON, err := json.Marshal(map[string]any{
+		"type":        "webauthn.get",
+		"challenge":   base64.RawURLEncoding.EncodeToString(append(payload, audience...)),
+		"origin":      passkey.Origin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rpIDHash := sha256.Sum256([]byte(passkey.RPID))
+	flags := byte(0x01) // user present
+	if passkey.UserVerified {
+		flags |= 0x04
+	}
+	// the RP ID hash, flags, and a signature counter of 1
+	authenticatorData := append(rpIDHash[:], flags, 0, 0, 0, 1)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := sha256.Sum256(append(authenticatorData[:len(authenticatorData):len(authenticatorData)], clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, k.Private, signed[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"signature":         base64.StdEncoding.EncodeToString(sig),
+		"hash":              "SHA-256",
+		"authenticatorData": base64.StdEncoding.EncodeToString(authenticatorData),
+		"clientDataJSON":    base64.StdEncoding.EncodeToString(clientDataJSON),
+	}, nil
+}
@@ -0,0 +1,98 @@
+package wskeyauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Client IDs may carry an X.509 certificate, and the intermediates that lead
+// up to a trusted root:
+//
+//	X509$<base64 DER certificate>[,<base64 DER intermediate>...]
+//
+// The client is challenged to prove it holds the certificate's key, which
+// must be a P-256 or Ed25519 key. It signs as a WebCrypto or did:key client
+// would. The base64 variants accepted are those of WithBase64.
+
+const x509Prefix = "X509$"
+
+// X509 configures the server to accept certificate client IDs.
+type X509 struct {
+	// Roots are the CAs that client certificates must chain up to.
+	Roots *x509.CertPool
+
+	// CheckRevocation, if set, is called with the verified chain, from the
+	// client's certificate up to the root, and returns an error if any of
+	// them was revoked.
+	CheckRevocation func(chain []*x509.Certificate) error
+}
+
+// WithX509 accepts certificate client IDs, whose certificates must be valid
+// and chain up to a root in x.Roots for the client's key to be accepted.
+// Without it, certificate client IDs are rejected.
+//
+// Chains of several certificates can outgrow DefaultReadLimit; raise it with
+// WithReadLimit if need be.
+func WithX509(x X509) Option {
+	return func(cfg *config) {
+		cfg.x509 = &x
+	}
+}
+
+func parseX509ClientID(clientID string, accept Base64) (*publicKey, error) {
+	var certs []*x509.Certificate
+	for _, encoded := range strings.Split(strings.TrimPrefix(clientID, x509Prefix), ",") {
+		der, err := accept.decode(nil, encoded)
+		if err != nil {
+			return nil, errors.New("expected certificates of ID to be base64: " + err.Error())
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	pub := &publicKey{certificates: certs}
+	switch key := certs[0].PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return nil, errors.New("expected certificate of ID to have a P-256 or Ed25519 key")
+		}
+		pub.ecdsa = key
+	case ed25519.PublicKey:
+		pub.ed25519 = key
+	default:
+		return nil, errors.New("expected certificate of ID to have a P-256 or Ed25519 key")
+	}
+	return pub, nil
+}
+
+// verify checks that the certificates of pub are valid at now, chain up to
+// one of our roots, and aren't revoked.
+func (x *X509) verify(pub *publicKey, now time.Time) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range pub.certificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	chains, err := pub.certificates[0].Verify(x509.VerifyOptions{
+		Roots:         x.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return err
+	}
+
+	if x.CheckRevocation != nil {
+		return x.CheckRevocation(chains[0])
+	}
+	return nil
+}
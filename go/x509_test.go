@@ -0,0 +1,57 @@
+package wskeyauth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	wskeyauth "github.com/castcam-live/ws-key-auth/go"
+	"github.com/castcam-live/ws-key-auth/go/wskeyauthtest"
+)
+
+func TestX509Base64(t *testing.T) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ = x509.ParseCertificate(caDER)
+
+	device := wskeyauthtest.MustGenerateKey()
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "device"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, device.Private.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	hs := wskeyauth.NewServerHandshake(
+		wskeyauth.WithX509(wskeyauth.X509{Roots: roots}),
+		wskeyauth.WithBase64(wskeyauth.Base64Std|wskeyauth.Base64RawURL))
+	hs.Start()
+	out, _, _ := hs.Feed(marshal(t, map[string]any{"type": "CLIENT_ID", "data": "X509$" + base64.RawURLEncoding.EncodeToString(der)}))
+	if out, state, err := hs.Feed(respond(t, device, only(t, out))); state != wskeyauth.StateAuthenticated {
+		t.Fatalf("handshake with an unpadded URL-safe certificate = %s, %v, %v", out, state, err)
+	}
+}